@@ -24,6 +24,10 @@ func main() {
 		os.Exit(1)
 	}
 	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
 
 	// Track operation counts
 	opCounts := make(map[string]int)
@@ -88,7 +92,10 @@ func main() {
 		ops = append(ops, opCount{name, count})
 	}
 	sort.Slice(ops, func(i, j int) bool {
-		return ops[i].count > ops[j].count
+		if ops[i].count != ops[j].count {
+			return ops[i].count > ops[j].count
+		}
+		return ops[i].name < ops[j].name
 	})
 
 	fmt.Println(strings.Repeat("=", 80))
@@ -123,7 +130,10 @@ func main() {
 			dbs = append(dbs, dbCount{db, count})
 		}
 		sort.Slice(dbs, func(i, j int) bool {
-			return dbs[i].count > dbs[j].count
+			if dbs[i].count != dbs[j].count {
+				return dbs[i].count > dbs[j].count
+			}
+			return dbs[i].db < dbs[j].db
 		})
 
 		totalGetMore := 0
@@ -159,7 +169,10 @@ func main() {
 			colls = append(colls, collCount{coll, count})
 		}
 		sort.Slice(colls, func(i, j int) bool {
-			return colls[i].count > colls[j].count
+			if colls[i].count != colls[j].count {
+				return colls[i].count > colls[j].count
+			}
+			return colls[i].coll < colls[j].coll
 		})
 
 		totalGetMore := 0
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// maxCommentsListed caps the comments printed; the rest are summarized in one line
+const maxCommentsListed = 50
+
+// maxCommentWidth truncates long comments in the report
+const maxCommentWidth = 48
+
+// commentStats counts the top-level comment field of OP_MSG requests, so traffic can
+// be traced back to the application code paths that set identifying comments
+type commentStats struct {
+	// requests counts the OP_MSG requests examined; commented those with a string comment
+	requests  int
+	commented int
+
+	// nonString counts comments of another BSON type (allowed since 4.4), which aren't grouped
+	nonString int
+
+	// decodeErrors counts requests whose command document couldn't be decoded
+	decodeErrors int
+
+	// counts holds requests per comment; commands the requests per command name under each
+	counts   map[string]int
+	commands map[string]map[string]int
+}
+
+func newCommentStats() *commentStats {
+	return &commentStats{
+		counts:   make(map[string]int),
+		commands: make(map[string]map[string]int),
+	}
+}
+
+// add records the comment of one OP_MSG request for command cmdName
+func (c *commentStats) add(cmdName string, packet *reader.Packet) {
+	c.requests++
+
+	msg, err := packet.WireMessage()
+	if err != nil {
+		c.decodeErrors++
+		return
+	}
+	body, err := sender.DecodeBody(msg)
+	if err != nil {
+		c.decodeErrors++
+		return
+	}
+	value, err := bson.Raw(body.Document).LookupErr("comment")
+	if err != nil {
+		return
+	}
+	comment, ok := value.StringValueOK()
+	if !ok {
+		c.nonString++
+		return
+	}
+
+	c.commented++
+	c.counts[comment]++
+	byCommand := c.commands[comment]
+	if byCommand == nil {
+		byCommand = make(map[string]int)
+		c.commands[comment] = byCommand
+	}
+	byCommand[cmdName]++
+}
+
+// print lists comments by request count, each with the commands it appeared on
+func (c *commentStats) print() {
+	fmt.Printf("OP_MSG requests:     %d\n", c.requests)
+	fmt.Printf("With a comment:      %d (%.1f%%)\n", c.commented, countPercent(c.commented, c.requests))
+	if c.nonString > 0 {
+		fmt.Printf("Non-string comments: %d (not grouped)\n", c.nonString)
+	}
+	if c.decodeErrors > 0 {
+		fmt.Printf("Decode failures:     %d\n", c.decodeErrors)
+	}
+	if len(c.counts) == 0 {
+		fmt.Println("\n  (No requests with a string comment found)")
+		return
+	}
+
+	comments := sortedByCount(c.counts)
+	fmt.Printf("\nDistinct comments:   %d\n\n", len(comments))
+	for i, comment := range comments {
+		if i == maxCommentsListed {
+			fmt.Printf("  ... and %d more comments\n", len(comments)-maxCommentsListed)
+			break
+		}
+		count := c.counts[comment]
+		fmt.Printf("  %-50s: %6d (%5.1f%%)\n", quoteComment(comment), count, countPercent(count, c.commented))
+
+		var on []string
+		for _, name := range sortedByCount(c.commands[comment]) {
+			on = append(on, fmt.Sprintf("%s (%d)", name, c.commands[comment][name]))
+		}
+		fmt.Printf("    on: %s\n", strings.Join(on, ", "))
+	}
+}
+
+// sortedByCount returns the keys of counts, most frequent first (ties by name)
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// quoteComment quotes a comment for display, truncating it to maxCommentWidth runes
+func quoteComment(comment string) string {
+	runes := []rune(comment)
+	if len(runes) > maxCommentWidth {
+		return fmt.Sprintf("%q...", string(runes[:maxCommentWidth-3]))
+	}
+	return fmt.Sprintf("%q", comment)
+}
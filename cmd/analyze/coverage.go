@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// parseCoverage counts the packets the command distribution couldn't account for, so
+// a distribution built from a small share of the recording isn't taken at face value
+type parseCoverage struct {
+	// opMsg counts OP_MSG packets; noCommand those whose command name couldn't be
+	// extracted (too short, or not starting with a body section)
+	opMsg     int
+	noCommand int
+
+	// badBSON counts OP_MSG packets whose sections don't frame or whose documents
+	// aren't valid BSON; they may still have had a command name extracted
+	badBSON int
+
+	// otherOpCodes counts packets of every other opcode (legacy OP_QUERY/OP_REPLY,
+	// OP_COMPRESSED), which the command distribution leaves out
+	otherOpCodes int
+}
+
+// add counts one packet with a wire message; cmdName is the command extracted from it
+func (c *parseCoverage) add(packet *reader.Packet, opCode uint32, cmdName string) {
+	if opCode != 2013 {
+		c.otherOpCodes++
+		return
+	}
+
+	c.opMsg++
+	if cmdName == "" {
+		c.noCommand++
+	}
+	if !validOpMsg(packet.Message) {
+		c.badBSON++
+	}
+}
+
+// validOpMsg reports whether every document in an OP_MSG message is valid BSON
+func validOpMsg(msg []byte) bool {
+	_, sections, _, err := reader.SplitOpMsgSections(msg)
+	if err != nil {
+		return false
+	}
+	for _, section := range sections {
+		for _, doc := range section.Documents {
+			if bson.Raw(doc).Validate() != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// print writes the coverage counts and the share of OP_MSG packets with a command
+func (c *parseCoverage) print() {
+	fmt.Printf("OP_MSG packets:          %d\n", c.opMsg)
+	fmt.Printf("  No command name:       %d (%.1f%%)\n", c.noCommand, countPercent(c.noCommand, c.opMsg))
+	fmt.Printf("  BSON parse failures:   %d (%.1f%%)\n", c.badBSON, countPercent(c.badBSON, c.opMsg))
+	fmt.Printf("Non-OP_MSG packets:      %d (not in the command distribution)\n", c.otherOpCodes)
+
+	if c.opMsg == 0 {
+		fmt.Println("\nNo OP_MSG packets; the command distribution is empty")
+		return
+	}
+	extracted := c.opMsg - c.noCommand
+	fmt.Printf("\n%.1f%% of OP_MSG packets had an extractable command\n", countPercent(extracted, c.opMsg))
+}
+
+// countPercent returns n as a percentage of total (0 if total is 0)
+func countPercent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// driverStats counts the client metadata of recorded connection handshakes
+type driverStats struct {
+	// handshakes is the number of handshakes that carried client metadata
+	handshakes int
+
+	// sessions holds the sessions that sent at least one of them
+	sessions map[uint64]bool
+
+	// drivers counts handshakes by "name version"; platforms by OS and runtime
+	drivers   map[string]int
+	platforms map[string]int
+}
+
+func newDriverStats() *driverStats {
+	return &driverStats{
+		sessions:  make(map[uint64]bool),
+		drivers:   make(map[string]int),
+		platforms: make(map[string]int),
+	}
+}
+
+// add records one handshake's client metadata
+func (d *driverStats) add(sessionID uint64, info *sender.ClientInfo) {
+	d.handshakes++
+	d.sessions[sessionID] = true
+
+	driver := strings.TrimSpace(info.DriverName + " " + info.DriverVersion)
+	d.drivers[orDash(driver)]++
+
+	osName := info.OSType
+	if info.OSName != "" && !strings.EqualFold(info.OSName, info.OSType) {
+		osName = strings.TrimSpace(osName + " " + info.OSName)
+	}
+	if info.Architecture != "" {
+		osName = strings.TrimSpace(osName + " (" + info.Architecture + ")")
+	}
+	platform := orDash(osName) + " / " + orDash(info.Platform)
+	d.platforms[platform]++
+}
+
+// print reports handshake counts by driver and by platform
+func (d *driverStats) print(totalSessions int) {
+	if d.handshakes == 0 {
+		fmt.Println("  (No handshakes with client metadata found - the recording may have started")
+		fmt.Println("   after clients connected, or handshakes were filtered out)")
+		return
+	}
+
+	fmt.Printf("Handshakes: %d (from %d of %d sessions)\n", d.handshakes, len(d.sessions), totalSessions)
+
+	fmt.Println("\nBy driver:")
+	printCounts(d.drivers, d.handshakes)
+
+	fmt.Println("\nBy OS / platform:")
+	printCounts(d.platforms, d.handshakes)
+}
+
+// printCounts prints counts sorted by frequency, with their share of total
+func printCounts(counts map[string]int, total int) {
+	type stat struct {
+		name  string
+		count int
+	}
+	var stats []stat
+	for name, count := range counts {
+		stats = append(stats, stat{name, count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].count != stats[j].count {
+			return stats[i].count > stats[j].count
+		}
+		return stats[i].name < stats[j].name
+	})
+
+	for _, s := range stats {
+		pct := float64(s.count) / float64(total) * 100
+		fmt.Printf("  %-50s: %6d (%5.1f%%)\n", s.name, s.count, pct)
+	}
+}
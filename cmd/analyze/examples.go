@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// exampleSampler keeps a uniform random sample of k request documents per command
+// Reservoir sampling: the n-th occurrence of a command replaces a random kept example
+// with probability k/n, so every occurrence is equally likely to be kept. Documents are
+// only decoded when they enter the sample.
+type exampleSampler struct {
+	k      int
+	redact bool
+	rng    *rand.Rand
+
+	// seen counts occurrences per command; samples holds the rendered examples
+	seen    map[string]int
+	samples map[string][]string
+
+	// decodeErrors counts sampled packets whose document couldn't be decoded
+	decodeErrors int
+}
+
+func newExampleSampler(k int, redact bool) *exampleSampler {
+	return &exampleSampler{
+		k:       k,
+		redact:  redact,
+		rng:     rand.New(rand.NewSource(1)), // fixed seed: the same recording gives the same examples
+		seen:    make(map[string]int),
+		samples: make(map[string][]string),
+	}
+}
+
+// add offers one request for command cmdName to the sample
+func (e *exampleSampler) add(cmdName string, packet *reader.Packet) {
+	e.seen[cmdName]++
+	n := e.seen[cmdName]
+
+	slot := n - 1
+	if n > e.k {
+		slot = e.rng.Intn(n)
+		if slot >= e.k {
+			return
+		}
+	}
+
+	example, err := renderExample(packet, e.redact)
+	if err != nil {
+		e.decodeErrors++
+		return
+	}
+
+	kept := e.samples[cmdName]
+	if slot < len(kept) {
+		kept[slot] = example
+	} else {
+		e.samples[cmdName] = append(kept, example)
+	}
+}
+
+// print writes the examples grouped by command name
+func (e *exampleSampler) print() {
+	if len(e.samples) == 0 {
+		fmt.Println("  (No OP_MSG requests to sample)")
+		return
+	}
+
+	names := make([]string, 0, len(e.samples))
+	for name := range e.samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		examples := e.samples[name]
+		fmt.Printf("\n%s (%d of %d):\n", name, len(examples), e.seen[name])
+		for _, example := range examples {
+			fmt.Printf("  %s\n", example)
+		}
+	}
+
+	if e.decodeErrors > 0 {
+		fmt.Printf("\n%d sampled documents could not be decoded\n", e.decodeErrors)
+	}
+}
+
+// renderExample decodes a request's command document as extended JSON
+// Kind-1 document sequences are folded back into the command under their identifier.
+func renderExample(packet *reader.Packet, redact bool) (string, error) {
+	msg, err := packet.WireMessage()
+	if err != nil {
+		return "", err
+	}
+	body, err := sender.DecodeBody(msg)
+	if err != nil {
+		return "", err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(body.Document, &doc); err != nil {
+		return "", fmt.Errorf("failed to unmarshal command: %w", err)
+	}
+	for _, seq := range body.Sequences {
+		docs := make(bson.A, 0, len(seq.Documents))
+		for _, raw := range seq.Documents {
+			var d bson.D
+			if err := bson.Unmarshal(raw, &d); err != nil {
+				return "", fmt.Errorf("failed to unmarshal %s sequence: %w", seq.Identifier, err)
+			}
+			docs = append(docs, d)
+		}
+		doc = append(doc, bson.E{Key: seq.Identifier, Value: docs})
+	}
+
+	if redact {
+		doc = redactCommand(doc)
+	}
+
+	out, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to render command: %w", err)
+	}
+	return string(out), nil
+}
+
+// redactCommand replaces a command's values with type placeholders
+// The command name field (which holds the collection) and $db are kept.
+func redactCommand(doc bson.D) bson.D {
+	out := make(bson.D, len(doc))
+	for i, e := range doc {
+		if i == 0 || e.Key == "$db" {
+			out[i] = e
+			continue
+		}
+		out[i] = bson.E{Key: e.Key, Value: redactValue(e.Value)}
+	}
+	return out
+}
+
+// redactValue replaces scalars with a placeholder naming their type
+// Documents keep their keys; arrays keep only their first element and a count of the rest.
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(v))
+		for i, e := range v {
+			out[i] = bson.E{Key: e.Key, Value: redactValue(e.Value)}
+		}
+		return out
+	case bson.A:
+		if len(v) == 0 {
+			return v
+		}
+		out := bson.A{redactValue(v[0])}
+		if len(v) > 1 {
+			out = append(out, fmt.Sprintf("<%d more>", len(v)-1))
+		}
+		return out
+	case nil:
+		return nil
+	default:
+		return fmt.Sprintf("<%T>", v)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// inventoryRequest is a listCollections or listIndexes request whose responses are parsed
+type inventoryRequest struct {
+	command    string
+	database   string
+	collection string // listIndexes only
+}
+
+// collectionInfo is a collection (or view) seen in a listCollections response
+type collectionInfo struct {
+	// kind is the listed type: collection, view or timeseries (empty if not listed)
+	kind string
+
+	// details summarizes the collection options (e.g. "capped", "view on users")
+	details []string
+
+	// indexes are the indexes from the latest listIndexes of the collection, by name
+	indexes map[string]indexInfo
+}
+
+// indexInfo is an index seen in a listIndexes response
+type indexInfo struct {
+	key     string
+	details []string
+}
+
+// cursorResponse is the part of a cursor-returning response the inventory reads
+type cursorResponse struct {
+	Cursor struct {
+		ID         int64      `bson:"id"`
+		FirstBatch []bson.Raw `bson:"firstBatch"`
+		NextBatch  []bson.Raw `bson:"nextBatch"`
+	} `bson:"cursor"`
+}
+
+// inventory recovers the collections and indexes of the recorded system from the
+// responses to listCollections and listIndexes, following their cursors through getMore
+type inventory struct {
+	pending map[reader.RequestKey]inventoryRequest
+	cursors map[int64]inventoryRequest
+
+	// databases maps each database to its collections by name
+	databases map[string]map[string]*collectionInfo
+
+	// responses counts the parsed responses by command
+	responses map[string]int
+}
+
+func newInventory() *inventory {
+	return &inventory{
+		pending:   make(map[reader.RequestKey]inventoryRequest),
+		cursors:   make(map[int64]inventoryRequest),
+		databases: make(map[string]map[string]*collectionInfo),
+		responses: make(map[string]int),
+	}
+}
+
+// add notes an inventory request, or parses the response to one
+func (inv *inventory) add(packet *reader.Packet) {
+	key, ok := packet.PairingKey()
+	if !ok {
+		return
+	}
+
+	if packet.IsRequest() {
+		inv.addRequest(packet, key)
+		return
+	}
+
+	req, ok := inv.pending[key]
+	if !ok {
+		return
+	}
+	delete(inv.pending, key)
+
+	message, err := packet.WireMessage()
+	if err != nil {
+		return
+	}
+	body, err := sender.DecodeBody(message)
+	if err != nil {
+		return
+	}
+	var resp cursorResponse
+	if err := bson.Unmarshal(body.Document, &resp); err != nil {
+		return
+	}
+	inv.responses[req.command]++
+
+	first := resp.Cursor.FirstBatch != nil
+	batch := resp.Cursor.FirstBatch
+	if !first {
+		batch = resp.Cursor.NextBatch
+	}
+	if resp.Cursor.ID != 0 {
+		inv.cursors[resp.Cursor.ID] = req
+	}
+
+	switch req.command {
+	case "listCollections":
+		for _, doc := range batch {
+			inv.addCollection(req.database, doc)
+		}
+	case "listIndexes":
+		coll := inv.collection(req.database, req.collection)
+		if first {
+			// A new listing replaces the indexes from an earlier one
+			coll.indexes = make(map[string]indexInfo)
+		}
+		for _, doc := range batch {
+			addIndex(coll, doc)
+		}
+	}
+}
+
+// addRequest notes a listCollections/listIndexes request, or a getMore on one's cursor
+func (inv *inventory) addRequest(packet *reader.Packet, key reader.RequestKey) {
+	name := packet.ExtractCommandName()
+	if name != "listCollections" && name != "listIndexes" && name != "getMore" {
+		return
+	}
+	cmd, err := sender.ExtractCommand(packet)
+	if err != nil {
+		return
+	}
+
+	switch name {
+	case "getMore":
+		id, ok := cmd.Document["getMore"].(int64)
+		if !ok {
+			return
+		}
+		if req, ok := inv.cursors[id]; ok {
+			delete(inv.cursors, id)
+			inv.pending[key] = req
+		}
+	case "listIndexes":
+		coll, _ := cmd.Document["listIndexes"].(string)
+		if coll != "" {
+			inv.pending[key] = inventoryRequest{command: name, database: cmd.Database, collection: coll}
+		}
+	default:
+		inv.pending[key] = inventoryRequest{command: name, database: cmd.Database}
+	}
+}
+
+// collection returns a collection's entry, creating it on first use
+func (inv *inventory) collection(database, name string) *collectionInfo {
+	colls, ok := inv.databases[database]
+	if !ok {
+		colls = make(map[string]*collectionInfo)
+		inv.databases[database] = colls
+	}
+	coll, ok := colls[name]
+	if !ok {
+		coll = &collectionInfo{}
+		colls[name] = coll
+	}
+	return coll
+}
+
+// addCollection records one document of a listCollections batch
+func (inv *inventory) addCollection(database string, doc bson.Raw) {
+	var listed struct {
+		Name    string `bson:"name"`
+		Type    string `bson:"type"`
+		Options bson.M `bson:"options"`
+	}
+	if err := bson.Unmarshal(doc, &listed); err != nil || listed.Name == "" {
+		return
+	}
+
+	coll := inv.collection(database, listed.Name)
+	coll.kind = listed.Type
+	coll.details = nil
+	if viewOn, ok := listed.Options["viewOn"].(string); ok {
+		coll.details = append(coll.details, "view on "+viewOn)
+	}
+	if capped, _ := listed.Options["capped"].(bool); capped {
+		coll.details = append(coll.details, fmt.Sprintf("capped (size %v)", listed.Options["size"]))
+	}
+	for _, option := range []string{"validator", "timeseries", "clusteredIndex", "collation", "changeStreamPreAndPostImages"} {
+		if _, ok := listed.Options[option]; ok {
+			coll.details = append(coll.details, option)
+		}
+	}
+}
+
+// addIndex records one document of a listIndexes batch
+func addIndex(coll *collectionInfo, doc bson.Raw) {
+	var listed struct {
+		Name    string `bson:"name"`
+		Key     bson.D `bson:"key"`
+		Options bson.M `bson:",inline"`
+	}
+	if err := bson.Unmarshal(doc, &listed); err != nil || listed.Name == "" {
+		return
+	}
+
+	index := indexInfo{key: formatIndexKey(listed.Key)}
+	for _, option := range []string{"unique", "sparse", "hidden"} {
+		if set, _ := listed.Options[option].(bool); set {
+			index.details = append(index.details, option)
+		}
+	}
+	if ttl, ok := listed.Options["expireAfterSeconds"]; ok {
+		index.details = append(index.details, fmt.Sprintf("ttl %vs", ttl))
+	}
+	for _, option := range []string{"partialFilterExpression", "collation", "wildcardProjection"} {
+		if _, ok := listed.Options[option]; ok {
+			index.details = append(index.details, option)
+		}
+	}
+	coll.indexes[listed.Name] = index
+}
+
+// formatIndexKey renders an index key pattern in shell style, e.g. { a: 1, b: "text" }
+func formatIndexKey(key bson.D) string {
+	fields := make([]string, len(key))
+	for i, e := range key {
+		if s, ok := e.Value.(string); ok {
+			fields[i] = fmt.Sprintf("%s: %q", e.Key, s)
+		} else {
+			fields[i] = fmt.Sprintf("%s: %v", e.Key, e.Value)
+		}
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// print lists the recovered collections and their indexes by database
+func (inv *inventory) print() {
+	if len(inv.responses) == 0 {
+		fmt.Println("  (No listCollections/listIndexes responses found - the recording may contain requests")
+		fmt.Println("   only, or clients did not list collections or indexes during the capture)")
+		return
+	}
+
+	fmt.Printf("Parsed %d listCollections and %d listIndexes responses\n",
+		inv.responses["listCollections"], inv.responses["listIndexes"])
+
+	for _, database := range sortedKeys(inv.databases) {
+		colls := inv.databases[database]
+		fmt.Printf("\n%s (%d collections)\n", database, len(colls))
+		for _, name := range sortedKeys(colls) {
+			coll := colls[name]
+			printTrimmed("  %-40s  %-12s  %s", name, orDash(coll.kind), strings.Join(coll.details, ", "))
+			if coll.indexes == nil {
+				continue
+			}
+			for _, indexName := range sortedKeys(coll.indexes) {
+				index := coll.indexes[indexName]
+				printTrimmed("    %-38s  %s  %s", indexName, index.key, strings.Join(index.details, ", "))
+			}
+		}
+	}
+}
+
+// printTrimmed prints a formatted line without the padding left by empty trailing columns
+func printTrimmed(format string, args ...interface{}) {
+	fmt.Println(strings.TrimRight(fmt.Sprintf(format, args...), " "))
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
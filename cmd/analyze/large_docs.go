@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// maxBSONSize is MongoDB's maximum BSON document size (16 MiB)
+const maxBSONSize = 16 << 20
+
+// defaultLargeDocThreshold is the --large-docs threshold unless --large-doc-mb is given
+const defaultLargeDocThreshold = 8 << 20
+
+// largeDocsListed is how many documents over the threshold are listed individually
+const largeDocsListed = 50
+
+// largeDoc is a request document over the threshold
+type largeDoc struct {
+	command   string
+	namespace string
+
+	// location is where the document sits in the message: "body" or "identifier[i]"
+	location string
+
+	size      int
+	sessionID uint64
+	offset    uint64
+}
+
+// largestDoc is the largest request document seen for one command
+type largestDoc struct {
+	size      int
+	namespace string
+
+	// over counts this command's documents over the threshold
+	over int
+}
+
+// largeDocs finds the largest BSON document of each command, walking the kind-0 body
+// and every kind-1 document sequence of OP_MSG requests (decompressing OP_COMPRESSED)
+type largeDocs struct {
+	threshold int
+
+	// largest is the largest document by command
+	largest map[string]*largestDoc
+
+	// over lists the documents over the threshold in recording order; overCount
+	// counts all of them, including those not kept
+	over      []largeDoc
+	overCount int
+
+	// scanned counts the requests whose sections were walked
+	scanned int
+}
+
+func newLargeDocs(threshold int) *largeDocs {
+	return &largeDocs{threshold: threshold, largest: make(map[string]*largestDoc)}
+}
+
+// add walks the sections of a request
+func (l *largeDocs) add(packet *reader.Packet) {
+	if !packet.IsRequest() {
+		return
+	}
+	message, err := packet.WireMessage()
+	if err != nil {
+		return
+	}
+	body, err := sender.DecodeBody(message)
+	if err != nil {
+		return
+	}
+	l.scanned++
+
+	command := packet.ExtractCommandName()
+	ns, _ := requestNamespace(packet)
+	l.note(packet, command, ns, "body", len(body.Document))
+	for _, seq := range body.Sequences {
+		for i, doc := range seq.Documents {
+			l.note(packet, command, ns, fmt.Sprintf("%s[%d]", seq.Identifier, i), len(doc))
+		}
+	}
+}
+
+// note records one document's size
+func (l *largeDocs) note(packet *reader.Packet, command, ns, location string, size int) {
+	largest, ok := l.largest[command]
+	if !ok {
+		largest = &largestDoc{}
+		l.largest[command] = largest
+	}
+	if size > largest.size {
+		largest.size = size
+		largest.namespace = ns
+	}
+	if size < l.threshold {
+		return
+	}
+	largest.over++
+	l.overCount++
+	if len(l.over) < largeDocsListed {
+		l.over = append(l.over, largeDoc{
+			command:   command,
+			namespace: ns,
+			location:  location,
+			size:      size,
+			sessionID: packet.SessionID,
+			offset:    packet.Offset,
+		})
+	}
+}
+
+// print lists the largest document per command and every document over the threshold
+func (l *largeDocs) print() {
+	if l.scanned == 0 {
+		fmt.Println("  (No OP_MSG requests found)")
+		return
+	}
+
+	commands := sortedKeys(l.largest)
+	sort.SliceStable(commands, func(i, j int) bool {
+		return l.largest[commands[i]].size > l.largest[commands[j]].size
+	})
+
+	fmt.Printf("Walked %d requests; threshold %s (%.0f%% of the %s BSON limit)\n\n",
+		l.scanned, formatBytes(uint64(l.threshold)), 100*float64(l.threshold)/maxBSONSize, formatBytes(maxBSONSize))
+	fmt.Printf("  %-20s  %12s  %7s  %8s  %s\n", "COMMAND", "LARGEST", "LIMIT", "OVER", "NAMESPACE")
+	for _, command := range commands {
+		largest := l.largest[command]
+		fmt.Printf("  %-20s  %12s  %6.1f%%  %8d  %s\n", orDash(command), formatBytes(uint64(largest.size)),
+			100*float64(largest.size)/maxBSONSize, largest.over, orDash(largest.namespace))
+	}
+
+	if l.overCount == 0 {
+		fmt.Printf("\n✓ No documents of %s or more\n", formatBytes(uint64(l.threshold)))
+		return
+	}
+	fmt.Printf("\n⚠️  %d documents of %s or more:\n\n", l.overCount, formatBytes(uint64(l.threshold)))
+	fmt.Printf("  %12s  %-20s  %-40s  %-16s  %10s  %s\n", "SIZE", "COMMAND", "NAMESPACE", "SECTION", "SESSION", "OFFSET")
+	for _, doc := range l.over {
+		fmt.Printf("  %12s  %-20s  %-40s  %-16s  %10d  %s\n", formatBytes(uint64(doc.size)), orDash(doc.command),
+			orDash(doc.namespace), doc.location, doc.sessionID, reader.FormatOffset(doc.offset))
+	}
+	if l.overCount > len(l.over) {
+		fmt.Printf("  ... and %d more\n", l.overCount-len(l.over))
+	}
+}
@@ -6,21 +6,111 @@ import (
 	"os"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
 )
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> [--sessions-full] [--redact-metadata] [--follow-renames] [--drivers] [--by-comment] [--examples K [--redact]] [--slowest N] [--inventory] [--large-docs [--large-doc-mb N]] [--system-only | --include-system]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nAnalyzes a MongoDB traffic recording file and provides detailed statistics.\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fmt.Fprintf(os.Stderr, "  --sessions-full   Print every session's parsed metadata (remote, local, appName, driver)\n")
+		fmt.Fprintf(os.Stderr, "  --redact-metadata Mask client/server addresses in session metadata (10.0.0.5:51807 -> 10.0.0.x:xxxxx)\n")
+		fmt.Fprintf(os.Stderr, "  --follow-renames  Attribute requests before and after renameCollection to the collection's final name\n")
+		fmt.Fprintf(os.Stderr, "  --drivers         Summarize client drivers and platforms from connection handshakes\n")
+		fmt.Fprintf(os.Stderr, "  --by-comment      Group requests by their top-level string comment, with the commands\n")
+		fmt.Fprintf(os.Stderr, "                    each comment appears on\n")
+		fmt.Fprintf(os.Stderr, "  --examples K      Print K randomly sampled request documents per command\n")
+		fmt.Fprintf(os.Stderr, "  --redact          With --examples, replace values with their type and trim arrays\n")
+		fmt.Fprintf(os.Stderr, "  --slowest N       List the N requests with the longest recorded request-to-response time\n")
+		fmt.Fprintf(os.Stderr, "  --inventory       List the collections and indexes of the recorded system, recovered from\n")
+		fmt.Fprintf(os.Stderr, "                    listCollections/listIndexes responses (and getMores on their cursors)\n")
+		fmt.Fprintf(os.Stderr, "  --large-docs      Report the largest BSON document per command (body or document sequence)\n")
+		fmt.Fprintf(os.Stderr, "                    and every document near the 16MB limit\n")
+		fmt.Fprintf(os.Stderr, "  --large-doc-mb N  With --large-docs, report documents of N MB or more (default: 8)\n")
+		fmt.Fprintf(os.Stderr, "  --system-only     Analyze only traffic on internal namespaces (local, admin, config, system.*)\n")
+		fmt.Fprintf(os.Stderr, "                    and the responses to it, e.g. to debug replication or sharding\n")
+		fmt.Fprintf(os.Stderr, "  --include-system  Analyze internal namespaces along with user traffic (the default)\n")
 		os.Exit(1)
 	}
 
 	filePath := os.Args[1]
+	sessionsFull := false
+	redactMetadata := false
+	followRenames := false
+	drivers := false
+	byComment := false
+	examples := 0
+	redact := false
+	slowest := 0
+	inventoryList := false
+	largeDocsReport := false
+	largeDocThreshold := defaultLargeDocThreshold
+	systemOnly := false
+	includeSystem := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--sessions-full":
+			sessionsFull = true
+		case "--redact-metadata":
+			redactMetadata = true
+		case "--follow-renames":
+			followRenames = true
+		case "--drivers":
+			drivers = true
+		case "--by-comment":
+			byComment = true
+		case "--examples":
+			if i+1 < len(os.Args) {
+				if _, err := fmt.Sscanf(os.Args[i+1], "%d", &examples); err != nil || examples < 1 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --examples '%s'. Expected a positive count\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				i++
+			}
+		case "--redact":
+			redact = true
+		case "--inventory":
+			inventoryList = true
+		case "--large-docs":
+			largeDocsReport = true
+		case "--large-doc-mb":
+			if i+1 < len(os.Args) {
+				var mb float64
+				if _, err := fmt.Sscanf(os.Args[i+1], "%g", &mb); err != nil || mb <= 0 || mb > 16 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --large-doc-mb '%s'. Expected a size between 0 and 16\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				largeDocThreshold = int(mb * (1 << 20))
+				i++
+			}
+		case "--system-only":
+			systemOnly = true
+		case "--include-system":
+			includeSystem = true
+		case "--slowest":
+			if i+1 < len(os.Args) {
+				if _, err := fmt.Sscanf(os.Args[i+1], "%d", &slowest); err != nil || slowest < 1 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --slowest '%s'. Expected a positive count\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				i++
+			}
+		}
+	}
+
+	if systemOnly && includeSystem {
+		fmt.Fprintf(os.Stderr, "Error: --system-only and --include-system are mutually exclusive\n")
+		os.Exit(1)
+	}
 
 	fmt.Printf("Analyzing recording: %s\n", filePath)
+	if systemOnly {
+		fmt.Println("Filter: internal namespaces only (local, admin, config, system.*)")
+	}
 	fmt.Println(strings.Repeat("=", 80))
 
 	// Open recording
@@ -30,12 +120,42 @@ func main() {
 		os.Exit(1)
 	}
 	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
 
 	// Collect statistics
 	stats := &Statistics{
 		sessions:       make(map[uint64]*SessionStats),
 		opCodes:        make(map[uint32]int),
 		commandCounts:  make(map[string]int),
+		opCodeBytes:    make(map[uint32]uint64),
+		commandBytes:   make(map[string]uint64),
+		followRenames:  followRenames,
+	}
+	if drivers {
+		stats.drivers = newDriverStats()
+	}
+	if byComment {
+		stats.comments = newCommentStats()
+	}
+	if examples > 0 {
+		stats.examples = newExampleSampler(examples, redact)
+	}
+	if slowest > 0 {
+		stats.slowest = newSlowestOps(slowest)
+	}
+	if inventoryList {
+		stats.inventory = newInventory()
+	}
+	if largeDocsReport {
+		stats.largeDocs = newLargeDocs(largeDocThreshold)
+	}
+
+	var filter *systemFilter
+	if systemOnly {
+		filter = newSystemFilter()
 	}
 
 	packetNum := 0
@@ -50,11 +170,55 @@ func main() {
 		}
 
 		packetNum++
+		if redactMetadata {
+			packet.SessionMetadata = reader.RedactMetadata(packet.SessionMetadata)
+		}
+		if filter != nil && !filter.keep(packet) {
+			continue
+		}
 		stats.analyze(packet)
 	}
+	if filter != nil {
+		fmt.Printf("Skipped %d packets outside internal namespaces\n", filter.skipped)
+	}
 
 	// Print results
 	stats.print()
+
+	if sessionsFull {
+		fmt.Println("\n=== SESSION METADATA ===")
+		printSessionMetadata(stats.sessions)
+	}
+
+	if drivers {
+		fmt.Println("\n=== CLIENT DRIVERS ===")
+		stats.drivers.print(len(stats.sessions))
+	}
+
+	if byComment {
+		fmt.Println("\n=== COMMENTS ===")
+		stats.comments.print()
+	}
+
+	if examples > 0 {
+		fmt.Println("\n=== EXAMPLE DOCUMENTS ===")
+		stats.examples.print()
+	}
+
+	if slowest > 0 {
+		fmt.Println("\n=== SLOWEST RECORDED OPERATIONS ===")
+		stats.slowest.print()
+	}
+
+	if inventoryList {
+		fmt.Println("\n=== COLLECTION INVENTORY ===")
+		stats.inventory.print()
+	}
+
+	if largeDocsReport {
+		fmt.Println("\n=== LARGE DOCUMENTS ===")
+		stats.largeDocs.print()
+	}
 }
 
 type Statistics struct {
@@ -68,6 +232,35 @@ type Statistics struct {
 	opCodes        map[uint32]int
 	commandCounts  map[string]int
 
+	// opCodeBytes and commandBytes sum packet sizes per opcode/command
+	opCodeBytes    map[uint32]uint64
+	commandBytes   map[string]uint64
+
+	// nsEvents lists every collection-level request in order (see canonicalNamespaceCounts)
+	nsEvents       []namespaceEvent
+	followRenames  bool
+
+	// drivers summarizes handshake client metadata (nil unless --drivers)
+	drivers        *driverStats
+
+	// comments groups requests by their comment field (nil unless --by-comment)
+	comments       *commentStats
+
+	// examples samples request documents per command (nil unless --examples)
+	examples       *exampleSampler
+
+	// slowest pairs requests with responses to find the slowest (nil unless --slowest)
+	slowest        *slowestOps
+
+	// inventory recovers collections and indexes from list responses (nil unless --inventory)
+	inventory      *inventory
+
+	// largeDocs finds the largest request documents (nil unless --large-docs)
+	largeDocs      *largeDocs
+
+	// coverage counts the packets the command distribution couldn't parse
+	coverage       parseCoverage
+
 	firstOffset    uint64
 	lastOffset     uint64
 }
@@ -113,10 +306,29 @@ func (s *Statistics) analyze(packet *reader.Packet) {
 		return
 	}
 
+	if s.slowest != nil {
+		s.slowest.add(packet)
+	}
+	if s.inventory != nil {
+		s.inventory.add(packet)
+	}
+	if s.largeDocs != nil {
+		s.largeDocs.add(packet)
+	}
+
 	// Request vs response
 	if packet.IsRequest() {
 		s.requests++
 		session.requestCount++
+
+		if ns, renameTo := requestNamespace(packet); ns != "" {
+			s.nsEvents = append(s.nsEvents, namespaceEvent{ns: ns, renameTo: renameTo})
+		}
+		if s.drivers != nil {
+			if info, ok := sender.HandshakeClient(packet); ok {
+				s.drivers.add(packet.SessionID, info)
+			}
+		}
 	} else {
 		s.responses++
 		session.responseCount++
@@ -125,13 +337,23 @@ func (s *Statistics) analyze(packet *reader.Packet) {
 	// OpCode
 	opCode := packet.GetOpCode()
 	s.opCodes[opCode]++
+	s.opCodeBytes[opCode] += uint64(packet.Size)
 
 	// Try to extract command name from OP_MSG messages
+	cmdName := ""
 	if opCode == 2013 && len(packet.Message) > 20 {
-		if cmdName := extractCommandName(packet.Message); cmdName != "" {
+		if cmdName = extractCommandName(packet.Message); cmdName != "" {
 			s.commandCounts[cmdName]++
+			s.commandBytes[cmdName] += uint64(packet.Size)
+			if s.examples != nil && packet.IsRequest() {
+				s.examples.add(cmdName, packet)
+			}
+			if s.comments != nil && packet.IsRequest() {
+				s.comments.add(cmdName, packet)
+			}
 		}
 	}
+	s.coverage.add(packet, opCode, cmdName)
 }
 
 func (s *Statistics) print() {
@@ -142,49 +364,69 @@ func (s *Statistics) print() {
 	fmt.Printf("Responses:        %d\n", s.responses)
 	fmt.Printf("Empty messages:   %d\n", s.emptyMessages)
 
-	duration := time.Duration(s.lastOffset-s.firstOffset) * time.Microsecond
-	fmt.Printf("\nRecording duration: %v\n", duration)
-	fmt.Printf("First packet offset: %d μs\n", s.firstOffset)
-	fmt.Printf("Last packet offset:  %d μs\n", s.lastOffset)
+	fmt.Printf("\nRecording duration: %s\n", reader.FormatOffset(s.lastOffset-s.firstOffset))
+	fmt.Printf("First packet offset: %s (%d μs)\n", reader.FormatOffset(s.firstOffset), s.firstOffset)
+	fmt.Printf("Last packet offset:  %s (%d μs)\n", reader.FormatOffset(s.lastOffset), s.lastOffset)
 
 	fmt.Println("\n=== OPCODE DISTRIBUTION ===")
-	printOpCodeStats(s.opCodes)
+	printOpCodeStats(s.opCodes, s.opCodeBytes)
 
 	fmt.Println("\n=== COMMAND DISTRIBUTION (OP_MSG only) ===")
-	printCommandStats(s.commandCounts)
+	printCommandStats(s.commandCounts, s.commandBytes)
+
+	fmt.Println("\n=== PARSE COVERAGE ===")
+	s.coverage.print()
+
+	if s.followRenames {
+		fmt.Println("\n=== COLLECTION ACTIVITY (renames followed) ===")
+		printNamespaceStats(canonicalNamespaceCounts(s.nsEvents))
+	} else {
+		fmt.Println("\n=== COLLECTION ACTIVITY ===")
+		counts := make(map[string]int)
+		for _, event := range s.nsEvents {
+			counts[event.ns]++
+		}
+		printNamespaceStats(counts, nil)
+	}
 
 	fmt.Println("\n=== SESSION STATISTICS ===")
 	fmt.Printf("Total sessions: %d\n", len(s.sessions))
 	printSessionStats(s.sessions)
 }
 
-func printOpCodeStats(opCodes map[uint32]int) {
+func printOpCodeStats(opCodes map[uint32]int, opCodeBytes map[uint32]uint64) {
 	type opStat struct {
 		code  uint32
 		name  string
 		count int
+		bytes uint64
 	}
 
 	var stats []opStat
 	total := 0
+	var totalBytes uint64
 	for code, count := range opCodes {
 		name := getOpCodeName(code)
-		stats = append(stats, opStat{code, name, count})
+		stats = append(stats, opStat{code, name, count, opCodeBytes[code]})
 		total += count
+		totalBytes += opCodeBytes[code]
 	}
 
 	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].count > stats[j].count
+		if stats[i].count != stats[j].count {
+			return stats[i].count > stats[j].count
+		}
+		return stats[i].name < stats[j].name
 	})
 
 	for _, stat := range stats {
 		pct := float64(stat.count) / float64(total) * 100
-		fmt.Printf("  %-25s (%4d): %6d packets (%5.1f%%)\n",
-			stat.name, stat.code, stat.count, pct)
+		fmt.Printf("  %-25s (%4d): %6d packets (%5.1f%%)  %10s (%5.1f%%)\n",
+			stat.name, stat.code, stat.count, pct, formatBytes(stat.bytes), bytePercent(stat.bytes, totalBytes))
 	}
 }
 
-func printCommandStats(commands map[string]int) {
+func printCommandStats(commands map[string]int, commandBytes map[string]uint64) {
 	if len(commands) == 0 {
 		fmt.Println("  (No commands extracted)")
 		return
@@ -193,25 +435,40 @@ func printCommandStats(commands map[string]int) {
 	type cmdStat struct {
 		name  string
 		count int
+		bytes uint64
 	}
 
 	var stats []cmdStat
 	total := 0
+	var totalBytes uint64
 	for name, count := range commands {
-		stats = append(stats, cmdStat{name, count})
+		stats = append(stats, cmdStat{name, count, commandBytes[name]})
 		total += count
+		totalBytes += commandBytes[name]
 	}
 
 	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].count > stats[j].count
+		if stats[i].count != stats[j].count {
+			return stats[i].count > stats[j].count
+		}
+		return stats[i].name < stats[j].name
 	})
 
 	for _, stat := range stats {
 		pct := float64(stat.count) / float64(total) * 100
-		fmt.Printf("  %-30s: %6d (%5.1f%%)\n", stat.name, stat.count, pct)
+		fmt.Printf("  %-30s: %6d (%5.1f%%)  %10s (%5.1f%%)\n",
+			stat.name, stat.count, pct, formatBytes(stat.bytes), bytePercent(stat.bytes, totalBytes))
 	}
 }
 
+// bytePercent returns bytes as a percentage of total (0 if total is 0)
+func bytePercent(bytes, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(bytes) / float64(total) * 100
+}
+
 func printSessionStats(sessions map[uint64]*SessionStats) {
 	type sessStat struct {
 		id       uint64
@@ -220,12 +477,12 @@ func printSessionStats(sessions map[uint64]*SessionStats) {
 		requests int
 		responses int
 		bytes    uint64
-		duration time.Duration
+		duration uint64 // microseconds from the session's first to last packet
 	}
 
 	var stats []sessStat
 	for _, s := range sessions {
-		duration := time.Duration(s.lastSeen-s.firstSeen) * time.Microsecond
+		duration := s.lastSeen - s.firstSeen
 		stats = append(stats, sessStat{
 			id:        s.sessionID,
 			metadata:  s.metadata,
@@ -238,13 +495,16 @@ func printSessionStats(sessions map[uint64]*SessionStats) {
 	}
 
 	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].packets > stats[j].packets
+		if stats[i].packets != stats[j].packets {
+			return stats[i].packets > stats[j].packets
+		}
+		return stats[i].id < stats[j].id
 	})
 
 	fmt.Println()
-	fmt.Printf("%-10s %-45s %8s %8s %8s %10s\n",
-		"Session", "Metadata", "Packets", "Req", "Resp", "Bytes")
-	fmt.Println(strings.Repeat("-", 100))
+	fmt.Printf("%-10s %-45s %8s %8s %8s %10s %12s\n",
+		"Session", "Metadata", "Packets", "Req", "Resp", "Bytes", "Duration")
+	fmt.Println(strings.Repeat("-", 113))
 
 	for i, s := range stats {
 		if i >= 20 {
@@ -257,9 +517,96 @@ func printSessionStats(sessions map[uint64]*SessionStats) {
 			metadata = metadata[:40] + "..."
 		}
 
-		fmt.Printf("%-10d %-45s %8d %8d %8d %10s\n",
-			s.id, metadata, s.packets, s.requests, s.responses, formatBytes(s.bytes))
+		fmt.Printf("%-10d %-45s %8d %8d %8d %10s %12s\n",
+			s.id, metadata, s.packets, s.requests, s.responses, formatBytes(s.bytes), reader.FormatOffset(s.duration))
+	}
+}
+
+// printSessionMetadata prints every session's parsed metadata, followed by
+// session counts grouped by client host and application
+func printSessionMetadata(sessions map[uint64]*SessionStats) {
+	ids := make([]uint64, 0, len(sessions))
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	type clientKey struct {
+		host    string
+		appName string
+		driver  string
+	}
+	clientCounts := make(map[clientKey]int)
+	parseErrors := 0
+
+	fmt.Println()
+	fmt.Printf("%-10s %-22s %-22s %-20s %-20s %8s\n",
+		"Session", "Remote", "Local", "AppName", "Driver", "Packets")
+	fmt.Println(strings.Repeat("-", 107))
+
+	for _, id := range ids {
+		s := sessions[id]
+		meta, err := reader.ParseSessionMetadata(s.metadata)
+		if err != nil {
+			parseErrors++
+			fmt.Printf("%-10d %-22s %-22s %-20s %-20s %8d\n",
+				id, "(unparseable)", "", "", "", s.packetCount)
+			continue
+		}
+
+		fmt.Printf("%-10d %-22s %-22s %-20s %-20s %8d\n",
+			id, orDash(meta.Remote), orDash(meta.Local), orDash(meta.AppName), orDash(meta.Driver()), s.packetCount)
+
+		host := meta.Remote
+		if idx := strings.LastIndex(host, ":"); idx >= 0 {
+			host = host[:idx]
+		}
+		clientCounts[clientKey{host, meta.AppName, meta.Driver()}]++
+	}
+
+	if parseErrors > 0 {
+		fmt.Printf("\n%d sessions had metadata that could not be parsed\n", parseErrors)
+	}
+
+	type clientStat struct {
+		key   clientKey
+		count int
+	}
+	var clients []clientStat
+	for key, count := range clientCounts {
+		clients = append(clients, clientStat{key, count})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].count != clients[j].count {
+			return clients[i].count > clients[j].count
+		}
+		a, b := clients[i].key, clients[j].key
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.appName != b.appName {
+			return a.appName < b.appName
+		}
+		return a.driver < b.driver
+	})
+
+	fmt.Println("\nSessions by client:")
+	fmt.Printf("%-22s %-20s %-20s %8s\n", "Host", "AppName", "Driver", "Sessions")
+	fmt.Println(strings.Repeat("-", 73))
+	for _, c := range clients {
+		fmt.Printf("%-22s %-20s %-20s %8d\n",
+			orDash(c.key.host), orDash(c.key.appName), orDash(c.key.driver), c.count)
+	}
+}
+
+// orDash returns "-" for empty strings so table columns stay aligned
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
 
 func getOpCodeName(code uint32) string {
@@ -291,12 +638,12 @@ func getOpCodeName(code uint32) string {
 // OP_MSG format: flags(4) + sections...
 // Section 0: kind(1) + BSON document (first field is usually the command)
 func extractCommandName(message []byte) string {
-	if len(message) < 21 {
+	if len(message) < reader.MinOpMsgSize {
 		return ""
 	}
 
 	// Skip wire protocol header (16 bytes) and flags (4 bytes)
-	offset := 20
+	offset := reader.OpMsgSectionsOffset
 
 	// Read section kind
 	if message[offset] != 0 {
@@ -311,7 +658,7 @@ func extractCommandName(message []byte) string {
 	}
 
 	// Skip BSON size
-	offset += 4
+	offset += reader.BSONLengthSize
 
 	// Read first element type
 	if offset >= len(message) {
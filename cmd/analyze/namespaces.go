@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// namespaceEvent is one request against a collection, in recording order
+type namespaceEvent struct {
+	// ns is the "db.collection" the request targeted
+	ns string
+
+	// renameTo is the new namespace if the request was a renameCollection
+	renameTo string
+}
+
+// requestNamespace returns the namespace a request targets, and the new name for renameCollection
+// Returns an empty namespace for requests that don't target a collection
+func requestNamespace(packet *reader.Packet) (ns string, renameTo string) {
+	cmdName := packet.ExtractCommandName()
+	if cmdName == "renameCollection" {
+		// { renameCollection: "db.old", to: "db.new" } runs against admin
+		cmd, err := sender.ExtractCommand(packet)
+		if err != nil {
+			return "", ""
+		}
+		from, _ := cmd.Document["renameCollection"].(string)
+		to, _ := cmd.Document["to"].(string)
+		return from, to
+	}
+
+	db := packet.ExtractDatabase()
+	coll := packet.ExtractCollection()
+	if db == "" || coll == "" {
+		return "", ""
+	}
+	return db + "." + coll, ""
+}
+
+// canonicalNamespaceCounts attributes every request to the final name of its collection
+// Events are walked newest-first: once a rename old->new is passed, earlier requests on
+// "old" belong to whatever "new" eventually became, while earlier requests on "new" hit a
+// different collection (the rename target was dropped), so "new" stops aliasing.
+// Returns the per-namespace counts and, for each canonical namespace, the earlier names merged into it
+func canonicalNamespaceCounts(events []namespaceEvent) (map[string]int, map[string][]string) {
+	alias := make(map[string]string)
+	resolve := func(ns string) string {
+		if canonical, ok := alias[ns]; ok {
+			return canonical
+		}
+		return ns
+	}
+
+	counts := make(map[string]int)
+	merged := make(map[string]map[string]bool)
+
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+
+		if event.renameTo != "" && event.renameTo != event.ns {
+			alias[event.ns] = resolve(event.renameTo)
+			delete(alias, event.renameTo)
+		}
+
+		canonical := resolve(event.ns)
+		counts[canonical]++
+		if canonical != event.ns {
+			if merged[canonical] == nil {
+				merged[canonical] = make(map[string]bool)
+			}
+			merged[canonical][event.ns] = true
+		}
+	}
+
+	previousNames := make(map[string][]string)
+	for canonical, names := range merged {
+		for name := range names {
+			previousNames[canonical] = append(previousNames[canonical], name)
+		}
+		sort.Strings(previousNames[canonical])
+	}
+
+	return counts, previousNames
+}
+
+// printNamespaceStats prints request counts per collection, noting names merged by renames
+func printNamespaceStats(counts map[string]int, previousNames map[string][]string) {
+	if len(counts) == 0 {
+		fmt.Println("  (No collection-level requests)")
+		return
+	}
+
+	type nsStat struct {
+		ns    string
+		count int
+	}
+
+	var stats []nsStat
+	total := 0
+	for ns, count := range counts {
+		stats = append(stats, nsStat{ns, count})
+		total += count
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].count != stats[j].count {
+			return stats[i].count > stats[j].count
+		}
+		return stats[i].ns < stats[j].ns
+	})
+
+	for _, stat := range stats {
+		pct := float64(stat.count) / float64(total) * 100
+		fmt.Printf("  %-40s: %6d (%5.1f%%)", stat.ns, stat.count, pct)
+		if names := previousNames[stat.ns]; len(names) > 0 {
+			fmt.Printf("  [renamed from %s]", strings.Join(names, ", "))
+		}
+		fmt.Println()
+	}
+}
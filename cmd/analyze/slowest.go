@@ -0,0 +1,121 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// slowOp is one request paired with its response
+type slowOp struct {
+	sessionID uint64
+	offset    uint64 // request offset (microseconds)
+	command   string
+	namespace string
+	latency   time.Duration
+}
+
+// pendingRequest is a request still waiting for its response
+type pendingRequest struct {
+	offset    uint64
+	command   string
+	namespace string
+}
+
+// slowHeap is a min-heap by latency, so the fastest of the kept ops is evicted first
+type slowHeap []slowOp
+
+func (h slowHeap) Len() int            { return len(h) }
+func (h slowHeap) Less(i, j int) bool  { return h[i].latency < h[j].latency }
+func (h slowHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x interface{}) { *h = append(*h, x.(slowOp)) }
+func (h *slowHeap) Pop() interface{} {
+	old := *h
+	op := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return op
+}
+
+// slowestOps pairs requests with their responses and keeps the n slowest
+// Latency is the recorded gap between a request and the first response to it, i.e.
+// the original server time plus network time as seen at the capture point.
+type slowestOps struct {
+	n       int
+	pending map[reader.RequestKey]pendingRequest
+	slowest slowHeap
+
+	// paired counts requests matched with a response
+	paired int
+}
+
+func newSlowestOps(n int) *slowestOps {
+	return &slowestOps{
+		n:       n,
+		pending: make(map[reader.RequestKey]pendingRequest),
+	}
+}
+
+// add records a request, or pairs a response with its request
+func (s *slowestOps) add(packet *reader.Packet) {
+	key, ok := packet.PairingKey()
+	if !ok {
+		return
+	}
+
+	if packet.IsRequest() {
+		ns, _ := requestNamespace(packet)
+		s.pending[key] = pendingRequest{
+			offset:    packet.Offset,
+			command:   packet.ExtractCommandName(),
+			namespace: ns,
+		}
+		return
+	}
+
+	// Exhaust cursors answer one request many times; only the first response counts
+	req, ok := s.pending[key]
+	if !ok {
+		return
+	}
+	delete(s.pending, key)
+	s.paired++
+
+	var latency time.Duration
+	if packet.Offset > req.offset {
+		latency = time.Duration(packet.Offset-req.offset) * time.Microsecond
+	}
+	op := slowOp{sessionID: packet.SessionID, offset: req.offset, command: req.command, namespace: req.namespace, latency: latency}
+
+	if len(s.slowest) < s.n {
+		heap.Push(&s.slowest, op)
+	} else if latency > s.slowest[0].latency {
+		s.slowest[0] = op
+		heap.Fix(&s.slowest, 0)
+	}
+}
+
+// print lists the kept ops, slowest first
+func (s *slowestOps) print() {
+	if s.paired == 0 {
+		fmt.Println("  (No request/response pairs found - the recording may contain requests only)")
+		return
+	}
+
+	ops := append([]slowOp(nil), s.slowest...)
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].latency != ops[j].latency {
+			return ops[i].latency > ops[j].latency
+		}
+		return ops[i].offset < ops[j].offset
+	})
+
+	fmt.Printf("Paired %d requests with responses; %d slowest:\n\n", s.paired, len(ops))
+	fmt.Printf("  %12s  %-20s  %-40s  %10s  %s\n", "LATENCY", "COMMAND", "NAMESPACE", "SESSION", "OFFSET")
+	for _, op := range ops {
+		fmt.Printf("  %12v  %-20s  %-40s  %10d  %d μs\n",
+			op.latency, orDash(op.command), orDash(op.namespace), op.sessionID, op.offset)
+	}
+}
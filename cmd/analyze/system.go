@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// systemFilter narrows the analysis to internal namespaces (--system-only)
+// Requests are classified by database and collection; a response is kept if the
+// request it answers was.
+type systemFilter struct {
+	// kept records the requests kept so far; exhaust cursors answer one request many times
+	kept map[reader.RequestKey]bool
+
+	// skipped counts packets left out of the analysis
+	skipped int
+}
+
+func newSystemFilter() *systemFilter {
+	return &systemFilter{kept: make(map[reader.RequestKey]bool)}
+}
+
+// keep reports whether packet belongs in a system-only analysis
+func (f *systemFilter) keep(packet *reader.Packet) bool {
+	key, ok := packet.PairingKey()
+	if !ok {
+		f.skipped++
+		return false
+	}
+
+	if packet.IsRequest() {
+		if !isSystemRequest(packet) {
+			f.skipped++
+			return false
+		}
+		f.kept[key] = true
+		return true
+	}
+
+	if !f.kept[key] {
+		f.skipped++
+		return false
+	}
+	return true
+}
+
+// isSystemRequest reports whether a request targets an internal database or collection
+func isSystemRequest(packet *reader.Packet) bool {
+	return reader.IsInternalDatabase(packet.ExtractDatabase()) ||
+		reader.IsInternalCollection(packet.ExtractCollection())
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	baseFile := os.Args[1]
+	otherFile := os.Args[2]
+
+	// Parse options
+	requestsOnly := false
+	userOpsOnly := false
+	sequence := false
+	maxSequenceOps := 5000
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--requests-only":
+			requestsOnly = true
+		case "--user-ops":
+			userOpsOnly = true
+		case "--sequence":
+			sequence = true
+		case "--max-sequence-ops":
+			if i+1 < len(os.Args) {
+				fmt.Sscanf(os.Args[i+1], "%d", &maxSequenceOps)
+				i++
+			}
+		}
+	}
+
+	base, err := loadProfile(baseFile, requestsOnly, userOpsOnly)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", baseFile, err)
+		os.Exit(1)
+	}
+	other, err := loadProfile(otherFile, requestsOnly, userOpsOnly)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", otherFile, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Base:  %s\n", baseFile)
+	fmt.Printf("Other: %s\n", otherFile)
+	fmt.Println(strings.Repeat("=", 80))
+
+	fmt.Println("\n=== OVERVIEW ===")
+	fmt.Printf("  %-20s %12s %12s %10s\n", "", "Base", "Other", "Delta")
+	printCountRow("Packets", base.packets, other.packets)
+	printCountRow("Commands", base.ops, other.ops)
+	printCountRow("Distinct commands", len(base.commands), len(other.commands))
+	printCountRow("Namespaces", len(base.namespaces), len(other.namespaces))
+
+	fmt.Println("\n=== COMMAND MIX ===")
+	printCountDiff(base.commands, other.commands, base.ops, other.ops)
+
+	fmt.Println("\n=== NAMESPACE COVERAGE ===")
+	printCountDiff(base.namespaces, other.namespaces, base.ops, other.ops)
+
+	if sequence {
+		fmt.Println("\n=== SEQUENCE ALIGNMENT ===")
+		printSequenceDiff(base.sequence, other.sequence, maxSequenceOps)
+	}
+}
+
+// profile summarizes the commands in one recording
+type profile struct {
+	// packets is the total number of packets read
+	packets int
+
+	// ops is the number of packets with an extractable command
+	ops int
+
+	// commands counts occurrences of each command name
+	commands map[string]int
+
+	// namespaces counts commands per "db.collection" (or "db" for database-level commands)
+	namespaces map[string]int
+
+	// sequence lists "command namespace" in recording order
+	sequence []string
+}
+
+// loadProfile reads a recording and builds its command profile
+func loadProfile(path string, requestsOnly, userOpsOnly bool) (*profile, error) {
+	rec, err := reader.NewRecordingReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rec.Close()
+	if rec.Empty() {
+		return nil, fmt.Errorf("%s: %w", path, reader.ErrEmptyRecording)
+	}
+
+	p := &profile{
+		commands:   make(map[string]int),
+		namespaces: make(map[string]int),
+	}
+
+	for {
+		packet, err := rec.Next()
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packet %d: %w", p.packets+1, err)
+		}
+		p.packets++
+
+		if requestsOnly && !packet.IsRequest() {
+			continue
+		}
+		if userOpsOnly && !packet.IsLikelyUserOperation() {
+			continue
+		}
+
+		cmd := packet.ExtractCommandName()
+		if cmd == "" {
+			continue
+		}
+
+		ns := packet.ExtractDatabase()
+		if coll := packet.ExtractCollection(); coll != "" {
+			ns += "." + coll
+		}
+
+		p.ops++
+		p.commands[cmd]++
+		p.namespaces[ns]++
+		p.sequence = append(p.sequence, cmd+" "+ns)
+	}
+}
+
+// printCountRow prints one overview row with its delta
+func printCountRow(label string, base, other int) {
+	fmt.Printf("  %-20s %12d %12d %+10d\n", label, base, other, other-base)
+}
+
+// printCountDiff reports keys added, removed and changed between two count maps
+// Changed entries are sorted by the magnitude of their count change
+func printCountDiff(base, other map[string]int, baseTotal, otherTotal int) {
+	var added, removed, changed []string
+	for key := range base {
+		if _, ok := other[key]; !ok {
+			removed = append(removed, key)
+		} else if base[key] != other[key] {
+			changed = append(changed, key)
+		}
+	}
+	for key := range other {
+		if _, ok := base[key]; !ok {
+			added = append(added, key)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		fmt.Println("  (No differences)")
+		return
+	}
+
+	byCount := func(keys []string, counts func(string) int) {
+		sort.Slice(keys, func(i, j int) bool {
+			if counts(keys[i]) != counts(keys[j]) {
+				return counts(keys[i]) > counts(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+	}
+	byCount(added, func(key string) int { return other[key] })
+	byCount(removed, func(key string) int { return base[key] })
+	byCount(changed, func(key string) int { return abs(other[key] - base[key]) })
+
+	if len(added) > 0 {
+		fmt.Printf("  Added (%d):\n", len(added))
+		for _, key := range added {
+			fmt.Printf("    + %-40s %8d\n", key, other[key])
+		}
+	}
+	if len(removed) > 0 {
+		fmt.Printf("  Removed (%d):\n", len(removed))
+		for _, key := range removed {
+			fmt.Printf("    - %-40s %8d\n", key, base[key])
+		}
+	}
+	if len(changed) > 0 {
+		fmt.Printf("  Changed (%d):\n", len(changed))
+		for _, key := range changed {
+			fmt.Printf("    ~ %-40s %8d -> %-8d (%+d, share %.1f%% -> %.1f%%)\n",
+				key, base[key], other[key], other[key]-base[key],
+				share(base[key], baseTotal), share(other[key], otherTotal))
+		}
+	}
+}
+
+// printSequenceDiff aligns two command sequences (longest common subsequence)
+// and prints the commands present in only one of them
+func printSequenceDiff(base, other []string, maxOps int) {
+	if maxOps > 0 {
+		if len(base) > maxOps || len(other) > maxOps {
+			fmt.Printf("  (Aligning first %d commands of each recording; use --max-sequence-ops to change)\n", maxOps)
+		}
+		if len(base) > maxOps {
+			base = base[:maxOps]
+		}
+		if len(other) > maxOps {
+			other = other[:maxOps]
+		}
+	}
+
+	// lcs[i][j] is the LCS length of base[i:] and other[j:]
+	lcs := make([][]int32, len(base)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(other)+1)
+	}
+	for i := len(base) - 1; i >= 0; i-- {
+		for j := len(other) - 1; j >= 0; j-- {
+			if base[i] == other[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	differences := 0
+	i, j := 0, 0
+	for i < len(base) || j < len(other) {
+		switch {
+		case i < len(base) && j < len(other) && base[i] == other[j]:
+			i++
+			j++
+		case j < len(other) && (i == len(base) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Printf("  + [%6d] %s\n", j+1, other[j])
+			differences++
+			j++
+		default:
+			fmt.Printf("  - [%6d] %s\n", i+1, base[i])
+			differences++
+			i++
+		}
+	}
+
+	if differences == 0 {
+		fmt.Println("  (Sequences are identical)")
+		return
+	}
+	fmt.Printf("\n  %d commands in common, %d only in base, %d only in other\n",
+		lcs[0][0], len(base)-int(lcs[0][0]), len(other)-int(lcs[0][0]))
+}
+
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <base-recording> <other-recording> [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nCompares two MongoDB traffic recordings and reports differences in\n")
+	fmt.Fprintf(os.Stderr, "command mix, namespace coverage and per-command counts.\n")
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	fmt.Fprintf(os.Stderr, "  --requests-only          Only compare requests (skip responses)\n")
+	fmt.Fprintf(os.Stderr, "  --user-ops               Only compare user operations (skip internal ops)\n")
+	fmt.Fprintf(os.Stderr, "  --sequence               Also align command sequences and list commands present in only one\n")
+	fmt.Fprintf(os.Stderr, "  --max-sequence-ops N     Commands per recording to align (default: 5000, 0 = all)\n")
+	fmt.Fprintf(os.Stderr, "\nExample:\n")
+	fmt.Fprintf(os.Stderr, "  %s before.bin after.bin --requests-only --user-ops --sequence\n", os.Args[0])
+}
@@ -7,8 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
 )
 
 type FilterConfig struct {
@@ -18,11 +20,21 @@ type FilterConfig struct {
 	userOpsOnly        bool
 	userOpsOnlySmart   bool // Use context-aware filtering
 	excludeInternal    bool
+	writesOnly         bool
 	includeCommands    []string
 	excludeCommands    []string
+	namespaces         *reader.NamespaceFilter
+	includeAppNames    []string
+	excludeAppNames    []string
 	minOffset          uint64
 	maxOffset          uint64
+	minSessionPackets  int
+	readsAfterWrites   uint64
+	stripFields        []string
+	checksum           sender.ChecksumMode
 	verbose            bool
+	headerless         bool
+	progress           bool
 }
 
 type FilterStats struct {
@@ -30,8 +42,19 @@ type FilterStats struct {
 	outputPackets      int
 	droppedResponses   int
 	droppedInternal    int
+	droppedReads       int
 	droppedByCommand   int
+	droppedByNamespace int
 	droppedByTime      int
+	droppedTrivial     int
+	trivialSessions    int
+	droppedByAppName   int
+	appNameSessions    int
+	droppedReadWindow  int
+	strippedPackets    int
+	stripSkipped       int
+	checksumRewrites   int
+	checksumSkipped    int
 	inputBytes         uint64
 	outputBytes        uint64
 }
@@ -45,16 +68,43 @@ func main() {
 	flag.BoolVar(&config.userOpsOnly, "user-ops-only", false, "Keep only user operations (simple command-based filter)")
 	flag.BoolVar(&config.userOpsOnlySmart, "user-ops-smart", false, "Keep only user operations (context-aware: checks db/collection for getMore, etc.)")
 	flag.BoolVar(&config.excludeInternal, "exclude-internal", false, "Exclude internal operations (hello, getMore, replication)")
+	flag.BoolVar(&config.writesOnly, "writes-only", false, "Keep only requests that change data or schema (insert, update, delete, findAndModify, DDL)")
 
 	var includeCommands string
 	var excludeCommands string
 	flag.StringVar(&includeCommands, "include-commands", "", "Comma-separated list of commands to include")
 	flag.StringVar(&excludeCommands, "exclude-commands", "", "Comma-separated list of commands to exclude")
 
+	var excludeNamespaces []string
+	flag.Func("exclude-namespace", "Drop requests on a namespace ('db.collection', or 'db' / 'db.*' for a whole database; * and ? wildcards in either part) and their responses (repeatable)", func(ns string) error {
+		excludeNamespaces = append(excludeNamespaces, ns)
+		return nil
+	})
+
+	flag.Func("include-appname", "Keep only sessions whose client appName (from session metadata or the handshake) is this name (repeatable; requires a pre-scan)", func(name string) error {
+		config.includeAppNames = append(config.includeAppNames, name)
+		return nil
+	})
+	flag.Func("exclude-appname", "Drop sessions whose client appName is this name, e.g. a monitoring agent (repeatable; requires a pre-scan)", func(name string) error {
+		config.excludeAppNames = append(config.excludeAppNames, name)
+		return nil
+	})
+
 	flag.Uint64Var(&config.minOffset, "min-offset", 0, "Minimum offset (microseconds) - drop packets before this")
 	flag.Uint64Var(&config.maxOffset, "max-offset", 0, "Maximum offset (microseconds) - drop packets after this (0=unlimited)")
 
+	flag.Uint64Var(&config.readsAfterWrites, "reads-after-writes", 0, "Keep a read (find, aggregate, count, distinct) only if a write to its namespace was kept at most this many microseconds before it (0=keep all reads)")
+	flag.IntVar(&config.minSessionPackets, "min-session-packets", 0, "Drop sessions with fewer than N packets in the input (0=keep all; requires a pre-scan)")
+
+	var stripFields string
+	flag.StringVar(&stripFields, "strip-fields", "", "Comma-separated top-level fields to remove from OP_MSG bodies (e.g. comment,$clusterTime)")
+
+	var checksum string
+	flag.StringVar(&checksum, "checksum", "keep", "OP_MSG checksums: keep (as recorded), strip (clear checksumPresent and drop the checksum) or recompute (CRC-32C)")
+
 	flag.BoolVar(&config.verbose, "verbose", false, "Verbose output")
+	flag.BoolVar(&config.progress, "progress", false, "Show progress through the input file on stderr")
+	flag.BoolVar(&config.headerless, "headerless", false, "Write output without a file header (same layout as server-written recordings)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
@@ -70,10 +120,24 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -user-ops-smart\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Combine: requests-only + user-ops-only (maximum reduction)\n")
 		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -requests-only -user-ops-only\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Keep only writes and DDL (a \"what changed\" recording that rebuilds state)\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output writes.bin -writes-only\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Keep only insert and update operations\n")
 		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -include-commands insert,update\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Exclude hello and getMore (remove health checks)\n")
 		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -exclude-commands hello,getMore\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Drop one noisy collection and keep everything else\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -exclude-namespace app.events\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Drop a monitoring agent's sessions, identified by the appName it connects with\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -exclude-appname mongodb-exporter\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Keep writes and only the reads within 50ms after a write to the same namespace\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -requests-only -reads-after-writes 50000\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Drop trivial sessions (e.g. monitoring connections with a few health checks)\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -min-session-packets 10\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Shrink messages by removing bulky fields that don't affect replay\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -requests-only -strip-fields comment,$clusterTime\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Drop OP_MSG checksums before raw replay against a target that negotiates differently\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output filtered.bin -requests-only -checksum strip\n\n", os.Args[0])
 	}
 
 	flag.Parse()
@@ -98,6 +162,30 @@ func main() {
 		}
 	}
 
+	if stripFields != "" {
+		for _, field := range strings.Split(stripFields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				config.stripFields = append(config.stripFields, field)
+			}
+		}
+	}
+
+	if len(excludeNamespaces) > 0 {
+		namespaces, err := reader.NewNamespaceFilter(nil, excludeNamespaces)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid -exclude-namespace: %v\n", err)
+			os.Exit(1)
+		}
+		config.namespaces = namespaces
+	}
+
+	mode, err := sender.ParseChecksumMode(checksum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.checksum = mode
+
 	// Run filter
 	stats, err := filterRecording(config)
 	if err != nil {
@@ -110,12 +198,39 @@ func main() {
 }
 
 func filterRecording(config *FilterConfig) (*FilterStats, error) {
+	stats := &FilterStats{}
+
+	// Pre-scan: find sessions too small to keep, or whose appName is filtered out
+	var trivialSessions, appNameSessions map[uint64]bool
+	filterAppNames := len(config.includeAppNames) > 0 || len(config.excludeAppNames) > 0
+	if config.minSessionPackets > 0 || filterAppNames {
+		counts, appNames, err := prescanSessions(config.inputFile)
+		if err != nil {
+			return nil, err
+		}
+		trivialSessions = make(map[uint64]bool)
+		appNameSessions = make(map[uint64]bool)
+		for sessionID, count := range counts {
+			if config.minSessionPackets > 0 && count < config.minSessionPackets {
+				trivialSessions[sessionID] = true
+			}
+			if filterAppNames && !keepAppName(appNames[sessionID], config) {
+				appNameSessions[sessionID] = true
+			}
+		}
+		stats.trivialSessions = len(trivialSessions)
+		stats.appNameSessions = len(appNameSessions)
+	}
+
 	// Open input
 	input, err := reader.NewRecordingReader(config.inputFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open input: %w", err)
 	}
 	defer input.Close()
+	if input.Empty() {
+		return nil, fmt.Errorf("%s: %w", config.inputFile, reader.ErrEmptyRecording)
+	}
 
 	// Create output directory if needed
 	outputDir := filepath.Dir(config.outputFile)
@@ -123,16 +238,23 @@ func filterRecording(config *FilterConfig) (*FilterStats, error) {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Open output
-	output, err := os.Create(config.outputFile)
+	// Open output (carrying over the capture start time if the input has a header)
+	output, err := reader.NewRecordingWriter(config.outputFile, reader.WriterOptions{
+		StartTime:  input.StartTime(),
+		Headerless: config.headerless,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create output: %w", err)
 	}
 	defer output.Close()
 
-	stats := &FilterStats{}
+	var reads *readWindow
+	if config.readsAfterWrites > 0 {
+		reads = newReadWindow(config.readsAfterWrites, !config.requestsOnly)
+	}
 
 	// Process packets
+	var lastProgress time.Time
 	for {
 		packet, err := input.Next()
 		if err == io.EOF {
@@ -145,8 +267,21 @@ func filterRecording(config *FilterConfig) (*FilterStats, error) {
 		stats.inputPackets++
 		stats.inputBytes += uint64(packet.Size)
 
+		if config.progress && time.Since(lastProgress) >= progressInterval {
+			printProgress(input, stats)
+			lastProgress = time.Now()
+		}
+
 		// Apply filters
-		keep, reason := shouldKeepPacket(packet, config)
+		keep, reason := false, "trivial-session"
+		if appNameSessions[packet.SessionID] {
+			reason = "appname"
+		} else if !trivialSessions[packet.SessionID] {
+			keep, reason = shouldKeepPacket(packet, config)
+			if keep && reads != nil && !reads.keep(packet) {
+				keep, reason = false, "read-window"
+			}
+		}
 
 		if config.verbose && !keep {
 			fmt.Printf("Dropping packet %d: %s (session=%d, cmd=%s)\n",
@@ -160,16 +295,54 @@ func filterRecording(config *FilterConfig) (*FilterStats, error) {
 				stats.droppedResponses++
 			case "internal-operation":
 				stats.droppedInternal++
+			case "read":
+				stats.droppedReads++
 			case "command-filter":
 				stats.droppedByCommand++
+			case "namespace":
+				stats.droppedByNamespace++
 			case "time-range":
 				stats.droppedByTime++
+			case "trivial-session":
+				stats.droppedTrivial++
+			case "appname":
+				stats.droppedByAppName++
+			case "read-window":
+				stats.droppedReadWindow++
 			}
 			continue
 		}
 
+		// Rewrite the message without the stripped fields
+		if len(config.stripFields) > 0 && len(packet.Message) > 0 {
+			stripped, err := stripPacketFields(packet, config.stripFields)
+			if err != nil {
+				stats.stripSkipped++
+				if config.verbose {
+					fmt.Printf("Not stripping packet %d: %v\n", stats.inputPackets, err)
+				}
+			} else if stripped != packet {
+				stats.strippedPackets++
+				packet = stripped
+			}
+		}
+
+		// Strip or recompute OP_MSG checksums (after field stripping, which re-encodes)
+		if config.checksum != sender.ChecksumKeep && len(packet.Message) > 0 {
+			message, changed, err := sender.SetChecksum(packet.Message, config.checksum)
+			if err != nil {
+				stats.checksumSkipped++
+				if config.verbose {
+					fmt.Printf("Not rewriting checksum of packet %d: %v\n", stats.inputPackets, err)
+				}
+			} else if changed {
+				stats.checksumRewrites++
+				packet = withMessage(packet, message)
+			}
+		}
+
 		// Write packet to output
-		if err := writePacket(output, packet); err != nil {
+		if err := output.Write(packet); err != nil {
 			return nil, fmt.Errorf("failed to write packet: %w", err)
 		}
 
@@ -177,9 +350,116 @@ func filterRecording(config *FilterConfig) (*FilterStats, error) {
 		stats.outputBytes += uint64(packet.Size)
 	}
 
+	if config.progress {
+		printProgress(input, stats)
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if err := output.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close output: %w", err)
+	}
+
 	return stats, nil
 }
 
+// stripPacketFields returns a copy of packet with fields removed from its OP_MSG body
+// Returns packet itself if none of the fields were present. OP_COMPRESSED messages
+// are left alone (an error), since re-encoding would have to decompress them.
+func stripPacketFields(packet *reader.Packet, fields []string) (*reader.Packet, error) {
+	message, changed, err := sender.StripFields(packet.Message, fields)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return packet, nil
+	}
+	return withMessage(packet, message), nil
+}
+
+// withMessage returns a copy of packet carrying message, with its size adjusted
+func withMessage(packet *reader.Packet, message []byte) *reader.Packet {
+	return &reader.Packet{
+		Size:            packet.Size - uint32(len(packet.Message)) + uint32(len(message)),
+		EventType:       packet.EventType,
+		SessionID:       packet.SessionID,
+		SessionMetadata: packet.SessionMetadata,
+		Offset:          packet.Offset,
+		Order:           packet.Order,
+		Message:         message,
+	}
+}
+
+// progressInterval is the minimum time between progress updates
+const progressInterval = 250 * time.Millisecond
+
+// printProgress overwrites the progress line with how far through the input file the filter is
+func printProgress(input *reader.RecordingReader, stats *FilterStats) {
+	pos, err := input.Position()
+	if err != nil || input.Size() == 0 {
+		return
+	}
+	pct := float64(pos) / float64(input.Size()) * 100
+	fmt.Fprintf(os.Stderr, "\rProgress: %5.1f%% (%s / %s, %d packets, %d kept)",
+		pct, formatBytes(uint64(pos)), formatBytes(uint64(input.Size())), stats.inputPackets, stats.outputPackets)
+}
+
+// prescanSessions reads the whole recording, counting packets per session and finding
+// each session's appName: from its session metadata, else from its handshake request
+func prescanSessions(path string) (map[uint64]int, map[uint64]string, error) {
+	input, err := reader.NewRecordingReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open input for pre-scan: %w", err)
+	}
+	defer input.Close()
+
+	counts := make(map[uint64]int)
+	appNames := make(map[uint64]string)
+	for {
+		packet, err := input.Next()
+		if err == io.EOF {
+			return counts, appNames, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read packet during pre-scan: %w", err)
+		}
+		counts[packet.SessionID]++
+
+		if appNames[packet.SessionID] != "" {
+			continue
+		}
+		if counts[packet.SessionID] == 1 {
+			if meta, err := packet.ParseSessionMetadata(); err == nil && meta.AppName != "" {
+				appNames[packet.SessionID] = meta.AppName
+				continue
+			}
+		}
+		if packet.IsRequest() {
+			if name := sender.HandshakeAppName(packet); name != "" {
+				appNames[packet.SessionID] = name
+			}
+		}
+	}
+}
+
+// keepAppName reports whether a session with this appName ("" if unknown) passes the
+// appName filters; sessions without a known appName only pass when there is no include list
+func keepAppName(appName string, config *FilterConfig) bool {
+	for _, name := range config.excludeAppNames {
+		if appName == name {
+			return false
+		}
+	}
+	if len(config.includeAppNames) == 0 {
+		return true
+	}
+	for _, name := range config.includeAppNames {
+		if appName == name {
+			return true
+		}
+	}
+	return false
+}
+
 func shouldKeepPacket(packet *reader.Packet, config *FilterConfig) (bool, string) {
 	// Time range filter
 	if config.minOffset > 0 && packet.Offset < config.minOffset {
@@ -189,6 +469,11 @@ func shouldKeepPacket(packet *reader.Packet, config *FilterConfig) (bool, string
 		return false, "time-range"
 	}
 
+	// Namespace filter (before the others, so it sees every request its responses follow)
+	if config.namespaces != nil && !config.namespaces.Keep(packet) {
+		return false, "namespace"
+	}
+
 	// Requests-only filter
 	if config.requestsOnly {
 		if len(packet.Message) == 0 {
@@ -219,6 +504,16 @@ func shouldKeepPacket(packet *reader.Packet, config *FilterConfig) (bool, string
 		}
 	}
 
+	// Writes only (empty messages are session events and are kept)
+	if config.writesOnly && len(packet.Message) > 0 {
+		if !packet.IsRequest() {
+			return false, "response"
+		}
+		if !packet.IsWriteOperation() {
+			return false, "read"
+		}
+	}
+
 	// Exclude internal operations
 	if config.excludeInternal {
 		if packet.IsInternalOperation() {
@@ -255,76 +550,6 @@ func shouldKeepPacket(packet *reader.Packet, config *FilterConfig) (bool, string
 	return true, ""
 }
 
-func writePacket(w io.Writer, packet *reader.Packet) error {
-	// Reconstruct the packet in binary format
-	// Format: size(4) + id(8) + session(null-terminated) + offset(8) + order(8) + message
-
-	// Calculate total size
-	headerSize := 4 + 8 + len(packet.SessionMetadata) + 1 + 8 + 8
-	totalSize := uint32(headerSize + len(packet.Message))
-
-	// Write size
-	if err := writeLittleEndianUint32(w, totalSize); err != nil {
-		return err
-	}
-
-	// Write session ID
-	if err := writeLittleEndianUint64(w, packet.SessionID); err != nil {
-		return err
-	}
-
-	// Write session metadata (null-terminated)
-	if _, err := w.Write([]byte(packet.SessionMetadata)); err != nil {
-		return err
-	}
-	if _, err := w.Write([]byte{0}); err != nil {
-		return err
-	}
-
-	// Write offset
-	if err := writeLittleEndianUint64(w, packet.Offset); err != nil {
-		return err
-	}
-
-	// Write order
-	if err := writeLittleEndianUint64(w, packet.Order); err != nil {
-		return err
-	}
-
-	// Write message
-	if len(packet.Message) > 0 {
-		if _, err := w.Write(packet.Message); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func writeLittleEndianUint32(w io.Writer, v uint32) error {
-	b := make([]byte, 4)
-	b[0] = byte(v)
-	b[1] = byte(v >> 8)
-	b[2] = byte(v >> 16)
-	b[3] = byte(v >> 24)
-	_, err := w.Write(b)
-	return err
-}
-
-func writeLittleEndianUint64(w io.Writer, v uint64) error {
-	b := make([]byte, 8)
-	b[0] = byte(v)
-	b[1] = byte(v >> 8)
-	b[2] = byte(v >> 16)
-	b[3] = byte(v >> 24)
-	b[4] = byte(v >> 32)
-	b[5] = byte(v >> 40)
-	b[6] = byte(v >> 48)
-	b[7] = byte(v >> 56)
-	_, err := w.Write(b)
-	return err
-}
-
 func printStats(stats *FilterStats) {
 	fmt.Println("\n" + strings.Repeat("=", 80))
 	fmt.Println("FILTER RESULTS")
@@ -352,6 +577,22 @@ func printStats(stats *FilterStats) {
 		fmt.Printf("  Bytes dropped:   %s (%.1f%%)\n", formatBytes(bytesDropped), pctBytes)
 	}
 
+	if stats.strippedPackets > 0 || stats.stripSkipped > 0 {
+		fmt.Printf("\nField stripping:\n")
+		fmt.Printf("  Rewritten packets:   %d\n", stats.strippedPackets)
+		if stats.stripSkipped > 0 {
+			fmt.Printf("  Not rewritable:      %d (compressed or not OP_MSG)\n", stats.stripSkipped)
+		}
+	}
+
+	if stats.checksumRewrites > 0 || stats.checksumSkipped > 0 {
+		fmt.Printf("\nChecksums:\n")
+		fmt.Printf("  Rewritten packets:   %d\n", stats.checksumRewrites)
+		if stats.checksumSkipped > 0 {
+			fmt.Printf("  Malformed OP_MSG:    %d (left as recorded)\n", stats.checksumSkipped)
+		}
+	}
+
 	if packetsDropped > 0 {
 		fmt.Printf("\nDropped by reason:\n")
 		if stats.droppedResponses > 0 {
@@ -360,12 +601,27 @@ func printStats(stats *FilterStats) {
 		if stats.droppedInternal > 0 {
 			fmt.Printf("  Internal operations: %d\n", stats.droppedInternal)
 		}
+		if stats.droppedReads > 0 {
+			fmt.Printf("  Reads (non-writes):  %d\n", stats.droppedReads)
+		}
 		if stats.droppedByCommand > 0 {
 			fmt.Printf("  Command filters:     %d\n", stats.droppedByCommand)
 		}
+		if stats.droppedByNamespace > 0 {
+			fmt.Printf("  Namespace filters:   %d\n", stats.droppedByNamespace)
+		}
 		if stats.droppedByTime > 0 {
 			fmt.Printf("  Time range:          %d\n", stats.droppedByTime)
 		}
+		if stats.droppedTrivial > 0 {
+			fmt.Printf("  Trivial sessions:    %d (%d sessions)\n", stats.droppedTrivial, stats.trivialSessions)
+		}
+		if stats.droppedByAppName > 0 {
+			fmt.Printf("  App names:           %d (%d sessions)\n", stats.droppedByAppName, stats.appNameSessions)
+		}
+		if stats.droppedReadWindow > 0 {
+			fmt.Printf("  Read window:         %d (reads with no recent write to their namespace)\n", stats.droppedReadWindow)
+		}
 	}
 
 	fmt.Println()
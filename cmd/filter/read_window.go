@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// readWindow keeps reads only if a write to their namespace was kept shortly before
+// them, so a recording keeps the reads that verify writes and drops background polling
+// Reads are the "read" category (find, aggregate, count, distinct) with a collection;
+// getMore and database-level commands are kept. Packets must arrive in offset order.
+type readWindow struct {
+	// window is how long after a write (microseconds) a read on its namespace is kept
+	window uint64
+
+	// lastWrite maps each "db.collection" to the offset of its latest kept write
+	lastWrite map[string]uint64
+
+	// dropped holds the dropped reads whose response is still to come (nil = responses
+	// are dropped by -requests-only anyway)
+	dropped map[reader.RequestKey]bool
+}
+
+// newReadWindow creates a readWindow; trackResponses drops the responses to dropped reads
+func newReadWindow(window uint64, trackResponses bool) *readWindow {
+	w := &readWindow{window: window, lastWrite: make(map[string]uint64)}
+	if trackResponses {
+		w.dropped = make(map[reader.RequestKey]bool)
+	}
+	return w
+}
+
+// keep reports whether a packet passes the window, noting the writes it sees
+// It is only given packets every other filter kept, so the writes it times reads
+// against are the ones in the output.
+func (w *readWindow) keep(p *reader.Packet) bool {
+	key, ok := p.PairingKey()
+	if !ok {
+		return true
+	}
+
+	if !p.IsRequest() {
+		if w.dropped[key] {
+			delete(w.dropped, key)
+			return false
+		}
+		return true
+	}
+
+	coll := p.ExtractCollection()
+	if coll == "" {
+		return true
+	}
+	ns := p.ExtractDatabase() + "." + coll
+
+	if p.IsWriteOperation() {
+		w.lastWrite[ns] = p.Offset
+		return true
+	}
+	if p.GetCommandCategory() != "read" {
+		return true
+	}
+
+	if last, ok := w.lastWrite[ns]; ok && p.Offset >= last && p.Offset-last <= w.window {
+		return true
+	}
+	if w.dropped != nil {
+		w.dropped[key] = true
+	}
+	return false
+}
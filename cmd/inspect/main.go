@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"os"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
 )
 
 // Simple tool to inspect the raw format of a recording file
@@ -21,6 +25,26 @@ func main() {
 
 	fmt.Printf("File size: %d bytes\n\n", len(data))
 
+	// Recordings written by this project's tools may start with a file header
+	if bytes.HasPrefix(data, reader.FileMagic[:]) && len(data) >= 8 {
+		header, err := reader.ReadFileHeader(bytes.NewReader(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file header: %v\n", err)
+			os.Exit(1)
+		}
+		headerSize := binary.LittleEndian.Uint32(data[4:8])
+		fmt.Println("=== File Header ===")
+		fmt.Printf("  size: %d bytes\n", headerSize)
+		fmt.Printf("  version: %d\n", header.Version)
+		fmt.Printf("  flags: 0x%04x\n", header.Flags)
+		if header.StartTime.IsZero() {
+			fmt.Printf("  start time: (unknown)\n\n")
+		} else {
+			fmt.Printf("  start time: %s\n\n", header.StartTime.UTC().Format(time.RFC3339Nano))
+		}
+		data = data[headerSize:]
+	}
+
 	// Read first packet header
 	if len(data) < 20 {
 		fmt.Fprintf(os.Stderr, "File too small\n")
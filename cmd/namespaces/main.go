@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// namespaceCounts counts the requests sent to one namespace
+type namespaceCounts struct {
+	requests int
+	reads    int
+	writes   int
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nLists the distinct db.collection namespaces a recording's requests touch,\n")
+		fmt.Fprintf(os.Stderr, "with per-namespace request counts split into reads and writes.\n")
+		os.Exit(1)
+	}
+
+	filePath := os.Args[1]
+
+	rec, err := reader.NewRecordingReader(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
+
+	namespaces := make(map[string]*namespaceCounts)
+	totalRequests := 0
+	noNamespace := 0
+
+	// Responses carry no namespace, so their bodies are skipped unread
+	for {
+		packet, err := rec.NextRequest()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading packet: %v\n", err)
+			os.Exit(1)
+		}
+		totalRequests++
+
+		ns := namespace(packet)
+		if ns == "" {
+			noNamespace++
+			continue
+		}
+
+		counts, ok := namespaces[ns]
+		if !ok {
+			counts = &namespaceCounts{}
+			namespaces[ns] = counts
+		}
+		counts.requests++
+		switch {
+		case packet.IsWriteOperation():
+			counts.writes++
+		case isRead(packet):
+			counts.reads++
+		}
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("NAMESPACES (%d)\n", len(names))
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Println()
+
+	if len(names) == 0 {
+		fmt.Println("(No requests with a db.collection namespace)")
+	} else {
+		fmt.Printf("%-50s %9s %9s %9s %9s\n", "NAMESPACE", "REQUESTS", "READS", "WRITES", "OTHER")
+		for _, ns := range names {
+			c := namespaces[ns]
+			fmt.Printf("%-50s %9d %9d %9d %9d\n", ns, c.requests, c.reads, c.writes, c.requests-c.reads-c.writes)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Requests: %d (%d without a collection, e.g. hello or database-level commands)\n", totalRequests, noNamespace)
+}
+
+// namespace returns a request's "db.collection", or "" if it doesn't target a collection
+// getMore names its collection in the "collection" field rather than the command field,
+// and renameCollection (run against admin) names the full source namespace.
+func namespace(packet *reader.Packet) string {
+	db := packet.ExtractDatabase()
+	if db == "" {
+		return ""
+	}
+
+	cmd := packet.ExtractCommandName()
+	coll := packet.ExtractCollection()
+	if cmd == "renameCollection" {
+		return coll
+	}
+	if coll == "" && cmd == "getMore" {
+		if body, err := sender.DecodeBody(packet.Message); err == nil {
+			coll, _ = body.Document.Lookup("collection").StringValueOK()
+		}
+	}
+	if coll == "" {
+		return ""
+	}
+	return db + "." + coll
+}
+
+// isRead reports whether a request only reads data (find, aggregate, count, distinct, getMore)
+func isRead(packet *reader.Packet) bool {
+	switch packet.GetCommandCategory() {
+	case "read", "read-continuation":
+		return true
+	}
+	return packet.ExtractCommandName() == "listIndexes"
+}
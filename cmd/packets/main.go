@@ -13,20 +13,47 @@ import (
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> [filter]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> [filter] [--dump-bytes N] [--full] [--redact-metadata]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nShows detailed packet information.\n")
 		fmt.Fprintf(os.Stderr, "\nFilters:\n")
 		fmt.Fprintf(os.Stderr, "  all         - Show all packets (default)\n")
 		fmt.Fprintf(os.Stderr, "  user        - Show only user operations (insert, find, update, delete, aggregate)\n")
 		fmt.Fprintf(os.Stderr, "  command:X   - Show packets containing command X (e.g., command:insert)\n")
 		fmt.Fprintf(os.Stderr, "  session:N   - Show packets for session N\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		fmt.Fprintf(os.Stderr, "  --dump-bytes N  Hex dump at most N bytes of each message and BSON body\n")
+		fmt.Fprintf(os.Stderr, "                  (default: 128 of the message, 256 of the body; 0 disables dumps)\n")
+		fmt.Fprintf(os.Stderr, "  --full          Hex dump entire messages and bodies\n")
+		fmt.Fprintf(os.Stderr, "  --redact-metadata\n")
+		fmt.Fprintf(os.Stderr, "                  Mask client/server addresses in session metadata\n")
+		fmt.Fprintf(os.Stderr, "                  (10.0.0.5:51807 -> 10.0.0.x:xxxxx)\n")
 		os.Exit(1)
 	}
 
 	filePath := os.Args[1]
 	filter := "all"
-	if len(os.Args) >= 3 {
-		filter = os.Args[2]
+	limits := defaultDumpLimits
+	redactMetadata := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--dump-bytes":
+			if i+1 < len(os.Args) {
+				var n int
+				if _, err := fmt.Sscanf(os.Args[i+1], "%d", &n); err != nil || n < 0 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --dump-bytes '%s'\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				limits = dumpLimits{message: n, bson: n}
+				i++
+			}
+		case "--full":
+			limits = dumpLimits{message: -1, bson: -1}
+		case "--redact-metadata":
+			redactMetadata = true
+		default:
+			filter = os.Args[i]
+		}
 	}
 
 	rec, err := reader.NewRecordingReader(filePath)
@@ -35,6 +62,10 @@ func main() {
 		os.Exit(1)
 	}
 	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
 
 	packetNum := 0
 	shown := 0
@@ -62,7 +93,10 @@ func main() {
 			break
 		}
 
-		printPacket(packet, packetNum)
+		if redactMetadata {
+			packet.SessionMetadata = reader.RedactMetadata(packet.SessionMetadata)
+		}
+		printPacket(packet, packetNum, limits)
 	}
 
 	if shown == 0 {
@@ -103,7 +137,27 @@ func shouldShow(packet *reader.Packet, filter string) bool {
 	return false
 }
 
-func printPacket(packet *reader.Packet, num int) {
+// dumpLimits bounds the hex dumps printed per packet, in bytes (0 = no dump, -1 = everything)
+type dumpLimits struct {
+	// message limits the dump of the whole wire message
+	message int
+
+	// bson limits the dump of each OP_MSG body document
+	bson int
+}
+
+// defaultDumpLimits are the dump sizes used without --dump-bytes or --full
+var defaultDumpLimits = dumpLimits{message: 128, bson: 256}
+
+// dumpLength returns how many of size bytes to dump under limit
+func dumpLength(size, limit int) int {
+	if limit < 0 || size < limit {
+		return size
+	}
+	return limit
+}
+
+func printPacket(packet *reader.Packet, num int, limits dumpLimits) {
 	fmt.Println(strings.Repeat("=", 100))
 	fmt.Printf("PACKET #%d\n", num)
 	fmt.Println(strings.Repeat("=", 100))
@@ -111,7 +165,7 @@ func printPacket(packet *reader.Packet, num int) {
 	fmt.Printf("Size:             %d bytes\n", packet.Size)
 	fmt.Printf("Session ID:       %d\n", packet.SessionID)
 	fmt.Printf("Session Metadata: %s\n", packet.SessionMetadata)
-	fmt.Printf("Offset:           %d μs (%.3f ms from start)\n", packet.Offset, float64(packet.Offset)/1000.0)
+	fmt.Printf("Offset:           %d μs (%s from start)\n", packet.Offset, reader.FormatOffset(packet.Offset))
 	fmt.Printf("Order:            %d\n", packet.Order)
 	fmt.Printf("Message Length:   %d bytes\n", len(packet.Message))
 
@@ -147,7 +201,7 @@ func printPacket(packet *reader.Packet, num int) {
 	// Parse message body based on opcode
 	if opCode == 2013 {
 		// OP_MSG
-		parseOpMsg(packet.Message[16:])
+		parseOpMsg(packet.Message[16:], limits.bson)
 	} else if opCode == 2012 {
 		// OP_COMPRESSED
 		fmt.Println("\n--- Compressed Message ---")
@@ -180,17 +234,19 @@ func printPacket(packet *reader.Packet, num int) {
 	}
 
 	// Show hex dump of first part of message
-	fmt.Println("\n--- Message Hex Dump (first 128 bytes) ---")
-	dumpLen := len(packet.Message)
-	if dumpLen > 128 {
-		dumpLen = 128
+	if dumpLen := dumpLength(len(packet.Message), limits.message); dumpLen > 0 {
+		if dumpLen == len(packet.Message) {
+			fmt.Printf("\n--- Message Hex Dump (%d bytes) ---\n", dumpLen)
+		} else {
+			fmt.Printf("\n--- Message Hex Dump (first %d bytes) ---\n", dumpLen)
+		}
+		fmt.Println(hex.Dump(packet.Message[:dumpLen]))
 	}
-	fmt.Println(hex.Dump(packet.Message[:dumpLen]))
 
 	fmt.Println()
 }
 
-func parseOpMsg(body []byte) {
+func parseOpMsg(body []byte, bsonLimit int) {
 	if len(body) < 5 {
 		fmt.Println("\n--- OP_MSG Body ---")
 		fmt.Println("(Too short)")
@@ -252,14 +308,14 @@ func parseOpMsg(body []byte) {
 				if bsonEnd > len(body) {
 					bsonEnd = len(body)
 				}
-				fmt.Println("  BSON hex:")
-				dumpLen := int(bsonSize)
-				if dumpLen > 256 {
-					dumpLen = 256
-					fmt.Printf("  (showing first 256 of %d bytes)\n", bsonSize)
-				}
-				if offset+dumpLen <= len(body) {
-					fmt.Print(indent(hex.Dump(body[offset:offset+dumpLen]), "    "))
+				if dumpLen := dumpLength(int(bsonSize), bsonLimit); dumpLen > 0 {
+					fmt.Println("  BSON hex:")
+					if dumpLen < int(bsonSize) {
+						fmt.Printf("  (showing first %d of %d bytes)\n", dumpLen, bsonSize)
+					}
+					if offset+dumpLen <= len(body) {
+						fmt.Print(indent(hex.Dump(body[offset:offset+dumpLen]), "    "))
+					}
 				}
 
 				offset = bsonEnd
@@ -269,6 +325,12 @@ func parseOpMsg(body []byte) {
 			if offset+4 < len(body) {
 				seqSize := binary.LittleEndian.Uint32(body[offset : offset+4])
 				fmt.Printf("  Sequence Size: %d bytes\n", seqSize)
+				seqEnd := offset + int(seqSize)
+				if seqEnd > len(body) {
+					fmt.Printf("  (truncated: only %d bytes present)\n", len(body)-offset)
+					seqEnd = len(body)
+				}
+				parseDocumentSequence(body[offset+4:seqEnd], bsonLimit)
 				offset += int(seqSize)
 			}
 		} else {
@@ -284,8 +346,80 @@ func parseOpMsg(body []byte) {
 	}
 }
 
+// maxSequencePreview is how many documents of a kind-1 section are previewed
+const maxSequencePreview = 3
+
+// parseDocumentSequence prints a kind-1 section's identifier and its documents
+// seq is the section after its size field: the identifier cstring, then BSON documents.
+// The first few documents are previewed by size and first field; the first one is
+// also hex dumped, up to bsonLimit bytes.
+func parseDocumentSequence(seq []byte, bsonLimit int) {
+	idEnd := 0
+	for idEnd < len(seq) && seq[idEnd] != 0 {
+		idEnd++
+	}
+	if idEnd >= len(seq) {
+		fmt.Println("  (Identifier not terminated)")
+		return
+	}
+	fmt.Printf("  Identifier: %s\n", seq[:idEnd])
+
+	docs := seq[idEnd+1:]
+	count := 0
+	for pos := 0; pos < len(docs); {
+		if pos+4 > len(docs) {
+			fmt.Printf("  (%d trailing bytes)\n", len(docs)-pos)
+			break
+		}
+		docSize := int(binary.LittleEndian.Uint32(docs[pos : pos+4]))
+		if docSize < 5 || pos+docSize > len(docs) {
+			fmt.Printf("  Document %d: invalid size %d (%d bytes left)\n", count, docSize, len(docs)-pos)
+			break
+		}
+		doc := docs[pos : pos+docSize]
+
+		if count < maxSequencePreview {
+			fmt.Printf("  Document %d: %d bytes", count, docSize)
+			if name, elementType, ok := firstField(doc); ok {
+				fmt.Printf(", first field: %s (type %d)", name, elementType)
+			}
+			fmt.Println()
+			if count == 0 {
+				if dumpLen := dumpLength(docSize, bsonLimit); dumpLen > 0 {
+					if dumpLen < docSize {
+						fmt.Printf("  (showing first %d of %d bytes)\n", dumpLen, docSize)
+					}
+					fmt.Print(indent(hex.Dump(doc[:dumpLen]), "    "))
+				}
+			}
+		}
+		count++
+		pos += docSize
+	}
+
+	if count > maxSequencePreview {
+		fmt.Printf("  ... %d more documents\n", count-maxSequencePreview)
+	}
+	fmt.Printf("  Documents: %d\n", count)
+}
+
+// firstField returns the name and type of a BSON document's first element
+func firstField(doc []byte) (string, byte, bool) {
+	if len(doc) < 6 || doc[4] == 0 {
+		return "", 0, false
+	}
+	nameEnd := 5
+	for nameEnd < len(doc) && doc[nameEnd] != 0 {
+		nameEnd++
+	}
+	if nameEnd >= len(doc) {
+		return "", 0, false
+	}
+	return string(doc[5:nameEnd]), doc[4], true
+}
+
 func extractCommandName(message []byte) string {
-	if len(message) < 21 {
+	if len(message) < reader.MinOpMsgSize {
 		return ""
 	}
 
@@ -294,7 +428,7 @@ func extractCommandName(message []byte) string {
 		return ""
 	}
 
-	offset := 20 // Skip header (16) + flags (4)
+	offset := reader.OpMsgSectionsOffset // Skip header (16) + flags (4)
 	if message[offset] != 0 {
 		return ""
 	}
@@ -304,8 +438,8 @@ func extractCommandName(message []byte) string {
 		return ""
 	}
 
-	offset += 4 // Skip BSON size
-	offset++    // Skip element type
+	offset += reader.BSONLengthSize // Skip BSON size
+	offset++                        // Skip element type
 
 	nameStart := offset
 	for offset < len(message) && message[offset] != 0 {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// authMechanisms are the values accepted by --auth-mechanism
+var authMechanisms = []string{"SCRAM-SHA-1", "SCRAM-SHA-256", "MONGODB-X509", "MONGODB-AWS", "MONGODB-OIDC", "GSSAPI", "PLAIN"}
+
+// connectionOptions are the TLS and auth settings given on the command line, applied on
+// top of each target URI
+type connectionOptions struct {
+	// tlsConfig enables TLS with the given CA and client certificate (nil = as in the URI)
+	tlsConfig *tls.Config
+
+	// authMechanism overrides the URI's authMechanism ("" = as in the URI)
+	authMechanism string
+}
+
+// newConnectionOptions loads the CA and client certificate files and validates the auth mechanism
+// keyFile defaults to certFile, for a PEM file holding both the certificate and its key.
+func newConnectionOptions(caFile, certFile, keyFile, authMechanism string) (connectionOptions, error) {
+	var conn connectionOptions
+
+	if keyFile != "" && certFile == "" {
+		return conn, fmt.Errorf("--tls-key requires --tls-cert")
+	}
+	if caFile != "" || certFile != "" {
+		conn.tlsConfig = &tls.Config{}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return conn, fmt.Errorf("failed to read --tls-ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return conn, fmt.Errorf("no PEM certificates found in --tls-ca file %s", caFile)
+		}
+		conn.tlsConfig.RootCAs = pool
+	}
+	if certFile != "" {
+		if keyFile == "" {
+			keyFile = certFile
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return conn, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		conn.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if authMechanism != "" {
+		valid := false
+		for _, m := range authMechanisms {
+			if strings.EqualFold(authMechanism, m) {
+				authMechanism, valid = m, true
+				break
+			}
+		}
+		if !valid {
+			return conn, fmt.Errorf("invalid auth mechanism '%s'. Must be one of %s", authMechanism, strings.Join(authMechanisms, ", "))
+		}
+		conn.authMechanism = authMechanism
+	}
+
+	return conn, nil
+}
+
+// set reports whether any option was given
+func (c connectionOptions) set() bool {
+	return c.tlsConfig != nil || c.authMechanism != ""
+}
+
+// clientOptions returns the options to connect to uri with, or none if nothing was given
+// The URI's credential (username, password, authSource) is kept when the mechanism is overridden.
+func (c connectionOptions) clientOptions(uri string) []*options.ClientOptions {
+	if !c.set() {
+		return nil
+	}
+	opts := options.Client()
+	if c.tlsConfig != nil {
+		opts.SetTLSConfig(c.tlsConfig)
+	}
+	if c.authMechanism != "" {
+		var cred options.Credential
+		if fromURI := options.Client().ApplyURI(uri).Auth; fromURI != nil {
+			cred = *fromURI
+		}
+		cred.AuthMechanism = c.authMechanism
+		opts.SetAuth(cred)
+	}
+	return []*options.ClientOptions{opts}
+}
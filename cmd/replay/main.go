@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/replay"
 	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 func main() {
@@ -26,8 +31,61 @@ func main() {
 	requestsOnly := false
 	userOpsOnly := false
 	dryRun := false
+	showDrift := false
+	showTiming := false
+	estimate := false
+	showSession := false
+	summaryOnly := false
+	showFailures := false
+	preserveRetryable := false
+	driverHelpers := false
+	preserveAppName := false
+	echoScript := false
+	echoFailures := false
+	assertResponses := false
+	rewriteCursors := false
+	tolerateWriteErrors := false
+	var responseFields replay.ResponseFields
+	responseFieldsArg := ""
+	orderedCompare := false
+	var ignoreFields []string
+	strictOrder := false
+	reverse := false
+	orderBy := replay.OrderByOrder
+	orderWindow := 1024
 	limit := 0
+	repeat := 1
+	seamlessRepeat := false
+	sessionSample := 0.0
+	var injectLatency, injectJitter time.Duration
+	var maxDuration time.Duration
+	var reportInterval time.Duration
+	var seed int64 = 1
 	speed := 1.0 // default: 1x speed (preserve original timing)
+	var startAt time.Time
+	skipPast := false
+	paceByResponse := false
+	var renames []string
+	namespaceMapPath := ""
+	readConcern := ""
+	stripMaxTime := false
+	decompressFallback := false
+	defaultDB := ""
+	injectComment := ""
+	writeConcern := ""
+	targetURIs := []string{mongoURI}
+	routing := string(replay.RouteRoundRobin)
+	onDuplicate := ""
+	blockAggWrites := ""
+	recordResponses := ""
+	summaryJSON := ""
+	adaptConcerns := false
+	warnEncrypted := false
+	shadow := false
+	rawAddr := ""
+	tlsCA, tlsCert, tlsKey, authMechanism := "", "", "", ""
+	var excludeNamespaces []string
+	var categories []string
 
 	for i := 3; i < len(os.Args); i++ {
 		switch os.Args[i] {
@@ -36,22 +94,277 @@ func main() {
 				replayMode = os.Args[i+1]
 				i++
 			}
+		case "--uri":
+			if i+1 < len(os.Args) {
+				targetURIs = append(targetURIs, os.Args[i+1])
+				i++
+			}
+		case "--targets":
+			if i+1 < len(os.Args) {
+				for _, uri := range strings.Split(os.Args[i+1], ",") {
+					if uri = strings.TrimSpace(uri); uri != "" {
+						targetURIs = append(targetURIs, uri)
+					}
+				}
+				i++
+			}
+		case "--route":
+			if i+1 < len(os.Args) {
+				routing = os.Args[i+1]
+				i++
+			}
 		case "--requests-only":
 			requestsOnly = true
 		case "--user-ops":
 			userOpsOnly = true
 		case "--dry-run":
 			dryRun = true
+		case "--show-drift":
+			showDrift = true
+		case "--show-timing":
+			showTiming = true
+		case "--estimate":
+			estimate = true
+		case "--thread-metadata":
+			showSession = true
+		case "--summary-only":
+			summaryOnly = true
+		case "--show-failures":
+			showFailures = true
+		case "--preserve-retryable-writes":
+			preserveRetryable = true
+		case "--driver-helpers":
+			driverHelpers = true
+		case "--preserve-appname":
+			preserveAppName = true
+		case "--echo-script":
+			echoScript = true
+		case "--echo-failures":
+			echoFailures = true
+		case "--assert-responses":
+			assertResponses = true
+		case "--ordered-compare":
+			orderedCompare = true
+		case "--ignore-fields":
+			if i+1 < len(os.Args) {
+				for _, path := range strings.Split(os.Args[i+1], ",") {
+					if path = strings.TrimSpace(path); path != "" {
+						ignoreFields = append(ignoreFields, path)
+					}
+				}
+				i++
+			}
+		case "--rewrite-cursors":
+			rewriteCursors = true
+		case "--tolerate-write-errors":
+			tolerateWriteErrors = true
+		case "--warn-encrypted":
+			warnEncrypted = true
+		case "--shadow":
+			shadow = true
+		case "--on-duplicate":
+			if i+1 < len(os.Args) {
+				onDuplicate = os.Args[i+1]
+				i++
+			}
+		case "--record-responses":
+			if i+1 < len(os.Args) {
+				recordResponses = os.Args[i+1]
+				i++
+			}
+		case "--summary-json":
+			if i+1 < len(os.Args) {
+				summaryJSON = os.Args[i+1]
+				i++
+			}
+		case "--raw-addr":
+			if i+1 < len(os.Args) {
+				rawAddr = os.Args[i+1]
+				i++
+			}
+		case "--adapt-concerns":
+			adaptConcerns = true
+		case "--tls-ca":
+			if i+1 < len(os.Args) {
+				tlsCA = os.Args[i+1]
+				i++
+			}
+		case "--tls-cert":
+			if i+1 < len(os.Args) {
+				tlsCert = os.Args[i+1]
+				i++
+			}
+		case "--tls-key":
+			if i+1 < len(os.Args) {
+				tlsKey = os.Args[i+1]
+				i++
+			}
+		case "--auth-mechanism":
+			if i+1 < len(os.Args) {
+				authMechanism = os.Args[i+1]
+				i++
+			}
+		case "--block-agg-writes":
+			if i+1 < len(os.Args) {
+				blockAggWrites = os.Args[i+1]
+				i++
+			}
+		case "--response-fields":
+			if i+1 < len(os.Args) {
+				fields, err := replay.ParseResponseFields(os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --response-fields '%s': %v\n", os.Args[i+1], err)
+					os.Exit(1)
+				}
+				responseFields = fields
+				responseFieldsArg = os.Args[i+1]
+				i++
+			}
+		case "--strict-order":
+			strictOrder = true
+		case "--reverse":
+			reverse = true
+		case "--order-by":
+			if i+1 < len(os.Args) {
+				by, err := replay.ParseOrderBy(os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				orderBy = by
+				strictOrder = true // --order-by sequences through the strict-order window
+				i++
+			}
+		case "--order-window":
+			if i+1 < len(os.Args) {
+				fmt.Sscanf(os.Args[i+1], "%d", &orderWindow)
+				i++
+			}
 		case "--limit":
 			if i+1 < len(os.Args) {
 				fmt.Sscanf(os.Args[i+1], "%d", &limit)
 				i++
 			}
+		case "--repeat":
+			if i+1 < len(os.Args) {
+				if _, err := fmt.Sscanf(os.Args[i+1], "%d", &repeat); err != nil || repeat < 1 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --repeat '%s'. Expected a positive count\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				i++
+			}
+		case "--seamless-repeat":
+			seamlessRepeat = true
+		case "--session-sample":
+			if i+1 < len(os.Args) {
+				if _, err := fmt.Sscanf(os.Args[i+1], "%f", &sessionSample); err != nil || sessionSample <= 0 || sessionSample > 1 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --session-sample '%s' (must be a fraction in (0, 1], e.g. 0.1)\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				i++
+			}
 		case "--speed":
 			if i+1 < len(os.Args) {
 				fmt.Sscanf(os.Args[i+1], "%f", &speed)
 				i++
 			}
+		case "--start-at":
+			if i+1 < len(os.Args) {
+				t, err := time.Parse(time.RFC3339Nano, os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --start-at '%s' (want RFC 3339, e.g. 2024-01-01T09:00:00Z): %v\n", os.Args[i+1], err)
+					os.Exit(1)
+				}
+				startAt = t
+				i++
+			}
+		case "--skip-past":
+			skipPast = true
+		case "--pace-by-response":
+			paceByResponse = true
+		case "--max-duration":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --max-duration '%s': %v\n", os.Args[i+1], err)
+					os.Exit(1)
+				}
+				maxDuration = d
+				i++
+			}
+		case "--report-interval":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil || d <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: Invalid --report-interval '%s' (must be a positive duration like 10s)\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				reportInterval = d
+				i++
+			}
+		case "--inject-latency", "--inject-jitter":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Invalid %s '%s': %v\n", os.Args[i], os.Args[i+1], err)
+					os.Exit(1)
+				}
+				if os.Args[i] == "--inject-latency" {
+					injectLatency = d
+				} else {
+					injectJitter = d
+				}
+				i++
+			}
+		case "--seed":
+			if i+1 < len(os.Args) {
+				fmt.Sscanf(os.Args[i+1], "%d", &seed)
+				i++
+			}
+		case "--rename-ns":
+			if i+1 < len(os.Args) {
+				renames = append(renames, os.Args[i+1])
+				i++
+			}
+		case "--namespace-map":
+			if i+1 < len(os.Args) {
+				namespaceMapPath = os.Args[i+1]
+				i++
+			}
+		case "--exclude-namespace":
+			if i+1 < len(os.Args) {
+				excludeNamespaces = append(excludeNamespaces, os.Args[i+1])
+				i++
+			}
+		case "--categories":
+			if i+1 < len(os.Args) {
+				categories = strings.Split(os.Args[i+1], ",")
+				i++
+			}
+		case "--default-db":
+			if i+1 < len(os.Args) {
+				defaultDB = os.Args[i+1]
+				i++
+			}
+		case "--strip-max-time":
+			stripMaxTime = true
+		case "--decompress-fallback":
+			decompressFallback = true
+		case "--inject-comment":
+			if i+1 < len(os.Args) {
+				injectComment = os.Args[i+1]
+				i++
+			}
+		case "--read-concern":
+			if i+1 < len(os.Args) {
+				readConcern = os.Args[i+1]
+				i++
+			}
+		case "--write-concern":
+			if i+1 < len(os.Args) {
+				writeConcern = os.Args[i+1]
+				i++
+			}
 		}
 	}
 
@@ -61,6 +374,23 @@ func main() {
 		os.Exit(1)
 	}
 
+	if estimate {
+		if paceByResponse {
+			fmt.Fprintf(os.Stderr, "Error: --estimate cannot be combined with --pace-by-response (think times are paced from the target's responses)\n")
+			os.Exit(1)
+		}
+		if !startAt.IsZero() {
+			fmt.Fprintf(os.Stderr, "Error: --estimate cannot be combined with --start-at\n")
+			os.Exit(1)
+		}
+		if recordResponses != "" {
+			fmt.Fprintf(os.Stderr, "Error: --estimate cannot be combined with --record-responses\n")
+			os.Exit(1)
+		}
+		// An estimate is a quiet dry run that plans each op's sleep instead of taking it
+		dryRun, showTiming, summaryOnly = true, true, true
+	}
+
 	// Open recording file
 	rec, err := reader.NewRecordingReader(filePath)
 	if err != nil {
@@ -68,6 +398,145 @@ func main() {
 		os.Exit(1)
 	}
 	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
+
+	// Wrap in an order-enforcing window if requested, then reverse the result
+	orderSource := func(rec *reader.RecordingReader) (replay.PacketSource, error) {
+		var src replay.PacketSource = rec
+		if strictOrder {
+			ordered, err := replay.NewOrderedSourceBy(rec, orderWindow, orderBy)
+			if err != nil {
+				return nil, err
+			}
+			src = ordered
+		}
+		if reverse {
+			return replay.NewReverseSource(src), nil
+		}
+		return src, nil
+	}
+	src, err := orderSource(rec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if recordResponses != "" && (replayMode != "raw" || dryRun) {
+		fmt.Fprintf(os.Stderr, "Error: --record-responses requires raw mode without --dry-run\n")
+		os.Exit(1)
+	}
+	if recordResponses == filePath {
+		fmt.Fprintf(os.Stderr, "Error: --record-responses would overwrite the recording being replayed\n")
+		os.Exit(1)
+	}
+	if summaryJSON != "" && summaryJSON == filePath {
+		fmt.Fprintf(os.Stderr, "Error: --summary-json would overwrite the recording being replayed\n")
+		os.Exit(1)
+	}
+	if recordResponses != "" {
+		// Recorded responses are replaced by the target's, never sent
+		requestsOnly = true
+	}
+
+	var namespaces *reader.NamespaceFilter
+	if len(excludeNamespaces) > 0 {
+		namespaces, err = reader.NewNamespaceFilter(nil, excludeNamespaces)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --exclude-namespace: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var commandFilter reader.Filter
+	if len(categories) > 0 {
+		commandFilter, err = reader.CategoryFilter(categories...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --categories: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if skipPast && startAt.IsZero() {
+		fmt.Fprintf(os.Stderr, "Error: --skip-past requires --start-at\n")
+		os.Exit(1)
+	}
+	if paceByResponse && speed <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --pace-by-response requires paced replay (--speed > 0)\n")
+		os.Exit(1)
+	}
+
+	// Loop the recording, reopening it for each pass
+	if seamlessRepeat && repeat < 2 {
+		fmt.Fprintf(os.Stderr, "Error: --seamless-repeat requires --repeat N with N >= 2\n")
+		os.Exit(1)
+	}
+	if repeat > 1 {
+		reopen := func() (replay.PacketSource, error) {
+			rec, err := reader.NewRecordingReader(filePath)
+			if err != nil {
+				return nil, err
+			}
+			return orderSource(rec)
+		}
+		repeated, err := replay.NewRepeatSource(src, reopen, repeat, seamlessRepeat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		src = repeated
+	}
+
+	// Pre-scan: index recorded responses by the request they answer
+	var responseIndex replay.ResponseIndex
+	if responseFields != 0 && !assertResponses {
+		fmt.Fprintf(os.Stderr, "Error: --response-fields requires --assert-responses\n")
+		os.Exit(1)
+	}
+	if orderedCompare {
+		if !assertResponses {
+			fmt.Fprintf(os.Stderr, "Error: --ordered-compare requires --assert-responses\n")
+			os.Exit(1)
+		}
+		// Order is compared by _id, so _ids are summarized even if --response-fields left them out
+		if responseFields == 0 {
+			responseFields = replay.DefaultResponseFields
+		}
+		responseFields |= replay.FieldIDs | replay.FieldOrder
+	}
+	if len(ignoreFields) > 0 {
+		if !assertResponses {
+			fmt.Fprintf(os.Stderr, "Error: --ignore-fields requires --assert-responses\n")
+			os.Exit(1)
+		}
+		// Ignored fields only matter when whole response bodies are compared
+		if responseFields == 0 {
+			responseFields = replay.DefaultResponseFields
+		}
+		responseFields |= replay.FieldBody
+	}
+	if assertResponses || rewriteCursors {
+		scan, err := reader.NewRecordingReader(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+			os.Exit(1)
+		}
+		// Cursor rewriting only needs cursor ids, which every summary keeps
+		indexFields := responseFields
+		if !assertResponses {
+			indexFields = replay.FieldOK
+		}
+		responseIndex, err = replay.IndexResponsesIgnoring(scan, indexFields, ignoreFields)
+		scan.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error indexing recorded responses: %v\n", err)
+			os.Exit(1)
+		}
+		// Responses are compared or paired with live ones, never replayed
+		requestsOnly = true
+	}
 
 	// Print header
 	fmt.Printf("Replay Mode: %s\n", strings.ToUpper(replayMode))
@@ -78,286 +547,709 @@ func main() {
 	if userOpsOnly {
 		fmt.Println("Filter: User operations only")
 	}
+	if len(excludeNamespaces) > 0 {
+		fmt.Printf("Filter: Excluding namespaces %s\n", strings.Join(excludeNamespaces, ", "))
+	}
+	if len(categories) > 0 {
+		fmt.Printf("Filter: Command categories %s\n", strings.Join(categories, ", "))
+	}
 	if limit > 0 {
 		fmt.Printf("Limit: %d operations\n", limit)
 	}
+	if repeat > 1 {
+		if seamlessRepeat {
+			fmt.Printf("Repeat: %d passes, seamless (each pass follows the previous one's last op)\n", repeat)
+		} else {
+			fmt.Printf("Repeat: %d passes (each pass restarts the recorded timeline)\n", repeat)
+		}
+	}
+	if sessionSample > 0 {
+		fmt.Printf("Session sample: %.1f%% of sessions (chosen by session ID hash)\n", sessionSample*100)
+	}
+	if maxDuration > 0 {
+		fmt.Printf("Max duration: %v\n", maxDuration)
+	}
+	if reportInterval > 0 {
+		fmt.Printf("Interval reports: every %v\n", reportInterval)
+	}
+	if assertResponses {
+		fmt.Printf("Assert responses: %d recorded responses indexed\n", len(responseIndex))
+		if responseFieldsArg != "" {
+			fmt.Printf("Response fields: %s\n", responseFieldsArg)
+		}
+		if orderedCompare {
+			fmt.Println("Ordered compare: cursor documents must come back in the recorded order")
+		}
+		if len(ignoreFields) > 0 {
+			fmt.Printf("Ignored fields: %s (plus %s)\n", strings.Join(ignoreFields, ", "), strings.Join(sender.DefaultVolatileFields, ", "))
+		}
+	}
+	if recordResponses != "" {
+		fmt.Printf("Record responses: %s (each request with the target's response)\n", recordResponses)
+	}
+	if rewriteCursors {
+		fmt.Printf("Cursor rewriting: %d recorded responses indexed, one session per recorded session\n", len(responseIndex))
+	}
+	if injectLatency > 0 || injectJitter > 0 {
+		fmt.Printf("Injected latency: %v ± %v (seed %d)\n", injectLatency, injectJitter, seed)
+	}
+	if strictOrder {
+		fmt.Printf("Ordering: strict by %s (window of %d packets)\n", orderBy, orderWindow)
+	}
+	if reverse {
+		fmt.Println("Ordering: reversed, last packet first (the whole recording is read into memory)")
+	}
+	if len(targetURIs) > 1 {
+		fmt.Printf("Targets: %d (%s routing)\n", len(targetURIs), routing)
+	}
 	if speed == 0 {
 		fmt.Println("Speed: Fast-forward (no delays)")
 	} else {
-		fmt.Printf("Speed: %.1fx\n", speed)
+		fmt.Printf("Speed: %.1fx", speed)
+		if paceByResponse {
+			fmt.Print(", paced by recorded response (think time)")
+		}
+		fmt.Println()
 	}
-
-	// Replay based on mode
-	if replayMode == "raw" {
-		runRawMode(rec, mongoURI, requestsOnly, userOpsOnly, dryRun, limit, speed)
-	} else {
-		runCommandMode(rec, mongoURI, requestsOnly, userOpsOnly, dryRun, limit, speed)
+	if !startAt.IsZero() {
+		fmt.Printf("Start at: %s", startAt.Format(time.RFC3339Nano))
+		if recorded := rec.StartTime(); !recorded.IsZero() {
+			fmt.Printf(" (recorded start %s, shifted by %v)", recorded.UTC().Format(time.RFC3339Nano), startAt.Sub(recorded).Round(time.Second))
+		}
+		if skipPast {
+			fmt.Print(", skipping past-due ops")
+		}
+		fmt.Println()
 	}
-}
-
-func runRawMode(rec *reader.RecordingReader, mongoURI string, requestsOnly, userOpsOnly, dryRun bool, limit int, speed float64) {
-	ctx := context.Background()
 
-	// Connect to MongoDB (unless dry-run)
-	var rawSender *sender.RawSender
-	if !dryRun {
-		var err error
-		rawSender, err = sender.NewRawSender(ctx, mongoURI)
+	// Build command transforms from flags
+	var transforms []replay.TransformFunc
+	for _, rename := range renames {
+		from, to, ok := strings.Cut(rename, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --rename-ns '%s'. Expected FROM=TO\n", rename)
+			os.Exit(1)
+		}
+		transform, err := replay.RenameNamespace(from, to)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error connecting to MongoDB: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		defer rawSender.Close()
-		fmt.Printf("Connected to MongoDB at %s (raw mode)\n", mongoURI)
-	} else {
-		fmt.Println("DRY RUN MODE - Wire messages will be validated but not sent")
+		transforms = append(transforms, transform)
+		fmt.Printf("Rename: %s -> %s\n", from, to)
 	}
-	fmt.Println()
-
-	// Statistics
-	totalPackets := 0
-	skippedPackets := 0
-	successfulOps := 0
-	failedOps := 0
-	wallClockStart := time.Now()
-
-	// Timing state for speed control
-	var replayStartTime time.Time
-	var replayEndTime time.Time
-	var firstOffset uint64
-	var lastOffset uint64
-	firstOp := true
-
-	// Replay loop
-	for {
-		packet, err := rec.Next()
-		if err == io.EOF {
-			break
-		}
+	if namespaceMapPath != "" {
+		nsMap, err := replay.LoadNamespaceMap(namespaceMapPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading packet: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		transforms = append(transforms, nsMap.Transform())
+		fmt.Printf("Namespace map: %d mappings from %s\n", nsMap.Len(), namespaceMapPath)
+	}
+	if stripMaxTime {
+		transforms = append(transforms, replay.StripMaxTime())
+		fmt.Println("maxTimeMS: stripped")
+	}
+	if injectComment != "" {
+		transforms = append(transforms, replay.InjectComment(injectComment))
+		fmt.Printf("Comment: %q\n", injectComment)
+	}
+	if readConcern != "" {
+		transforms = append(transforms, replay.OverrideReadConcern(readConcern))
+		fmt.Printf("Read concern: %s\n", readConcern)
+	}
+	if writeConcern != "" {
+		var w interface{} = writeConcern
+		var n int
+		if _, err := fmt.Sscanf(writeConcern, "%d", &n); err == nil {
+			w = n
+		}
+		transforms = append(transforms, replay.OverrideWriteConcern(bson.M{"w": w}))
+		fmt.Printf("Write concern: w=%v\n", w)
+	}
+	if preserveRetryable {
+		fmt.Println("Retryable writes: preserving lsid/txnNumber")
+	}
+	if tolerateWriteErrors {
+		fmt.Println("Write errors: partially failed batches count as successful")
+	}
+	if warnEncrypted {
+		fmt.Println("Encrypted fields: ops carrying CSFLE ciphertext are flagged")
+	}
+	if defaultDB != "" {
+		fmt.Printf("Default database: %s (for commands without $db)\n", defaultDB)
+	}
+	if decompressFallback {
+		fmt.Println("Compressed ops: resent decompressed as commands if the raw send fails")
+	}
+	if preserveRetryable && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --preserve-retryable-writes requires --mode command (raw mode always sends lsid/txnNumber)\n")
+		os.Exit(1)
+	}
+	if driverHelpers && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --driver-helpers requires --mode command\n")
+		os.Exit(1)
+	}
+	if preserveAppName && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --preserve-appname requires --mode command\n")
+		os.Exit(1)
+	}
+	if preserveAppName && len(targetURIs) > 1 {
+		fmt.Fprintf(os.Stderr, "Error: --preserve-appname cannot be combined with multiple targets\n")
+		os.Exit(1)
+	}
+	if reverse {
+		// Reversed, getMores come before the commands that opened their cursors and
+		// responses before their requests
+		if rewriteCursors {
+			fmt.Fprintf(os.Stderr, "Error: --reverse cannot be combined with --rewrite-cursors\n")
+			os.Exit(1)
+		}
+		if paceByResponse {
+			fmt.Fprintf(os.Stderr, "Error: --reverse cannot be combined with --pace-by-response\n")
 			os.Exit(1)
 		}
+		if !startAt.IsZero() {
+			fmt.Fprintf(os.Stderr, "Error: --reverse cannot be combined with --start-at (reversed offsets no longer match the capture's clock)\n")
+			os.Exit(1)
+		}
+	}
+	if rewriteCursors && len(targetURIs) > 1 && routing != string(replay.RouteSession) {
+		fmt.Fprintf(os.Stderr, "Error: --rewrite-cursors with multiple targets requires --route session\n")
+		os.Exit(1)
+	}
+	if showTiming && !dryRun {
+		fmt.Fprintf(os.Stderr, "Error: --show-timing requires --dry-run\n")
+		os.Exit(1)
+	}
+	if showTiming && paceByResponse {
+		fmt.Fprintf(os.Stderr, "Error: --show-timing cannot be combined with --pace-by-response\n")
+		os.Exit(1)
+	}
+	if decompressFallback && replayMode != "raw" {
+		fmt.Fprintf(os.Stderr, "Error: --decompress-fallback requires --mode raw\n")
+		os.Exit(1)
+	}
+	if defaultDB != "" && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --default-db requires --mode command\n")
+		os.Exit(1)
+	}
+	if tolerateWriteErrors && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --tolerate-write-errors requires --mode command\n")
+		os.Exit(1)
+	}
+	if onDuplicate != "" && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --on-duplicate requires --mode command\n")
+		os.Exit(1)
+	}
+	if blockAggWrites != "" && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --block-agg-writes requires --mode command\n")
+		os.Exit(1)
+	}
+	if rawAddr != "" && replayMode != "raw" {
+		fmt.Fprintf(os.Stderr, "Error: --raw-addr requires --mode raw\n")
+		os.Exit(1)
+	}
+	if rawAddr != "" && (len(targetURIs) > 1 || decompressFallback) {
+		fmt.Fprintf(os.Stderr, "Error: --raw-addr cannot be combined with several targets or --decompress-fallback\n")
+		os.Exit(1)
+	}
+	connection, err := newConnectionOptions(tlsCA, tlsCert, tlsKey, authMechanism)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if rawAddr != "" && connection.set() {
+		fmt.Fprintf(os.Stderr, "Error: --raw-addr connects without TLS or auth; it cannot be combined with --tls-* or --auth-mechanism\n")
+		os.Exit(1)
+	}
+	if tlsCA != "" {
+		fmt.Printf("TLS CA file: %s\n", tlsCA)
+	}
+	if tlsCert != "" {
+		fmt.Printf("TLS client certificate: %s\n", tlsCert)
+	}
+	if connection.authMechanism != "" {
+		fmt.Printf("Auth mechanism: %s\n", connection.authMechanism)
+	}
+	if adaptConcerns && (replayMode != "command" || dryRun) {
+		fmt.Fprintf(os.Stderr, "Error: --adapt-concerns requires --mode command without --dry-run (the target's topology is detected on connect)\n")
+		os.Exit(1)
+	}
+	if shadow && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --shadow requires --mode command\n")
+		os.Exit(1)
+	}
+	if shadow {
+		fmt.Printf("Shadow mode: only reads are sent (%s); writes and DDL are skipped\n", strings.Join(replay.ShadowReadCommands, ", "))
+	}
+	if rewriteCursors && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --rewrite-cursors requires --mode command\n")
+		os.Exit(1)
+	}
+	var recordedResponses, cursorResponses replay.ResponseIndex
+	if assertResponses {
+		recordedResponses = responseIndex
+	}
+	if rewriteCursors {
+		cursorResponses = responseIndex
+	}
+	if (echoScript || echoFailures) && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --echo-script and --echo-failures require --mode command\n")
+		os.Exit(1)
+	}
+	var scriptOutput io.Writer
+	if echoScript || echoFailures {
+		scriptOutput = os.Stderr
+	}
+	if len(transforms) > 0 && replayMode != "command" {
+		fmt.Fprintf(os.Stderr, "Error: --rename-ns, --namespace-map, --strip-max-time, --inject-comment, --read-concern and --write-concern require --mode command\n")
+		os.Exit(1)
+	}
 
-		totalPackets++
+	runReplay(src, targetURIs, senderOptions{driverHelpers: driverHelpers, preserveAppName: preserveAppName, decompressFallback: decompressFallback, recordResponses: recordResponses, summaryJSON: summaryJSON, estimate: estimate, adaptConcerns: adaptConcerns, rawAddr: rawAddr, connection: connection}, replay.Config{
+		Mode:           replay.Mode(replayMode),
+		RequestsOnly:   requestsOnly,
+		UserOpsOnly:    userOpsOnly,
+		Namespaces:     namespaces,
+		CommandFilter:  commandFilter,
+		DryRun:         dryRun,
+		ShowDrift:      showDrift,
+		ShowTiming:     showTiming,
+		ShowSession:    showSession,
+		Limit:          limit,
+		SessionSample:  sessionSample,
+		MaxDuration:    maxDuration,
+		ReportInterval: reportInterval,
+		Speed:          speed,
+		StartAt:        startAt,
+		SkipPast:       skipPast,
+		PaceByResponse: paceByResponse,
+		Routing:        replay.Routing(routing),
 
-		// Apply filters
-		if requestsOnly && !packet.IsRequest() {
-			skippedPackets++
-			continue
-		}
+		DecompressFallback: decompressFallback,
 
-		if userOpsOnly && !packet.IsLikelyUserOperation() {
-			skippedPackets++
-			continue
-		}
+		InjectLatency: injectLatency,
+		InjectJitter:  injectJitter,
+		Seed:          seed,
 
-		// Check if packet has a wire message
-		if len(packet.Message) == 0 {
-			skippedPackets++
-			continue
-		}
+		SummaryOnly:             summaryOnly,
+		ShowFailures:            showFailures,
+		PreserveRetryableWrites: preserveRetryable,
+		DefaultDatabase:         defaultDB,
+		TolerateWriteErrors:     tolerateWriteErrors,
+		WarnEncrypted:           warnEncrypted,
+		Shadow:                  shadow,
+		OnDuplicate:             replay.DuplicatePolicy(onDuplicate),
+		BlockAggWrites:          replay.AggWritePolicy(blockAggWrites),
+		RecordedResponses:       recordedResponses,
+		ResponseFields:          responseFields,
+		IgnoreFields:            ignoreFields,
+		CursorResponses:         cursorResponses,
+		Transforms:              transforms,
+		Output:                  os.Stdout,
+		ScriptOutput:            scriptOutput,
+		ScriptFailuresOnly:      echoFailures && !echoScript,
+	})
+}
 
-		// Check limit
-		if limit > 0 && (successfulOps+failedOps) >= limit {
-			fmt.Printf("\nReached limit of %d operations\n", limit)
-			break
-		}
+// senderOptions configures the command-mode senders created by runReplay
+type senderOptions struct {
+	// driverHelpers sends CRUD commands through typed driver helpers
+	driverHelpers bool
+
+	// preserveAppName connects a separate client per recorded appName
+	preserveAppName bool
+
+	// decompressFallback also connects a command sender per target in raw mode,
+	// for OP_COMPRESSED ops the target rejects
+	decompressFallback bool
+
+	// recordResponses is the path of a new recording to write each request and the
+	// target's response to ("" = off)
+	recordResponses string
 
-		// Timing logic (unless speed is 0 for fast-forward)
-		if speed > 0 {
-			if firstOp {
-				replayStartTime = time.Now()
-				firstOffset = packet.Offset
-				firstOp = false
+	// summaryJSON is the path to write the run's summary to as JSON ("" = off)
+	summaryJSON string
+
+	// estimate prints the projected duration and op count of a dry run instead of
+	// the replay summary
+	estimate bool
+
+	// adaptConcerns detects the targets' topology and downgrades recorded read/write
+	// concerns it would reject
+	adaptConcerns bool
+
+	// rawAddr sends raw mode messages over a plain TCP connection to this host:port
+	// instead of through the driver (no auth or TLS)
+	rawAddr string
+
+	// connection holds the TLS and auth options applied to every target URI
+	connection connectionOptions
+}
+
+func runReplay(src replay.PacketSource, mongoURIs []string, senderOpts senderOptions, config replay.Config) {
+	ctx := context.Background()
+	mongoURI := mongoURIs[0]
+	var adapter *replay.ConcernAdapter
+
+	// Connect to MongoDB (unless dry-run), with one sender per target URI
+	if !config.DryRun {
+		for _, uri := range mongoURIs {
+			target := replay.Target{Name: uri}
+			if config.Mode == replay.ModeRaw && senderOpts.rawAddr != "" {
+				connSender, err := sender.DialConnSender(ctx, senderOpts.rawAddr)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				defer connSender.Close()
+				fmt.Printf("Connected to %s over plain TCP (raw mode; no auth, TLS or server selection)\n", senderOpts.rawAddr)
+				config.Targets = append(config.Targets, replay.Target{Name: senderOpts.rawAddr, RawSender: connSender})
+				continue
+			}
+			if config.Mode == replay.ModeRaw {
+				rawSender, err := sender.NewRawSender(ctx, uri, senderOpts.connection.clientOptions(uri)...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting to MongoDB at %s: %v\n", uri, err)
+					os.Exit(1)
+				}
+				defer rawSender.Close()
+				target.RawSender = rawSender
+				if senderOpts.decompressFallback {
+					snd, err := sender.New(ctx, uri, senderOpts.connection.clientOptions(uri)...)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error connecting to MongoDB at %s: %v\n", uri, err)
+						os.Exit(1)
+					}
+					defer snd.Close()
+					target.CommandSender = snd
+				}
 			} else {
-				// Calculate target time based on recording offset
-				elapsedInRecording := packet.Offset - firstOffset // microseconds
-				targetElapsed := time.Duration(float64(elapsedInRecording)/speed) * time.Microsecond
-				targetTime := replayStartTime.Add(targetElapsed)
-
-				// Sleep until target time (if we're ahead of schedule)
-				if sleepDuration := time.Until(targetTime); sleepDuration > 0 {
-					time.Sleep(sleepDuration)
+				snd, err := sender.New(ctx, uri, senderOpts.connection.clientOptions(uri)...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting to MongoDB at %s: %v\n", uri, err)
+					os.Exit(1)
 				}
+				defer snd.Close()
+				snd.UseDriverHelpers(senderOpts.driverHelpers)
+				target.CommandSender = snd
 			}
+			fmt.Printf("Connected to MongoDB at %s (%s mode)\n", uri, config.Mode)
+			config.Targets = append(config.Targets, target)
+		}
+		if senderOpts.adaptConcerns {
+			adapter = concernAdapter(config.Targets)
+			config.Transforms = append(config.Transforms, adapter.Transform())
+		}
+		if len(config.Targets) == 1 {
+			config.RawSender = config.Targets[0].RawSender
+			config.CommandSender = config.Targets[0].CommandSender
+			config.Targets = nil
 		}
 
-		// Send raw wire message (or just validate in dry-run mode)
-		if dryRun {
-			// Just validate the wire message header
-			cmd := packet.ExtractCommandName()
-			db := packet.ExtractDatabase()
-			fmt.Printf("[DRY RUN] %s.%s (raw wire message, %d bytes)\n", db, cmd, len(packet.Message))
-			successfulOps++
+		if config.Mode == replay.ModeCommand {
+			if senderOpts.preserveAppName {
+				var appSenders []*sender.Sender
+				defer func() {
+					for _, s := range appSenders {
+						s.Close()
+					}
+				}()
+				config.CommandSenderForApp = func(appName string) (replay.CommandSender, error) {
+					opts := append(senderOpts.connection.clientOptions(mongoURI), options.Client().SetAppName(appName))
+					s, err := sender.New(ctx, mongoURI, opts...)
+					if err != nil {
+						return nil, err
+					}
+					s.UseDriverHelpers(senderOpts.driverHelpers)
+					appSenders = append(appSenders, s)
+					fmt.Printf("Connected to MongoDB as appName %q\n", appName)
+					return s, nil
+				}
+			}
+		}
+		if senderOpts.driverHelpers {
+			fmt.Println("Sending insert/find/update/delete/aggregate through driver helpers")
+		}
+	} else {
+		if senderOpts.estimate {
+			fmt.Println("ESTIMATE MODE - Timing will be planned at the replay speed but nothing sent")
+		} else if config.Mode == replay.ModeRaw {
+			fmt.Println("DRY RUN MODE - Wire messages will be validated but not sent")
 		} else {
-			result, err := rawSender.SendRawWireMessage(ctx, packet.Message)
-			if err != nil {
-				cmd := packet.ExtractCommandName()
-				db := packet.ExtractDatabase()
-				fmt.Printf("❌ FAILED: %s.%s - %v\n", db, cmd, err)
-				failedOps++
-			} else {
-				fmt.Printf("✓ %s (reqID=%d, took %v)\n", result.OpCode.String(), result.RequestID, result.Duration)
-				successfulOps++
+			fmt.Println("DRY RUN MODE - Commands will be parsed but not sent")
+		}
+		if len(mongoURIs) > 1 {
+			// Nothing is sent, but ops are still routed and counted per target
+			for _, uri := range mongoURIs {
+				config.Targets = append(config.Targets, replay.Target{Name: uri})
 			}
 		}
-
-		// Track timing for last processed operation
-		lastOffset = packet.Offset
-		replayEndTime = time.Now()
 	}
+	fmt.Println()
 
-	printSummary(totalPackets, skippedPackets, successfulOps, failedOps, time.Since(wallClockStart), firstOffset, lastOffset, replayStartTime, replayEndTime, speed)
+	var recorder *reader.RecordingWriter
+	if senderOpts.recordResponses != "" {
+		w, err := reader.NewRecordingWriter(senderOpts.recordResponses, reader.WriterOptions{StartTime: time.Now()})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		recorder = w
+		config.RecordResponses = w
+	}
 
-	if failedOps > 0 {
+	config.LiveStats = replay.NewReplayStats()
+	replayer, err := replay.New(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-}
 
-func runCommandMode(rec *reader.RecordingReader, mongoURI string, requestsOnly, userOpsOnly, dryRun bool, limit int, speed float64) {
-	ctx := context.Background()
+	// Ctrl-C stops the replay and still prints the summary
+	runCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
 
-	// Connect to MongoDB (unless dry-run)
-	var snd *sender.Sender
-	if !dryRun {
-		var err error
-		snd, err = sender.New(ctx, mongoURI)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error connecting to MongoDB: %v\n", err)
-			os.Exit(1)
+	stats, err := replayer.Run(runCtx, src)
+	if recorder != nil {
+		// Close even after a failed run so the exchanges written so far are kept
+		if closeErr := recorder.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
-		defer snd.Close()
-		fmt.Printf("Connected to MongoDB at %s (command mode)\n", mongoURI)
-	} else {
-		fmt.Println("DRY RUN MODE - Commands will be parsed but not sent")
 	}
-	fmt.Println()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
-	// Statistics
-	totalPackets := 0
-	skippedPackets := 0
-	successfulOps := 0
-	failedOps := 0
-	wallClockStart := time.Now()
-
-	// Timing state for speed control
-	var replayStartTime time.Time
-	var replayEndTime time.Time
-	var firstOffset uint64
-	var lastOffset uint64
-	firstOp := true
-
-	// Replay loop
-	for {
-		packet, err := rec.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading packet: %v\n", err)
+	if senderOpts.estimate {
+		printEstimate(stats)
+	} else {
+		printSummary(stats, config.LiveStats.Snapshot())
+	}
+	if config.Shadow && !senderOpts.estimate {
+		printReadLatency(config.LiveStats.Snapshot())
+	}
+	if adapter != nil {
+		printConcernDowngrades(adapter.Downgrades())
+	}
+	if senderOpts.summaryJSON != "" {
+		if err := writeSummaryJSON(senderOpts.summaryJSON, replay.NewReplaySummary(stats, config.LiveStats.Snapshot())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("\nSummary written to %s\n", senderOpts.summaryJSON)
+	}
 
-		totalPackets++
+	if stats.FailedOps > 0 {
+		os.Exit(1)
+	}
+}
 
-		// Apply filters
-		if requestsOnly && !packet.IsRequest() {
-			skippedPackets++
-			continue
-		}
+// writeSummaryJSON writes the run's summary as JSON to path
+func writeSummaryJSON(path string, summary replay.ReplaySummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create summary file: %w", err)
+	}
+	if err := summary.WriteJSON(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
 
-		if userOpsOnly && !packet.IsLikelyUserOperation() {
-			skippedPackets++
-			continue
+// printReadLatency prints the latency percentiles of the reads sent in shadow mode
+func printReadLatency(live replay.StatsSnapshot) {
+	all := live.Latencies(replay.ShadowReadCommands...)
+	if all.Count() == 0 {
+		return
+	}
+	fmt.Println("\nRead latency (shadow mode):")
+	line := func(name string, h replay.LatencyHistogram) {
+		fmt.Printf("  %-12s %8d ops  p50 %-10v p95 %-10v p99 %-10v max %v\n",
+			name, h.Count(), h.Percentile(50), h.Percentile(95), h.Percentile(99), h.Max())
+	}
+	for _, name := range replay.ShadowReadCommands {
+		if h := live.Latencies(name); h.Count() > 0 {
+			line(name, h)
 		}
+	}
+	line("all reads", all)
+	fmt.Println("  (percentiles are upper bounds, within about 6%)")
+}
 
-		// Extract command
-		cmd, err := sender.ExtractCommand(packet)
-		if err != nil {
-			// Skip packets that can't be parsed
-			skippedPackets++
+// concernAdapter detects the targets' topology and returns an adapter for it
+// With several targets, concerns are adapted for the most restrictive one (a standalone).
+func concernAdapter(targets []replay.Target) *replay.ConcernAdapter {
+	topology := sender.TopologyReplicaSet
+	for _, t := range targets {
+		snd, ok := t.CommandSender.(*sender.Sender)
+		if !ok {
 			continue
 		}
-
-		// Check limit
-		if limit > 0 && (successfulOps+failedOps) >= limit {
-			fmt.Printf("\nReached limit of %d operations\n", limit)
-			break
-		}
-
-		// Timing logic (unless speed is 0 for fast-forward)
-		if speed > 0 {
-			if firstOp {
-				replayStartTime = time.Now()
-				firstOffset = packet.Offset
-				firstOp = false
-			} else {
-				// Calculate target time based on recording offset
-				elapsedInRecording := packet.Offset - firstOffset // microseconds
-				targetElapsed := time.Duration(float64(elapsedInRecording)/speed) * time.Microsecond
-				targetTime := replayStartTime.Add(targetElapsed)
-
-				// Sleep until target time (if we're ahead of schedule)
-				if sleepDuration := time.Until(targetTime); sleepDuration > 0 {
-					time.Sleep(sleepDuration)
-				}
-			}
+		detected, err := snd.DetectTopology()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error detecting topology of %s: %v\n", t.Name, err)
+			os.Exit(1)
 		}
-
-		// Send command (or just print in dry-run mode)
-		if dryRun {
-			fmt.Printf("[DRY RUN] %s.%s\n", cmd.Database, cmd.Name)
-			successfulOps++
-		} else {
-			result, err := snd.SendCommand(cmd.Database, cmd.Document)
-			if err != nil {
-				fmt.Printf("❌ FAILED: %s.%s - %v\n", cmd.Database, cmd.Name, err)
-				failedOps++
-			} else if !result.IsOK() {
-				fmt.Printf("⚠️  WARNING: %s.%s - ok=0 (took %v)\n", cmd.Database, cmd.Name, result.Duration)
-				failedOps++
-			} else {
-				fmt.Printf("✓ %s.%s (took %v)\n", cmd.Database, cmd.Name, result.Duration)
-				successfulOps++
-			}
+		if detected == sender.TopologyStandalone || topology == sender.TopologyReplicaSet {
+			topology = detected
 		}
+	}
+	fmt.Printf("Target topology: %s (adapting read/write concerns)\n", topology)
+	return replay.NewConcernAdapter(topology, os.Stdout)
+}
 
-		// Track timing for last processed operation
-		lastOffset = packet.Offset
-		replayEndTime = time.Now()
+// printConcernDowngrades lists how often each concern downgrade was made
+func printConcernDowngrades(downgrades map[string]int) {
+	if len(downgrades) == 0 {
+		return
+	}
+	names := make([]string, 0, len(downgrades))
+	for name := range downgrades {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("\nAdapted concerns:")
+	for _, name := range names {
+		fmt.Printf("  %-40s %d ops\n", name, downgrades[name])
 	}
+}
 
-	printSummary(totalPackets, skippedPackets, successfulOps, failedOps, time.Since(wallClockStart), firstOffset, lastOffset, replayStartTime, replayEndTime, speed)
+// summaryCommands is how many commands the per-command summary lists
+const summaryCommands = 10
 
-	if failedOps > 0 {
-		os.Exit(1)
+// printEstimate prints the ops a replay would send and how long pacing them would take,
+// from the timeline planned by a dry run
+func printEstimate(stats *replay.Stats) {
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Println("REPLAY ESTIMATE")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("Total packets:       %d\n", stats.TotalPackets)
+	fmt.Printf("Skipped packets:     %d\n", stats.SkippedPackets)
+	if stats.SessionsSeen > 0 {
+		fmt.Printf("Sampled sessions:    %d of %d\n", stats.SessionsSampled, stats.SessionsSeen)
+	}
+	fmt.Printf("Eligible ops:        %d\n", stats.Ops())
+	if stats.FailedOps > 0 {
+		fmt.Printf("Unparseable ops:     %d (included above; they would fail when replayed)\n", stats.FailedOps)
+	}
+	if stats.Speed > 0 {
+		fmt.Printf("Projected duration:  %v (at %.1fx)\n", stats.PlannedDuration.Round(time.Microsecond), stats.Speed)
+	} else {
+		fmt.Println("Projected duration:  unpaced (--speed 0 sends as fast as the target responds)")
 	}
+	fmt.Println("\nThe projection covers pacing only; time spent waiting on the target and any")
+	fmt.Println("injected latency come on top when an op runs past the next one's due time.")
 }
 
-func printSummary(totalPackets, skippedPackets, successfulOps, failedOps int, duration time.Duration, firstOffset, lastOffset uint64, replayStartTime, replayEndTime time.Time, speed float64) {
+func printSummary(stats *replay.Stats, live replay.StatsSnapshot) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("REPLAY SUMMARY")
 	fmt.Println(strings.Repeat("=", 60))
-	fmt.Printf("Total packets:       %d\n", totalPackets)
-	fmt.Printf("Skipped packets:     %d\n", skippedPackets)
-	fmt.Printf("Successful ops:      %d\n", successfulOps)
-	fmt.Printf("Failed ops:          %d\n", failedOps)
-	fmt.Printf("Duration:            %v\n", duration)
-	if successfulOps+failedOps > 0 {
-		fmt.Printf("Average per op:      %v\n", duration/time.Duration(successfulOps+failedOps))
+	fmt.Printf("Total packets:       %d\n", stats.TotalPackets)
+	fmt.Printf("Skipped packets:     %d\n", stats.SkippedPackets)
+	if stats.ShadowWritesSkipped > 0 {
+		fmt.Printf("Shadow skipped:      %d writes/DDL (not sent)\n", stats.ShadowWritesSkipped)
+	}
+	if stats.PastDueSkipped > 0 {
+		fmt.Printf("Past-due skipped:    %d (due before the replay started)\n", stats.PastDueSkipped)
+	}
+	if stats.SessionsSeen > 0 {
+		fmt.Printf("Sampled sessions:    %d of %d\n", stats.SessionsSampled, stats.SessionsSeen)
+	}
+	fmt.Printf("Successful ops:      %d\n", stats.SuccessfulOps)
+	fmt.Printf("Failed ops:          %d\n", stats.FailedOps)
+	if stats.PartialWrites > 0 {
+		fmt.Printf("Partial writes:      %d (counted as successful; some statements failed)\n", stats.PartialWrites)
+	}
+	if stats.ResponsesRecorded > 0 {
+		fmt.Printf("Recorded exchanges:  %d (request/response pairs written)\n", stats.ResponsesRecorded)
+	}
+	if stats.AggWritesBlocked > 0 {
+		fmt.Printf("Blocked agg writes:  %d ($out/$merge aggregations)\n", stats.AggWritesBlocked)
+	}
+	if stats.EncryptedOps > 0 {
+		fmt.Printf("Encrypted ops:       %d (need the source's key vault to behave as recorded)\n", stats.EncryptedOps)
+	}
+	if stats.DuplicatesSkipped > 0 || stats.DuplicatesUpserted > 0 {
+		fmt.Printf("Duplicate keys:      %d skipped, %d upserted\n", stats.DuplicatesSkipped, stats.DuplicatesUpserted)
+	}
+	fmt.Printf("Duration:            %v\n", stats.Duration)
+	if stats.Ops() > 0 {
+		fmt.Printf("Average per op:      %v\n", stats.Duration/time.Duration(stats.Ops()))
+	}
+	if stats.ResponsesCompared > 0 || stats.ResponsesUnpaired > 0 {
+		fmt.Printf("Responses compared:  %d (%d mismatched, %d requests without a recorded response)\n",
+			stats.ResponsesCompared, stats.ResponseMismatches, stats.ResponsesUnpaired)
+		if stats.OrderMismatches > 0 {
+			fmt.Printf("Order mismatches:    %d (documents returned in a different order)\n", stats.OrderMismatches)
+		}
+	}
+	if stats.CursorsMapped > 0 || stats.CursorsRewritten > 0 || stats.CursorsUnmapped > 0 {
+		fmt.Printf("Cursors:             %d mapped, %d ids rewritten, %d ids without a live cursor\n",
+			stats.CursorsMapped, stats.CursorsRewritten, stats.CursorsUnmapped)
+	}
+	if stats.CursorsLeaked > 0 {
+		fmt.Printf("Leaked cursors:      %d left open at the end (%d killed)\n", stats.CursorsLeaked, stats.CursorsKilled)
+	}
+	if stats.DecompressFallbacks > 0 {
+		fmt.Printf("Decompress fallbacks: %d (OP_COMPRESSED ops resent as commands)\n", stats.DecompressFallbacks)
+	}
+	if stats.PlannedDuration > 0 {
+		fmt.Printf("Planned duration:    %v (simulated, at %.1fx)\n", stats.PlannedDuration.Round(time.Microsecond), stats.Speed)
+	}
+	if stats.InjectedDelay > 0 {
+		fmt.Printf("Injected delay:      %v\n", stats.InjectedDelay)
+	}
+	if pool := stats.Pool; pool.Checkouts > 0 || pool.CheckoutFailures > 0 {
+		fmt.Printf("Pool checkouts:      %d (avg wait %v, max %v; included in op latency)\n",
+			pool.Checkouts, pool.AverageCheckoutWait().Round(time.Microsecond), pool.MaxCheckoutWait.Round(time.Microsecond))
+		if pool.Exhausted > 0 {
+			fmt.Printf("Pool exhausted:      %d checkouts waited for a free connection (raise maxPoolSize)\n", pool.Exhausted)
+		}
+		if pool.CheckoutFailures > 0 {
+			fmt.Printf("Checkout failures:   %d (%d timed out waiting for a connection)\n", pool.CheckoutFailures, pool.CheckoutTimeouts)
+		}
+	}
+	if len(stats.Targets) > 0 {
+		fmt.Println("\nPer target:")
+		for _, t := range stats.Targets {
+			fmt.Printf("  %-40s %d successful, %d failed\n", t.Name, t.SuccessfulOps, t.FailedOps)
+		}
+	}
+	if names := live.CommandNames(); len(names) > 0 {
+		fmt.Println("\nPer command:")
+		for i, name := range names {
+			if i == summaryCommands {
+				fmt.Printf("  ... and %d more\n", len(names)-summaryCommands)
+				break
+			}
+			c := live.Commands[name]
+			avg := time.Duration(0)
+			if c.Successes > 0 {
+				avg = c.Latency / time.Duration(c.Successes)
+			}
+			fmt.Printf("  %-24s %d successful, %d failed, avg %v, max %v\n", orUnknown(name), c.Successes, c.Failures, avg, c.MaxLatency)
+		}
 	}
 
 	// Timing validation (only if we processed operations and speed > 0)
-	if successfulOps+failedOps > 0 && speed > 0 && !replayStartTime.IsZero() && !replayEndTime.IsZero() {
-		recordingDuration := time.Duration(lastOffset-firstOffset) * time.Microsecond
-		expectedDuration := time.Duration(float64(recordingDuration) / speed)
-		actualDuration := replayEndTime.Sub(replayStartTime)
+	if stats.Ops() > 0 && stats.Speed > 0 && !stats.ReplayStart.IsZero() && !stats.ReplayEnd.IsZero() {
+		expectedDuration := stats.ExpectedDuration()
+		actualDuration := stats.ActualDuration()
 
 		fmt.Println()
-		fmt.Printf("Recording duration:  %v\n", recordingDuration)
-		fmt.Printf("Expected duration:   %v (at %.1fx speed)\n", expectedDuration, speed)
+		fmt.Printf("Recording duration:  %v\n", stats.RecordingDuration())
+		fmt.Printf("Expected duration:   %v (at %.1fx speed)\n", expectedDuration, stats.Speed)
 		fmt.Printf("Actual duration:     %v\n", actualDuration)
+		fmt.Printf("Max drift:           %v\n", stats.MaxDrift)
 
 		// Calculate timing accuracy
 		if expectedDuration > 0 {
@@ -377,6 +1269,14 @@ func printSummary(totalPackets, skippedPackets, successfulOps, failedOps int, du
 	fmt.Println(strings.Repeat("=", 60))
 }
 
+// orUnknown labels ops whose command name couldn't be extracted
+func orUnknown(name string) string {
+	if name == "" {
+		return "(unknown)"
+	}
+	return name
+}
+
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> <mongodb-uri> [options]\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\nReplays recorded MongoDB traffic against a target MongoDB instance.\n")
@@ -392,10 +1292,111 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "                     2.0:     2x faster\n")
 	fmt.Fprintf(os.Stderr, "                     0.5:     Half speed\n")
 	fmt.Fprintf(os.Stderr, "                     0:       Fast-forward (no delays)\n")
+	fmt.Fprintf(os.Stderr, "  --start-at T       Send each op at T (RFC 3339) plus its recorded offset, keeping the\n")
+	fmt.Fprintf(os.Stderr, "                     capture's time of day; ops already due are sent immediately\n")
+	fmt.Fprintf(os.Stderr, "  --skip-past        With --start-at, skip ops that were due before the replay started\n")
+	fmt.Fprintf(os.Stderr, "  --pace-by-response Send each request its recorded think time (the gap after its session's\n")
+	fmt.Fprintf(os.Stderr, "                     previous response) after that session's previous op completes, instead\n")
+	fmt.Fprintf(os.Stderr, "                     of at its offset; models closed-loop clients on a slower target\n")
+	fmt.Fprintf(os.Stderr, "  --uri URI          Also replay against URI (repeatable); ops are spread over all targets\n")
+	fmt.Fprintf(os.Stderr, "  --targets LIST     Comma-separated URIs to add as targets (same as repeating --uri)\n")
+	fmt.Fprintf(os.Stderr, "  --route MODE       Target routing: 'round-robin' per op or 'session' to keep each\n")
+	fmt.Fprintf(os.Stderr, "                     recorded session on one target (default: round-robin)\n")
 	fmt.Fprintf(os.Stderr, "  --requests-only    Only replay requests (skip responses)\n")
 	fmt.Fprintf(os.Stderr, "  --user-ops         Only replay user operations (skip internal ops)\n")
 	fmt.Fprintf(os.Stderr, "  --dry-run          Parse and validate without sending\n")
+	fmt.Fprintf(os.Stderr, "  --show-drift       Print per-op drift versus the recorded timeline\n")
+	fmt.Fprintf(os.Stderr, "  --show-timing      With --dry-run, don't sleep; print each op's planned sleep and\n")
+	fmt.Fprintf(os.Stderr, "                     time on the simulated timeline at --speed\n")
+	fmt.Fprintf(os.Stderr, "  --estimate         Send nothing; scan the recording with the current --speed and filters\n")
+	fmt.Fprintf(os.Stderr, "                     and print the eligible op count and projected duration\n")
+	fmt.Fprintf(os.Stderr, "  --thread-metadata  Tag per-op output with the recorded session ID\n")
+	fmt.Fprintf(os.Stderr, "  --summary-only     Suppress per-op output and print only the final summary\n")
+	fmt.Fprintf(os.Stderr, "  --show-failures    With --summary-only, still print per-op failures\n")
+	fmt.Fprintf(os.Stderr, "  --assert-responses Compare each live response with the recorded one (ok, doc count, _ids);\n")
+	fmt.Fprintf(os.Stderr, "                     a divergence fails the op. Implies --requests-only\n")
+	fmt.Fprintf(os.Stderr, "  --response-fields LIST  With --assert-responses, compare only these keys: ok, n,\n")
+	fmt.Fprintf(os.Stderr, "                     cursor.id, cursor.firstBatch (length), _id, body (the whole response\n")
+	fmt.Fprintf(os.Stderr, "                     without volatile fields). Leaving out _id keeps memory bounded\n")
+	fmt.Fprintf(os.Stderr, "  --ignore-fields LIST  Dot-paths to leave out of whole-response comparison, on top of\n")
+	fmt.Fprintf(os.Stderr, "                     $clusterTime, operationTime and cursor.id (comma-separated, repeatable).\n")
+	fmt.Fprintf(os.Stderr, "                     Implies body in --response-fields; requires --assert-responses\n")
+	fmt.Fprintf(os.Stderr, "  --ordered-compare  With --assert-responses, also require cursor documents (by _id) in the\n")
+	fmt.Fprintf(os.Stderr, "                     recorded order; order differences are reported apart from content ones\n")
+	fmt.Fprintf(os.Stderr, "  --rewrite-cursors  Send getMore/killCursors with the cursor ids the target returned,\n")
+	fmt.Fprintf(os.Stderr, "                     paired with recorded ids via recorded responses (command mode).\n")
+	fmt.Fprintf(os.Stderr, "                     Cursors still open at the end are killed. Implies --requests-only\n")
+	fmt.Fprintf(os.Stderr, "  --record-responses FILE  Write each request sent and the target's response to a new\n")
+	fmt.Fprintf(os.Stderr, "                     recording FILE, e.g. for mock-server mode (raw mode). Implies --requests-only\n")
+	fmt.Fprintf(os.Stderr, "  --summary-json FILE  Also write the final stats (totals, per-command counts, latency\n")
+	fmt.Fprintf(os.Stderr, "                     percentiles, drift) to FILE as JSON, for CI to check thresholds\n")
+	fmt.Fprintf(os.Stderr, "  --tolerate-write-errors  Count a write batch where only some statements failed (writeErrors)\n")
+	fmt.Fprintf(os.Stderr, "                     as successful with warnings instead of failed (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --on-duplicate P   Inserted documents that hit a duplicate key: 'fail' (default), 'skip'\n")
+	fmt.Fprintf(os.Stderr, "                     them, or 'upsert' to replace the existing document by _id (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --exclude-namespace NS  Skip requests on NS ('db.collection', or 'db' / 'db.*' for a whole\n")
+	fmt.Fprintf(os.Stderr, "                     database; * and ? wildcards in either part) and the responses to them (repeatable)\n")
+	fmt.Fprintf(os.Stderr, "  --categories LIST  Only replay requests in these command categories, e.g. 'crud,read'\n")
+	fmt.Fprintf(os.Stderr, "                     (%s,\n", strings.Join(reader.CommandCategories[:7], ", "))
+	fmt.Fprintf(os.Stderr, "                     %s)\n", strings.Join(reader.CommandCategories[7:], ", "))
+	fmt.Fprintf(os.Stderr, "  --block-agg-writes P  Aggregations ending in $out/$merge: 'skip' them, or 'strip' the\n")
+	fmt.Fprintf(os.Stderr, "                     stage and send them as reads, e.g. for read-only load tests (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --raw-addr HOST:PORT  Send raw mode messages over a plain TCP connection to HOST:PORT (e.g. a\n")
+	fmt.Fprintf(os.Stderr, "                     proxy under test) instead of the driver's pool. No auth or TLS: the\n")
+	fmt.Fprintf(os.Stderr, "                     target must accept unauthenticated plain connections (raw mode)\n")
+	fmt.Fprintf(os.Stderr, "  --tls-ca FILE      Verify the target's certificate against the CA(s) in PEM FILE\n")
+	fmt.Fprintf(os.Stderr, "  --tls-cert FILE    Connect with the client certificate in PEM FILE, e.g. for X.509 auth\n")
+	fmt.Fprintf(os.Stderr, "                     (FILE holds the key too, unless --tls-key is given)\n")
+	fmt.Fprintf(os.Stderr, "  --tls-key FILE     Private key for --tls-cert\n")
+	fmt.Fprintf(os.Stderr, "  --auth-mechanism M Authenticate with M (e.g. MONGODB-X509, SCRAM-SHA-256), keeping the\n")
+	fmt.Fprintf(os.Stderr, "                     URI's username, password and authSource. Applies to every target\n")
+	fmt.Fprintf(os.Stderr, "  --adapt-concerns   Detect the target's topology and downgrade recorded concerns it rejects,\n")
+	fmt.Fprintf(os.Stderr, "                     e.g. snapshot reads or w: 3 on a standalone, with a warning (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --shadow           Send only reads (find, aggregate, count, distinct, getMore) and skip\n")
+	fmt.Fprintf(os.Stderr, "                     writes, DDL and other commands, reporting read latency percentiles;\n")
+	fmt.Fprintf(os.Stderr, "                     for production-adjacent targets such as a new read replica (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --warn-encrypted   Flag ops carrying Client-Side Field Level Encryption ciphertext (BinData\n")
+	fmt.Fprintf(os.Stderr, "                     subtype 6), which only behave as recorded if the target shares the\n")
+	fmt.Fprintf(os.Stderr, "                     source's key vault. The ops are still sent\n")
+	fmt.Fprintf(os.Stderr, "  --default-db DB    Send commands whose $db can't be extracted to DB (e.g. admin)\n")
+	fmt.Fprintf(os.Stderr, "                     instead of skipping them (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --decompress-fallback  Resend an OP_COMPRESSED op decompressed as a command when the\n")
+	fmt.Fprintf(os.Stderr, "                     raw send fails, e.g. the target lacks the compressor (raw mode)\n")
+	fmt.Fprintf(os.Stderr, "  --preserve-retryable-writes  Keep lsid/txnNumber on retryable writes outside transactions (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --preserve-appname   Connect with each recorded session's appName (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --driver-helpers     Send insert/find/update/delete/aggregate via typed driver helpers (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --echo-script        Print each command as a mongosh statement to stderr (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --echo-failures      Like --echo-script, but only for failed commands (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --rename-ns FROM=TO  Redirect a namespace ('db' or 'db.coll', command mode, repeatable)\n")
+	fmt.Fprintf(os.Stderr, "  --namespace-map FILE Redirect namespaces listed in a CSV file of 'old,new' lines\n")
+	fmt.Fprintf(os.Stderr, "                       ('db.coll', 'db.*' or 'db', with * and ? wildcards in the source; command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --strip-max-time     Remove maxTimeMS so a slower target doesn't time out (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --inject-comment TEXT  Set the comment on every command, to find replayed ops in the\n")
+	fmt.Fprintf(os.Stderr, "                       target's logs (overrides recorded comments; command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --read-concern LEVEL Override readConcern level on reads (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --write-concern W    Override writeConcern w on writes (command mode)\n")
+	fmt.Fprintf(os.Stderr, "  --inject-latency D Add delay D (e.g. 5ms) before every send, on top of recorded timing\n")
+	fmt.Fprintf(os.Stderr, "  --inject-jitter D  Randomize the injected delay by up to ±D\n")
+	fmt.Fprintf(os.Stderr, "  --seed N           Seed for the jitter RNG (default: 1)\n")
+	fmt.Fprintf(os.Stderr, "  --strict-order     Dispatch packets strictly by ascending order number\n")
+	fmt.Fprintf(os.Stderr, "  --order-window N   Packets buffered for --strict-order re-sequencing (default: 1024)\n")
+	fmt.Fprintf(os.Stderr, "  --order-by KEY     Field --strict-order sequences by when order and offset disagree:\n")
+	fmt.Fprintf(os.Stderr, "                     'order' for the exact capture sequence (default) or 'offset' for\n")
+	fmt.Fprintf(os.Stderr, "                     wall-clock timing. Implies --strict-order\n")
+	fmt.Fprintf(os.Stderr, "  --reverse          Replay the recording last packet first, keeping the recorded gaps.\n")
+	fmt.Fprintf(os.Stderr, "                     Reads it all into memory. Ops are sent as recorded, not inverted:\n")
+	fmt.Fprintf(os.Stderr, "                     combine with filters (e.g. --categories ddl) for teardown\n")
 	fmt.Fprintf(os.Stderr, "  --limit N          Limit replay to first N operations\n")
+	fmt.Fprintf(os.Stderr, "  --repeat N         Replay the recording N times in a row. Each pass restarts the\n")
+	fmt.Fprintf(os.Stderr, "                     recorded timeline, so the time from capture start to the first\n")
+	fmt.Fprintf(os.Stderr, "                     packet is replayed as a pause between passes\n")
+	fmt.Fprintf(os.Stderr, "  --seamless-repeat  With --repeat, start each pass right at the previous pass's last op\n")
+	fmt.Fprintf(os.Stderr, "  --session-sample F Replay only a fraction F (e.g. 0.1) of sessions, each in full;\n")
+	fmt.Fprintf(os.Stderr, "                     the same sessions are chosen on every run\n")
+	fmt.Fprintf(os.Stderr, "  --max-duration D   Stop after D of wall-clock time (e.g. 10m) and print the summary\n")
+	fmt.Fprintf(os.Stderr, "  --report-interval D  Every D (e.g. 10s), print a REPORT line with that window's ops,\n")
+	fmt.Fprintf(os.Stderr, "                     ops/s, failures, average latency and connection checkouts\n")
+	fmt.Fprintf(os.Stderr, "                     (printed with --summary-only too)\n")
 	fmt.Fprintf(os.Stderr, "\nExamples:\n")
 	fmt.Fprintf(os.Stderr, "  # Raw mode with original timing (default)\n")
 	fmt.Fprintf(os.Stderr, "  %s recording.bin mongodb://localhost:27017 --requests-only\n", os.Args[0])
@@ -403,6 +1404,8 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "  %s recording.bin mongodb://localhost:27017 --speed 0 --requests-only\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\n  # Command mode at 2x speed\n")
 	fmt.Fprintf(os.Stderr, "  %s recording.bin mongodb://localhost:27017 --mode command --speed 2.0 --user-ops\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\n  # Spread load over two mongos routers, one router per recorded session\n")
+	fmt.Fprintf(os.Stderr, "  %s recording.bin mongodb://mongos1:27017 --uri mongodb://mongos2:27017 --route session\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\n  # Dry run to validate\n")
 	fmt.Fprintf(os.Stderr, "  %s recording.bin mongodb://localhost:27017 --dry-run --limit 100\n", os.Args[0])
 }
@@ -1,14 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
 	"github.com/fsnow/traffic-replay/pkg/reader"
-	"go.mongodb.org/mongo-driver/v2/bson"
+	"github.com/fsnow/traffic-replay/pkg/scriptgen"
 )
 
 func main() {
@@ -39,6 +37,10 @@ func main() {
 		os.Exit(1)
 	}
 	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
 
 	fmt.Println("// Generated from:", filePath)
 	fmt.Println("// MongoDB operations replay script")
@@ -90,7 +92,7 @@ func main() {
 			db = "unknown"
 		}
 
-		script, err := generateScript(packet, cmd, db)
+		script, err := scriptgen.GeneratePacket(packet, db)
 		if err != nil {
 			// If we can't parse it, just note it
 			unknownOps = append(unknownOps, fmt.Sprintf("// Packet %d: %s (parse error: %v)", totalPackets, cmd, err))
@@ -120,375 +122,3 @@ func main() {
 	// Print summary
 	fmt.Fprintf(os.Stderr, "\nGenerated script from %d packets (%d operations)\n", totalPackets, outputPackets)
 }
-
-func generateScript(packet *reader.Packet, cmd string, db string) (string, error) {
-	// Extract the BSON document from the OP_MSG packet
-	opCode := packet.GetOpCode()
-	if opCode != 2013 { // OP_MSG
-		return "", fmt.Errorf("unsupported opcode: %d", opCode)
-	}
-
-	// OP_MSG structure:
-	// Header: 16 bytes (already part of Message)
-	// Flags: 4 bytes
-	// Sections: variable
-	//   Section kind: 1 byte
-	//   For kind 0: BSON document
-
-	if len(packet.Message) < 16+4+1+4 {
-		return "", fmt.Errorf("packet too short")
-	}
-
-	// Skip to BSON document (after header + flags + section kind)
-	offset := 16 + 4 + 1
-
-	// Read BSON document
-	bsonDoc := packet.Message[offset:]
-
-	// Parse BSON to map
-	var doc bson.M
-	err := bson.Unmarshal(bsonDoc, &doc)
-	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal BSON: %w", err)
-	}
-
-	// Clean up internal driver/server fields from the document
-	doc = cleanInternalFields(doc)
-
-	// Generate script based on command type
-	switch cmd {
-	case "insert":
-		return generateInsert(doc, db)
-	case "update":
-		return generateUpdate(doc, db)
-	case "delete":
-		return generateDelete(doc, db)
-	case "find":
-		return generateFind(doc, db)
-	case "aggregate":
-		return generateAggregate(doc, db)
-	case "findAndModify":
-		return generateFindAndModify(doc, db)
-	case "createIndexes":
-		return generateCreateIndexes(doc, db)
-	case "dropIndexes":
-		return generateDropIndexes(doc, db)
-	case "create":
-		return generateCreate(doc, db)
-	case "drop":
-		return generateDrop(doc, db)
-	default:
-		// For other commands, just output as runCommand
-		// (already cleaned of internal fields above)
-		return generateRunCommand(doc, cmd, db)
-	}
-}
-
-// cleanInternalFields removes driver/server internal fields from BSON documents
-func cleanInternalFields(doc bson.M) bson.M {
-	cleaned := bson.M{}
-
-	// List of internal driver/server fields to remove
-	internalFields := map[string]bool{
-		"$clusterTime": true,
-		"$db":          true,
-		"$readPreference": true,
-		"lsid":         true,
-		"txnNumber":    true,
-		"autocommit":   true,
-		"startTransaction": true,
-	}
-
-	for key, value := range doc {
-		// Skip internal fields
-		if internalFields[key] {
-			continue
-		}
-
-		// Recursively clean nested documents
-		switch v := value.(type) {
-		case bson.M:
-			cleaned[key] = cleanInternalFields(v)
-		case bson.A:
-			cleaned[key] = cleanInternalFieldsArray(v)
-		default:
-			cleaned[key] = value
-		}
-	}
-
-	return cleaned
-}
-
-// cleanInternalFieldsArray recursively cleans arrays
-func cleanInternalFieldsArray(arr bson.A) bson.A {
-	cleaned := bson.A{}
-
-	for _, item := range arr {
-		switch v := item.(type) {
-		case bson.M:
-			cleaned = append(cleaned, cleanInternalFields(v))
-		case bson.A:
-			cleaned = append(cleaned, cleanInternalFieldsArray(v))
-		default:
-			cleaned = append(cleaned, item)
-		}
-	}
-
-	return cleaned
-}
-
-func generateInsert(doc bson.M, database string) (string, error) {
-	coll, ok := doc["insert"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	documents, ok := doc["documents"].(bson.A)
-	if !ok {
-		return "", fmt.Errorf("missing documents array")
-	}
-
-	// Use insertMany if multiple documents, insertOne if single document
-	if len(documents) == 1 {
-		jsonBytes, err := json.MarshalIndent(documents[0], "", "  ")
-		if err != nil {
-			return "", err
-		}
-		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.insertOne(%s);", database, coll, string(jsonBytes)), nil
-	}
-
-	// Multiple documents - use insertMany
-	jsonBytes, err := json.MarshalIndent(documents, "", "  ")
-	if err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.insertMany(%s);", database, coll, string(jsonBytes)), nil
-}
-
-func generateUpdate(doc bson.M, database string) (string, error) {
-	coll, ok := doc["update"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	updates, ok := doc["updates"].(bson.A)
-	if !ok {
-		return "", fmt.Errorf("missing updates array")
-	}
-
-	var lines []string
-	for _, u := range updates {
-		update, ok := u.(bson.M)
-		if !ok {
-			continue
-		}
-
-		filter := update["q"]
-		updateDoc := update["u"]
-		multi := update["multi"]
-
-		filterJSON, _ := json.MarshalIndent(filter, "", "  ")
-		updateJSON, _ := json.MarshalIndent(updateDoc, "", "  ")
-
-		// Check if updateDoc is a replacement (no atomic operators) or an update
-		isReplacement := true
-		if updateDocMap, ok := updateDoc.(bson.M); ok {
-			for key := range updateDocMap {
-				if strings.HasPrefix(key, "$") {
-					isReplacement = false
-					break
-				}
-			}
-		}
-
-		if isReplacement {
-			// Full document replacement - use replaceOne
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.replaceOne(\n  %s,\n  %s\n);",
-				database, coll, string(filterJSON), string(updateJSON)))
-		} else if multi == true {
-			// Update with operators - updateMany
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.updateMany(\n  %s,\n  %s\n);",
-				database, coll, string(filterJSON), string(updateJSON)))
-		} else {
-			// Update with operators - updateOne
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.updateOne(\n  %s,\n  %s\n);",
-				database, coll, string(filterJSON), string(updateJSON)))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
-}
-
-func generateDelete(doc bson.M, database string) (string, error) {
-	coll, ok := doc["delete"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	deletes, ok := doc["deletes"].(bson.A)
-	if !ok {
-		return "", fmt.Errorf("missing deletes array")
-	}
-
-	var lines []string
-	for _, d := range deletes {
-		del, ok := d.(bson.M)
-		if !ok {
-			continue
-		}
-
-		filter := del["q"]
-		limit := del["limit"]
-
-		filterJSON, _ := json.MarshalIndent(filter, "", "  ")
-
-		// limit: 0 = deleteMany, limit: 1 = deleteOne
-		if limit == int32(1) || limit == int64(1) {
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.deleteOne(%s);", database, coll, string(filterJSON)))
-		} else {
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.deleteMany(%s);", database, coll, string(filterJSON)))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
-}
-
-func generateFind(doc bson.M, database string) (string, error) {
-	coll, ok := doc["find"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	filter := doc["filter"]
-	if filter == nil {
-		filter = bson.M{}
-	}
-
-	filterJSON, _ := json.MarshalIndent(filter, "", "  ")
-
-	// Add projection if present
-	if projection, ok := doc["projection"].(bson.M); ok && len(projection) > 0 {
-		projJSON, _ := json.MarshalIndent(projection, "", "  ")
-		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(\n  %s\n).project(%s);", database, coll, string(filterJSON), string(projJSON)), nil
-	}
-
-	// Add sort if present
-	if sort, ok := doc["sort"].(bson.M); ok && len(sort) > 0 {
-		sortJSON, _ := json.MarshalIndent(sort, "", "  ")
-		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(\n  %s\n).sort(%s);", database, coll, string(filterJSON), string(sortJSON)), nil
-	}
-
-	// Add limit if present
-	if limit, ok := doc["limit"]; ok {
-		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(\n  %s\n).limit(%v);", database, coll, string(filterJSON), limit), nil
-	}
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(%s);", database, coll, string(filterJSON)), nil
-}
-
-func generateAggregate(doc bson.M, database string) (string, error) {
-	coll, ok := doc["aggregate"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	pipeline, ok := doc["pipeline"].(bson.A)
-	if !ok {
-		return "", fmt.Errorf("missing pipeline")
-	}
-
-	pipelineJSON, _ := json.MarshalIndent(pipeline, "", "  ")
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.aggregate(%s);", database, coll, string(pipelineJSON)), nil
-}
-
-func generateFindAndModify(doc bson.M, database string) (string, error) {
-	coll, ok := doc["findAndModify"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	// Remove the collection name from the document for runCommand
-	delete(doc, "findAndModify")
-
-	argsJSON, _ := json.MarshalIndent(doc, "", "  ")
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.findAndModify(%s);", database, coll, string(argsJSON)), nil
-}
-
-func generateCreateIndexes(doc bson.M, database string) (string, error) {
-	coll, ok := doc["createIndexes"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	indexes, ok := doc["indexes"].(bson.A)
-	if !ok {
-		return "", fmt.Errorf("missing indexes array")
-	}
-
-	var lines []string
-	for _, idx := range indexes {
-		indexDoc, ok := idx.(bson.M)
-		if !ok {
-			continue
-		}
-
-		key := indexDoc["key"]
-		keyJSON, _ := json.MarshalIndent(key, "", "  ")
-
-		// Build options
-		options := bson.M{}
-		if name, ok := indexDoc["name"].(string); ok {
-			options["name"] = name
-		}
-		if unique, ok := indexDoc["unique"].(bool); ok && unique {
-			options["unique"] = true
-		}
-
-		if len(options) > 0 {
-			optJSON, _ := json.MarshalIndent(options, "", "  ")
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.createIndex(%s, %s);", database, coll, string(keyJSON), string(optJSON)))
-		} else {
-			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.createIndex(%s);", database, coll, string(keyJSON)))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
-}
-
-func generateDropIndexes(doc bson.M, database string) (string, error) {
-	coll, ok := doc["dropIndexes"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	index := doc["index"]
-	indexJSON, _ := json.Marshal(index)
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.dropIndex(%s);", database, coll, string(indexJSON)), nil
-}
-
-func generateCreate(doc bson.M, database string) (string, error) {
-	coll, ok := doc["create"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").createCollection(\"%s\");", database, coll), nil
-}
-
-func generateDrop(doc bson.M, database string) (string, error) {
-	coll, ok := doc["drop"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing collection name")
-	}
-
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.drop();", database, coll), nil
-}
-
-func generateRunCommand(doc bson.M, cmd string, database string) (string, error) {
-	// Document is already cleaned by cleanInternalFields()
-	docJSON, _ := json.MarshalIndent(doc, "", "  ")
-	return fmt.Sprintf("db.getSiblingDB(\"%s\").runCommand(%s);", database, string(docJSON)), nil
-}
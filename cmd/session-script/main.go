@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/scriptgen"
+)
+
+// sessionPackets iterates one session's packets, skipping the bodies of all others
+type sessionPackets struct {
+	rec       *reader.RecordingReader
+	sessionID uint64
+}
+
+// Next returns the session's next packet
+func (s sessionPackets) Next() (*reader.Packet, error) {
+	return s.rec.NextMatchingHeader(func(p *reader.Packet) bool {
+		return p.SessionID == s.sessionID
+	})
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> <session-id>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nWrites the session's requests, in order, as a mongosh script.\n")
+		fmt.Fprintf(os.Stderr, "List session ids with: go run ./cmd/analyze <recording-file> --sessions-full\n")
+		os.Exit(1)
+	}
+
+	filePath := os.Args[1]
+	sessionID, err := strconv.ParseUint(os.Args[2], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid session id '%s'. Must be a non-negative integer\n", os.Args[2])
+		os.Exit(1)
+	}
+
+	rec, err := reader.NewRecordingReader(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
+
+	fmt.Println("// Generated from:", filePath)
+	stats, err := scriptgen.WriteSessionScript(os.Stdout, sessionPackets{rec, sessionID}, sessionID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading packet: %v\n", err)
+		os.Exit(1)
+	}
+	if stats.Packets == 0 {
+		fmt.Fprintf(os.Stderr, "Error: session %d not found in %s\n", sessionID, filePath)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nGenerated script from %d packets (%d operations, %d commented out)\n",
+		stats.Packets, stats.Operations, stats.Commented)
+}
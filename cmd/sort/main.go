@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+func main() {
+	var inputPath, outputPath, tempDir string
+	var runMB uint64
+	var headerless, dedupeBoundaries bool
+
+	flag.StringVar(&inputPath, "input", "", "Input recording file, or a directory of .bin files read in name order (required)")
+	flag.StringVar(&outputPath, "output", "", "Output recording file (required)")
+	flag.Uint64Var(&runMB, "run-mb", reader.DefaultSortRunBytes>>20, "Packet data (MiB) sorted in memory before spilling a run to a temp file")
+	flag.StringVar(&tempDir, "tmp-dir", "", "Directory for spilled runs (default: system temp directory)")
+	flag.BoolVar(&headerless, "headerless", false, "Write output without a file header (same layout as server-written recordings)")
+	flag.BoolVar(&dedupeBoundaries, "dedupe-boundaries", false, "With a directory input, drop a file's first packet if it repeats the previous file's last packet (rotation overlap; not applied to legacy-format files, which have no order field)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Re-sort a MongoDB traffic recording by (offset, order).\n")
+		fmt.Fprintf(os.Stderr, "Packets with equal offset and order keep their input order. Inputs larger than\n")
+		fmt.Fprintf(os.Stderr, "-run-mb are sorted in runs spilled to temp files and merged.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  # Sort a single recording\n")
+		fmt.Fprintf(os.Stderr, "  %s -input recording.bin -output sorted.bin\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Merge a directory of captures into one ordered recording\n")
+		fmt.Fprintf(os.Stderr, "  %s -input captures/ -output merged.bin -run-mb 1024\n\n", os.Args[0])
+	}
+
+	flag.Parse()
+
+	if inputPath == "" || outputPath == "" || runMB == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	stats, boundaryDuplicates, err := sortRecording(inputPath, outputPath, tempDir, runMB<<20, headerless, dedupeBoundaries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Println("SORT RESULTS")
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("\nPackets:      %d\n", stats.Packets)
+	if stats.Runs > 0 {
+		fmt.Printf("Spilled runs: %d\n", stats.Runs)
+	}
+	if dedupeBoundaries {
+		fmt.Printf("Deduped:      %d (boundary packets repeated across file rotations)\n", boundaryDuplicates)
+	}
+	if stats.AlreadySorted() {
+		fmt.Println("\n✓ Input was already sorted by (offset, order)")
+	} else {
+		fmt.Printf("\n⚠️  Input was out of order: %d packets sorted before their predecessor\n", stats.OutOfOrder)
+	}
+	fmt.Printf("\nWrote: %s\n", outputPath)
+}
+
+// sortRecording sorts a recording file or directory into outputPath, returning how
+// many rotation-overlap duplicates were dropped from a directory
+func sortRecording(inputPath, outputPath, tempDir string, runBytes uint64, headerless, dedupeBoundaries bool) (*reader.SortStats, int, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to access input: %w", err)
+	}
+
+	var src reader.PacketIterator
+	var startTime time.Time
+	var boundaryDuplicates func() int
+	if info.IsDir() {
+		set, err := reader.NewRecordingSet(inputPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer set.Close()
+		set.DedupeBoundaries(dedupeBoundaries)
+		src = set
+		boundaryDuplicates = set.BoundaryDuplicates
+	} else {
+		rec, err := reader.NewRecordingReader(inputPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer rec.Close()
+		if rec.Empty() {
+			return nil, 0, fmt.Errorf("%s: %w", inputPath, reader.ErrEmptyRecording)
+		}
+		src = rec
+		startTime = rec.StartTime()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	output, err := reader.NewRecordingWriter(outputPath, reader.WriterOptions{
+		StartTime:  startTime,
+		Headerless: headerless,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create output: %w", err)
+	}
+	defer output.Close()
+
+	stats, err := reader.SortPackets(src, output, reader.SortOptions{RunBytes: runBytes, TempDir: tempDir})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := output.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to close output: %w", err)
+	}
+	if boundaryDuplicates != nil {
+		return stats, boundaryDuplicates(), nil
+	}
+	return stats, 0, nil
+}
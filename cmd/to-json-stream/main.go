@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// frame is one packet as a packet-trace record (see docs/json-stream.md)
+type frame struct {
+	Frame        int     `json:"frame"`
+	Time         string  `json:"time,omitempty"`
+	TimeRelative float64 `json:"time_relative"`
+	Session      uint64  `json:"session"`
+	Src          string  `json:"src,omitempty"`
+	Dst          string  `json:"dst,omitempty"`
+	Direction    string  `json:"direction"`
+	Length       int     `json:"length"`
+	OpCode       uint32  `json:"opcode,omitempty"`
+	OpCodeName   string  `json:"opcode_name,omitempty"`
+	Compressed   bool    `json:"compressed,omitempty"`
+	RequestID    int32   `json:"request_id,omitempty"`
+	ResponseTo   int32   `json:"response_to,omitempty"`
+	Command      string  `json:"command,omitempty"`
+	Database     string  `json:"database,omitempty"`
+	Collection   string  `json:"collection,omitempty"`
+}
+
+// endpoints are a session's client and server addresses
+type endpoints struct {
+	remote string
+	local  string
+}
+
+// request is the command summary of a request, copied onto the responses to it
+type request struct {
+	command    string
+	database   string
+	collection string
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	filePath := os.Args[1]
+	startArg := ""
+	redactMetadata := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--start-time":
+			if i+1 < len(os.Args) {
+				startArg = os.Args[i+1]
+				i++
+			}
+		case "--redact-metadata":
+			redactMetadata = true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option: %s\n", os.Args[i])
+			printUsage()
+			os.Exit(1)
+		}
+	}
+
+	rec, err := reader.NewRecordingReader(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
+
+	// Absolute timestamps need a capture start time: the flag wins over the file header
+	start := rec.StartTime()
+	if startArg != "" {
+		start, err = time.Parse(time.RFC3339Nano, startArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --start-time '%s' (want RFC 3339, e.g. 2024-05-01T12:00:00Z): %v\n", startArg, err)
+			os.Exit(1)
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+
+	sessions := make(map[uint64]*endpoints)
+	requests := make(map[uint64]map[int32]request)
+
+	for n := 1; ; n++ {
+		packet, err := rec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "Error reading packet %d: %v\n", n, err)
+			os.Exit(1)
+		}
+
+		f := frame{
+			Frame:        n,
+			TimeRelative: float64(packet.Offset) / 1e6,
+			Session:      packet.SessionID,
+			Length:       len(packet.Message),
+		}
+		if !start.IsZero() {
+			f.Time = start.Add(time.Duration(packet.Offset) * time.Microsecond).UTC().Format(time.RFC3339Nano)
+		}
+
+		if redactMetadata {
+			packet.SessionMetadata = reader.RedactMetadata(packet.SessionMetadata)
+		}
+		ep := sessionEndpoints(sessions, packet)
+
+		if len(packet.Message) < reader.WireHeaderSize {
+			// Session start/end events carry no wire message; the end frees the session's requests
+			f.Direction = "event"
+			if _, open := requests[packet.SessionID]; open {
+				delete(requests, packet.SessionID)
+			} else {
+				requests[packet.SessionID] = make(map[int32]request)
+			}
+			f.Src, f.Dst = ep.remote, ep.local
+			if err := enc.Encode(f); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing frame %d: %v\n", n, err)
+				os.Exit(1)
+			}
+			continue
+		}
+
+		f.RequestID = int32(binary.LittleEndian.Uint32(packet.Message[4:8]))
+		f.ResponseTo = int32(binary.LittleEndian.Uint32(packet.Message[8:12]))
+		f.OpCode = packet.GetOpCode()
+		f.OpCodeName = opCodeName(f.OpCode)
+		f.Compressed = f.OpCode == 2012
+
+		if packet.IsRequest() {
+			f.Direction = "request"
+			f.Src, f.Dst = ep.remote, ep.local
+			f.Command = packet.ExtractCommandName()
+			f.Database = packet.ExtractDatabase()
+			f.Collection = packet.ExtractCollection()
+
+			pending, ok := requests[packet.SessionID]
+			if !ok {
+				pending = make(map[int32]request)
+				requests[packet.SessionID] = pending
+			}
+			pending[f.RequestID] = request{command: f.Command, database: f.Database, collection: f.Collection}
+		} else {
+			f.Direction = "response"
+			f.Src, f.Dst = ep.local, ep.remote
+			if req, ok := requests[packet.SessionID][f.ResponseTo]; ok {
+				f.Command, f.Database, f.Collection = req.command, req.database, req.collection
+			}
+		}
+
+		if err := enc.Encode(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing frame %d: %v\n", n, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// sessionEndpoints returns the client and server addresses of a packet's session
+// Addresses come from the first packet of the session whose metadata has them.
+func sessionEndpoints(sessions map[uint64]*endpoints, packet *reader.Packet) *endpoints {
+	ep, ok := sessions[packet.SessionID]
+	if !ok {
+		ep = &endpoints{}
+		sessions[packet.SessionID] = ep
+	}
+	if ep.remote == "" && ep.local == "" && packet.SessionMetadata != "" {
+		if meta, err := packet.ParseSessionMetadata(); err == nil {
+			ep.remote, ep.local = meta.Remote, meta.Local
+		}
+	}
+	return ep
+}
+
+func opCodeName(code uint32) string {
+	switch code {
+	case 1:
+		return "OP_REPLY"
+	case 2001:
+		return "OP_UPDATE"
+	case 2002:
+		return "OP_INSERT"
+	case 2004:
+		return "OP_QUERY"
+	case 2005:
+		return "OP_GET_MORE"
+	case 2006:
+		return "OP_DELETE"
+	case 2007:
+		return "OP_KILL_CURSORS"
+	case 2012:
+		return "OP_COMPRESSED"
+	case 2013:
+		return "OP_MSG"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nWrites one JSON object per packet (JSON Lines) to stdout, modeled on a packet-trace\n")
+	fmt.Fprintf(os.Stderr, "record: time, client/server addresses, direction and a command summary.\n")
+	fmt.Fprintf(os.Stderr, "See docs/json-stream.md for the schema.\n")
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	fmt.Fprintf(os.Stderr, "  --start-time T     Capture start time (RFC 3339) for absolute timestamps; defaults to\n")
+	fmt.Fprintf(os.Stderr, "                     the file header's start time. Without either, only time_relative is set\n")
+	fmt.Fprintf(os.Stderr, "  --redact-metadata  Mask the src/dst addresses taken from session metadata\n")
+	fmt.Fprintf(os.Stderr, "                     (10.0.0.5:51807 -> 10.0.0.x:xxxxx)\n")
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	filePath := os.Args[1]
+
+	// Parse options
+	maxPerKind := 20
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--max-per-kind":
+			if i+1 < len(os.Args) {
+				fmt.Sscanf(os.Args[i+1], "%d", &maxPerKind)
+				i++
+			}
+		}
+	}
+
+	rec, err := reader.NewRecordingReader(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer rec.Close()
+	if rec.Empty() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", filePath, reader.ErrEmptyRecording)
+		os.Exit(1)
+	}
+
+	v := newValidator()
+	for {
+		packet, err := rec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading packet %d: %v\n", v.packets+1, err)
+			os.Exit(1)
+		}
+		v.check(packet)
+	}
+
+	fmt.Printf("Validating recording: %s\n", filePath)
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("Format:    %s\n", rec.Format())
+	fmt.Printf("Packets:   %d\n", v.packets)
+	fmt.Printf("Requests:  %d\n", v.requests)
+	fmt.Printf("Responses: %d\n", v.responses)
+	if n := rec.TrailingBytes(); n > 0 {
+		fmt.Printf("⚠️  Ignored %d trailing bytes after the last complete packet (partial packet)\n", n)
+	}
+
+	hazards := v.hazards()
+	total := 0
+	for _, kind := range hazardKinds {
+		list := hazards[kind.name]
+		total += len(list)
+
+		fmt.Printf("\n=== %s ===\n", kind.title)
+		if kind.name == "orphan-request" && v.responses == 0 {
+			fmt.Println("  (no responses in recording; not checked)")
+			continue
+		}
+		if kind.name == "dangling-cursor" {
+			if v.responses > 0 {
+				fmt.Println("  (cursors matched by id against earlier responses)")
+			} else {
+				fmt.Println("  (no responses in recording; cursors matched by namespace against earlier cursor-opening commands)")
+			}
+		}
+		if len(list) == 0 {
+			fmt.Println("  ✓ None")
+			continue
+		}
+		for i, h := range list {
+			if maxPerKind > 0 && i == maxPerKind {
+				fmt.Printf("  ... and %d more\n", len(list)-maxPerKind)
+				break
+			}
+			fmt.Printf("  ❌ order=%d session=%d: %s\n", h.order, h.sessionID, h.detail)
+		}
+	}
+
+	fmt.Println("\n=== PER-SESSION PAIRING ===")
+	if n := v.inFlight(); n > 0 {
+		fmt.Printf("  (%d request(s) in sessions still open at the end of the recording had no response yet; not counted)\n", n)
+	}
+	sessions := pairingBySession(hazards)
+	if len(sessions) == 0 {
+		fmt.Println("  ✓ Every session's requests and responses pair up")
+	}
+	for i, sp := range sessions {
+		if maxPerKind > 0 && i == maxPerKind {
+			fmt.Printf("  ... and %d more sessions\n", len(sessions)-maxPerKind)
+			break
+		}
+		fmt.Printf("  ⚠️  session=%d: %d orphan request(s), %d orphan response(s)\n", sp.sessionID, sp.orphanRequests, sp.orphanResponses)
+	}
+
+	fmt.Println()
+	if total > 0 {
+		fmt.Printf("❌ %d replay hazard(s) found\n", total)
+		os.Exit(1)
+	}
+	fmt.Println("✓ No replay hazards found")
+}
+
+// hazardKinds are the checks in report order
+var hazardKinds = []struct {
+	name  string
+	title string
+}{
+	{"dangling-cursor", "CURSOR OPS WITHOUT ORIGINATING COMMAND"},
+	{"orphan-response", "RESPONSES WITHOUT MATCHING REQUEST"},
+	{"orphan-request", "REQUESTS WITHOUT A RESPONSE BY SESSION END"},
+	{"legacy-opcode", "LEGACY OPCODES"},
+}
+
+// hazard is one replay problem found in the recording
+type hazard struct {
+	// order is the packet's Order field
+	order uint64
+
+	// sessionID is the packet's session
+	sessionID uint64
+
+	// detail describes the problem
+	detail string
+}
+
+// validator accumulates pairing state over one pass of the recording
+type validator struct {
+	packets   int
+	requests  int
+	responses int
+
+	// seenRequests are the requests read so far, by session and requestID
+	seenRequests map[reader.RequestKey]bool
+
+	// openCursors are cursor ids returned by responses to requests in the recording
+	openCursors map[int64]bool
+
+	// cursorNamespaces are namespaces that had a cursor-opening request (requests-only fallback)
+	cursorNamespaces map[string]bool
+
+	// byCursorID and byNamespace are the dangling-cursor hazards under each matching strategy;
+	// which one is reported depends on whether the recording has responses
+	byCursorID  []hazard
+	byNamespace []hazard
+
+	orphanResponses []hazard
+	orphanRequests  []hazard
+	legacyOpcodes   []hazard
+
+	// started are the sessions seen so far (a later empty message ends the session)
+	started map[uint64]bool
+
+	// outstanding are requests still awaiting a response, by session, requestID -> order
+	outstanding map[uint64]map[int32]uint64
+}
+
+// cursorOpeningCommands return a cursor that later getMore/killCursors can use
+var cursorOpeningCommands = map[string]bool{
+	"find":            true,
+	"aggregate":       true,
+	"listCollections": true,
+	"listIndexes":     true,
+}
+
+func newValidator() *validator {
+	return &validator{
+		seenRequests:     make(map[reader.RequestKey]bool),
+		openCursors:      make(map[int64]bool),
+		cursorNamespaces: make(map[string]bool),
+		started:          make(map[uint64]bool),
+		outstanding:      make(map[uint64]map[int32]uint64),
+	}
+}
+
+// noResponseOpcodes are legacy opcodes the server never answers
+var noResponseOpcodes = map[uint32]bool{
+	2001: true, // OP_UPDATE
+	2002: true, // OP_INSERT
+	2006: true, // OP_DELETE
+	2007: true, // OP_KILL_CURSORS
+}
+
+// opMsgMoreToCome is the OP_MSG flag bit for "no response expected" (requests) or
+// "another response follows" (exhaust cursor replies)
+const opMsgMoreToCome = 1 << 1
+
+// check runs every per-packet check and updates pairing state
+func (v *validator) check(packet *reader.Packet) {
+	v.packets++
+	if len(packet.Message) == 0 && v.started[packet.SessionID] {
+		v.endSession(packet.SessionID)
+		return
+	}
+	v.started[packet.SessionID] = true
+	if len(packet.Message) < 16 {
+		return
+	}
+
+	opCode := packet.GetOpCode()
+	if opCode != 2012 && opCode != 2013 {
+		v.legacyOpcodes = append(v.legacyOpcodes, hazard{packet.Order, packet.SessionID,
+			fmt.Sprintf("%s (raw replay rejects it; command mode skips it)", getOpCodeName(opCode))})
+	}
+
+	key, _ := packet.PairingKey()
+
+	if packet.IsRequest() {
+		v.requests++
+		v.seenRequests[key] = true
+		if expectsResponse(packet) {
+			if v.outstanding[packet.SessionID] == nil {
+				v.outstanding[packet.SessionID] = make(map[int32]uint64)
+			}
+			v.outstanding[packet.SessionID][key.RequestID] = packet.Order
+		}
+		v.checkRequest(packet)
+		return
+	}
+
+	v.responses++
+	if !v.seenRequests[key] {
+		v.orphanResponses = append(v.orphanResponses, hazard{packet.Order, packet.SessionID,
+			fmt.Sprintf("response to requestID %d, which is not in the recording", key.RequestID)})
+		return
+	}
+	delete(v.outstanding[packet.SessionID], key.RequestID)
+
+	// Each exhaust-cursor reply answers the previous reply, so its own requestID is
+	// the key the next reply pairs on
+	if opMsgFlags(packet)&opMsgMoreToCome != 0 {
+		requestID := int32(binary.LittleEndian.Uint32(packet.Message[4:8]))
+		v.seenRequests[reader.RequestKey{SessionID: packet.SessionID, RequestID: requestID}] = true
+	}
+
+	// Cursors returned to requests in the recording can be continued
+	if doc, ok := opMsgBody(packet); ok {
+		if id, ok := doc.Lookup("cursor", "id").AsInt64OK(); ok && id != 0 {
+			v.openCursors[id] = true
+		}
+	}
+}
+
+// endSession reports the session's requests that never received a response
+func (v *validator) endSession(sessionID uint64) {
+	for requestID, order := range v.outstanding[sessionID] {
+		v.orphanRequests = append(v.orphanRequests, hazard{order, sessionID,
+			fmt.Sprintf("requestID %d got no response before the session ended", requestID)})
+	}
+	delete(v.outstanding, sessionID)
+}
+
+// inFlight returns the number of requests awaiting a response in sessions still open
+// at the end of the recording; the capture may simply have stopped before the reply
+func (v *validator) inFlight() int {
+	n := 0
+	for _, requests := range v.outstanding {
+		n += len(requests)
+	}
+	return n
+}
+
+// expectsResponse returns true if the server answers this request
+// OP_MSG requests with moreToCome (e.g. w:0 writes) and legacy write opcodes get no reply
+func expectsResponse(packet *reader.Packet) bool {
+	msg, err := packet.WireMessage()
+	if err != nil || len(msg) < 16 {
+		return false
+	}
+	opCode := binary.LittleEndian.Uint32(msg[12:16])
+	if noResponseOpcodes[opCode] {
+		return false
+	}
+	return opCode != 2013 || opMsgFlags(packet)&opMsgMoreToCome == 0
+}
+
+// opMsgFlags returns the flag bits of an OP_MSG (or compressed OP_MSG) packet, or 0
+func opMsgFlags(packet *reader.Packet) uint32 {
+	msg, err := packet.WireMessage()
+	if err != nil || len(msg) < reader.OpMsgSectionsOffset || binary.LittleEndian.Uint32(msg[12:16]) != 2013 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(msg[reader.WireHeaderSize:reader.OpMsgSectionsOffset])
+}
+
+// checkRequest tracks cursor-opening commands and checks getMore/killCursors against them
+func (v *validator) checkRequest(packet *reader.Packet) {
+	cmdName := packet.ExtractCommandName()
+	db := packet.ExtractDatabase()
+
+	if cursorOpeningCommands[cmdName] {
+		v.cursorNamespaces[db+"."+packet.ExtractCollection()] = true
+		return
+	}
+	if cmdName != "getMore" && cmdName != "killCursors" {
+		return
+	}
+
+	doc, ok := opMsgBody(packet)
+	if !ok {
+		return
+	}
+
+	// { getMore: <id>, collection: "c" } and { killCursors: "c", cursors: [<id>...] }
+	var ids []int64
+	var coll string
+	if cmdName == "getMore" {
+		if id, ok := doc.Lookup("getMore").AsInt64OK(); ok {
+			ids = append(ids, id)
+		}
+		coll, _ = doc.Lookup("collection").StringValueOK()
+	} else if cursors, ok := doc.Lookup("cursors").ArrayOK(); ok {
+		values, _ := cursors.Values()
+		for _, value := range values {
+			if id, ok := value.AsInt64OK(); ok {
+				ids = append(ids, id)
+			}
+		}
+		coll, _ = doc.Lookup("killCursors").StringValueOK()
+	}
+	ns := db + "." + coll
+
+	for _, id := range ids {
+		if !v.openCursors[id] {
+			v.byCursorID = append(v.byCursorID, hazard{packet.Order, packet.SessionID,
+				fmt.Sprintf("%s on %s for cursor %d, which no earlier response opened", cmdName, ns, id)})
+		}
+	}
+	if !v.cursorNamespaces[ns] {
+		v.byNamespace = append(v.byNamespace, hazard{packet.Order, packet.SessionID,
+			fmt.Sprintf("%s on %s with no earlier cursor-opening command on that namespace", cmdName, ns)})
+	}
+}
+
+// hazards returns the hazards found, by kind
+// Cursor ids are only known from responses, so recordings without responses fall back to
+// namespaces; they also can't have answered requests, so orphan requests aren't reported
+func (v *validator) hazards() map[string][]hazard {
+	dangling := v.byCursorID
+	var orphanRequests []hazard
+	if v.responses == 0 {
+		dangling = v.byNamespace
+	} else {
+		orphanRequests = v.orphanRequests
+		sort.Slice(orphanRequests, func(i, j int) bool {
+			return orphanRequests[i].order < orphanRequests[j].order
+		})
+	}
+	return map[string][]hazard{
+		"dangling-cursor": dangling,
+		"orphan-response": v.orphanResponses,
+		"orphan-request":  orphanRequests,
+		"legacy-opcode":   v.legacyOpcodes,
+	}
+}
+
+// sessionPairing is one session's count of unpaired requests and responses
+type sessionPairing struct {
+	sessionID       uint64
+	orphanRequests  int
+	orphanResponses int
+}
+
+// pairingBySession counts orphan requests and responses per session, by session ID
+func pairingBySession(hazards map[string][]hazard) []sessionPairing {
+	counts := make(map[uint64]*sessionPairing)
+	get := func(id uint64) *sessionPairing {
+		if counts[id] == nil {
+			counts[id] = &sessionPairing{sessionID: id}
+		}
+		return counts[id]
+	}
+	for _, h := range hazards["orphan-request"] {
+		get(h.sessionID).orphanRequests++
+	}
+	for _, h := range hazards["orphan-response"] {
+		get(h.sessionID).orphanResponses++
+	}
+
+	var sessions []sessionPairing
+	for _, c := range counts {
+		sessions = append(sessions, *c)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].sessionID < sessions[j].sessionID
+	})
+	return sessions
+}
+
+// opMsgBody returns the kind-0 body document of an OP_MSG (or compressed OP_MSG) packet
+func opMsgBody(packet *reader.Packet) (bson.Raw, bool) {
+	msg, err := packet.WireMessage()
+	if err != nil {
+		return nil, false
+	}
+	body, err := sender.DecodeBody(msg)
+	if err != nil {
+		return nil, false
+	}
+	return body.Document, true
+}
+
+func getOpCodeName(code uint32) string {
+	switch code {
+	case 1:
+		return "OP_REPLY (legacy)"
+	case 2001:
+		return "OP_UPDATE (legacy)"
+	case 2002:
+		return "OP_INSERT (legacy)"
+	case 2004:
+		return "OP_QUERY (legacy)"
+	case 2005:
+		return "OP_GET_MORE (legacy)"
+	case 2006:
+		return "OP_DELETE (legacy)"
+	case 2007:
+		return "OP_KILL_CURSORS (legacy)"
+	default:
+		return fmt.Sprintf("opcode %d", code)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <recording-file> [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Check a recording for replay hazards before replaying it:\n")
+	fmt.Fprintf(os.Stderr, "  - getMore/killCursors whose cursor-opening command is absent\n")
+	fmt.Fprintf(os.Stderr, "  - responses without a matching request\n")
+	fmt.Fprintf(os.Stderr, "  - requests without a response by the end of their session\n")
+	fmt.Fprintf(os.Stderr, "  - legacy opcodes\n\n")
+	fmt.Fprintf(os.Stderr, "Exits with status 1 if any hazard is found.\n\n")
+	fmt.Fprintf(os.Stderr, "Options:\n")
+	fmt.Fprintf(os.Stderr, "  --max-per-kind N   Report at most N hazards of each kind (default: 20, 0 = all)\n")
+	fmt.Fprintf(os.Stderr, "\nExample:\n")
+	fmt.Fprintf(os.Stderr, "  %s filtered.bin\n", os.Args[0])
+}
@@ -3,13 +3,13 @@ module github.com/fsnow/traffic-replay
 go 1.25.3
 
 require (
+	github.com/golang/snappy v1.0.0
+	github.com/klauspost/compress v1.16.7
 	go.mongodb.org/mongo-driver v1.17.6
 	go.mongodb.org/mongo-driver/v2 v2.4.0
 )
 
 require (
-	github.com/golang/snappy v1.0.0 // indirect
-	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
 	github.com/xdg-go/scram v1.1.2 // indirect
 	github.com/xdg-go/stringprep v1.0.4 // indirect
@@ -2,14 +2,11 @@ package reader
 
 // ExtractCommandName extracts the MongoDB command name from a packet's message
 // Works for OP_MSG (2013) messages by reading the first BSON field name
+// OP_COMPRESSED messages are decompressed first (see WireMessage)
 // Returns empty string if unable to extract
 func (p *Packet) ExtractCommandName() string {
-	if len(p.Message) < 21 {
-		return ""
-	}
-
-	opCode := p.GetOpCode()
-	if opCode != 2013 { // Only works for OP_MSG
+	msg := p.opMsgWire(MinOpMsgSize) // Only works for OP_MSG
+	if msg == nil {
 		return ""
 	}
 
@@ -19,37 +16,37 @@ func (p *Packet) ExtractCommandName() string {
 	// - Section kind: 1 byte (kind 0 = body)
 	// - BSON document: size(4) + type(1) + name(null-terminated) + ...
 
-	offset := 16 + 4 // Skip wire header + flags
+	offset := OpMsgSectionsOffset // Skip wire header + flags
 
 	// Check section kind (must be 0 for body)
-	if p.Message[offset] != 0 {
+	if msg[offset] != 0 {
 		return ""
 	}
 	offset++
 
 	// Skip BSON document size
-	if offset+4 > len(p.Message) {
+	if offset+BSONLengthSize > len(msg) {
 		return ""
 	}
-	offset += 4
+	offset += BSONLengthSize
 
 	// Read element type (we don't validate it)
-	if offset >= len(p.Message) {
+	if offset >= len(msg) {
 		return ""
 	}
 	offset++
 
 	// Read element name (null-terminated string)
 	nameStart := offset
-	for offset < len(p.Message) && p.Message[offset] != 0 {
+	for offset < len(msg) && msg[offset] != 0 {
 		offset++
 	}
 
-	if offset >= len(p.Message) {
+	if offset >= len(msg) {
 		return ""
 	}
 
-	return string(p.Message[nameStart:offset])
+	return string(msg[nameStart:offset])
 }
 
 // IsUserOperation returns true if this packet contains a user-initiated operation
@@ -125,6 +122,19 @@ func (p *Packet) IsInternalOperation() bool {
 	return replicationOps[cmd] || monitoringOps[cmd] || internalOps[cmd]
 }
 
+// IsWriteOperation returns true if this packet's command changes data or schema
+// That is every "crud" and "ddl" command (see GetCommandCategory) except the
+// read-only listIndexes.
+func (p *Packet) IsWriteOperation() bool {
+	switch p.GetCommandCategory() {
+	case "crud":
+		return true
+	case "ddl":
+		return p.ExtractCommandName() != "listIndexes"
+	}
+	return false
+}
+
 // GetCommandCategory returns a human-readable category for the command
 func (p *Packet) GetCommandCategory() string {
 	cmd := p.ExtractCommandName()
@@ -0,0 +1,157 @@
+package reader
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// buildOpQuery creates a legacy OP_QUERY wire message on the given namespace
+func buildOpQuery(t testing.TB, ns string, doc bson.D) []byte {
+	t.Helper()
+
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	msg := buildWireMessage(int32(16+4+len(ns)+1+8+len(body)), 7, 0, 2004)
+	msg = append(msg, 0, 0, 0, 0) // flags
+	msg = append(msg, ns...)
+	msg = append(msg, 0)
+	msg = append(msg, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff) // numberToSkip, numberToReturn
+	return append(msg, body...)
+}
+
+// commandPacket builds a request packet carrying an OP_MSG with the given body
+func commandPacket(t testing.TB, doc bson.D) *Packet {
+	t.Helper()
+	return &Packet{EventType: EventTypeRegular, Message: buildOpMsg(t, doc)}
+}
+
+func TestExtractCommandName(t *testing.T) {
+	insert := buildOpMsg(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}})
+
+	// A kind-1 section first: ExtractCommandName only reads a leading kind-0 body
+	kind1First := append([]byte{}, insert[:20]...)
+	kind1First = append(kind1First, 1)
+	kind1First = append(kind1First, insert[21:]...)
+
+	tests := []struct {
+		name    string
+		message []byte
+		want    string
+	}{
+		{"insert", insert, "insert"},
+		{"find", buildOpMsg(t, bson.D{{Key: "find", Value: "users"}, {Key: "filter", Value: bson.D{}}}), "find"},
+		{"getMore", buildOpMsg(t, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "users"}}), "getMore"},
+		{"hello", buildOpMsg(t, bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "admin"}}), "hello"},
+		{"compressed", compressOpMsg(t, insert, CompressorZlib), "insert"},
+		{"empty message", nil, ""},
+		{"header only", insert[:16], ""},
+		{"truncated name", insert[:24], ""},
+		{"OP_QUERY", buildOpQuery(t, "admin.$cmd", bson.D{{Key: "isMaster", Value: int32(1)}}), ""},
+		{"kind-1 section first", kind1First, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Packet{Message: tt.message}
+			if got := p.ExtractCommandName(); got != tt.want {
+				t.Errorf("ExtractCommandName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandClassification(t *testing.T) {
+	tests := []struct {
+		command  string
+		user     bool
+		internal bool
+		category string
+	}{
+		{"insert", true, false, "crud"},
+		{"find", true, false, "read"},
+		{"getMore", false, true, "read-continuation"},
+		{"createIndexes", true, false, "ddl"},
+		{"explain", true, false, "other"},
+		{"hello", false, true, "health-check"},
+		{"replSetHeartbeat", false, true, "replication"},
+		{"_configsvrCommitChunkSplit", false, true, "other"},
+		{"startRecordingTraffic", false, false, "recording-control"},
+		{"listDatabases", false, false, "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			p := commandPacket(t, bson.D{{Key: tt.command, Value: int32(1)}, {Key: "$db", Value: "app"}})
+			if got := p.IsUserOperation(); got != tt.user {
+				t.Errorf("IsUserOperation() = %v, want %v", got, tt.user)
+			}
+			if got := p.IsInternalOperation(); got != tt.internal {
+				t.Errorf("IsInternalOperation() = %v, want %v", got, tt.internal)
+			}
+			if got := p.GetCommandCategory(); got != tt.category {
+				t.Errorf("GetCommandCategory() = %q, want %q", got, tt.category)
+			}
+		})
+	}
+}
+
+func TestIsWriteOperation(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"insert", true},
+		{"update", true},
+		{"delete", true},
+		{"findAndModify", true},
+		{"create", true},
+		{"drop", true},
+		{"createIndexes", true},
+		{"dropIndexes", true},
+		{"renameCollection", true},
+		{"collMod", true},
+		{"listIndexes", false},
+		{"find", false},
+		{"aggregate", false},
+		{"getMore", false},
+		{"hello", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			p := commandPacket(t, bson.D{{Key: tt.command, Value: "users"}, {Key: "$db", Value: "app"}})
+			if got := p.IsWriteOperation(); got != tt.want {
+				t.Errorf("IsWriteOperation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (&Packet{}).IsWriteOperation() {
+		t.Error("IsWriteOperation() = true for an empty message")
+	}
+}
+
+func TestGetCommandCategory_NoCommandName(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		want    string
+	}{
+		{"OP_QUERY", buildOpQuery(t, "admin.$cmd", bson.D{{Key: "isMaster", Value: int32(1)}}), "legacy-query"},
+		{"OP_REPLY", buildWireMessage(36, 7, 3, 1), "legacy-reply"},
+		{"empty message", nil, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Packet{Message: tt.message}
+			if got := p.GetCommandCategory(); got != tt.want {
+				t.Errorf("GetCommandCategory() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
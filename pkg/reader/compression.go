@@ -0,0 +1,154 @@
+package reader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor IDs used in OP_COMPRESSED messages
+const (
+	CompressorNoop   byte = 0
+	CompressorSnappy byte = 1
+	CompressorZlib   byte = 2
+	CompressorZstd   byte = 3
+)
+
+// maxUncompressedSize bounds the uncompressed size claimed by an OP_COMPRESSED header
+// (48MB is the server's maximum wire message size)
+const maxUncompressedSize = 48 * 1024 * 1024
+
+// decompressFunc is the decompressor used by Packet; tests replace it to count calls
+var decompressFunc = DecompressMessage
+
+// DecompressMessage converts an OP_COMPRESSED (2012) wire message into the original message
+//
+// OP_COMPRESSED format:
+//   header            : 16 bytes  - Standard wire header (opCode = 2012)
+//   originalOpcode    : int32 LE  - OpCode of the wrapped message
+//   uncompressedSize  : int32 LE  - Size of the wrapped message body (excluding header)
+//   compressorId      : uint8     - 0=noop, 1=snappy, 2=zlib, 3=zstd
+//   compressedMessage : []byte    - Compressed body
+//
+// The returned message has a rebuilt 16-byte header carrying the original requestID,
+// responseTo and opCode, followed by the decompressed body
+func DecompressMessage(message []byte) ([]byte, error) {
+	if len(message) < 25 {
+		return nil, fmt.Errorf("compressed message too short: %d bytes", len(message))
+	}
+	if opCode := binary.LittleEndian.Uint32(message[12:16]); opCode != 2012 {
+		return nil, fmt.Errorf("not an OP_COMPRESSED message (opCode %d)", opCode)
+	}
+
+	originalOpCode := binary.LittleEndian.Uint32(message[16:20])
+	uncompressedSize := binary.LittleEndian.Uint32(message[20:24])
+	compressorID := message[24]
+	compressed := message[25:]
+
+	if uncompressedSize > maxUncompressedSize {
+		return nil, fmt.Errorf("uncompressed size %d exceeds maximum %d", uncompressedSize, maxUncompressedSize)
+	}
+
+	body, err := decompressBody(compressorID, compressed, int(uncompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) != int(uncompressedSize) {
+		return nil, fmt.Errorf("decompressed size %d does not match header size %d", len(body), uncompressedSize)
+	}
+
+	out := make([]byte, 16+len(body))
+	binary.LittleEndian.PutUint32(out[0:4], uint32(len(out)))
+	copy(out[4:12], message[4:12]) // requestID and responseTo
+	binary.LittleEndian.PutUint32(out[12:16], originalOpCode)
+	copy(out[16:], body)
+	return out, nil
+}
+
+// decompressBody decompresses an OP_COMPRESSED payload with the given compressor
+func decompressBody(compressorID byte, compressed []byte, uncompressedSize int) ([]byte, error) {
+	switch compressorID {
+	case CompressorNoop:
+		return compressed, nil
+
+	case CompressorSnappy:
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress snappy payload: %w", err)
+		}
+		return body, nil
+
+	case CompressorZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zlib payload: %w", err)
+		}
+		defer zr.Close()
+
+		body := make([]byte, uncompressedSize)
+		if _, err := io.ReadFull(zr, body); err != nil {
+			return nil, fmt.Errorf("failed to decompress zlib payload: %w", err)
+		}
+		return body, nil
+
+	case CompressorZstd:
+		zr, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		defer zr.Close()
+
+		body, err := zr.DecodeAll(compressed, make([]byte, 0, uncompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd payload: %w", err)
+		}
+		return body, nil
+
+	default:
+		return nil, fmt.Errorf("unknown compressor ID: %d", compressorID)
+	}
+}
+
+// WireMessage returns the packet's wire message with any OP_COMPRESSED wrapper removed
+// The decompressed message is computed on first use and cached on the packet, so
+// repeated extraction calls (ExtractCommandName, ExtractDatabase, ...) decompress once.
+// Uncompressed messages are returned as-is. A Packet is not safe for concurrent use.
+func (p *Packet) WireMessage() ([]byte, error) {
+	if len(p.Message) < 16 || p.GetOpCode() != 2012 {
+		return p.Message, nil
+	}
+
+	if !p.decompressed {
+		p.wireMessage, p.decompressErr = decompressFunc(p.Message)
+		p.decompressed = true
+	}
+	return p.wireMessage, p.decompressErr
+}
+
+// wire returns the decompressed wire message for extraction helpers (nil if it can't be decoded)
+func (p *Packet) wire() []byte {
+	msg, err := p.WireMessage()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// opMsgWire returns the decompressed wire message if it is an OP_MSG (2013) of at least
+// minLen bytes, else nil. OP_COMPRESSED messages are unwrapped first, so the extraction
+// helpers see compressed and uncompressed OP_MSG traffic alike.
+func (p *Packet) opMsgWire(minLen int) []byte {
+	msg := p.wire()
+	if len(msg) < minLen || len(msg) < WireHeaderSize {
+		return nil
+	}
+	if binary.LittleEndian.Uint32(msg[12:16]) != 2013 {
+		return nil
+	}
+	return msg
+}
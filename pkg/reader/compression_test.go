@@ -0,0 +1,186 @@
+package reader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// buildOpMsg creates an uncompressed OP_MSG wire message with a single kind-0 body section
+func buildOpMsg(t testing.TB, doc bson.D) []byte {
+	t.Helper()
+
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	msg := buildWireMessage(int32(16+4+1+len(body)), 7, 0, 2013)
+	msg = append(msg, 0, 0, 0, 0) // flags
+	msg = append(msg, 0)          // section kind 0
+	return append(msg, body...)
+}
+
+// compressOpMsg wraps an uncompressed wire message in OP_COMPRESSED using the given compressor
+func compressOpMsg(t testing.TB, msg []byte, compressorID byte) []byte {
+	t.Helper()
+
+	body := msg[16:]
+	var compressed []byte
+	switch compressorID {
+	case CompressorNoop:
+		compressed = body
+	case CompressorSnappy:
+		compressed = snappy.Encode(nil, body)
+	case CompressorZlib:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		compressed = buf.Bytes()
+	case CompressorZstd:
+		zw, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatalf("failed to create zstd writer: %v", err)
+		}
+		compressed = zw.EncodeAll(body, nil)
+		zw.Close()
+	}
+
+	out := buildWireMessage(int32(16+9+len(compressed)), 7, 0, 2012)
+	out = binary.LittleEndian.AppendUint32(out, binary.LittleEndian.Uint32(msg[12:16]))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, compressorID)
+	return append(out, compressed...)
+}
+
+func TestDecompressMessage(t *testing.T) {
+	original := buildOpMsg(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}})
+
+	compressors := map[string]byte{
+		"noop":   CompressorNoop,
+		"snappy": CompressorSnappy,
+		"zlib":   CompressorZlib,
+		"zstd":   CompressorZstd,
+	}
+
+	for name, id := range compressors {
+		t.Run(name, func(t *testing.T) {
+			got, err := DecompressMessage(compressOpMsg(t, original, id))
+			if err != nil {
+				t.Fatalf("DecompressMessage failed: %v", err)
+			}
+			if !bytes.Equal(got, original) {
+				t.Errorf("decompressed message differs from original\ngot:  %x\nwant: %x", got, original)
+			}
+		})
+	}
+}
+
+func TestDecompressMessage_Invalid(t *testing.T) {
+	original := buildOpMsg(t, bson.D{{Key: "ping", Value: 1}, {Key: "$db", Value: "admin"}})
+
+	if _, err := DecompressMessage(original); err == nil {
+		t.Error("expected error for uncompressed message")
+	}
+
+	unknown := compressOpMsg(t, original, CompressorNoop)
+	unknown[24] = 9
+	if _, err := DecompressMessage(unknown); err == nil {
+		t.Error("expected error for unknown compressor")
+	}
+
+	wrongSize := compressOpMsg(t, original, CompressorNoop)
+	binary.LittleEndian.PutUint32(wrongSize[20:24], 1)
+	if _, err := DecompressMessage(wrongSize); err == nil {
+		t.Error("expected error for mismatched uncompressed size")
+	}
+}
+
+func TestPacket_ExtractionFromCompressed(t *testing.T) {
+	original := buildOpMsg(t, bson.D{{Key: "find", Value: "orders"}, {Key: "$db", Value: "shop"}})
+	packet := &Packet{Message: compressOpMsg(t, original, CompressorSnappy)}
+
+	if got := packet.ExtractCommandName(); got != "find" {
+		t.Errorf("ExtractCommandName() = %q, want %q", got, "find")
+	}
+	if got := packet.ExtractDatabase(); got != "shop" {
+		t.Errorf("ExtractDatabase() = %q, want %q", got, "shop")
+	}
+	if got := packet.ExtractCollection(); got != "orders" {
+		t.Errorf("ExtractCollection() = %q, want %q", got, "orders")
+	}
+	if got := packet.GetOpCode(); got != 2012 {
+		t.Errorf("GetOpCode() = %d, want 2012 (raw opcode is unchanged)", got)
+	}
+}
+
+func TestPacket_WireMessageCachesDecompression(t *testing.T) {
+	calls := 0
+	defer func(orig func([]byte) ([]byte, error)) { decompressFunc = orig }(decompressFunc)
+	decompressFunc = func(message []byte) ([]byte, error) {
+		calls++
+		return DecompressMessage(message)
+	}
+
+	original := buildOpMsg(t, bson.D{{Key: "update", Value: "users"}, {Key: "$db", Value: "app"}})
+	packet := &Packet{Message: compressOpMsg(t, original, CompressorZlib)}
+
+	packet.ExtractCommandName()
+	packet.ExtractDatabase()
+	packet.ExtractCollection()
+	packet.IsLikelyUserOperation()
+
+	if calls != 1 {
+		t.Errorf("decompressed %d times, want 1", calls)
+	}
+
+	// Uncompressed packets never reach the decompressor
+	plain := &Packet{Message: original}
+	plain.ExtractCommandName()
+	if calls != 1 {
+		t.Errorf("uncompressed packet was decompressed")
+	}
+}
+
+// benchmarkExtraction runs the extraction helpers analyze uses on one compressed packet
+func benchmarkExtraction(b *testing.B, fresh bool) {
+	original := buildOpMsg(b, bson.D{
+		{Key: "find", Value: "orders"},
+		{Key: "filter", Value: bson.D{{Key: "status", Value: "open"}}},
+		{Key: "$db", Value: "shop"},
+	})
+	message := compressOpMsg(b, original, CompressorSnappy)
+
+	calls := 0
+	defer func(orig func([]byte) ([]byte, error)) { decompressFunc = orig }(decompressFunc)
+	decompressFunc = func(message []byte) ([]byte, error) {
+		calls++
+		return DecompressMessage(message)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if fresh {
+			// A new Packet per helper models re-decompressing on every call
+			(&Packet{Message: message}).ExtractCommandName()
+			(&Packet{Message: message}).ExtractDatabase()
+			(&Packet{Message: message}).ExtractCollection()
+			continue
+		}
+
+		packet := &Packet{Message: message}
+		packet.ExtractCommandName()
+		packet.ExtractDatabase()
+		packet.ExtractCollection()
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "decompress/op")
+}
+
+func BenchmarkExtraction_Cached(b *testing.B)   { benchmarkExtraction(b, false) }
+func BenchmarkExtraction_Uncached(b *testing.B) { benchmarkExtraction(b, true) }
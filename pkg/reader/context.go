@@ -8,18 +8,14 @@ import (
 // ExtractDatabase attempts to extract the database name from a packet
 // Returns empty string if unable to extract
 func (p *Packet) ExtractDatabase() string {
-	if len(p.Message) < 21 {
-		return ""
-	}
-
-	opCode := p.GetOpCode()
-	if opCode != 2013 {
+	msg := p.opMsgWire(MinOpMsgSize)
+	if msg == nil {
 		return ""
 	}
 
 	// Try to find $db field in BSON document
 	// This is a simplified parser - looks for "$db" string followed by string value
-	msgStr := string(p.Message)
+	msgStr := string(msg)
 	idx := strings.Index(msgStr, "$db")
 	if idx == -1 {
 		return ""
@@ -28,20 +24,20 @@ func (p *Packet) ExtractDatabase() string {
 	// Skip "$db\0" and BSON string type (0x02)
 	// Then read string length and value
 	idx += 4 // Skip "$db\0"
-	if idx+4 >= len(p.Message) {
+	if idx+4 >= len(msg) {
 		return ""
 	}
 
 	// Read string length (little-endian uint32)
-	strLen := binary.LittleEndian.Uint32(p.Message[idx : idx+4])
+	strLen := binary.LittleEndian.Uint32(msg[idx : idx+4])
 	idx += 4
 
-	if idx+int(strLen) > len(p.Message) {
+	if idx+int(strLen) > len(msg) {
 		return ""
 	}
 
 	// String is null-terminated, so length includes the null byte
-	return string(p.Message[idx : idx+int(strLen)-1])
+	return string(msg[idx : idx+int(strLen)-1])
 }
 
 // ExtractCollection attempts to extract the collection name from a packet
@@ -59,44 +55,40 @@ func (p *Packet) ExtractCollection() string {
 	// For now, we'll use a simplified approach by looking at the BSON structure
 	// This is a heuristic and may not work for all cases
 
-	if len(p.Message) < 30 {
-		return ""
-	}
-
-	opCode := p.GetOpCode()
-	if opCode != 2013 {
+	msg := p.opMsgWire(30)
+	if msg == nil {
 		return ""
 	}
 
-	offset := 16 + 4 + 1 + 4 + 1 // header + flags + section kind + bson size + element type
+	offset := OpMsgBodyOffset() + BSONLengthSize + 1 // header + flags + section kind + bson size + element type
 
 	// Skip command name
-	for offset < len(p.Message) && p.Message[offset] != 0 {
+	for offset < len(msg) && msg[offset] != 0 {
 		offset++
 	}
 	offset++ // Skip null terminator
 
 	// Now we're at the value of the command field
 	// For commands like insert/find/update/delete, this is a BSON string (type 0x02)
-	if offset >= len(p.Message) {
+	if offset >= len(msg) {
 		return ""
 	}
 
 	// Check if it's a string type (0x02)
-	if offset > 0 && p.Message[offset-1-len(cmd)-1] == 0x02 {
+	if offset > 0 && msg[offset-1-len(cmd)-1] == 0x02 {
 		// Read string length
-		if offset+4 > len(p.Message) {
+		if offset+4 > len(msg) {
 			return ""
 		}
-		strLen := binary.LittleEndian.Uint32(p.Message[offset : offset+4])
+		strLen := binary.LittleEndian.Uint32(msg[offset : offset+4])
 		offset += 4
 
-		if offset+int(strLen) > len(p.Message) {
+		if offset+int(strLen) > len(msg) {
 			return ""
 		}
 
 		// String is null-terminated
-		return string(p.Message[offset : offset+int(strLen)-1])
+		return string(msg[offset : offset+int(strLen)-1])
 	}
 
 	return ""
@@ -0,0 +1,91 @@
+package reader
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestExtractDatabaseAndCollection(t *testing.T) {
+	insert := buildOpMsg(t, bson.D{{Key: "insert", Value: "users"}, {Key: "ordered", Value: true}, {Key: "$db", Value: "app"}})
+
+	tests := []struct {
+		name     string
+		message  []byte
+		wantDB   string
+		wantColl string
+	}{
+		{"insert", insert, "app", "users"},
+		{"find", buildOpMsg(t, bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.D{}}, {Key: "$db", Value: "shop"}}), "shop", "orders"},
+		// getMore's command value is the cursor id; the collection is a separate field
+		{"getMore", buildOpMsg(t, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}), "app", ""},
+		{"hello", buildOpMsg(t, bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "admin"}}), "admin", ""},
+		{"no $db", buildOpMsg(t, bson.D{{Key: "find", Value: "orders"}}), "", "orders"},
+		{"compressed", compressOpMsg(t, insert, CompressorZstd), "app", "users"},
+		{"empty message", nil, "", ""},
+		{"header only", insert[:16], "", ""},
+		{"OP_QUERY", buildOpQuery(t, "admin.$cmd", bson.D{{Key: "isMaster", Value: int32(1)}, {Key: "$db", Value: "admin"}}), "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Packet{Message: tt.message}
+			if got := p.ExtractDatabase(); got != tt.wantDB {
+				t.Errorf("ExtractDatabase() = %q, want %q", got, tt.wantDB)
+			}
+			if got := p.ExtractCollection(); got != tt.wantColl {
+				t.Errorf("ExtractCollection() = %q, want %q", got, tt.wantColl)
+			}
+		})
+	}
+}
+
+func TestIsInternalDatabaseAndCollection(t *testing.T) {
+	for _, db := range []string{"local", "admin", "config"} {
+		if !IsInternalDatabase(db) {
+			t.Errorf("IsInternalDatabase(%q) = false, want true", db)
+		}
+	}
+	if IsInternalDatabase("app") {
+		t.Error(`IsInternalDatabase("app") = true, want false`)
+	}
+
+	for _, coll := range []string{"system.sessions", "oplog.rs", "replset.minvalid"} {
+		if !IsInternalCollection(coll) {
+			t.Errorf("IsInternalCollection(%q) = false, want true", coll)
+		}
+	}
+	if IsInternalCollection("users") {
+		t.Error(`IsInternalCollection("users") = true, want false`)
+	}
+}
+
+func TestIsLikelyUserOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  bson.D
+		want bool
+	}{
+		{"insert on user db", bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}, true},
+		{"insert on config", bson.D{{Key: "insert", Value: "system.sessions"}, {Key: "$db", Value: "config"}}, false},
+		{"update on admin", bson.D{{Key: "update", Value: "users"}, {Key: "$db", Value: "admin"}}, false},
+		{"find on user db", bson.D{{Key: "find", Value: "orders"}, {Key: "$db", Value: "shop"}}, true},
+		{"find on admin", bson.D{{Key: "find", Value: "orders"}, {Key: "$db", Value: "admin"}}, false},
+		{"getMore on user db", bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "orders"}, {Key: "$db", Value: "shop"}}, true},
+		{"getMore on local", bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "oplog.rs"}, {Key: "$db", Value: "local"}}, false},
+		{"hello", bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "app"}}, false},
+		{"unknown command", bson.D{{Key: "fooBar", Value: int32(1)}, {Key: "$db", Value: "app"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandPacket(t, tt.doc).IsLikelyUserOperation(); got != tt.want {
+				t.Errorf("IsLikelyUserOperation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (&Packet{}).IsLikelyUserOperation() {
+		t.Error("IsLikelyUserOperation() = true for an empty packet")
+	}
+}
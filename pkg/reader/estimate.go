@@ -0,0 +1,63 @@
+package reader
+
+import (
+	"fmt"
+	"io"
+)
+
+// estimateSamplePackets is how many leading packets EstimatePacketCount measures
+const estimateSamplePackets = 1000
+
+// EstimatePacketCount returns an approximate number of packets in a recording file
+// It measures the average size of the first packets and divides the file size by it,
+// so it costs a few reads however large the file is. The result is only an estimate:
+// it is off by as much as the opening packets' sizes differ from the rest of the file
+// (e.g. a burst of small handshakes followed by large bulk writes). Files with no more
+// than the sampled packets are counted exactly. For an exact count, read the whole file.
+func EstimatePacketCount(path string) (int, error) {
+	r, err := NewRecordingReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	start, err := r.Position()
+	if err != nil {
+		return 0, err
+	}
+
+	sampled := 0
+	for sampled < estimateSamplePackets {
+		if _, err := r.skipPacket(); err == io.EOF {
+			// The whole file was sampled, so this is the exact count
+			return sampled, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to sample packet %d of %s: %w", sampled+1, path, err)
+		}
+		sampled++
+	}
+
+	pos, err := r.Position()
+	if err != nil {
+		return 0, err
+	}
+	avg := float64(pos-start) / float64(sampled)
+	return int(float64(r.Size()-start)/avg + 0.5), nil
+}
+
+// skipPacket reads past the next packet without allocating its message
+// Returns the packet's header fields; its Message is left empty.
+func (r *RecordingReader) skipPacket() (*Packet, error) {
+	if r.atTrailingPartial() {
+		return nil, io.EOF
+	}
+	packet, messageSize, err := readPacketHeader(r.reader, r.format)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.reader.Discard(messageSize); err != nil {
+		return nil, fmt.Errorf("failed to skip message data: %w", err)
+	}
+	r.next += int64(packet.Size)
+	return packet, nil
+}
@@ -0,0 +1,58 @@
+package reader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// writeUniformRecording writes n equally sized request packets with a file header
+func writeUniformRecording(t *testing.T, path string, n int) {
+	t.Helper()
+
+	w, err := NewRecordingWriter(path, WriterOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		packet := &Packet{SessionID: 1, Offset: uint64(i), Order: uint64(i), Message: buildWireMessage(16, int32(i+1), 0, 2013)}
+		if err := w.Write(packet); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestEstimatePacketCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets int
+	}{
+		{"empty", 0},
+		{"fewer than the sample", 10},
+		{"larger than the sample", 3*estimateSamplePackets + 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rec.bin")
+			writeUniformRecording(t, path, tt.packets)
+
+			// Equal packet sizes make the estimate exact
+			got, err := EstimatePacketCount(path)
+			if err != nil {
+				t.Fatalf("EstimatePacketCount failed: %v", err)
+			}
+			if got != tt.packets {
+				t.Errorf("EstimatePacketCount = %d, want %d", got, tt.packets)
+			}
+		})
+	}
+}
+
+func TestEstimatePacketCount_MissingFile(t *testing.T) {
+	if _, err := EstimatePacketCount(filepath.Join(t.TempDir(), "missing.bin")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
@@ -0,0 +1,64 @@
+package reader
+
+// EventReader yields packets with their EventType inferred, looking one packet ahead
+// A packet with a wire message is Regular. An empty-message packet is a SessionStart if
+// its session has no packets yet (or none since its last end), or if the next packet
+// continues the session with data; otherwise it is a SessionEnd. Session IDs reused
+// after an end start a new session. RecordingReader and RecordingSet leave every packet
+// Regular, since a single packet can't tell a start marker from an end marker.
+type EventReader struct {
+	src PacketIterator
+
+	// peeked is the packet read ahead by Peek (nil if none); peekErr the error reading it
+	peeked  *Packet
+	peekErr error
+
+	// open holds the sessions that have started and not yet ended
+	open map[uint64]bool
+}
+
+// NewEventReader creates an EventReader reading from src
+func NewEventReader(src PacketIterator) *EventReader {
+	return &EventReader{
+		src:  src,
+		open: make(map[uint64]bool),
+	}
+}
+
+// Peek returns the next packet without consuming it
+// Its EventType is only inferred once Next returns it.
+func (r *EventReader) Peek() (*Packet, error) {
+	if r.peeked == nil && r.peekErr == nil {
+		r.peeked, r.peekErr = r.src.Next()
+	}
+	return r.peeked, r.peekErr
+}
+
+// Next returns the next packet with its EventType set
+// Returns io.EOF when there are no more packets
+func (r *EventReader) Next() (*Packet, error) {
+	packet, err := r.Peek()
+	if err != nil {
+		return nil, err
+	}
+	r.peeked = nil
+
+	if len(packet.Message) > 0 {
+		packet.EventType = EventTypeRegular
+		r.open[packet.SessionID] = true
+		return packet, nil
+	}
+
+	// A read error after this packet is returned by the following call to Next
+	next, err := r.Peek()
+	continues := err == nil && next.SessionID == packet.SessionID && len(next.Message) > 0
+
+	if continues || !r.open[packet.SessionID] {
+		packet.EventType = EventTypeSessionStart
+		r.open[packet.SessionID] = true
+	} else {
+		packet.EventType = EventTypeSessionEnd
+		delete(r.open, packet.SessionID)
+	}
+	return packet, nil
+}
@@ -0,0 +1,104 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEventReader(t *testing.T) {
+	msg := buildWireMessage(16, 1, 0, 2013)
+	packets := append(interleavedSessions(),
+		// Session 1's ID is reused after its end marker
+		&Packet{SessionID: 1, Order: 10},
+		&Packet{SessionID: 1, Order: 11, Message: msg},
+		// Session 4 restarts without an end marker: the empty packet is followed by data
+		&Packet{SessionID: 4, Order: 12, Message: msg},
+		&Packet{SessionID: 4, Order: 13},
+		&Packet{SessionID: 4, Order: 14, Message: msg},
+		&Packet{SessionID: 4, Order: 15},
+	)
+
+	want := []EventType{
+		EventTypeSessionStart, // 1 start
+		EventTypeSessionStart, // 2 start
+		EventTypeRegular,
+		EventTypeRegular,
+		EventTypeRegular,
+		EventTypeSessionEnd, // 3 end (it had no start marker)
+		EventTypeRegular,
+		EventTypeSessionEnd, // 1 end
+		EventTypeRegular,
+		EventTypeSessionStart, // 1 reused
+		EventTypeRegular,
+		EventTypeRegular,
+		EventTypeSessionStart, // 4 continues with data
+		EventTypeRegular,
+		EventTypeSessionEnd, // 4 end at EOF
+	}
+
+	r := NewEventReader(&packetList{packets: packets})
+	for i, wantType := range want {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next %d failed: %v", i, err)
+		}
+		if p.Order != packets[i].Order || p.EventType != wantType {
+			t.Errorf("packet %d: order %d is %s, want order %d as %s", i, p.Order, p.EventType, packets[i].Order, wantType)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next after last packet = %v, want io.EOF", err)
+	}
+}
+
+func TestEventReader_Peek(t *testing.T) {
+	msg := buildWireMessage(16, 1, 0, 2013)
+	r := NewEventReader(&packetList{packets: []*Packet{
+		{SessionID: 1, Order: 1, Message: msg},
+		{SessionID: 1, Order: 2},
+	}})
+
+	for i := 0; i < 2; i++ {
+		p, err := r.Peek()
+		if err != nil || p.Order != 1 {
+			t.Fatalf("Peek %d = %v, %v; want order 1", i, p, err)
+		}
+	}
+	if p, _ := r.Next(); p.Order != 1 {
+		t.Errorf("Next after Peek returned order %d, want 1", p.Order)
+	}
+	if p, _ := r.Next(); p.Order != 2 || p.EventType != EventTypeSessionEnd {
+		t.Errorf("second Next = order %d %s, want order 2 SessionEnd", p.Order, p.EventType)
+	}
+	if _, err := r.Peek(); err != io.EOF {
+		t.Errorf("Peek at end = %v, want io.EOF", err)
+	}
+}
+
+// failingList yields its packets, then err
+type failingList struct {
+	packetList
+	err error
+}
+
+func (l *failingList) Next() (*Packet, error) {
+	if l.idx >= len(l.packets) {
+		return nil, l.err
+	}
+	return l.packetList.Next()
+}
+
+func TestEventReader_ReadErrorAfterMarker(t *testing.T) {
+	readErr := errors.New("disk error")
+	r := NewEventReader(&failingList{packetList: packetList{packets: []*Packet{{SessionID: 1}}}, err: readErr})
+
+	// The marker is still returned; the error surfaces on the next call
+	p, err := r.Next()
+	if err != nil || p.EventType != EventTypeSessionStart {
+		t.Fatalf("Next = %v, %v; want the start marker", p, err)
+	}
+	if _, err := r.Next(); !errors.Is(err, readErr) {
+		t.Errorf("Next = %v, want %v", err, readErr)
+	}
+}
@@ -0,0 +1,87 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter decides which packets a tool keeps
+// Filters may be stateful (NamespaceFilter drops the responses to the requests it
+// dropped), so each must see every packet in recording order.
+type Filter interface {
+	Keep(p *Packet) bool
+}
+
+// FilterFunc adapts a stateless function to a Filter
+type FilterFunc func(p *Packet) bool
+
+// Keep reports whether the function keeps the packet
+func (f FilterFunc) Keep(p *Packet) bool {
+	return f(p)
+}
+
+// CommandCategories are the categories GetCommandCategory returns
+var CommandCategories = []string{
+	"crud", "read", "read-continuation", "ddl", "health-check", "info", "replication",
+	"admin", "recording-control", "legacy-query", "legacy-reply", "other", "unknown",
+}
+
+// RequestsOnly returns a filter keeping requests and dropping responses
+func RequestsOnly() Filter {
+	return FilterFunc(func(p *Packet) bool {
+		return p.IsRequest()
+	})
+}
+
+// UserOpsOnly returns a filter keeping packets that are likely user operations
+// (see IsLikelyUserOperation)
+func UserOpsOnly() Filter {
+	return FilterFunc(func(p *Packet) bool {
+		return p.IsLikelyUserOperation()
+	})
+}
+
+// CategoryFilter returns a filter keeping requests whose command is in one of the
+// categories (see GetCommandCategory), e.g. "crud" and "read" for CRUD-only traffic.
+// Responses and session events don't name a command, so they are kept; combine with
+// RequestsOnly to drop them.
+func CategoryFilter(categories ...string) (Filter, error) {
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no command categories given")
+	}
+	allowed := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if !isCommandCategory(c) {
+			return nil, fmt.Errorf("invalid command category '%s'. Must be one of: %s", c, strings.Join(CommandCategories, ", "))
+		}
+		allowed[c] = true
+	}
+	return FilterFunc(func(p *Packet) bool {
+		return !p.IsRequest() || allowed[p.GetCommandCategory()]
+	}), nil
+}
+
+// isCommandCategory reports whether c is one of CommandCategories
+func isCommandCategory(c string) bool {
+	for _, known := range CommandCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// AllFilters returns a filter keeping packets that every filter keeps
+// Filters are applied in order and stop at the first that drops a packet, so a
+// stateful filter that must see every packet belongs first.
+func AllFilters(filters ...Filter) Filter {
+	return FilterFunc(func(p *Packet) bool {
+		for _, f := range filters {
+			if !f.Keep(p) {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,58 @@
+package reader
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCategoryFilter(t *testing.T) {
+	crud, err := CategoryFilter("crud", " read ")
+	if err != nil {
+		t.Fatalf("CategoryFilter failed: %v", err)
+	}
+
+	response := commandPacket(t, bson.D{{Key: "ok", Value: 1.0}})
+	binary.LittleEndian.PutUint32(response.Message[8:12], 7)
+
+	packets := []struct {
+		name   string
+		packet *Packet
+		want   bool
+	}{
+		{"insert", commandPacket(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}), true},
+		{"find", commandPacket(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}), true},
+		{"hello", commandPacket(t, bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "admin"}}), false},
+		{"createIndexes", commandPacket(t, bson.D{{Key: "createIndexes", Value: "users"}, {Key: "$db", Value: "app"}}), false},
+		{"response", response, true},
+		{"session event", &Packet{EventType: EventTypeSessionStart}, true},
+	}
+	for _, tt := range packets {
+		if got := crud.Keep(tt.packet); got != tt.want {
+			t.Errorf("%s: Keep = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := CategoryFilter("crud", "writes"); err == nil {
+		t.Error("CategoryFilter accepted unknown category 'writes'")
+	}
+	if _, err := CategoryFilter(); err == nil {
+		t.Error("CategoryFilter accepted no categories")
+	}
+}
+
+func TestAllFilters(t *testing.T) {
+	crud, _ := CategoryFilter("crud")
+	f := AllFilters(RequestsOnly(), crud)
+
+	insert := commandPacket(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}})
+	response := commandPacket(t, bson.D{{Key: "ok", Value: 1.0}})
+	binary.LittleEndian.PutUint32(response.Message[8:12], 7)
+	find := commandPacket(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})
+
+	if !f.Keep(insert) || f.Keep(response) || f.Keep(find) {
+		t.Errorf("Keep(insert, response, find) = %v, %v, %v; want true, false, false",
+			f.Keep(insert), f.Keep(response), f.Keep(find))
+	}
+}
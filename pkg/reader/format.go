@@ -0,0 +1,143 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// ErrByteOrder reports a packet size that only makes sense read big-endian
+// Recordings are little-endian on every platform the server writes them on, so this
+// usually means the file was converted or written by another tool, or isn't a recording.
+var ErrByteOrder = errors.New("file may be big-endian or not a recording")
+
+// maxWireMessageSize is the largest wire message a server sends or accepts (maxMessageSizeBytes)
+const maxWireMessageSize = 48000000
+
+// maxSessionMetadataSize is the longest session metadata string readPacketHeader accepts
+const maxSessionMetadataSize = 10000
+
+// PacketFormat identifies the packet layout of a recording
+type PacketFormat uint8
+
+const (
+	// PacketFormatOrdered is the current layout, with an 8-byte order field after the offset
+	PacketFormatOrdered PacketFormat = 0
+
+	// PacketFormatLegacy is the older layout without the order field: the wire message
+	// follows the offset directly
+	PacketFormatLegacy PacketFormat = 1
+)
+
+// String returns a human-readable name for the packet format
+func (f PacketFormat) String() string {
+	switch f {
+	case PacketFormatOrdered:
+		return "ordered"
+	case PacketFormatLegacy:
+		return "legacy (no order field)"
+	default:
+		return "unknown"
+	}
+}
+
+// orderSize returns the size of the order field in this format
+func (f PacketFormat) orderSize() int {
+	if f == PacketFormatLegacy {
+		return 0
+	}
+	return 8
+}
+
+// minPacketSize is the smallest valid packet: size, id, empty session, offset and order
+func (f PacketFormat) minPacketSize() uint32 {
+	return uint32(4 + 8 + 1 + 8 + f.orderSize())
+}
+
+// maxPacketSize is the largest valid packet: the longest session metadata and wire message
+func (f PacketFormat) maxPacketSize() uint32 {
+	return f.minPacketSize() + maxSessionMetadataSize + maxWireMessageSize
+}
+
+// checkPacketSize reports a packet size larger than limit, the bytes the packet can span
+// If the size read big-endian would fit, the error wraps ErrByteOrder, since a
+// byte-swapped size is the likelier explanation than a corrupt one.
+func checkPacketSize(size uint32, limit int64, format PacketFormat) error {
+	if int64(size) <= limit {
+		return nil
+	}
+	if swapped := bits.ReverseBytes32(size); swapped >= format.minPacketSize() && int64(swapped) <= limit {
+		return fmt.Errorf("invalid packet size: %d (maximum %d bytes), but %d read big-endian: %w", size, limit, swapped, ErrByteOrder)
+	}
+	return fmt.Errorf("invalid packet size: %d (maximum %d bytes)", size, limit)
+}
+
+// DetectPacketFormat peeks at the first packet of a stream and reports its layout
+// The bytes after the offset are either the order field followed by the wire message,
+// or the wire message itself; a wire message starts with its own length, so whichever
+// reading makes that length fill the rest of the packet wins. An empty message settles
+// it by size alone. The current layout is assumed when the packet is ambiguous or
+// unreadable (ReadPacket then reports any problem). Nothing is consumed from r.
+func DetectPacketFormat(r *bufio.Reader) PacketFormat {
+	head, _ := r.Peek(4)
+	if len(head) < 4 {
+		return PacketFormatOrdered
+	}
+	size := int(binary.LittleEndian.Uint32(head))
+
+	data, _ := r.Peek(min(size, r.Size()))
+	if len(data) < 4+8+1+8 {
+		return PacketFormatOrdered
+	}
+
+	// Skip size, session ID and the null-terminated session metadata
+	end := bytes.IndexByte(data[12:], 0)
+	if end < 0 {
+		return PacketFormatOrdered
+	}
+	afterOffset := 12 + end + 1 + 8
+	remaining := size - afterOffset
+
+	switch {
+	case remaining == 0:
+		// Only the legacy layout has room for an empty packet with no order field
+		return PacketFormatLegacy
+	case remaining == 8:
+		// An order field and an empty message; no wire message is 8 bytes long
+		return PacketFormatOrdered
+	case wireLengthIs(data, afterOffset+8, remaining-8):
+		return PacketFormatOrdered
+	case wireLengthIs(data, afterOffset, remaining):
+		return PacketFormatLegacy
+	default:
+		return PacketFormatOrdered
+	}
+}
+
+// wireLengthIs reports whether data holds, at pos, a wire message length equal to want
+func wireLengthIs(data []byte, pos, want int) bool {
+	if want < 16 || pos < 0 || pos+4 > len(data) {
+		return false
+	}
+	return int(int32(binary.LittleEndian.Uint32(data[pos:pos+4]))) == want
+}
+
+// ReadPacketFormat reads a single packet laid out in the given format
+// Legacy packets have no order field, so their Order is left at 0.
+// Returns io.EOF when there are no more packets to read
+func ReadPacketFormat(r io.Reader, format PacketFormat) (*Packet, error) {
+	packet, messageSize, err := readPacketHeader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readPacketMessage(r, packet, messageSize); err != nil {
+		return nil, err
+	}
+
+	return packet, nil
+}
@@ -0,0 +1,137 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildLegacyPacket creates a packet in the legacy layout, which has no order field
+func buildLegacyPacket(sessionID uint64, sessionMetadata string, offset uint64, message []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(4+8+len(sessionMetadata)+1+8+len(message)))
+	binary.Write(buf, binary.LittleEndian, sessionID)
+	buf.WriteString(sessionMetadata)
+	buf.WriteByte(0)
+	binary.Write(buf, binary.LittleEndian, offset)
+	buf.Write(message)
+	return buf.Bytes()
+}
+
+func TestDetectPacketFormat(t *testing.T) {
+	request := buildWireMessage(16, 1, 0, 2013)
+
+	tests := []struct {
+		name string
+		data []byte
+		want PacketFormat
+	}{
+		{"ordered", buildTestPacket(EventTypeRegular, 1, "{}", 100, 1, request), PacketFormatOrdered},
+		{"ordered empty message", buildTestPacket(EventTypeSessionStart, 1, "{}", 100, 1, nil), PacketFormatOrdered},
+		{"legacy", buildLegacyPacket(1, "{}", 100, request), PacketFormatLegacy},
+		{"legacy empty message", buildLegacyPacket(1, "{}", 100, nil), PacketFormatLegacy},
+		{"empty stream", nil, PacketFormatOrdered},
+		{"truncated", []byte{0xff, 0, 0, 0, 1}, PacketFormatOrdered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tt.data))
+			if got := DetectPacketFormat(r); got != tt.want {
+				t.Errorf("DetectPacketFormat = %v, want %v", got, tt.want)
+			}
+			if r.Buffered() != len(tt.data) && len(tt.data) > 0 {
+				t.Errorf("DetectPacketFormat consumed input: %d of %d bytes buffered", r.Buffered(), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestRecordingReader_LegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.bin")
+
+	var data []byte
+	data = append(data, buildLegacyPacket(1, "{}", 1000, nil)...)
+	data = append(data, buildLegacyPacket(1, "{}", 2000, buildWireMessage(16, 7, 0, 2013))...)
+	data = append(data, buildLegacyPacket(1, "{}", 2000, buildWireMessage(16, 8, 7, 2013))...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	rec, err := NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer rec.Close()
+
+	if rec.Format() != PacketFormatLegacy {
+		t.Fatalf("Format = %v, want %v", rec.Format(), PacketFormatLegacy)
+	}
+
+	var packets []*Packet
+	for {
+		packet, err := rec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		packets = append(packets, packet)
+	}
+
+	if len(packets) != 3 {
+		t.Fatalf("read %d packets, want 3", len(packets))
+	}
+	for i, p := range packets {
+		if p.Order != uint64(i) {
+			t.Errorf("packet %d: Order = %d, want its position %d", i, p.Order, i)
+		}
+	}
+	if len(packets[0].Message) != 0 {
+		t.Errorf("packet 0: message length = %d, want 0", len(packets[0].Message))
+	}
+	if !packets[1].IsRequest() || packets[1].Offset != 2000 || len(packets[1].Message) != 16 {
+		t.Errorf("packet 1 = offset %d, %d-byte message, want a 16-byte request at 2000", packets[1].Offset, len(packets[1].Message))
+	}
+	if packets[2].IsRequest() {
+		t.Error("packet 2: IsRequest = true, want a response")
+	}
+}
+
+func TestRecordingReader_LegacyFormatNextRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.bin")
+
+	var data []byte
+	data = append(data, buildLegacyPacket(1, "", 10, buildWireMessage(16, 1, 0, 2013))...)
+	data = append(data, buildLegacyPacket(1, "", 20, buildWireMessage(16, 2, 1, 2013))...)
+	data = append(data, buildLegacyPacket(1, "", 30, buildWireMessage(16, 3, 0, 2013))...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	rec, err := NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer rec.Close()
+
+	var offsets []uint64
+	for {
+		packet, err := rec.NextRequest()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRequest failed: %v", err)
+		}
+		offsets = append(offsets, packet.Offset)
+	}
+	if len(offsets) != 2 || offsets[0] != 10 || offsets[1] != 30 {
+		t.Errorf("request offsets = %v, want [10 30]", offsets)
+	}
+}
@@ -0,0 +1,123 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FileHeader is the optional header at the start of a recording file
+//
+// Binary Format:
+//   magic         : [4]byte   - "TRRC"
+//   headerSize    : uint32 LE - Total header size in bytes (allows future extension)
+//   version       : uint16 LE - Header format version
+//   flags         : uint16 LE - Reserved for future use
+//   startMicros   : int64  LE - Capture start time (Unix microseconds, 0 = unknown)
+//
+// Files written by the MongoDB server have no header and start directly with a packet.
+// The magic read as a packet size would be ~1.1GB, far beyond the 48MB maximum
+// wire message size, so a headerless file can never be mistaken for one with a header.
+type FileHeader struct {
+	// Version is the header format version
+	Version uint16
+
+	// Flags is reserved for future use
+	Flags uint16
+
+	// StartTime is the wall-clock time the capture started (zero if unknown)
+	StartTime time.Time
+}
+
+// FileMagic identifies a recording file that starts with a FileHeader
+var FileMagic = [4]byte{'T', 'R', 'R', 'C'}
+
+const (
+	// FileHeaderVersion is the current header format version
+	FileHeaderVersion uint16 = 1
+
+	// fileHeaderSize is the size of a version 1 header
+	fileHeaderSize = 4 + 4 + 2 + 2 + 8
+)
+
+// hasFileHeader reports whether the buffered stream starts with the header magic
+func hasFileHeader(r *bufio.Reader) (bool, error) {
+	magic, err := r.Peek(len(FileMagic))
+	if err == io.EOF {
+		// Too short to hold a header; ReadPacket will report any problem
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(magic, FileMagic[:]), nil
+}
+
+// ReadFileHeader reads a file header, including any extension bytes beyond the known fields
+// The caller must have already determined that the stream starts with FileMagic
+func ReadFileHeader(r io.Reader) (*FileHeader, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("failed to read header magic: %w", err)
+	}
+	if magic != FileMagic {
+		return nil, fmt.Errorf("invalid header magic: %q", magic[:])
+	}
+
+	var headerSize uint32
+	if err := binary.Read(r, binary.LittleEndian, &headerSize); err != nil {
+		return nil, fmt.Errorf("failed to read header size: %w", err)
+	}
+	if headerSize < fileHeaderSize {
+		return nil, fmt.Errorf("invalid header size: %d (minimum %d bytes)", headerSize, fileHeaderSize)
+	}
+
+	header := &FileHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &header.Version); err != nil {
+		return nil, fmt.Errorf("failed to read header version: %w", err)
+	}
+	if header.Version == 0 || header.Version > FileHeaderVersion {
+		return nil, fmt.Errorf("unsupported recording header version: %d (max supported %d)", header.Version, FileHeaderVersion)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header.Flags); err != nil {
+		return nil, fmt.Errorf("failed to read header flags: %w", err)
+	}
+
+	var startMicros int64
+	if err := binary.Read(r, binary.LittleEndian, &startMicros); err != nil {
+		return nil, fmt.Errorf("failed to read header start time: %w", err)
+	}
+	if startMicros != 0 {
+		header.StartTime = time.UnixMicro(startMicros)
+	}
+
+	// Skip extension bytes written by newer minor revisions
+	if extra := int64(headerSize) - fileHeaderSize; extra > 0 {
+		if _, err := io.CopyN(io.Discard, r, extra); err != nil {
+			return nil, fmt.Errorf("failed to skip header extension: %w", err)
+		}
+	}
+
+	return header, nil
+}
+
+// WriteFileHeader writes a current-version file header
+func WriteFileHeader(w io.Writer, header *FileHeader) error {
+	var startMicros int64
+	if !header.StartTime.IsZero() {
+		startMicros = header.StartTime.UnixMicro()
+	}
+
+	buf := make([]byte, fileHeaderSize)
+	copy(buf[0:4], FileMagic[:])
+	binary.LittleEndian.PutUint32(buf[4:8], fileHeaderSize)
+	binary.LittleEndian.PutUint16(buf[8:10], FileHeaderVersion)
+	binary.LittleEndian.PutUint16(buf[10:12], header.Flags)
+	binary.LittleEndian.PutUint64(buf[12:20], uint64(startMicros))
+
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,268 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SessionMetadata holds the parsed contents of a packet's SessionMetadata string
+//
+// The server writes session metadata in a relaxed JSON form with unquoted keys:
+//   { remote: "127.0.0.1:51807", local: "127.0.0.1:28004" }
+//
+// Nested documents are flattened into dotted keys in Fields
+// (e.g. client.driver.name) so callers can look up values without walking a tree.
+type SessionMetadata struct {
+	// Remote is the client address (host:port)
+	Remote string
+
+	// Local is the server address the client connected to (host:port)
+	Local string
+
+	// AppName is the application name from the client handshake, if present
+	AppName string
+
+	// DriverName is the client driver name, if present
+	DriverName string
+
+	// DriverVersion is the client driver version, if present
+	DriverVersion string
+
+	// Fields contains every parsed value keyed by its dotted path
+	Fields map[string]string
+}
+
+// appNameKeys are the dotted paths checked (in order) for the application name
+var appNameKeys = []string{"appName", "application.name", "client.application.name"}
+
+// driverNameKeys are the dotted paths checked (in order) for the driver name
+var driverNameKeys = []string{"driver.name", "client.driver.name"}
+
+// driverVersionKeys are the dotted paths checked (in order) for the driver version
+var driverVersionKeys = []string{"driver.version", "client.driver.version"}
+
+// ParseSessionMetadata parses a session metadata string into its fields
+// An empty string yields empty metadata rather than an error
+func ParseSessionMetadata(s string) (*SessionMetadata, error) {
+	meta := &SessionMetadata{Fields: make(map[string]string)}
+
+	if strings.TrimSpace(s) == "" {
+		return meta, nil
+	}
+
+	p := &metadataParser{input: s}
+	p.skipSpace()
+	if err := p.parseDocument("", meta.Fields); err != nil {
+		return nil, fmt.Errorf("failed to parse session metadata: %w", err)
+	}
+
+	meta.Remote = meta.Fields["remote"]
+	meta.Local = meta.Fields["local"]
+	meta.AppName = firstField(meta.Fields, appNameKeys)
+	meta.DriverName = firstField(meta.Fields, driverNameKeys)
+	meta.DriverVersion = firstField(meta.Fields, driverVersionKeys)
+
+	return meta, nil
+}
+
+// ParseSessionMetadata parses this packet's SessionMetadata string
+func (p *Packet) ParseSessionMetadata() (*SessionMetadata, error) {
+	return ParseSessionMetadata(p.SessionMetadata)
+}
+
+// Driver returns "name version" for the client driver, or just the name if no version is known
+func (m *SessionMetadata) Driver() string {
+	if m.DriverVersion == "" {
+		return m.DriverName
+	}
+	return m.DriverName + " " + m.DriverVersion
+}
+
+// firstField returns the first non-empty value among the given keys
+func firstField(fields map[string]string, keys []string) string {
+	for _, key := range keys {
+		if v := fields[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// metadataParser is a small recursive-descent parser for the relaxed JSON metadata format
+type metadataParser struct {
+	input string
+	pos   int
+}
+
+func (p *metadataParser) skipSpace() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *metadataParser) peek() byte {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *metadataParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return fmt.Errorf("expected '%c' at position %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// parseDocument parses { key: value, ... } and stores values under prefix
+func (p *metadataParser) parseDocument(prefix string, fields map[string]string) error {
+	if err := p.expect('{'); err != nil {
+		return err
+	}
+
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return nil
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if err := p.parseValue(path, fields); err != nil {
+			return err
+		}
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			// Closed on next iteration
+		default:
+			return fmt.Errorf("expected ',' or '}' at position %d", p.pos)
+		}
+	}
+}
+
+// parseKey parses a quoted or bare key
+func (p *metadataParser) parseKey() (string, error) {
+	p.skipSpace()
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.parseQuoted()
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n:,{}[]", p.input[p.pos]) < 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected key at position %d", p.pos)
+	}
+	return p.input[start:p.pos], nil
+}
+
+// parseValue parses a string, document, array, or bare scalar and stores it under path
+// Arrays are stored as a comma-separated list of their scalar elements
+func (p *metadataParser) parseValue(path string, fields map[string]string) error {
+	p.skipSpace()
+	switch p.peek() {
+	case '{':
+		return p.parseDocument(path, fields)
+	case '[':
+		return p.parseArray(path, fields)
+	case '"', '\'':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return err
+		}
+		fields[path] = s
+		return nil
+	default:
+		start := p.pos
+		for p.pos < len(p.input) && strings.IndexByte(",}]", p.input[p.pos]) < 0 {
+			p.pos++
+		}
+		value := strings.TrimSpace(p.input[start:p.pos])
+		if value == "" {
+			return fmt.Errorf("expected value at position %d", start)
+		}
+		fields[path] = value
+		return nil
+	}
+}
+
+func (p *metadataParser) parseArray(path string, fields map[string]string) error {
+	if err := p.expect('['); err != nil {
+		return err
+	}
+
+	var items []string
+	for i := 0; ; i++ {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			break
+		}
+
+		// Parse each element into a scratch map so nested documents don't clobber fields
+		elem := make(map[string]string)
+		elemPath := fmt.Sprintf("%s.%d", path, i)
+		if err := p.parseValue(elemPath, elem); err != nil {
+			return err
+		}
+		for k, v := range elem {
+			fields[k] = v
+		}
+		if v, ok := elem[elemPath]; ok {
+			items = append(items, v)
+		}
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			// Closed on next iteration
+		default:
+			return fmt.Errorf("expected ',' or ']' at position %d", p.pos)
+		}
+	}
+
+	fields[path] = strings.Join(items, ",")
+	return nil
+}
+
+// parseQuoted parses a single- or double-quoted string with backslash escapes
+func (p *metadataParser) parseQuoted() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.input):
+			sb.WriteByte(p.input[p.pos])
+			p.pos++
+		case c == quote:
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
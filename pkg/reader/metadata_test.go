@@ -0,0 +1,89 @@
+package reader
+
+import "testing"
+
+func TestParseSessionMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		remote        string
+		local         string
+		appName       string
+		driver        string
+		expectedField map[string]string
+	}{
+		{
+			name:   "empty",
+			input:  "",
+			remote: "",
+			local:  "",
+		},
+		{
+			name:   "server format with unquoted keys",
+			input:  `{ remote: "127.0.0.1:51807", local: "127.0.0.1:28004" }`,
+			remote: "127.0.0.1:51807",
+			local:  "127.0.0.1:28004",
+		},
+		{
+			name:    "nested client metadata",
+			input:   `{ remote: "10.0.0.5:40000", local: "10.0.0.1:27017", client: { application: { name: "orders-svc" }, driver: { name: "nodejs", version: "6.3.0" } } }`,
+			remote:  "10.0.0.5:40000",
+			local:   "10.0.0.1:27017",
+			appName: "orders-svc",
+			driver:  "nodejs 6.3.0",
+			expectedField: map[string]string{
+				"client.driver.name": "nodejs",
+			},
+		},
+		{
+			name:    "quoted keys, single quotes and bare scalars",
+			input:   `{ "remote": '1.2.3.4:5', appName: "my app", port: 27017, tags: [ "a", "b" ] }`,
+			remote:  "1.2.3.4:5",
+			appName: "my app",
+			expectedField: map[string]string{
+				"port": "27017",
+				"tags": "a,b",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParseSessionMetadata(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSessionMetadata failed: %v", err)
+			}
+			if meta.Remote != tt.remote {
+				t.Errorf("Remote = %q, want %q", meta.Remote, tt.remote)
+			}
+			if meta.Local != tt.local {
+				t.Errorf("Local = %q, want %q", meta.Local, tt.local)
+			}
+			if meta.AppName != tt.appName {
+				t.Errorf("AppName = %q, want %q", meta.AppName, tt.appName)
+			}
+			if meta.Driver() != tt.driver {
+				t.Errorf("Driver() = %q, want %q", meta.Driver(), tt.driver)
+			}
+			for k, v := range tt.expectedField {
+				if meta.Fields[k] != v {
+					t.Errorf("Fields[%q] = %q, want %q", k, meta.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSessionMetadata_Invalid(t *testing.T) {
+	inputs := []string{
+		`{ remote: "unterminated }`,
+		`{ remote "missing colon" }`,
+		`remote: "no braces"`,
+	}
+
+	for _, input := range inputs {
+		if _, err := ParseSessionMetadata(input); err == nil {
+			t.Errorf("ParseSessionMetadata(%q) expected error, got nil", input)
+		}
+	}
+}
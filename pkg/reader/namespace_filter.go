@@ -0,0 +1,95 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// NamespaceFilter keeps or drops packets by the namespace their request targets
+// Patterns are those of NamespaceMatcher: "db.collection", or "db" / "db.*" for a whole
+// database, with * and ? wildcards in either part. A request is dropped if it matches
+// an exclude pattern; otherwise, if there are include patterns, it is kept only if it
+// matches one of them. Exclude wins when both match.
+// Responses follow their request, so the filter must see packets in recording order.
+type NamespaceFilter struct {
+	include *NamespaceMatcher
+	exclude *NamespaceMatcher
+
+	// dropped holds the dropped requests awaiting their response
+	dropped map[RequestKey]bool
+}
+
+// NewNamespaceFilter parses include and exclude patterns into a filter
+func NewNamespaceFilter(include, exclude []string) (*NamespaceFilter, error) {
+	f := &NamespaceFilter{dropped: make(map[RequestKey]bool)}
+	var err error
+	if f.include, err = NewNamespaceMatcher(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = NewNamespaceMatcher(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match reports whether a namespace passes the filter's patterns; coll is "" for
+// commands that don't name a collection, which only database-wide patterns match
+func (f *NamespaceFilter) Match(db, coll string) bool {
+	if f.exclude.Matches(db, coll) {
+		return false
+	}
+	return f.include.Len() == 0 || f.include.Matches(db, coll)
+}
+
+// Keep reports whether a packet passes the filter
+// Requests are matched by their $db and collection (getMore's "collection" field);
+// responses are kept unless their request was dropped. Packets without a wire message
+// (session events) are always kept.
+func (f *NamespaceFilter) Keep(p *Packet) bool {
+	key, ok := p.PairingKey()
+	if !ok {
+		return true
+	}
+
+	if !p.IsRequest() {
+		if f.dropped[key] {
+			delete(f.dropped, key)
+			return false
+		}
+		return true
+	}
+
+	if f.Match(p.ExtractDatabase(), requestCollection(p)) {
+		return true
+	}
+	f.dropped[key] = true
+	return false
+}
+
+// requestCollection returns the collection a request targets ("" if none or unknown)
+func requestCollection(p *Packet) string {
+	if p.ExtractCommandName() != "getMore" {
+		return p.ExtractCollection()
+	}
+
+	// getMore's command value is the cursor id; the collection is a string field
+	msg := p.opMsgWire(MinOpMsgSize)
+	if msg == nil {
+		return ""
+	}
+	field := []byte("\x02collection\x00")
+	idx := bytes.Index(msg, field)
+	if idx == -1 {
+		return ""
+	}
+	idx += len(field)
+	if idx+BSONLengthSize > len(msg) {
+		return ""
+	}
+	strLen := int(binary.LittleEndian.Uint32(msg[idx : idx+BSONLengthSize]))
+	idx += BSONLengthSize
+	if strLen < 1 || idx+strLen > len(msg) {
+		return ""
+	}
+	return string(msg[idx : idx+strLen-1])
+}
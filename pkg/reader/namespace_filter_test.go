@@ -0,0 +1,90 @@
+package reader
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNamespaceFilter_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		ns      [2]string
+		want    bool
+	}{
+		{"no patterns", nil, nil, [2]string{"app", "users"}, true},
+		{"excluded collection", nil, []string{"app.events"}, [2]string{"app", "events"}, false},
+		{"other collection", nil, []string{"app.events"}, [2]string{"app", "users"}, true},
+		{"excluded database", nil, []string{"app.*"}, [2]string{"app", "users"}, false},
+		{"excluded database without collection", nil, []string{"app"}, [2]string{"app", ""}, false},
+		{"collection pattern skips database command", nil, []string{"app.events"}, [2]string{"app", ""}, true},
+		{"included", []string{"app"}, nil, [2]string{"app", "users"}, true},
+		{"not included", []string{"app.users"}, nil, [2]string{"app", "orders"}, false},
+		{"exclude wins over include", []string{"app"}, []string{"app.events"}, [2]string{"app", "events"}, false},
+		{"included and not excluded", []string{"app"}, []string{"app.events"}, [2]string{"app", "users"}, true},
+		{"excluded but not included", []string{"app"}, []string{"logs.*"}, [2]string{"logs", "raw"}, false},
+		{"excluded by wildcard", nil, []string{"*.events"}, [2]string{"logs", "events"}, false},
+		{"included by wildcard", []string{"prod?.users"}, nil, [2]string{"prod2", "users"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewNamespaceFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewNamespaceFilter failed: %v", err)
+			}
+			if got := f.Match(tt.ns[0], tt.ns[1]); got != tt.want {
+				t.Errorf("Match(%s, %s) = %v, want %v", tt.ns[0], tt.ns[1], got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceFilter_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", ".users", " "} {
+		if _, err := NewNamespaceFilter(nil, []string{pattern}); err == nil {
+			t.Errorf("NewNamespaceFilter(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestNamespaceFilter_Keep(t *testing.T) {
+	f, err := NewNamespaceFilter([]string{"app"}, []string{"app.events"})
+	if err != nil {
+		t.Fatalf("NewNamespaceFilter failed: %v", err)
+	}
+
+	request := func(requestID int32, doc bson.D) *Packet {
+		p := commandPacket(t, doc)
+		binary.LittleEndian.PutUint32(p.Message[4:8], uint32(requestID))
+		return p
+	}
+	response := func(responseTo int32) *Packet {
+		p := commandPacket(t, bson.D{{Key: "ok", Value: 1.0}})
+		binary.LittleEndian.PutUint32(p.Message[8:12], uint32(responseTo))
+		return p
+	}
+
+	packets := []struct {
+		name   string
+		packet *Packet
+		want   bool
+	}{
+		{"included insert", request(1, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}), true},
+		{"its response", response(1), true},
+		{"excluded insert", request(2, bson.D{{Key: "insert", Value: "events"}, {Key: "$db", Value: "app"}}), false},
+		{"its response", response(2), false},
+		{"excluded getMore", request(3, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "events"}, {Key: "$db", Value: "app"}}), false},
+		{"included getMore", request(4, bson.D{{Key: "getMore", Value: int64(43)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}), true},
+		{"not included", request(5, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "other"}}), false},
+		{"session event", &Packet{EventType: EventTypeSessionStart}, true},
+	}
+	for _, tt := range packets {
+		if got := f.Keep(tt.packet); got != tt.want {
+			t.Errorf("%s: Keep = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NamespaceMatcher matches namespaces against a set of patterns
+// A pattern is "db.collection", with * (any run of characters, including none) and ?
+// (any one character) allowed in either part: "mydb.*", "*.events", "prod?.users".
+// A pattern without a collection ("db") covers the whole database, as "db.*" does.
+// The collection is everything after the first dot, so "*" in it also matches dots.
+// Patterns without wildcards are looked up in maps; only wildcard ones are scanned.
+type NamespaceMatcher struct {
+	// collections holds the wildcard-free "db.collection" patterns
+	collections map[string]bool
+
+	// databases holds the wildcard-free database patterns covering every collection
+	databases map[string]bool
+
+	// globs holds the patterns with a wildcard anywhere else
+	globs []namespaceGlob
+}
+
+// namespaceGlob is a pattern with wildcards in its database or collection part
+type namespaceGlob struct {
+	db   string
+	coll string
+}
+
+// NewNamespaceMatcher compiles patterns into a matcher
+func NewNamespaceMatcher(patterns []string) (*NamespaceMatcher, error) {
+	m := &NamespaceMatcher{
+		collections: make(map[string]bool),
+		databases:   make(map[string]bool),
+	}
+	for _, s := range patterns {
+		if err := m.add(s); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// add compiles one pattern
+func (m *NamespaceMatcher) add(s string) error {
+	s = strings.TrimSpace(s)
+	db, coll, _ := strings.Cut(s, ".")
+	if db == "" {
+		return fmt.Errorf("invalid namespace '%s'. Must be 'db' or 'db.collection', with * and ? wildcards in either part", s)
+	}
+	if coll == "" {
+		coll = "*"
+	}
+
+	switch {
+	case strings.ContainsAny(db, "*?"):
+		m.globs = append(m.globs, namespaceGlob{db: db, coll: coll})
+	case coll == "*":
+		m.databases[db] = true
+	case strings.ContainsAny(coll, "*?"):
+		m.globs = append(m.globs, namespaceGlob{db: db, coll: coll})
+	default:
+		m.collections[db+"."+coll] = true
+	}
+	return nil
+}
+
+// Len returns the number of patterns compiled (duplicates count once unless they have wildcards)
+func (m *NamespaceMatcher) Len() int {
+	return len(m.collections) + len(m.databases) + len(m.globs)
+}
+
+// Matches reports whether a namespace matches any pattern
+// coll is "" for commands that don't name a collection; a collection pattern only
+// matches it if it can match nothing, like the whole-database "*".
+func (m *NamespaceMatcher) Matches(db, coll string) bool {
+	if m.databases[db] {
+		return true
+	}
+	if coll != "" && m.collections[db+"."+coll] {
+		return true
+	}
+	for _, g := range m.globs {
+		if globMatch(g.db, db) && globMatch(g.coll, coll) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch reports whether s matches pattern, where * matches any run of characters
+// and ? exactly one; there is no escaping
+func globMatch(pattern, s string) bool {
+	p, n := 0, 0
+
+	// star is the pattern index after the last * seen (-1 if none) and retry the
+	// position in s it should next try to absorb up to, when backtracking
+	star, retry := -1, 0
+	for n < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			p++
+			star, retry = p, n
+		case p < len(pattern) && pattern[p] == '?':
+			_, size := utf8.DecodeRuneInString(s[n:])
+			p++
+			n += size
+		case p < len(pattern) && pattern[p] == s[n]:
+			p++
+			n++
+		case star >= 0:
+			_, size := utf8.DecodeRuneInString(s[retry:])
+			retry += size
+			p, n = star, retry
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,62 @@
+package reader
+
+import "testing"
+
+func TestNamespaceMatcher_Matches(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		db, coll string
+		want     bool
+	}{
+		{"exact", []string{"app.users"}, "app", "users", true},
+		{"exact other collection", []string{"app.users"}, "app", "orders", false},
+		{"exact other database", []string{"app.users"}, "logs", "users", false},
+		{"exact skips database command", []string{"app.users"}, "app", "", false},
+		{"database", []string{"app"}, "app", "users", true},
+		{"database command", []string{"app"}, "app", "", true},
+		{"whole collection wildcard", []string{"app.*"}, "app", "users", true},
+		{"whole collection wildcard, database command", []string{"app.*"}, "app", "", true},
+		{"whole collection wildcard, other database", []string{"app.*"}, "logs", "users", false},
+		{"database wildcard", []string{"*.events"}, "logs", "events", true},
+		{"database wildcard other collection", []string{"*.events"}, "logs", "users", false},
+		{"database wildcard skips database command", []string{"*.events"}, "logs", "", false},
+		{"database prefix", []string{"tenant_*"}, "tenant_42", "users", true},
+		{"database prefix no match", []string{"tenant_*"}, "tenant", "users", false},
+		{"database single character", []string{"prod?.users"}, "prod1", "users", true},
+		{"single character needs one", []string{"prod?.users"}, "prod", "users", false},
+		{"single character only one", []string{"prod?.users"}, "prod12", "users", false},
+		{"single character is a character, not a byte", []string{"caf?.users"}, "café", "users", true},
+		{"collection prefix", []string{"app.events_*"}, "app", "events_2024", true},
+		{"collection suffix", []string{"app.*_archive"}, "app", "orders_archive", true},
+		{"collection infix", []string{"app.a*b*c"}, "app", "axxbyyc", true},
+		{"collection infix backtracks", []string{"app.a*bc"}, "app", "abxbc", true},
+		{"collection infix no match", []string{"app.a*b*c"}, "app", "axxbyy", false},
+		{"collection wildcard spans dots", []string{"app.system.*"}, "app", "system.profile", true},
+		{"collection single character", []string{"app.user?"}, "app", "users", true},
+		{"both parts", []string{"*.cache_?"}, "shop", "cache_1", true},
+		{"everything", []string{"*"}, "anything", "at.all", true},
+		{"any pattern", []string{"logs", "app.users"}, "app", "users", true},
+		{"no patterns", nil, "app", "users", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewNamespaceMatcher(tt.patterns)
+			if err != nil {
+				t.Fatalf("NewNamespaceMatcher failed: %v", err)
+			}
+			if got := m.Matches(tt.db, tt.coll); got != tt.want {
+				t.Errorf("Matches(%q, %q) with %q = %v, want %v", tt.db, tt.coll, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceMatcher_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", ".users", "  "} {
+		if _, err := NewNamespaceMatcher([]string{pattern}); err == nil {
+			t.Errorf("NewNamespaceMatcher(%q) succeeded, want an error", pattern)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package reader
+
+import "time"
+
+// FormatOffset renders a recording offset (microseconds) as a duration, e.g. 1h2m3.456s
+// Offsets of a second or more are rounded to the millisecond; shorter ones keep
+// microsecond precision (e.g. 12.345ms, 250µs).
+func FormatOffset(micros uint64) string {
+	d := time.Duration(micros) * time.Microsecond
+	if d >= time.Second {
+		d = d.Round(time.Millisecond)
+	}
+	return d.String()
+}
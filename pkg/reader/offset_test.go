@@ -0,0 +1,24 @@
+package reader
+
+import "testing"
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		micros uint64
+		want   string
+	}{
+		{0, "0s"},
+		{250, "250µs"},
+		{12_345, "12.345ms"},
+		{1_500_000, "1.5s"},
+		{59_999_999, "1m0s"},
+		{3_723_456_789, "1h2m3.457s"},
+		{3_723_000_000, "1h2m3s"},
+		{100 * 3_600_000_000, "100h0m0s"},
+	}
+	for _, tt := range tests {
+		if got := FormatOffset(tt.micros); got != tt.want {
+			t.Errorf("FormatOffset(%d) = %q, want %q", tt.micros, got, tt.want)
+		}
+	}
+}
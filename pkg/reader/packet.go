@@ -41,7 +41,7 @@ func (e EventType) String() string {
 //   id            : uint64 LE (8 bytes)  - Session/connection identifier
 //   session       : string   (variable)  - Null-terminated string with session metadata (JSON)
 //   offset        : uint64 LE (8 bytes)  - Microseconds elapsed since recording started
-//   order         : uint64 LE (8 bytes)  - Sequence number for ordering packets
+//   order         : uint64 LE (8 bytes)  - Sequence number for ordering packets (absent in PacketFormatLegacy)
 //   message       : []byte   (variable)  - Wire protocol message (may be empty)
 //
 // Note: There is NO eventType field in the binary format. We infer EventType from context:
@@ -71,6 +71,15 @@ type Packet struct {
 	// Message contains the raw wire protocol message bytes
 	// Empty for SessionStart and SessionEnd events
 	Message []byte
+
+	// wireMessage caches the decompressed form of an OP_COMPRESSED Message (see WireMessage)
+	wireMessage []byte
+
+	// decompressErr caches the error from decompressing Message
+	decompressErr error
+
+	// decompressed is set once wireMessage/decompressErr have been computed
+	decompressed bool
 }
 
 // IsRequest returns true if this packet is a request (not a response)
@@ -106,25 +115,36 @@ func (p *Packet) GetOpCode() uint32 {
 	return binary.LittleEndian.Uint32(p.Message[12:16])
 }
 
-// ReadPacket reads a single packet from the provided reader
+// ReadPacket reads a single packet in the current (ordered) format from the provided reader
 // Returns io.EOF when there are no more packets to read
 func ReadPacket(r io.Reader) (*Packet, error) {
+	return ReadPacketFormat(r, PacketFormatOrdered)
+}
+
+// readPacketHeader reads every packet field up to (but not including) the wire message
+// Returns the number of message bytes that follow in the stream
+func readPacketHeader(r io.Reader, format PacketFormat) (*Packet, int, error) {
 	packet := &Packet{}
 
 	// Read size (4 bytes, little-endian)
 	if err := binary.Read(r, binary.LittleEndian, &packet.Size); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Sanity check: size should be at least the minimum header size
 	// Minimum: 4 (size) + 8 (id) + 1 (null terminator for empty session) + 8 (offset) + 8 (order) = 29 bytes
-	if packet.Size < 29 {
-		return nil, fmt.Errorf("invalid packet size: %d (minimum 29 bytes)", packet.Size)
+	// (21 bytes in the legacy format, which has no order field)
+	if minSize := format.minPacketSize(); packet.Size < minSize {
+		return nil, 0, fmt.Errorf("invalid packet size: %d (minimum %d bytes)", packet.Size, minSize)
+	}
+	// A size no server could have written is more likely a byte-swapped or foreign file
+	if err := checkPacketSize(packet.Size, int64(format.maxPacketSize()), format); err != nil {
+		return nil, 0, err
 	}
 
 	// Read session ID (8 bytes, little-endian)
 	if err := binary.Read(r, binary.LittleEndian, &packet.SessionID); err != nil {
-		return nil, fmt.Errorf("failed to read session ID: %w", err)
+		return nil, 0, fmt.Errorf("failed to read session ID: %w", err)
 	}
 
 	// Read session metadata (null-terminated string)
@@ -132,7 +152,7 @@ func ReadPacket(r io.Reader) (*Packet, error) {
 	for {
 		var b byte
 		if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
-			return nil, fmt.Errorf("failed to read session metadata: %w", err)
+			return nil, 0, fmt.Errorf("failed to read session metadata: %w", err)
 		}
 		if b == 0 {
 			// Found null terminator
@@ -141,37 +161,44 @@ func ReadPacket(r io.Reader) (*Packet, error) {
 		sessionBytes = append(sessionBytes, b)
 
 		// Sanity check: session metadata shouldn't be too long
-		if len(sessionBytes) > 10000 {
-			return nil, fmt.Errorf("session metadata too long (>10KB)")
+		if len(sessionBytes) > maxSessionMetadataSize {
+			return nil, 0, fmt.Errorf("session metadata too long (>10KB)")
 		}
 	}
 	packet.SessionMetadata = string(sessionBytes)
 
 	// Read offset (8 bytes, little-endian)
 	if err := binary.Read(r, binary.LittleEndian, &packet.Offset); err != nil {
-		return nil, fmt.Errorf("failed to read offset: %w", err)
+		return nil, 0, fmt.Errorf("failed to read offset: %w", err)
 	}
 
-	// Read order (8 bytes, little-endian)
-	if err := binary.Read(r, binary.LittleEndian, &packet.Order); err != nil {
-		return nil, fmt.Errorf("failed to read order: %w", err)
+	// Read order (8 bytes, little-endian; absent in the legacy format)
+	if format != PacketFormatLegacy {
+		if err := binary.Read(r, binary.LittleEndian, &packet.Order); err != nil {
+			return nil, 0, fmt.Errorf("failed to read order: %w", err)
+		}
 	}
 
 	// Calculate message size
 	// Total size - header size
 	// Header size = 4 (size) + 8 (id) + len(session) + 1 (null) + 8 (offset) + 8 (order)
-	headerSize := 4 + 8 + len(sessionBytes) + 1 + 8 + 8
+	headerSize := 4 + 8 + len(sessionBytes) + 1 + 8 + format.orderSize()
 	messageSize := int(packet.Size) - headerSize
 
 	if messageSize < 0 {
-		return nil, fmt.Errorf("invalid message size: %d (total size: %d, header size: %d)", messageSize, packet.Size, headerSize)
+		return nil, 0, fmt.Errorf("invalid message size: %d (total size: %d, header size: %d)", messageSize, packet.Size, headerSize)
 	}
 
+	return packet, messageSize, nil
+}
+
+// readPacketMessage reads the wire message that follows a packet header
+func readPacketMessage(r io.Reader, packet *Packet, messageSize int) error {
 	// Read message data
 	if messageSize > 0 {
 		packet.Message = make([]byte, messageSize)
 		if _, err := io.ReadFull(r, packet.Message); err != nil {
-			return nil, fmt.Errorf("failed to read message data: %w", err)
+			return fmt.Errorf("failed to read message data: %w", err)
 		}
 	}
 
@@ -181,13 +208,12 @@ func ReadPacket(r io.Reader) (*Packet, error) {
 	if len(packet.Message) > 0 {
 		packet.EventType = EventTypeRegular
 	} else {
-		// Empty message - could be session start or end
-		// For simplicity, mark as Regular for now
-		// TODO: Track session state to distinguish SessionStart from SessionEnd
+		// Empty message - could be session start or end, which a single packet can't
+		// tell apart; EventReader infers them from session state and look-ahead
 		packet.EventType = EventTypeRegular
 	}
 
-	return packet, nil
+	return nil
 }
 
 // ReadPacketFromBytes is a convenience function that reads a packet from a byte slice
@@ -3,7 +3,9 @@ package reader
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -182,6 +184,41 @@ func TestReadPacket_InvalidSize(t *testing.T) {
 	}
 }
 
+// byteSwapPacket rewrites a test packet's fixed-width header fields big-endian
+func byteSwapPacket(packet []byte, sessionMetadata string) []byte {
+	swapped := append([]byte(nil), packet...)
+	binary.BigEndian.PutUint32(swapped[0:4], binary.LittleEndian.Uint32(packet[0:4]))
+	binary.BigEndian.PutUint64(swapped[4:12], binary.LittleEndian.Uint64(packet[4:12]))
+	offset := 12 + len(sessionMetadata) + 1
+	for _, pos := range []int{offset, offset + 8} {
+		binary.BigEndian.PutUint64(swapped[pos:pos+8], binary.LittleEndian.Uint64(packet[pos:pos+8]))
+	}
+	return swapped
+}
+
+func TestReadPacket_ByteSwappedSize(t *testing.T) {
+	packet := byteSwapPacket(buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013)), "")
+
+	_, err := ReadPacketFromBytes(packet)
+	if !errors.Is(err, ErrByteOrder) {
+		t.Fatalf("ReadPacket error = %v, want ErrByteOrder", err)
+	}
+	if !strings.Contains(err.Error(), "big-endian") {
+		t.Errorf("error %q doesn't mention big-endian", err)
+	}
+}
+
+func TestReadPacket_OversizedPacket(t *testing.T) {
+	// Too large either way round: reported as an invalid size, not a byte order problem
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(0x7ffffff0))
+
+	_, err := ReadPacketFromBytes(buf.Bytes())
+	if err == nil || errors.Is(err, ErrByteOrder) || !strings.Contains(err.Error(), "invalid packet size") {
+		t.Errorf("ReadPacket error = %v, want invalid packet size", err)
+	}
+}
+
 func TestReadPacket_EOF(t *testing.T) {
 	// Try to read from empty buffer
 	_, err := ReadPacketFromBytes([]byte{})
@@ -0,0 +1,27 @@
+package reader
+
+import "encoding/binary"
+
+// RequestKey identifies a request within a recording, for pairing it with its responses
+// Request IDs are only unique per connection, so the session is part of the key
+type RequestKey struct {
+	// SessionID is the recorded session the request was sent on
+	SessionID uint64
+
+	// RequestID is the request's wire header requestID (its responses' responseTo)
+	RequestID int32
+}
+
+// PairingKey returns the key of the request a packet is or answers: the packet's own
+// requestID for a request, the responseTo for a response. A request and the responses
+// to it share a key. ok is false for packets without a wire header (session events).
+func (p *Packet) PairingKey() (key RequestKey, ok bool) {
+	if len(p.Message) < WireHeaderSize {
+		return RequestKey{}, false
+	}
+	id := int32(binary.LittleEndian.Uint32(p.Message[4:8]))
+	if responseTo := int32(binary.LittleEndian.Uint32(p.Message[8:12])); responseTo != 0 {
+		id = responseTo
+	}
+	return RequestKey{SessionID: p.SessionID, RequestID: id}, true
+}
@@ -0,0 +1,27 @@
+package reader
+
+import "testing"
+
+func TestPacket_PairingKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet *Packet
+		want   RequestKey
+		wantOK bool
+	}{
+		{"request", &Packet{SessionID: 7, Message: buildWireMessage(16, 42, 0, 2013)}, RequestKey{SessionID: 7, RequestID: 42}, true},
+		{"response", &Packet{SessionID: 7, Message: buildWireMessage(16, 900, 42, 2013)}, RequestKey{SessionID: 7, RequestID: 42}, true},
+		{"other session", &Packet{SessionID: 8, Message: buildWireMessage(16, 900, 42, 2013)}, RequestKey{SessionID: 8, RequestID: 42}, true},
+		{"session event", &Packet{SessionID: 7}, RequestKey{}, false},
+		{"truncated header", &Packet{SessionID: 7, Message: buildWireMessage(16, 42, 0, 2013)[:12]}, RequestKey{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.packet.PairingKey()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("PairingKey() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
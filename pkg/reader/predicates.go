@@ -0,0 +1,38 @@
+package reader
+
+// HeaderPredicate selects packets for RecordingReader.NextMatchingHeader
+// It may only use the packet's header fields (SessionID, Offset, Order, ...) and the
+// 16-byte wire header in Message (IsRequest, GetOpCode). It must not keep Message,
+// which aliases the reader's buffer.
+type HeaderPredicate func(p *Packet) bool
+
+// IsRequestHeader matches request packets (wire header responseTo == 0)
+func IsRequestHeader(p *Packet) bool {
+	return p.IsRequest()
+}
+
+// OpCodeIs returns a predicate matching packets whose wire header has one of the opCodes
+// The opcode is the raw header value, so compressed messages match 2012 (OP_COMPRESSED).
+func OpCodeIs(opCodes ...uint32) HeaderPredicate {
+	return func(p *Packet) bool {
+		opCode := p.GetOpCode()
+		for _, c := range opCodes {
+			if opCode == c {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllOf returns a predicate matching packets that satisfy every predicate
+func AllOf(preds ...HeaderPredicate) HeaderPredicate {
+	return func(p *Packet) bool {
+		for _, pred := range preds {
+			if !pred(p) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -18,9 +18,11 @@ func TestRealRecording(t *testing.T) {
 		return
 	}
 	defer reader.Close()
+	events := NewEventReader(reader)
 
 	stats := struct {
 		totalPackets   int
+		emptyMessages  int
 		sessionStarts  int
 		sessionEnds    int
 		regularPackets int
@@ -32,7 +34,7 @@ func TestRealRecording(t *testing.T) {
 	}
 
 	for {
-		packet, err := reader.Next()
+		packet, err := events.Next()
 		if err == io.EOF {
 			break
 		}
@@ -41,6 +43,14 @@ func TestRealRecording(t *testing.T) {
 		}
 
 		stats.totalPackets++
+		if len(packet.Message) == 0 {
+			stats.emptyMessages++
+			if packet.EventType == EventTypeRegular {
+				t.Errorf("Empty-message packet %d (session %d) classified as Regular", stats.totalPackets, packet.SessionID)
+			}
+		} else if packet.EventType != EventTypeRegular {
+			t.Errorf("Packet %d (session %d) with a message classified as %s", stats.totalPackets, packet.SessionID, packet.EventType)
+		}
 
 		switch packet.EventType {
 		case EventTypeSessionStart:
@@ -90,6 +100,11 @@ func TestRealRecording(t *testing.T) {
 		t.Fatal("Expected at least one regular packet")
 	}
 
+	// The sample operations were sent over connections opened during the capture
+	if stats.emptyMessages > 0 && stats.sessionStarts == 0 {
+		t.Errorf("Expected session starts among %d empty-message packets", stats.emptyMessages)
+	}
+
 	// Check for modern opcodes (OP_MSG=2013, OP_COMPRESSED=2012)
 	modernOpcodes := 0
 	legacyOpcodes := 0
@@ -2,11 +2,14 @@ package reader
 
 import (
 	"bufio"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 )
 
 // RecordingReader reads packets from a single MongoDB traffic recording file (.bin)
@@ -14,22 +17,109 @@ type RecordingReader struct {
 	file   *os.File
 	reader *bufio.Reader
 	path   string
+	header *FileHeader // nil for headerless (server-written) files
+	size   int64       // file size in bytes at open
 	closed bool
+
+	// format is the packet layout detected from the first packet
+	format PacketFormat
+
+	// read counts packets read, which numbers legacy packets that have no order field
+	read uint64
+
+	// empty is true if there was no packet data after the header at open
+	empty bool
+
+	// next is the byte offset in the file of the next unread packet
+	next int64
+
+	// trailing is the size of the partial packet found at the end of the file (0 if none)
+	trailing int64
 }
 
+// ErrEmptyRecording reports a recording with no packets
+var ErrEmptyRecording = errors.New("recording is empty (0 packets)")
+
+// DefaultReaderBufferSize is the read buffer size of NewRecordingReader
+const DefaultReaderBufferSize = 1024 * 1024 // 1MB buffer for performance
+
+// setReaderBufferSize is the read buffer size of each file a RecordingSet opens
+const setReaderBufferSize = 64 * 1024
+
 // NewRecordingReader opens a recording file and returns a reader
+// If the file starts with a FileHeader it is read and skipped; headerless files are read as-is
 func NewRecordingReader(path string) (*RecordingReader, error) {
+	return NewRecordingReaderWithBufferSize(path, DefaultReaderBufferSize)
+}
+
+// NewRecordingReaderWithBufferSize opens a recording file with a read buffer of bufSize bytes
+// Smaller buffers save memory when many readers are open at once; larger ones suit long
+// sequential scans. bufSize <= 0 uses DefaultReaderBufferSize.
+func NewRecordingReaderWithBufferSize(path string, bufSize int) (*RecordingReader, error) {
+	if bufSize <= 0 {
+		bufSize = DefaultReaderBufferSize
+	}
+
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open recording file %s: %w", path, err)
 	}
 
-	return &RecordingReader{
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat recording file %s: %w", path, err)
+	}
+
+	r := &RecordingReader{
 		file:   file,
-		reader: bufio.NewReaderSize(file, 1024*1024), // 1MB buffer for performance
+		size:   info.Size(),
+		reader: bufio.NewReaderSize(file, bufSize),
 		path:   path,
 		closed: false,
-	}, nil
+	}
+
+	hasHeader, err := hasFileHeader(r.reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read recording file %s: %w", path, err)
+	}
+	if hasHeader {
+		r.header, err = ReadFileHeader(r.reader)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read header of %s: %w", path, err)
+		}
+	}
+	if _, err := r.reader.Peek(1); err == io.EOF {
+		r.empty = true
+	}
+	if r.next, err = r.Position(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.format = DetectPacketFormat(r.reader)
+	if err := r.checkByteOrder(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read recording file %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// checkByteOrder rejects a file whose first packet size only fits the file read big-endian
+// Without it such a file would pass for a partial packet (see atTrailingPartial) and read
+// as an empty recording. Other oversized first packets are left to atTrailingPartial.
+func (r *RecordingReader) checkByteOrder() error {
+	head, err := r.reader.Peek(4)
+	if err != nil {
+		return nil
+	}
+	err = checkPacketSize(binary.LittleEndian.Uint32(head), r.size-r.next, r.format)
+	if errors.Is(err, ErrByteOrder) {
+		return err
+	}
+	return nil
 }
 
 // Next reads and returns the next packet from the recording
@@ -39,14 +129,134 @@ func (r *RecordingReader) Next() (*Packet, error) {
 		return nil, fmt.Errorf("reader is closed")
 	}
 
-	packet, err := ReadPacket(r.reader)
+	if r.atTrailingPartial() {
+		return nil, io.EOF
+	}
+	packet, err := ReadPacketFormat(r.reader, r.format)
 	if err != nil {
 		return nil, err
 	}
+	r.numberPacket(packet)
 
 	return packet, nil
 }
 
+// atTrailingPartial reports whether the unread bytes are a partial packet cut off by the
+// end of the file: too few for a size field, or fewer than a valid size field claims.
+// Capture pipelines can leave one behind when a flush is interrupted mid-write; every
+// complete packet before it has been read, so it is treated as the end of the recording
+// (with a warning) rather than as a read error. A size no packet could have is corruption,
+// not truncation, wherever it is in the file: it is left for the read to report, so a
+// corrupt size mid-file can't end the recording early. Only bytes present when the file
+// was opened count.
+func (r *RecordingReader) atTrailingPartial() bool {
+	if r.trailing > 0 {
+		return true
+	}
+
+	remaining := r.size - r.next
+	if remaining <= 0 {
+		return false
+	}
+	if remaining >= 4 {
+		head, err := r.reader.Peek(4)
+		if err != nil {
+			return false
+		}
+		size := binary.LittleEndian.Uint32(head)
+		if int64(size) <= remaining || size < r.format.minPacketSize() ||
+			checkPacketSize(size, int64(r.format.maxPacketSize()), r.format) != nil {
+			return false
+		}
+	}
+
+	r.trailing = remaining
+	fmt.Fprintf(os.Stderr, "Warning: ignoring %d trailing bytes at offset %d of %s (partial packet)\n", remaining, r.next, r.path)
+	return true
+}
+
+// TrailingBytes returns the size of the partial packet ignored at the end of the file
+// Returns 0 if none was found (yet); it is known once reading has reached it.
+func (r *RecordingReader) TrailingBytes() int64 {
+	return r.trailing
+}
+
+// numberPacket gives legacy packets, which have no order field, their position in the file
+// as their order, so ordering by (Offset, Order) keeps ties in file order
+func (r *RecordingReader) numberPacket(packet *Packet) {
+	if r.format == PacketFormatLegacy {
+		packet.Order = r.read
+	}
+	r.read++
+	r.next += int64(packet.Size)
+}
+
+// NextRequest reads and returns the next request packet, skipping responses
+// Response (and empty) message bodies are discarded from the stream without being
+// allocated, using the responseTo field peeked from the wire header.
+// Yields the same packets as filtering Next() with IsRequest().
+// Returns io.EOF when there are no more requests
+func (r *RecordingReader) NextRequest() (*Packet, error) {
+	return r.NextMatchingHeader(IsRequestHeader)
+}
+
+// NextMatching reads packets until one satisfies pred and returns it
+// pred sees the full packet. For predicates that only need the packet header and
+// wire header, NextMatchingHeader avoids reading skipped message bodies.
+// Returns io.EOF when no more packets match
+func (r *RecordingReader) NextMatching(pred func(*Packet) bool) (*Packet, error) {
+	for {
+		packet, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if pred(packet) {
+			return packet, nil
+		}
+	}
+}
+
+// NextMatchingHeader reads packets until one satisfies pred and returns it
+// pred sees the packet with Message holding only the 16-byte wire header (or the
+// whole message if shorter); bodies of packets it rejects are discarded from the
+// stream without being allocated. The returned packet has its full message.
+// Returns io.EOF when no more packets match
+func (r *RecordingReader) NextMatchingHeader(pred HeaderPredicate) (*Packet, error) {
+	if r.closed {
+		return nil, fmt.Errorf("reader is closed")
+	}
+
+	for {
+		if r.atTrailingPartial() {
+			return nil, io.EOF
+		}
+		packet, messageSize, err := readPacketHeader(r.reader, r.format)
+		if err != nil {
+			return nil, err
+		}
+		r.numberPacket(packet)
+
+		wireHeader, err := r.reader.Peek(min(messageSize, 16))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message header: %w", err)
+		}
+		if len(wireHeader) > 0 {
+			packet.Message = wireHeader
+		}
+
+		if pred(packet) {
+			if err := readPacketMessage(r.reader, packet, messageSize); err != nil {
+				return nil, err
+			}
+			return packet, nil
+		}
+
+		if _, err := r.reader.Discard(messageSize); err != nil {
+			return nil, fmt.Errorf("failed to skip message data: %w", err)
+		}
+	}
+}
+
 // Close closes the recording file
 func (r *RecordingReader) Close() error {
 	if r.closed {
@@ -61,14 +271,79 @@ func (r *RecordingReader) Path() string {
 	return r.path
 }
 
+// Size returns the size of the recording file in bytes, as of when it was opened
+func (r *RecordingReader) Size() int64 {
+	return r.size
+}
+
+// Position returns the byte offset in the file of the next unread packet
+// Compare with Size for progress reporting
+func (r *RecordingReader) Position() (int64, error) {
+	pos, err := r.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file position: %w", err)
+	}
+	return pos - int64(r.reader.Buffered()), nil
+}
+
+// Empty reports whether the file held no packets when it was opened: it is zero-length
+// or holds only a file header. Next on an empty reader returns io.EOF straight away.
+func (r *RecordingReader) Empty() bool {
+	return r.empty
+}
+
+// Format returns the packet layout detected when the file was opened
+func (r *RecordingReader) Format() PacketFormat {
+	return r.format
+}
+
+// Header returns the file header, or nil if the file has none
+func (r *RecordingReader) Header() *FileHeader {
+	return r.header
+}
+
+// StartTime returns the capture start time from the file header
+// Returns the zero time for headerless files or when the start time is unknown
+func (r *RecordingReader) StartTime() time.Time {
+	if r.header == nil {
+		return time.Time{}
+	}
+	return r.header.StartTime
+}
+
 // RecordingSet reads packets from a directory containing multiple recording files
-// It reads files in sorted order and yields packets in order across all files
+// It reads files in sorted order and yields packets in order across all files.
+// Zero-length files are left out of the set; files holding only a header are read
+// through like any other, so neither ends the set early.
 type RecordingSet struct {
 	dir     string
 	files   []string
+	empty   []string
 	current *RecordingReader
 	fileIdx int
 	closed  bool
+
+	// dedupeBoundaries suppresses a file's first packet when it repeats the last
+	// packet of the previous file
+	dedupeBoundaries bool
+
+	// last is the (SessionID, Order) of the last packet returned, and fileStart is
+	// set until the first packet of a newly opened file has been read
+	last      boundaryPacket
+	hasLast   bool
+	fileStart bool
+
+	// lastLegacy is set if the last packet returned came from a PacketFormatLegacy file
+	lastLegacy bool
+
+	// boundaryDuplicates counts the packets suppressed by dedupeBoundaries
+	boundaryDuplicates int
+}
+
+// boundaryPacket identifies a packet for rotation-overlap deduplication
+type boundaryPacket struct {
+	sessionID uint64
+	order     uint64
 }
 
 // NewRecordingSet opens a directory containing recording files (.bin)
@@ -97,9 +372,27 @@ func NewRecordingSet(dir string) (*RecordingSet, error) {
 	// Sort files by name (MongoDB recording files are typically numbered sequentially)
 	sort.Strings(files)
 
+	// Skip zero-length files (e.g. a rotation that never received traffic)
+	var nonEmpty, empty []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access recording file %s: %w", file, err)
+		}
+		if info.Size() == 0 {
+			empty = append(empty, file)
+		} else {
+			nonEmpty = append(nonEmpty, file)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, fmt.Errorf("all %d .bin files in %s are zero-length: %w", len(files), dir, ErrEmptyRecording)
+	}
+
 	return &RecordingSet{
 		dir:     dir,
-		files:   files,
+		files:   nonEmpty,
+		empty:   empty,
 		fileIdx: -1, // Will be incremented to 0 on first call to Next()
 		closed:  false,
 	}, nil
@@ -122,11 +415,12 @@ func (rs *RecordingSet) Next() (*Packet, error) {
 				return nil, io.EOF
 			}
 
-			reader, err := NewRecordingReader(rs.files[rs.fileIdx])
+			reader, err := NewRecordingReaderWithBufferSize(rs.files[rs.fileIdx], setReaderBufferSize)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open recording file %s: %w", rs.files[rs.fileIdx], err)
 			}
 			rs.current = reader
+			rs.fileStart = true
 		}
 
 		// Try to read next packet
@@ -145,10 +439,35 @@ func (rs *RecordingSet) Next() (*Packet, error) {
 			return nil, fmt.Errorf("error reading from %s: %w", rs.current.Path(), err)
 		}
 
+		// Legacy packets are numbered by position within their own file, so their
+		// orders can't be compared across a boundary
+		key := boundaryPacket{packet.SessionID, packet.Order}
+		legacy := rs.current.Format() == PacketFormatLegacy
+		atBoundary := rs.fileStart
+		rs.fileStart = false
+		if rs.dedupeBoundaries && atBoundary && rs.hasLast && !legacy && !rs.lastLegacy && key == rs.last {
+			rs.boundaryDuplicates++
+			continue
+		}
+		rs.last, rs.hasLast, rs.lastLegacy = key, true, legacy
 		return packet, nil
 	}
 }
 
+// DedupeBoundaries sets whether a file's first packet is suppressed when its
+// (SessionID, Order) equals the last packet of the previous file. A capture that
+// rotates files can write the boundary packet to both; this yields it once.
+// Boundaries next to a PacketFormatLegacy file are never deduplicated, since
+// legacy files have no recorded order to compare.
+func (rs *RecordingSet) DedupeBoundaries(enabled bool) {
+	rs.dedupeBoundaries = enabled
+}
+
+// BoundaryDuplicates returns how many packets DedupeBoundaries has suppressed so far
+func (rs *RecordingSet) BoundaryDuplicates() int {
+	return rs.boundaryDuplicates
+}
+
 // Close closes the recording set and any open file
 func (rs *RecordingSet) Close() error {
 	if rs.closed {
@@ -167,6 +486,11 @@ func (rs *RecordingSet) Files() []string {
 	return rs.files
 }
 
+// EmptyFiles returns the zero-length .bin files left out of the set
+func (rs *RecordingSet) EmptyFiles() []string {
+	return rs.empty
+}
+
 // CurrentFile returns the path of the currently open file, or empty string if none
 func (rs *RecordingSet) CurrentFile() string {
 	if rs.current == nil {
@@ -1,9 +1,13 @@
 package reader
 
 import (
+	"encoding/binary"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -135,6 +139,333 @@ func TestRecordingSet_NoFiles(t *testing.T) {
 	}
 }
 
+func TestRecordingReader_Empty(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	zeroLength := filepath.Join(tmpDir, "zero.bin")
+	if err := os.WriteFile(zeroLength, nil, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	headerOnly := filepath.Join(tmpDir, "header.bin")
+	w, err := NewRecordingWriter(headerOnly, WriterOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+	w.Close()
+	withPacket := filepath.Join(tmpDir, "packet.bin")
+	if err := os.WriteFile(withPacket, buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013)), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	for _, tt := range []struct {
+		path      string
+		wantEmpty bool
+	}{
+		{zeroLength, true},
+		{headerOnly, true},
+		{withPacket, false},
+	} {
+		r, err := NewRecordingReader(tt.path)
+		if err != nil {
+			t.Fatalf("NewRecordingReader(%s) failed: %v", filepath.Base(tt.path), err)
+		}
+		if r.Empty() != tt.wantEmpty {
+			t.Errorf("%s: Empty() = %v, want %v", filepath.Base(tt.path), r.Empty(), tt.wantEmpty)
+		}
+		if _, err := r.Next(); tt.wantEmpty && err != io.EOF {
+			t.Errorf("%s: Next() error = %v, want io.EOF", filepath.Base(tt.path), err)
+		}
+		r.Close()
+	}
+}
+
+func TestRecordingReader_ByteSwappedFile(t *testing.T) {
+	// A whole big-endian packet would otherwise pass for a trailing partial packet
+	path := filepath.Join(t.TempDir(), "swapped.bin")
+	packet := buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013))
+	if err := os.WriteFile(path, byteSwapPacket(packet, ""), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	r, err := NewRecordingReader(path)
+	if err == nil {
+		r.Close()
+		t.Fatal("NewRecordingReader succeeded on a byte-swapped file")
+	}
+	if !errors.Is(err, ErrByteOrder) {
+		t.Errorf("NewRecordingReader error = %v, want ErrByteOrder", err)
+	}
+}
+
+func TestRecordingReader_TrailingPartialPacket(t *testing.T) {
+	packet1 := buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013))
+	packet2 := buildTestPacket(EventTypeRegular, 1, "", 2000, 2, buildWireMessage(16, 101, 0, 2013))
+	valid := append(append([]byte(nil), packet1...), packet2...)
+
+	tests := []struct {
+		name     string
+		trailing []byte
+	}{
+		{"few bytes", []byte{0x01, 0x02, 0x03}},
+		{"size past the end", []byte{0xc8, 0x00, 0x00, 0x00, 0xde, 0xad, 0xbe, 0xef}},
+		{"truncated packet", packet1[:len(packet1)-5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.bin")
+			if err := os.WriteFile(path, append(append([]byte(nil), valid...), tt.trailing...), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			// Next, NextRequest and EstimatePacketCount all stop cleanly after the valid packets
+			r, err := NewRecordingReader(path)
+			if err != nil {
+				t.Fatalf("NewRecordingReader failed: %v", err)
+			}
+			defer r.Close()
+			for i := 0; i < 2; i++ {
+				if _, err := r.Next(); err != nil {
+					t.Fatalf("packet %d: %v", i+1, err)
+				}
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Fatalf("Next after valid packets = %v, want io.EOF", err)
+			}
+			if r.TrailingBytes() != int64(len(tt.trailing)) {
+				t.Errorf("TrailingBytes = %d, want %d", r.TrailingBytes(), len(tt.trailing))
+			}
+
+			requests, err := NewRecordingReader(path)
+			if err != nil {
+				t.Fatalf("NewRecordingReader failed: %v", err)
+			}
+			defer requests.Close()
+			count := 0
+			for ; ; count++ {
+				if _, err := requests.NextRequest(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("NextRequest failed: %v", err)
+				}
+			}
+			if count != 2 {
+				t.Errorf("NextRequest read %d packets, want 2", count)
+			}
+
+			if n, err := EstimatePacketCount(path); err != nil || n != 2 {
+				t.Errorf("EstimatePacketCount = %d, %v; want 2", n, err)
+			}
+		})
+	}
+}
+
+func TestRecordingReader_CorruptSizeIsNotTrailing(t *testing.T) {
+	// A size no packet could have is corruption, wherever it is: reading stops with an
+	// error instead of dropping the rest of the file as a trailing partial packet
+	var data []byte
+	for i := 0; i < 100; i++ {
+		data = append(data, buildTestPacket(EventTypeRegular, 1, "", uint64(1000*i), uint64(i), buildWireMessage(16, int32(i), 0, 2013))...)
+	}
+	packetLen := len(data) / 100
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		valid   int
+	}{
+		{"garbage in the middle", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[packetLen:], 0x7ffffff0)
+			return data
+		}, 1},
+		{"garbage at the end", func(data []byte) []byte {
+			return append(data, 0xf0, 0xff, 0xff, 0x7f, 0xde, 0xad, 0xbe, 0xef)
+		}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "corrupt.bin")
+			if err := os.WriteFile(path, tt.corrupt(append([]byte(nil), data...)), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			r, err := NewRecordingReader(path)
+			if err != nil {
+				t.Fatalf("NewRecordingReader failed: %v", err)
+			}
+			defer r.Close()
+			for i := 0; i < tt.valid; i++ {
+				if _, err := r.Next(); err != nil {
+					t.Fatalf("packet %d: %v", i+1, err)
+				}
+			}
+			if _, err := r.Next(); err == nil || err == io.EOF || !strings.Contains(err.Error(), "invalid packet size") {
+				t.Errorf("Next at the corrupt size = %v, want an invalid packet size error", err)
+			}
+			if r.TrailingBytes() != 0 {
+				t.Errorf("TrailingBytes = %d, want 0", r.TrailingBytes())
+			}
+		})
+	}
+}
+
+func TestRecordingReader_CorruptPacketIsAnError(t *testing.T) {
+	// A size field that fits in the file but is invalid is corruption, not a cut-off write
+	packet := buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013))
+	junk := append([]byte{0x05, 0x00, 0x00, 0x00}, make([]byte, 60)...)
+	path := filepath.Join(t.TempDir(), "test.bin")
+	if err := os.WriteFile(path, append(packet, junk...), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	r, err := NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("first packet: %v", err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("Next on corrupt packet = %v, want an error", err)
+	}
+}
+
+func TestRecordingSet_SkipsEmptyFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// An empty file first and in the middle must not end the set
+	os.WriteFile(filepath.Join(tmpDir, "file0.bin"), nil, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file1.bin"), buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013)), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file2.bin"), nil, 0644)
+	w, err := NewRecordingWriter(filepath.Join(tmpDir, "file3.bin"), WriterOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+	w.Close()
+	os.WriteFile(filepath.Join(tmpDir, "file4.bin"), buildTestPacket(EventTypeRegular, 1, "", 2000, 2, buildWireMessage(16, 101, 0, 2013)), 0644)
+
+	rs, err := NewRecordingSet(tmpDir)
+	if err != nil {
+		t.Fatalf("NewRecordingSet failed: %v", err)
+	}
+	defer rs.Close()
+
+	if rs.FileCount() != 3 || len(rs.EmptyFiles()) != 2 {
+		t.Errorf("FileCount = %d, EmptyFiles = %v; want 3 files and 2 empty", rs.FileCount(), rs.EmptyFiles())
+	}
+
+	count := 0
+	for {
+		_, err := rs.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read packet: %v", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("read %d packets, want 2", count)
+	}
+}
+
+func TestRecordingSet_DedupeBoundaries(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// file2 starts with the packet file1 ended on (session 1, order 2); its own
+	// later packet with the same order in another session is not a duplicate
+	boundary := buildTestPacket(EventTypeRegular, 1, "", 2000, 2, buildWireMessage(16, 101, 0, 2013))
+	file1 := append(buildTestPacket(EventTypeRegular, 1, "", 1000, 1, buildWireMessage(16, 100, 0, 2013)), boundary...)
+	file2 := append(append([]byte{}, boundary...), buildTestPacket(EventTypeRegular, 1, "", 3000, 3, buildWireMessage(16, 102, 0, 2013))...)
+	file3 := buildTestPacket(EventTypeRegular, 2, "", 3000, 3, buildWireMessage(16, 103, 0, 2013))
+	os.WriteFile(filepath.Join(tmpDir, "file1.bin"), file1, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file2.bin"), file2, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file3.bin"), file3, 0644)
+
+	read := func(dedupe bool) ([]uint64, int) {
+		rs, err := NewRecordingSet(tmpDir)
+		if err != nil {
+			t.Fatalf("NewRecordingSet failed: %v", err)
+		}
+		defer rs.Close()
+		rs.DedupeBoundaries(dedupe)
+
+		var orders []uint64
+		for {
+			p, err := rs.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to read packet: %v", err)
+			}
+			orders = append(orders, p.Order)
+		}
+		return orders, rs.BoundaryDuplicates()
+	}
+
+	if orders, dups := read(false); len(orders) != 5 || dups != 0 {
+		t.Errorf("without dedupe: orders %v, %d duplicates; want 5 packets, 0 duplicates", orders, dups)
+	}
+	orders, dups := read(true)
+	if want := []uint64{1, 2, 3, 3}; !reflect.DeepEqual(orders, want) {
+		t.Errorf("with dedupe: orders %v, want %v", orders, want)
+	}
+	if dups != 1 {
+		t.Errorf("BoundaryDuplicates = %d, want 1", dups)
+	}
+}
+
+func TestRecordingSet_DedupeBoundariesLegacy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Each file holds one packet of session 1, so both are numbered order 0 by their
+	// position in the file; they are distinct packets and both must be read
+	file1 := buildLegacyPacket(1, "{}", 1000, buildWireMessage(16, 100, 0, 2013))
+	file2 := buildLegacyPacket(1, "{}", 2000, buildWireMessage(16, 101, 0, 2013))
+	os.WriteFile(filepath.Join(tmpDir, "file1.bin"), file1, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file2.bin"), file2, 0644)
+
+	rs, err := NewRecordingSet(tmpDir)
+	if err != nil {
+		t.Fatalf("NewRecordingSet failed: %v", err)
+	}
+	defer rs.Close()
+	rs.DedupeBoundaries(true)
+
+	var offsets []uint64
+	for {
+		p, err := rs.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read packet: %v", err)
+		}
+		offsets = append(offsets, p.Offset)
+	}
+	if want := []uint64{1000, 2000}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("offsets %v, want %v", offsets, want)
+	}
+	if dups := rs.BoundaryDuplicates(); dups != 0 {
+		t.Errorf("BoundaryDuplicates = %d, want 0", dups)
+	}
+}
+
+func TestRecordingSet_AllFilesEmpty(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "file1.bin"), nil, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file2.bin"), nil, 0644)
+
+	_, err := NewRecordingSet(tmpDir)
+	if !errors.Is(err, ErrEmptyRecording) {
+		t.Errorf("NewRecordingSet error = %v, want ErrEmptyRecording", err)
+	}
+}
+
 func TestRecordingSet_NotADirectory(t *testing.T) {
 	tmpDir := t.TempDir()
 	tmpFile := filepath.Join(tmpDir, "notadir.txt")
@@ -146,3 +477,271 @@ func TestRecordingSet_NotADirectory(t *testing.T) {
 		t.Error("Expected error when path is not a directory, got nil")
 	}
 }
+
+// writeMixedRecording writes a recording alternating requests and responses of the given size
+func writeMixedRecording(t testing.TB, path string, pairs, responseSize int) {
+	t.Helper()
+
+	var data []byte
+	order := uint64(0)
+	for i := 0; i < pairs; i++ {
+		order++
+		data = append(data, buildTestPacket(EventTypeRegular, 1, "", order, order, buildWireMessage(16, int32(i+1), 0, 2013))...)
+
+		response := buildWireMessage(int32(responseSize), int32(i+1000), int32(i+1), 2013)
+		response = append(response, make([]byte, responseSize-16)...)
+		order++
+		data = append(data, buildTestPacket(EventTypeRegular, 1, "", order, order, response)...)
+	}
+	// Trailing empty session event
+	order++
+	data = append(data, buildTestPacket(EventTypeSessionEnd, 1, "", order, order, nil)...)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+}
+
+func TestRecordingReader_NextRequest(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "mixed.bin")
+	writeMixedRecording(t, tmpFile, 3, 64)
+
+	reader, err := NewRecordingReader(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create RecordingReader: %v", err)
+	}
+	defer reader.Close()
+
+	var orders []uint64
+	for {
+		packet, err := reader.NextRequest()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRequest failed: %v", err)
+		}
+		if !packet.IsRequest() {
+			t.Errorf("NextRequest returned a non-request (order %d)", packet.Order)
+		}
+		orders = append(orders, packet.Order)
+	}
+
+	want := []uint64{1, 3, 5}
+	if len(orders) != len(want) {
+		t.Fatalf("got request orders %v, want %v", orders, want)
+	}
+	for i := range want {
+		if orders[i] != want[i] {
+			t.Fatalf("got request orders %v, want %v", orders, want)
+		}
+	}
+}
+
+func TestRecordingReader_Position(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "mixed.bin")
+	writeMixedRecording(t, tmpFile, 3, 64)
+
+	reader, err := NewRecordingReader(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create RecordingReader: %v", err)
+	}
+	defer reader.Close()
+
+	info, err := os.Stat(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+	if reader.Size() != info.Size() {
+		t.Errorf("Size() = %d, want %d", reader.Size(), info.Size())
+	}
+
+	var consumed int64
+	for {
+		packet, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		consumed += int64(packet.Size)
+
+		pos, err := reader.Position()
+		if err != nil {
+			t.Fatalf("Position failed: %v", err)
+		}
+		if pos != consumed {
+			t.Fatalf("Position() = %d after packet %d, want %d", pos, packet.Order, consumed)
+		}
+	}
+
+	if consumed != reader.Size() {
+		t.Errorf("read %d bytes, file is %d", consumed, reader.Size())
+	}
+}
+
+func TestRecordingReader_NextMatching(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "opcodes.bin")
+
+	var data []byte
+	data = append(data, buildTestPacket(EventTypeSessionStart, 1, "", 0, 1, nil)...)
+	data = append(data, buildTestPacket(EventTypeRegular, 1, "", 1, 2, buildWireMessage(16, 1, 0, 2013))...)
+	data = append(data, buildTestPacket(EventTypeRegular, 1, "", 2, 3, buildWireMessage(16, 2, 1, 2013))...)
+	data = append(data, buildTestPacket(EventTypeRegular, 1, "", 3, 4, buildWireMessage(16, 3, 0, 2004))...)
+	data = append(data, buildTestPacket(EventTypeRegular, 1, "", 4, 5, buildWireMessage(16, 4, 3, 1))...)
+	data = append(data, buildTestPacket(EventTypeSessionEnd, 1, "", 5, 6, nil)...)
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	collect := func(next func(r *RecordingReader) (*Packet, error)) []uint64 {
+		reader, err := NewRecordingReader(tmpFile)
+		if err != nil {
+			t.Fatalf("Failed to create RecordingReader: %v", err)
+		}
+		defer reader.Close()
+
+		var orders []uint64
+		for {
+			packet, err := next(reader)
+			if err == io.EOF {
+				return orders
+			}
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if packet.Size != uint32(29+len(packet.Message)) {
+				t.Errorf("packet %d has a %d-byte message, want the full message", packet.Order, len(packet.Message))
+			}
+			orders = append(orders, packet.Order)
+		}
+	}
+
+	tests := []struct {
+		name string
+		next func(r *RecordingReader) (*Packet, error)
+		want []uint64
+	}{
+		{"full-packet predicate", func(r *RecordingReader) (*Packet, error) {
+			return r.NextMatching(func(p *Packet) bool { return len(p.Message) == 0 })
+		}, []uint64{1, 6}},
+		{"OpCodeIs", func(r *RecordingReader) (*Packet, error) {
+			return r.NextMatchingHeader(OpCodeIs(2004, 1))
+		}, []uint64{4, 5}},
+		{"IsRequestHeader", func(r *RecordingReader) (*Packet, error) {
+			return r.NextMatchingHeader(IsRequestHeader)
+		}, []uint64{2, 4}},
+		{"AllOf", func(r *RecordingReader) (*Packet, error) {
+			return r.NextMatchingHeader(AllOf(IsRequestHeader, OpCodeIs(2013)))
+		}, []uint64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(tt.next)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got orders %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got orders %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRecordingReader_RequestsOnly(b *testing.B) {
+	tmpFile := filepath.Join(b.TempDir(), "mixed.bin")
+	writeMixedRecording(b, tmpFile, 1000, 16*1024)
+
+	b.Run("NextThenFilter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, _ := NewRecordingReader(tmpFile)
+			for {
+				packet, err := reader.Next()
+				if err != nil {
+					break
+				}
+				_ = packet.IsRequest()
+			}
+			reader.Close()
+		}
+	})
+
+	b.Run("NextRequest", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, _ := NewRecordingReader(tmpFile)
+			for {
+				if _, err := reader.NextRequest(); err != nil {
+					break
+				}
+			}
+			reader.Close()
+		}
+	})
+
+	b.Run("NextMatching", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, _ := NewRecordingReader(tmpFile)
+			for {
+				if _, err := reader.NextMatching((*Packet).IsRequest); err != nil {
+					break
+				}
+			}
+			reader.Close()
+		}
+	})
+
+	b.Run("NextMatchingHeader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, _ := NewRecordingReader(tmpFile)
+			for {
+				if _, err := reader.NextMatchingHeader(AllOf(IsRequestHeader, OpCodeIs(2013))); err != nil {
+					break
+				}
+			}
+			reader.Close()
+		}
+	})
+}
+
+func TestNewRecordingReaderWithBufferSize(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "mixed.bin")
+	writeMixedRecording(t, tmpFile, 5, 300)
+
+	for _, bufSize := range []int{16, 64, 0} {
+		reader, err := NewRecordingReaderWithBufferSize(tmpFile, bufSize)
+		if err != nil {
+			t.Fatalf("bufSize %d: NewRecordingReaderWithBufferSize failed: %v", bufSize, err)
+		}
+
+		// Packets larger than the buffer must still be read whole
+		packets, requests := 0, 0
+		for {
+			packet, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("bufSize %d: Next failed: %v", bufSize, err)
+			}
+			packets++
+			if packet.IsRequest() {
+				requests++
+			} else if len(packet.Message) > 0 && len(packet.Message) != 300 {
+				t.Errorf("bufSize %d: response message is %d bytes, want 300", bufSize, len(packet.Message))
+			}
+		}
+		reader.Close()
+
+		if packets != 11 || requests != 5 {
+			t.Errorf("bufSize %d: read %d packets (%d requests), want 11 (5)", bufSize, packets, requests)
+		}
+	}
+}
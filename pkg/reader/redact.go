@@ -0,0 +1,86 @@
+package reader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// addressValue matches a remote or local key (bare or quoted) and its value: a double-
+// or single-quoted string, or a bare token. It is matched textually rather than through
+// ParseSessionMetadata so malformed or truncated metadata is still redacted.
+var addressValue = regexp.MustCompile(`\b(remote|local)(["']?\s*:\s*)("[^"]*"?|'[^']*'?|[^,\s}]+)`)
+
+// RedactMetadata masks the host and port of the remote and local addresses in a session
+// metadata string, leaving everything else (including its layout) as it was:
+//
+//	{ remote: "10.0.0.5:51807", local: "10.0.0.1:27017" }
+//	{ remote: "10.0.0.x:xxxxx", local: "10.0.0.x:xxxxx" }
+//
+// IPv4 hosts keep their first three octets and IPv6 hosts their first three groups, so
+// traffic can still be told apart by subnet; host names are replaced entirely. Unix
+// socket paths aren't addresses and are left alone.
+func RedactMetadata(meta string) string {
+	return addressValue.ReplaceAllStringFunc(meta, func(match string) string {
+		m := addressValue.FindStringSubmatch(match)
+		key, sep, value := m[1], m[2], m[3]
+
+		// Keep the quotes (a truncated value may lack the closing one)
+		openQuote, closeQuote := "", ""
+		if value[0] == '"' || value[0] == '\'' {
+			openQuote, value = value[:1], value[1:]
+			if strings.HasSuffix(value, openQuote) {
+				closeQuote, value = openQuote, value[:len(value)-1]
+			}
+		}
+		return key + sep + openQuote + redactAddress(value) + closeQuote
+	})
+}
+
+// redactAddress masks a host:port (or bare host) address
+func redactAddress(addr string) string {
+	if addr == "" || strings.HasPrefix(addr, "/") {
+		return addr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = strings.Trim(addr, "[]"), ""
+	}
+	host = redactHost(host)
+	if port == "" {
+		return host
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return host + ":" + strings.Repeat("x", len(port))
+}
+
+// redactHost masks all but the network prefix of an IP address, or all of a host name
+func redactHost(host string) string {
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "x"
+	case ip.To4() != nil && !strings.Contains(host, ":"):
+		octets := strings.Split(host, ".")
+		return strings.Join(octets[:3], ".") + ".x"
+	default:
+		groups := strings.Split(expandIPv6(ip), ":")
+		return strings.Join(groups[:3], ":") + ":x:x:x:x:x"
+	}
+}
+
+// expandIPv6 writes ip as eight colon-separated groups (no :: shorthand), without
+// leading zeros in each group
+func expandIPv6(ip net.IP) string {
+	ip = ip.To16()
+	groups := make([]string, 8)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%x", binary.BigEndian.Uint16(ip[2*i:]))
+	}
+	return strings.Join(groups, ":")
+}
@@ -0,0 +1,80 @@
+package reader
+
+import "testing"
+
+func TestRedactMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		meta string
+		want string
+	}{
+		{
+			name: "ipv4",
+			meta: `{ remote: "10.0.0.5:51807", local: "10.0.0.1:27017" }`,
+			want: `{ remote: "10.0.0.x:xxxxx", local: "10.0.0.x:xxxxx" }`,
+		},
+		{
+			name: "ipv6",
+			meta: `{ remote: "[2001:db8:85a3::8a2e:370:7334]:51807", local: "[::1]:27017" }`,
+			want: `{ remote: "[2001:db8:85a3:x:x:x:x:x]:xxxxx", local: "[0:0:0:x:x:x:x:x]:xxxxx" }`,
+		},
+		{
+			name: "host name without port",
+			meta: `{ remote: "app-7.internal.example.com", local: "10.0.0.1" }`,
+			want: `{ remote: "x", local: "10.0.0.x" }`,
+		},
+		{
+			name: "other fields kept",
+			meta: `{ remote: "10.0.0.5:51807", local: "10.0.0.1:27017", client: { application: { name: "orders" } } }`,
+			want: `{ remote: "10.0.0.x:xxxxx", local: "10.0.0.x:xxxxx", client: { application: { name: "orders" } } }`,
+		},
+		{
+			name: "quoted keys and single quotes",
+			meta: `{"remote":'192.168.1.20:40000',"local" : "192.168.1.1:27017"}`,
+			want: `{"remote":'192.168.1.x:xxxxx',"local" : "192.168.1.x:xxxxx"}`,
+		},
+		{
+			name: "unix socket",
+			meta: `{ remote: "anonymous unix socket:27017", local: "/tmp/mongodb-27017.sock" }`,
+			want: `{ remote: "x:xxxxx", local: "/tmp/mongodb-27017.sock" }`,
+		},
+		{
+			name: "malformed: truncated",
+			meta: `{ remote: "10.0.0.5:518`,
+			want: `{ remote: "10.0.0.x:xxx`,
+		},
+		{
+			name: "malformed: bare values",
+			meta: `remote: 10.0.0.5:51807, local: [fe80::1]:27017`,
+			want: `remote: 10.0.0.x:xxxxx, local: [fe80:0:0:x:x:x:x:x]:xxxxx`,
+		},
+		{
+			name: "malformed: not metadata",
+			meta: `not { metadata`,
+			want: `not { metadata`,
+		},
+		{
+			name: "empty",
+			meta: ``,
+			want: ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactMetadata(tt.meta); got != tt.want {
+				t.Errorf("RedactMetadata(%q) = %q, want %q", tt.meta, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactMetadata_StillParses(t *testing.T) {
+	meta, err := ParseSessionMetadata(RedactMetadata(`{ remote: "10.0.0.5:51807", local: "[::1]:27017", appName: "orders" }`))
+	if err != nil {
+		t.Fatalf("ParseSessionMetadata failed: %v", err)
+	}
+	if meta.Remote != "10.0.0.x:xxxxx" || meta.Local != "[0:0:0:x:x:x:x:x]:xxxxx" || meta.AppName != "orders" {
+		t.Errorf("parsed %+v, want redacted addresses and the appName kept", meta)
+	}
+}
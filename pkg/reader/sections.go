@@ -0,0 +1,183 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// OpMsgChecksumPresent is the OP_MSG flagBits bit set when a CRC-32C checksum trails the sections
+const OpMsgChecksumPresent uint32 = 1 << 0
+
+// Section is one OP_MSG section as raw bytes, for rewriting a message without
+// changing its section structure
+type Section struct {
+	// Kind is the section kind: 0 for the body document, 1 for a document sequence
+	Kind byte
+
+	// Identifier names a kind-1 sequence's command field (e.g. "documents"); "" for kind 0
+	Identifier string
+
+	// Documents are the raw BSON documents: the body for kind 0, the sequence for kind 1.
+	// Split returns slices of the message, so copy a document before changing it in place.
+	Documents [][]byte
+}
+
+// SplitOpMsgSections splits an OP_MSG wire message into its flags, sections (in message
+// order) and trailing checksum (nil unless the checksumPresent flag is set). Only the
+// framing is checked - section and document lengths - not the documents' contents, and
+// the checksum is returned as recorded without being verified.
+func SplitOpMsgSections(msg []byte) (flags uint32, sections []Section, checksum []byte, err error) {
+	if len(msg) < MinOpMsgSize {
+		return 0, nil, nil, fmt.Errorf("message too short for OP_MSG: %d bytes", len(msg))
+	}
+	if length := int(int32(binary.LittleEndian.Uint32(msg[0:4]))); length != len(msg) {
+		return 0, nil, nil, fmt.Errorf("message length mismatch: header says %d bytes, got %d", length, len(msg))
+	}
+	if opCode := binary.LittleEndian.Uint32(msg[12:16]); opCode != 2013 {
+		return 0, nil, nil, fmt.Errorf("not an OP_MSG message (opCode %d)", opCode)
+	}
+
+	flags = binary.LittleEndian.Uint32(msg[WireHeaderSize:OpMsgSectionsOffset])
+	end := len(msg)
+	if flags&OpMsgChecksumPresent != 0 {
+		if end-ChecksumSize < MinOpMsgSize {
+			return 0, nil, nil, fmt.Errorf("message too short for OP_MSG with checksum: %d bytes", len(msg))
+		}
+		end -= ChecksumSize
+		checksum = msg[end:]
+	}
+
+	for offset := OpMsgSectionsOffset; offset < end; {
+		kind := msg[offset]
+		start := offset
+		offset += SectionKindSize
+
+		switch kind {
+		case 0:
+			doc, err := SplitDocument(msg[offset:end])
+			if err != nil {
+				return 0, nil, nil, fmt.Errorf("invalid kind-0 section at offset %d: %w", start, err)
+			}
+			sections = append(sections, Section{Kind: 0, Documents: [][]byte{doc}})
+			offset += len(doc)
+
+		case 1:
+			section, size, err := splitDocumentSequence(msg[offset:end])
+			if err != nil {
+				return 0, nil, nil, fmt.Errorf("invalid kind-1 section at offset %d: %w", start, err)
+			}
+			sections = append(sections, section)
+			offset += size
+
+		default:
+			return 0, nil, nil, fmt.Errorf("unknown OP_MSG section kind %d at offset %d", kind, start)
+		}
+	}
+	return flags, sections, checksum, nil
+}
+
+// SplitDocument returns the BSON document framed at the start of data
+// Only its length prefix is checked, not its contents.
+func SplitDocument(data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("truncated document")
+	}
+	size := int(int32(binary.LittleEndian.Uint32(data[0:BSONLengthSize])))
+	if size < 5 || size > len(data) {
+		return nil, fmt.Errorf("document size %d exceeds remaining %d bytes", size, len(data))
+	}
+	return data[:size], nil
+}
+
+// splitDocumentSequence splits a kind-1 section payload, returning its size in bytes
+func splitDocumentSequence(data []byte) (Section, int, error) {
+	if len(data) < BSONLengthSize {
+		return Section{}, 0, fmt.Errorf("truncated section size")
+	}
+	size := int(int32(binary.LittleEndian.Uint32(data[0:BSONLengthSize])))
+	if size < BSONLengthSize+1 || size > len(data) {
+		return Section{}, 0, fmt.Errorf("section size %d exceeds remaining %d bytes", size, len(data))
+	}
+	payload := data[BSONLengthSize:size]
+
+	nul := bytes.IndexByte(payload, 0)
+	if nul < 0 {
+		return Section{}, 0, fmt.Errorf("unterminated sequence identifier")
+	}
+	section := Section{Kind: 1, Identifier: string(payload[:nul])}
+	for rest := payload[nul+1:]; len(rest) > 0; {
+		doc, err := SplitDocument(rest)
+		if err != nil {
+			return Section{}, 0, fmt.Errorf("sequence %q document %d: %w", section.Identifier, len(section.Documents), err)
+		}
+		section.Documents = append(section.Documents, doc)
+		rest = rest[len(doc):]
+	}
+	return section, size, nil
+}
+
+// BuildOpMsg builds an OP_MSG wire message from its parts; the inverse of
+// SplitOpMsgSections. Sections are written in the given order. With withChecksum the
+// checksumPresent flag is set and a CRC-32C over the message is appended; otherwise the
+// flag is cleared. requestID and responseTo go in the header, which the checksum covers.
+// A kind-0 section writes only its first document.
+func BuildOpMsg(requestID, responseTo int32, flags uint32, sections []Section, withChecksum bool) []byte {
+	if withChecksum {
+		flags |= OpMsgChecksumPresent
+	} else {
+		flags &^= OpMsgChecksumPresent
+	}
+
+	size := OpMsgSectionsOffset
+	for _, s := range sections {
+		size += SectionKindSize + s.size()
+	}
+	if withChecksum {
+		size += ChecksumSize
+	}
+
+	msg := make([]byte, 0, size)
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(size))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(requestID))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(responseTo))
+	msg = binary.LittleEndian.AppendUint32(msg, 2013)
+	msg = binary.LittleEndian.AppendUint32(msg, flags)
+
+	for _, s := range sections {
+		msg = append(msg, s.Kind)
+		if s.Kind == 0 {
+			if len(s.Documents) > 0 {
+				msg = append(msg, s.Documents[0]...)
+			}
+			continue
+		}
+		msg = binary.LittleEndian.AppendUint32(msg, uint32(s.size()))
+		msg = append(msg, s.Identifier...)
+		msg = append(msg, 0)
+		for _, doc := range s.Documents {
+			msg = append(msg, doc...)
+		}
+	}
+
+	if withChecksum {
+		msg = binary.LittleEndian.AppendUint32(msg, crc32.Checksum(msg, crc32.MakeTable(crc32.Castagnoli)))
+	}
+	return msg
+}
+
+// size is the section's size in bytes after its kind byte
+func (s Section) size() int {
+	if s.Kind == 0 {
+		if len(s.Documents) == 0 {
+			return 0
+		}
+		return len(s.Documents[0])
+	}
+	size := BSONLengthSize + len(s.Identifier) + 1
+	for _, doc := range s.Documents {
+		size += len(doc)
+	}
+	return size
+}
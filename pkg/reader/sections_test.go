@@ -0,0 +1,163 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// marshalDoc marshals a test document
+func marshalDoc(t *testing.T, doc bson.D) []byte {
+	t.Helper()
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+	return b
+}
+
+// sequenceOpMsg builds, byte by byte, an OP_MSG with a kind-1 "documents" section
+// before its body, optionally with a trailing CRC-32C checksum
+func sequenceOpMsg(t *testing.T, withChecksum bool) []byte {
+	t.Helper()
+	body := marshalDoc(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}})
+	docs := [][]byte{
+		marshalDoc(t, bson.D{{Key: "_id", Value: int32(1)}}),
+		marshalDoc(t, bson.D{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "b"}}),
+	}
+
+	var flags uint32
+	if withChecksum {
+		flags = OpMsgChecksumPresent
+	}
+	payload := binary.LittleEndian.AppendUint32(nil, flags)
+	seq := append([]byte("documents"), 0)
+	for _, d := range docs {
+		seq = append(seq, d...)
+	}
+	payload = append(payload, 1)
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(4+len(seq)))
+	payload = append(payload, seq...)
+	payload = append(payload, 0)
+	payload = append(payload, body...)
+
+	size := 16 + len(payload)
+	if withChecksum {
+		size += ChecksumSize
+	}
+	msg := append(buildWireMessage(int32(size), 42, 0, 2013), payload...)
+	if withChecksum {
+		msg = binary.LittleEndian.AppendUint32(msg, crc32.Checksum(msg, crc32.MakeTable(crc32.Castagnoli)))
+	}
+	return msg
+}
+
+func TestSplitOpMsgSections_RoundTrip(t *testing.T) {
+	messages := []struct {
+		name string
+		msg  []byte
+	}{
+		{"body only", buildOpMsg(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})},
+		{"sequence before body", sequenceOpMsg(t, false)},
+		{"with checksum", sequenceOpMsg(t, true)},
+	}
+
+	for _, tt := range messages {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, sections, checksum, err := SplitOpMsgSections(tt.msg)
+			if err != nil {
+				t.Fatalf("SplitOpMsgSections failed: %v", err)
+			}
+			if (checksum != nil) != (flags&OpMsgChecksumPresent != 0) {
+				t.Errorf("checksum %x with flags %#x", checksum, flags)
+			}
+
+			requestID := int32(binary.LittleEndian.Uint32(tt.msg[4:8]))
+			responseTo := int32(binary.LittleEndian.Uint32(tt.msg[8:12]))
+			rebuilt := BuildOpMsg(requestID, responseTo, flags, sections, checksum != nil)
+			if !bytes.Equal(rebuilt, tt.msg) {
+				t.Errorf("round trip changed the message:\n got %x\nwant %x", rebuilt, tt.msg)
+			}
+		})
+	}
+}
+
+func TestSplitOpMsgSections_Structure(t *testing.T) {
+	flags, sections, checksum, err := SplitOpMsgSections(sequenceOpMsg(t, true))
+	if err != nil {
+		t.Fatalf("SplitOpMsgSections failed: %v", err)
+	}
+	if flags != OpMsgChecksumPresent || len(checksum) != ChecksumSize {
+		t.Errorf("flags %#x, checksum %x; want checksumPresent and 4 checksum bytes", flags, checksum)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(sections))
+	}
+	if s := sections[0]; s.Kind != 1 || s.Identifier != "documents" || len(s.Documents) != 2 {
+		t.Errorf("section 0 = kind %d %q with %d documents, want kind 1 \"documents\" with 2", s.Kind, s.Identifier, len(s.Documents))
+	}
+	if s := sections[1]; s.Kind != 0 || len(s.Documents) != 1 || bson.Raw(s.Documents[0]).Lookup("insert").StringValue() != "users" {
+		t.Errorf("section 1 = %+v, want the insert body", s)
+	}
+}
+
+func TestBuildOpMsg_Rewrite(t *testing.T) {
+	msg := sequenceOpMsg(t, true)
+	flags, sections, _, err := SplitOpMsgSections(msg)
+	if err != nil {
+		t.Fatalf("SplitOpMsgSections failed: %v", err)
+	}
+
+	// Replace the second sequence document with a larger one and drop the checksum
+	sections[0].Documents[1] = marshalDoc(t, bson.D{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "a longer name"}})
+	rebuilt := BuildOpMsg(42, 0, flags, sections, false)
+
+	flags, resplit, checksum, err := SplitOpMsgSections(rebuilt)
+	if err != nil {
+		t.Fatalf("rewritten message does not split: %v", err)
+	}
+	if flags&OpMsgChecksumPresent != 0 || checksum != nil {
+		t.Errorf("flags %#x, checksum %x; want the checksum dropped", flags, checksum)
+	}
+	if name := bson.Raw(resplit[0].Documents[1]).Lookup("name").StringValue(); name != "a longer name" {
+		t.Errorf("rewritten document name = %q", name)
+	}
+	if resplit[1].Kind != 0 {
+		t.Errorf("section order changed: %+v", resplit)
+	}
+}
+
+func TestSplitOpMsgSections_Invalid(t *testing.T) {
+	valid := sequenceOpMsg(t, false)
+	withLength := func(msg []byte) []byte {
+		binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+		return msg
+	}
+
+	unknownKind := append([]byte(nil), valid...)
+	unknownKind[OpMsgSectionsOffset] = 2
+	badSequenceSize := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(badSequenceSize[OpMsgSectionsOffset+1:], 1000)
+	notOpMsg := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(notOpMsg[12:16], 2004)
+
+	messages := []struct {
+		name string
+		msg  []byte
+	}{
+		{"too short", valid[:MinOpMsgSize-1]},
+		{"length mismatch", valid[:len(valid)-1]},
+		{"truncated body", withLength(append([]byte(nil), valid[:len(valid)-1]...))},
+		{"unknown kind", unknownKind},
+		{"sequence overruns message", badSequenceSize},
+		{"not OP_MSG", notOpMsg},
+	}
+	for _, tt := range messages {
+		if _, _, _, err := SplitOpMsgSections(tt.msg); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
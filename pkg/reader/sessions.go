@@ -0,0 +1,216 @@
+package reader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+)
+
+// PacketIterator yields packets in order
+// RecordingReader and RecordingSet both satisfy this interface
+type PacketIterator interface {
+	Next() (*Packet, error)
+}
+
+// GroupBySession reads every packet from r and groups them by session ID
+// Packets keep their recording order within each session.
+// The whole recording is held in memory; for large recordings prefer SessionIterator,
+// which only holds sessions that are still open.
+func GroupBySession(r PacketIterator) (map[uint64][]*Packet, error) {
+	sessions := make(map[uint64][]*Packet)
+	for {
+		packet, err := r.Next()
+		if err == io.EOF {
+			return sessions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions[packet.SessionID] = append(sessions[packet.SessionID], packet)
+	}
+}
+
+// SessionPackets is one session's packets as emitted by SessionIterator
+type SessionPackets struct {
+	// SessionID is the session/connection identifier
+	SessionID uint64
+
+	// Packets are the session's packets in recording order
+	Packets []*Packet
+
+	// Ended is true if the session closed with a session-end marker
+	// (false if it was still open when the recording ended)
+	Ended bool
+}
+
+// SessionIterator streams complete sessions from a packet source
+// A session is emitted as soon as its session-end marker (an empty message that
+// isn't the session's first packet) is read; sessions still open at EOF are
+// emitted afterwards in the order they started. Only open sessions are buffered.
+type SessionIterator struct {
+	src     PacketIterator
+	open    map[uint64]*openSession
+	started uint64
+	flush   []*SessionPackets
+	eof     bool
+}
+
+// openSession is a session whose end marker hasn't been read yet
+type openSession struct {
+	seq     uint64 // start sequence, for emitting unfinished sessions in start order
+	packets []*Packet
+}
+
+// NewSessionIterator creates a SessionIterator reading from src
+func NewSessionIterator(src PacketIterator) *SessionIterator {
+	return &SessionIterator{
+		src:  src,
+		open: make(map[uint64]*openSession),
+	}
+}
+
+// Next returns the next complete session
+// Returns io.EOF once every session has been emitted
+func (it *SessionIterator) Next() (*SessionPackets, error) {
+	for !it.eof {
+		packet, err := it.src.Next()
+		if err == io.EOF {
+			it.eof = true
+			it.flushOpen()
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		session, ok := it.open[packet.SessionID]
+		if !ok {
+			it.started++
+			session = &openSession{seq: it.started}
+			it.open[packet.SessionID] = session
+		}
+		session.packets = append(session.packets, packet)
+
+		if len(packet.Message) == 0 && len(session.packets) > 1 {
+			delete(it.open, packet.SessionID)
+			return &SessionPackets{SessionID: packet.SessionID, Packets: session.packets, Ended: true}, nil
+		}
+	}
+
+	if len(it.flush) == 0 {
+		return nil, io.EOF
+	}
+	next := it.flush[0]
+	it.flush = it.flush[1:]
+	return next, nil
+}
+
+// flushOpen queues every still-open session, oldest first
+func (it *SessionIterator) flushOpen() {
+	type pending struct {
+		seq     uint64
+		session *SessionPackets
+	}
+
+	var sessions []pending
+	for id, s := range it.open {
+		sessions = append(sessions, pending{s.seq, &SessionPackets{SessionID: id, Packets: s.packets}})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].seq < sessions[j].seq
+	})
+
+	for _, p := range sessions {
+		it.flush = append(it.flush, p.session)
+	}
+	it.open = nil
+}
+
+// SessionSummary is one session's extent in a recording, as returned by SessionSummaries
+type SessionSummary struct {
+	// SessionID is the session/connection identifier
+	SessionID uint64
+
+	// Metadata is the session metadata string of the session's first packet that has one
+	Metadata string
+
+	// FirstOffset and LastOffset are the offsets (microseconds) of the session's first and last packets
+	FirstOffset uint64
+	LastOffset  uint64
+
+	// PacketCount is the number of packets in the session
+	PacketCount int
+}
+
+// SessionSummaries reads a recording file once and summarizes each of its sessions
+// Only packet headers are read; message bodies are skipped without being allocated.
+// Sessions are sorted by first offset (then session ID).
+func SessionSummaries(path string) ([]SessionSummary, error) {
+	r, err := NewRecordingReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	byID := make(map[uint64]*SessionSummary)
+	for n := 1; ; n++ {
+		packet, err := r.skipPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packet %d of %s: %w", n, path, err)
+		}
+
+		s, ok := byID[packet.SessionID]
+		if !ok {
+			s = &SessionSummary{SessionID: packet.SessionID, FirstOffset: packet.Offset}
+			byID[packet.SessionID] = s
+		}
+		if s.Metadata == "" {
+			s.Metadata = packet.SessionMetadata
+		}
+		if packet.Offset < s.FirstOffset {
+			s.FirstOffset = packet.Offset
+		}
+		if packet.Offset > s.LastOffset {
+			s.LastOffset = packet.Offset
+		}
+		s.PacketCount++
+	}
+
+	summaries := make([]SessionSummary, 0, len(byID))
+	for _, s := range byID {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].FirstOffset != summaries[j].FirstOffset {
+			return summaries[i].FirstOffset < summaries[j].FirstOffset
+		}
+		return summaries[i].SessionID < summaries[j].SessionID
+	})
+	return summaries, nil
+}
+
+// SessionSampled reports whether a session falls in a deterministic sample of the given fraction
+// The session ID is hashed, so the same sessions are chosen on every run and every
+// tool, and sessions are kept or dropped whole. Fractions <= 0 select nothing and
+// fractions >= 1 select every session.
+func SessionSampled(sessionID uint64, fraction float64) bool {
+	if fraction >= 1 {
+		return true
+	}
+	if fraction <= 0 {
+		return false
+	}
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], sessionID)
+	h := fnv.New64a()
+	h.Write(buf[:])
+
+	// The top 53 bits of the hash as a uniform value in [0, 1)
+	return float64(h.Sum64()>>11)/(1<<53) < fraction
+}
@@ -0,0 +1,172 @@
+package reader
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// packetList is a PacketIterator backed by a slice
+type packetList struct {
+	packets []*Packet
+	idx     int
+}
+
+func (l *packetList) Next() (*Packet, error) {
+	if l.idx >= len(l.packets) {
+		return nil, io.EOF
+	}
+	p := l.packets[l.idx]
+	l.idx++
+	return p, nil
+}
+
+// interleavedSessions builds three interleaved sessions:
+// session 1 and 3 end with an empty marker; session 2 is still open at EOF
+func interleavedSessions() []*Packet {
+	msg := buildWireMessage(16, 1, 0, 2013)
+	return []*Packet{
+		{SessionID: 1, Order: 1},               // session 1 start
+		{SessionID: 2, Order: 2},               // session 2 start
+		{SessionID: 1, Order: 3, Message: msg}, //
+		{SessionID: 3, Order: 4, Message: msg}, // session 3 has no start marker
+		{SessionID: 2, Order: 5, Message: msg}, //
+		{SessionID: 3, Order: 6},               // session 3 end
+		{SessionID: 1, Order: 7, Message: msg}, //
+		{SessionID: 1, Order: 8},               // session 1 end
+		{SessionID: 2, Order: 9, Message: msg}, // session 2 never ends
+	}
+}
+
+func TestGroupBySession(t *testing.T) {
+	sessions, err := GroupBySession(&packetList{packets: interleavedSessions()})
+	if err != nil {
+		t.Fatalf("GroupBySession failed: %v", err)
+	}
+
+	want := map[uint64][]uint64{
+		1: {1, 3, 7, 8},
+		2: {2, 5, 9},
+		3: {4, 6},
+	}
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(sessions), len(want))
+	}
+	for id, orders := range want {
+		assertOrders(t, id, sessions[id], orders)
+	}
+}
+
+func TestSessionIterator(t *testing.T) {
+	it := NewSessionIterator(&packetList{packets: interleavedSessions()})
+
+	want := []struct {
+		id     uint64
+		orders []uint64
+		ended  bool
+	}{
+		{3, []uint64{4, 6}, true},
+		{1, []uint64{1, 3, 7, 8}, true},
+		{2, []uint64{2, 5, 9}, false},
+	}
+
+	for _, w := range want {
+		session, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if session.SessionID != w.id || session.Ended != w.ended {
+			t.Fatalf("got session %d (ended=%v), want %d (ended=%v)", session.SessionID, session.Ended, w.id, w.ended)
+		}
+		assertOrders(t, w.id, session.Packets, w.orders)
+	}
+
+	if _, err := it.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after last session, got %v", err)
+	}
+}
+
+func assertOrders(t *testing.T, sessionID uint64, packets []*Packet, want []uint64) {
+	t.Helper()
+	if len(packets) != len(want) {
+		t.Fatalf("session %d: got %d packets, want %d", sessionID, len(packets), len(want))
+	}
+	for i, p := range packets {
+		if p.Order != want[i] {
+			t.Errorf("session %d packet %d: order %d, want %d", sessionID, i, p.Order, want[i])
+		}
+	}
+}
+
+func TestSessionSummaries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.bin")
+	w, err := NewRecordingWriter(path, WriterOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+
+	// Session 7 starts after session 9 despite its lower ID; session 9's metadata
+	// only appears on its second packet
+	msg := buildWireMessage(16, 1, 0, 2013)
+	packets := []*Packet{
+		{SessionID: 9, Offset: 100, Order: 1},
+		{SessionID: 9, SessionMetadata: `{ remote: "10.0.0.9:1" }`, Offset: 150, Order: 2, Message: msg},
+		{SessionID: 7, SessionMetadata: `{ remote: "10.0.0.7:1" }`, Offset: 200, Order: 3},
+		{SessionID: 7, Offset: 250, Order: 4, Message: msg},
+		{SessionID: 9, Offset: 300, Order: 5, Message: msg},
+		{SessionID: 7, Offset: 400, Order: 6},
+		{SessionID: 5, Offset: 400, Order: 7, Message: msg},
+	}
+	for _, p := range packets {
+		if err := w.Write(p); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got, err := SessionSummaries(path)
+	if err != nil {
+		t.Fatalf("SessionSummaries failed: %v", err)
+	}
+	want := []SessionSummary{
+		{SessionID: 9, Metadata: `{ remote: "10.0.0.9:1" }`, FirstOffset: 100, LastOffset: 300, PacketCount: 3},
+		{SessionID: 7, Metadata: `{ remote: "10.0.0.7:1" }`, FirstOffset: 200, LastOffset: 400, PacketCount: 3},
+		{SessionID: 5, FirstOffset: 400, LastOffset: 400, PacketCount: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SessionSummaries =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSessionSummaries_MissingFile(t *testing.T) {
+	if _, err := SessionSummaries(filepath.Join(t.TempDir(), "missing.bin")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestSessionSampled(t *testing.T) {
+	selected := 0
+	for id := uint64(1); id <= 10000; id++ {
+		sampled := SessionSampled(id, 0.1)
+		if sampled != SessionSampled(id, 0.1) {
+			t.Fatalf("session %d: sampling is not deterministic", id)
+		}
+		if sampled {
+			selected++
+			// A session in a sample is in every larger sample
+			if !SessionSampled(id, 0.5) {
+				t.Errorf("session %d sampled at 10%% but not at 50%%", id)
+			}
+		}
+	}
+	if selected < 900 || selected > 1100 {
+		t.Errorf("selected %d of 10000 sessions at 10%%, want about 1000", selected)
+	}
+
+	if SessionSampled(42, 0) || !SessionSampled(42, 1) {
+		t.Error("fractions 0 and 1 must select no and every session")
+	}
+}
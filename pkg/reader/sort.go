@@ -0,0 +1,255 @@
+package reader
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PacketWriter accepts packets in order
+// RecordingWriter satisfies this interface
+type PacketWriter interface {
+	Write(packet *Packet) error
+}
+
+// DefaultSortRunBytes is the default amount of packet data SortPackets buffers per run
+const DefaultSortRunBytes = 256 << 20
+
+// mergeReaderBufferSize is the read buffer of each run file, all of which are open during a merge
+const mergeReaderBufferSize = 64 << 10
+
+// SortOptions controls SortPackets
+type SortOptions struct {
+	// RunBytes is the packet data buffered in memory before a sorted run is spilled to
+	// a temporary file (0 = DefaultSortRunBytes)
+	RunBytes uint64
+
+	// TempDir is where runs are spilled ("" = the system temp directory)
+	TempDir string
+}
+
+// SortStats reports what SortPackets did
+type SortStats struct {
+	// Packets is the number of packets read (and written)
+	Packets int
+
+	// OutOfOrder counts packets that sort before the packet read just before them
+	OutOfOrder int
+
+	// Runs is the number of sorted runs spilled to temporary files (0 = sorted in memory)
+	Runs int
+}
+
+// AlreadySorted returns true if the input was already in (Offset, Order) order
+func (s *SortStats) AlreadySorted() bool {
+	return s.OutOfOrder == 0
+}
+
+// packetLess orders packets by offset, then by order number
+func packetLess(a, b *Packet) bool {
+	if a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return a.Order < b.Order
+}
+
+// packetMemory approximates the memory a buffered packet holds
+func packetMemory(p *Packet) uint64 {
+	return uint64(len(p.Message)+len(p.SessionMetadata)) + 64
+}
+
+// SortPackets reads every packet from src and writes them to dst ordered by (Offset, Order)
+// Packets with equal keys keep their input order. Input that fits in one run is sorted
+// in memory; larger input is sorted in runs spilled to temporary files, which are then
+// merged, so memory stays bounded by opts.RunBytes.
+func SortPackets(src PacketIterator, dst PacketWriter, opts SortOptions) (*SortStats, error) {
+	runBytes := opts.RunBytes
+	if runBytes == 0 {
+		runBytes = DefaultSortRunBytes
+	}
+
+	stats := &SortStats{}
+	var buf []*Packet
+	var buffered uint64
+	var prev *Packet
+	var runs []string
+	var tempDir string
+
+	defer func() {
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+	}()
+
+	spill := func() error {
+		if tempDir == "" {
+			dir, err := os.MkdirTemp(opts.TempDir, "traffic-sort-*")
+			if err != nil {
+				return fmt.Errorf("failed to create temp directory: %w", err)
+			}
+			tempDir = dir
+		}
+
+		path := filepath.Join(tempDir, fmt.Sprintf("run-%06d.bin", len(runs)))
+		if err := writeSortedRun(path, buf); err != nil {
+			return err
+		}
+		runs = append(runs, path)
+		buf, buffered = nil, 0
+		return nil
+	}
+
+	for {
+		packet, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packet: %w", err)
+		}
+
+		stats.Packets++
+		if prev != nil && packetLess(packet, prev) {
+			stats.OutOfOrder++
+		}
+		prev = packet
+
+		buf = append(buf, packet)
+		buffered += packetMemory(packet)
+		if buffered >= runBytes {
+			if err := spill(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(runs) == 0 {
+		sortRun(buf)
+		for _, packet := range buf {
+			if err := dst.Write(packet); err != nil {
+				return nil, fmt.Errorf("failed to write packet: %w", err)
+			}
+		}
+		return stats, nil
+	}
+
+	if len(buf) > 0 {
+		if err := spill(); err != nil {
+			return nil, err
+		}
+	}
+	stats.Runs = len(runs)
+
+	if err := mergeRuns(runs, dst); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// sortRun stably sorts packets by (Offset, Order)
+func sortRun(packets []*Packet) {
+	sort.SliceStable(packets, func(i, j int) bool {
+		return packetLess(packets[i], packets[j])
+	})
+}
+
+// writeSortedRun sorts packets and writes them to a headerless temporary recording
+func writeSortedRun(path string, packets []*Packet) error {
+	sortRun(packets)
+
+	w, err := NewRecordingWriter(path, WriterOptions{Headerless: true})
+	if err != nil {
+		return err
+	}
+	for _, packet := range packets {
+		if err := w.Write(packet); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to write sort run %s: %w", path, err)
+		}
+	}
+	return w.Close()
+}
+
+// runCursor is the next unmerged packet of one sorted run
+type runCursor struct {
+	packet *Packet
+	reader *RecordingReader
+	run    int
+}
+
+// runHeap orders run cursors by their next packet; ties go to the earlier run,
+// which holds the earlier input, so the merge stays stable
+type runHeap []*runCursor
+
+func (h runHeap) Len() int { return len(h) }
+
+func (h runHeap) Less(i, j int) bool {
+	if packetLess(h[i].packet, h[j].packet) {
+		return true
+	}
+	if packetLess(h[j].packet, h[i].packet) {
+		return false
+	}
+	return h[i].run < h[j].run
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runCursor)) }
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// mergeRuns k-way merges sorted run files into dst
+func mergeRuns(runs []string, dst PacketWriter) error {
+	h := make(runHeap, 0, len(runs))
+	defer func() {
+		for _, c := range h {
+			c.reader.Close()
+		}
+	}()
+
+	for i, path := range runs {
+		r, err := NewRecordingReaderWithBufferSize(path, mergeReaderBufferSize)
+		if err != nil {
+			return err
+		}
+		packet, err := r.Next()
+		if err != nil {
+			r.Close()
+			if err == io.EOF {
+				continue
+			}
+			return fmt.Errorf("failed to read sort run %s: %w", path, err)
+		}
+		h = append(h, &runCursor{packet: packet, reader: r, run: i})
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		c := h[0]
+		if err := dst.Write(c.packet); err != nil {
+			return fmt.Errorf("failed to write packet: %w", err)
+		}
+
+		packet, err := c.reader.Next()
+		if err == io.EOF {
+			c.reader.Close()
+			heap.Pop(&h)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read sort run %s: %w", c.reader.Path(), err)
+		}
+		c.packet = packet
+		heap.Fix(&h, 0)
+	}
+	return nil
+}
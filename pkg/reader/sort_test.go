@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// packetSink is a PacketWriter that collects packets
+type packetSink struct {
+	packets []*Packet
+}
+
+func (s *packetSink) Write(packet *Packet) error {
+	s.packets = append(s.packets, packet)
+	return nil
+}
+
+// shuffledPackets builds packets whose offsets arrive out of order, with ties on
+// (Offset, Order) told apart by session ID (the input position)
+func shuffledPackets() []*Packet {
+	offsets := []uint64{50, 10, 30, 10, 40, 20, 30, 0, 60, 20}
+	var packets []*Packet
+	for i, offset := range offsets {
+		packets = append(packets, &Packet{
+			SessionID: uint64(i),
+			Offset:    offset,
+			Order:     offset / 20, // equal offsets share an order number too
+			Message:   buildWireMessage(16, int32(i), 0, 2013),
+		})
+	}
+	return packets
+}
+
+// wantSorted is shuffledPackets' session IDs in stable (Offset, Order) order
+var wantSorted = []uint64{7, 1, 3, 5, 9, 2, 6, 4, 0, 8}
+
+func TestSortPackets_InMemory(t *testing.T) {
+	sink := &packetSink{}
+	stats, err := SortPackets(&packetList{packets: shuffledPackets()}, sink, SortOptions{})
+	if err != nil {
+		t.Fatalf("SortPackets failed: %v", err)
+	}
+
+	assertSessionOrder(t, sink.packets, wantSorted)
+	if stats.Packets != 10 || stats.Runs != 0 || stats.AlreadySorted() {
+		t.Errorf("stats = %+v, want 10 packets, no runs, not already sorted", stats)
+	}
+}
+
+func TestSortPackets_ExternalMerge(t *testing.T) {
+	tempDir := t.TempDir()
+	sink := &packetSink{}
+
+	// Every packet exceeds the run size, so each one is spilled as its own run
+	stats, err := SortPackets(&packetList{packets: shuffledPackets()}, sink, SortOptions{RunBytes: 1, TempDir: tempDir})
+	if err != nil {
+		t.Fatalf("SortPackets failed: %v", err)
+	}
+
+	assertSessionOrder(t, sink.packets, wantSorted)
+	if stats.Runs != 10 {
+		t.Errorf("Runs = %d, want 10", stats.Runs)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temp directory not cleaned up: %d entries left", len(entries))
+	}
+}
+
+func TestSortPackets_AlreadySorted(t *testing.T) {
+	var packets []*Packet
+	for i := 0; i < 5; i++ {
+		packets = append(packets, &Packet{SessionID: uint64(i), Offset: uint64(i * 10), Order: uint64(i)})
+	}
+
+	sink := &packetSink{}
+	stats, err := SortPackets(&packetList{packets: packets}, sink, SortOptions{RunBytes: 200, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("SortPackets failed: %v", err)
+	}
+
+	assertSessionOrder(t, sink.packets, []uint64{0, 1, 2, 3, 4})
+	if !stats.AlreadySorted() {
+		t.Errorf("OutOfOrder = %d, want 0", stats.OutOfOrder)
+	}
+}
+
+func assertSessionOrder(t *testing.T, packets []*Packet, want []uint64) {
+	t.Helper()
+
+	var got []uint64
+	for _, p := range packets {
+		got = append(got, p.SessionID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sorted session order = %v, want %v", got, want)
+	}
+}
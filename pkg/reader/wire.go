@@ -0,0 +1,40 @@
+package reader
+
+// Wire protocol layout sizes, in bytes
+//
+// OP_MSG format:
+//
+//	header    : 16 bytes  - messageLength, requestID, responseTo, opCode (int32 LE each)
+//	flagBits  : uint32 LE
+//	sections  : one or more of
+//	  kind 0  : uint8(0) + BSON document (the command body)
+//	  kind 1  : uint8(1) + int32 size + cstring identifier + BSON documents
+//	checksum  : uint32 LE - Only if the checksumPresent flag is set
+const (
+	// WireHeaderSize is the size of the standard message header every opcode starts with
+	WireHeaderSize = 16
+
+	// OpMsgFlagsSize is the size of the OP_MSG flagBits field that follows the header
+	OpMsgFlagsSize = 4
+
+	// SectionKindSize is the size of the kind byte that starts each OP_MSG section
+	SectionKindSize = 1
+
+	// BSONLengthSize is the size of the int32 length prefix of a BSON document
+	BSONLengthSize = 4
+
+	// ChecksumSize is the size of the optional trailing OP_MSG CRC-32C checksum
+	ChecksumSize = 4
+
+	// OpMsgSectionsOffset is the byte index of the first OP_MSG section (its kind byte)
+	OpMsgSectionsOffset = WireHeaderSize + OpMsgFlagsSize
+
+	// MinOpMsgSize is the smallest OP_MSG that can hold a section kind byte
+	MinOpMsgSize = OpMsgSectionsOffset + SectionKindSize
+)
+
+// OpMsgBodyOffset returns the byte index of the first section's body in an OP_MSG
+// For the usual kind-0 first section this is where the command document starts.
+func OpMsgBodyOffset() int {
+	return OpMsgSectionsOffset + SectionKindSize
+}
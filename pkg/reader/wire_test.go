@@ -0,0 +1,25 @@
+package reader
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestOpMsgBodyOffset(t *testing.T) {
+	msg := buildOpMsg(t, bson.D{{Key: "ping", Value: 1}, {Key: "$db", Value: "admin"}})
+
+	if msg[OpMsgSectionsOffset] != 0 {
+		t.Fatalf("section kind at %d = %d, want 0", OpMsgSectionsOffset, msg[OpMsgSectionsOffset])
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(msg[OpMsgBodyOffset():], &doc); err != nil {
+		t.Fatalf("body at offset %d does not decode: %v", OpMsgBodyOffset(), err)
+	}
+	if len(doc) == 0 || doc[0].Key != "ping" {
+		t.Errorf("body = %v, want the ping command", doc)
+	}
+	if MinOpMsgSize != 21 {
+		t.Errorf("MinOpMsgSize = %d, want 21", MinOpMsgSize)
+	}
+}
@@ -0,0 +1,107 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// WriterOptions controls how a RecordingWriter lays out its file
+type WriterOptions struct {
+	// StartTime is the capture start time stored in the header (zero = unknown)
+	StartTime time.Time
+
+	// Headerless writes a bare packet stream, identical to server-written files
+	Headerless bool
+}
+
+// RecordingWriter writes packets to a recording file
+// By default the file starts with a FileHeader so readers can identify and version it
+type RecordingWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	path   string
+	closed bool
+}
+
+// NewRecordingWriter creates (or truncates) a recording file and writes its header
+func NewRecordingWriter(path string, opts WriterOptions) (*RecordingWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording file %s: %w", path, err)
+	}
+
+	w := &RecordingWriter{
+		file:   file,
+		writer: bufio.NewWriterSize(file, 1024*1024), // 1MB buffer for performance
+		path:   path,
+	}
+
+	if !opts.Headerless {
+		header := &FileHeader{Version: FileHeaderVersion, StartTime: opts.StartTime}
+		if err := WriteFileHeader(w.writer, header); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write header to %s: %w", path, err)
+		}
+	}
+
+	return w, nil
+}
+
+// Write appends a packet to the recording
+func (w *RecordingWriter) Write(packet *Packet) error {
+	if w.closed {
+		return fmt.Errorf("writer is closed")
+	}
+	return WritePacket(w.writer, packet)
+}
+
+// Close flushes buffered packets and closes the file
+func (w *RecordingWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush %s: %w", w.path, err)
+	}
+	return w.file.Close()
+}
+
+// Path returns the path of the recording file
+func (w *RecordingWriter) Path() string {
+	return w.path
+}
+
+// WritePacket writes a single packet in the recording binary format
+// The size field is recomputed from the metadata and message lengths
+func WritePacket(w io.Writer, packet *Packet) error {
+	// Header size = 4 (size) + 8 (id) + len(session) + 1 (null) + 8 (offset) + 8 (order)
+	headerSize := 4 + 8 + len(packet.SessionMetadata) + 1 + 8 + 8
+	totalSize := uint32(headerSize + len(packet.Message))
+
+	buf := make([]byte, 0, headerSize)
+	buf = binary.LittleEndian.AppendUint32(buf, totalSize)
+	buf = binary.LittleEndian.AppendUint64(buf, packet.SessionID)
+	buf = append(buf, packet.SessionMetadata...)
+	buf = append(buf, 0)
+	buf = binary.LittleEndian.AppendUint64(buf, packet.Offset)
+	buf = binary.LittleEndian.AppendUint64(buf, packet.Order)
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	if len(packet.Message) > 0 {
+		if _, err := w.Write(packet.Message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
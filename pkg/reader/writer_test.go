@@ -0,0 +1,153 @@
+package reader
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readAll reads every packet from a recording file
+func readAll(t *testing.T, r *RecordingReader) []*Packet {
+	t.Helper()
+
+	var packets []*Packet
+	for {
+		packet, err := r.Next()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("Failed to read packet: %v", err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func TestRecordingWriter_RoundTripWithHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	start := time.Date(2025, 11, 3, 14, 30, 0, 123000, time.UTC)
+
+	w, err := NewRecordingWriter(path, WriterOptions{StartTime: start})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+
+	wireMsg := buildWireMessage(16, 100, 0, 2013)
+	in := []*Packet{
+		{SessionID: 1, SessionMetadata: `{ remote: "127.0.0.1:1" }`, Offset: 10, Order: 1},
+		{SessionID: 1, SessionMetadata: `{ remote: "127.0.0.1:1" }`, Offset: 20, Order: 2, Message: wireMsg},
+	}
+	for _, p := range in {
+		if err := w.Write(p); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer r.Close()
+
+	if r.Header() == nil {
+		t.Fatal("expected a file header")
+	}
+	if r.Header().Version != FileHeaderVersion {
+		t.Errorf("Version = %d, want %d", r.Header().Version, FileHeaderVersion)
+	}
+	if !r.StartTime().Equal(start) {
+		t.Errorf("StartTime = %v, want %v", r.StartTime(), start)
+	}
+
+	out := readAll(t, r)
+	if len(out) != len(in) {
+		t.Fatalf("read %d packets, want %d", len(out), len(in))
+	}
+	for i := range in {
+		if out[i].SessionID != in[i].SessionID || out[i].Offset != in[i].Offset || out[i].Order != in[i].Order ||
+			out[i].SessionMetadata != in[i].SessionMetadata || !bytes.Equal(out[i].Message, in[i].Message) {
+			t.Errorf("packet %d mismatch: got %+v, want %+v", i, out[i], in[i])
+		}
+	}
+}
+
+func TestRecordingWriter_Headerless(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+
+	w, err := NewRecordingWriter(path, WriterOptions{Headerless: true})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+	packet := &Packet{SessionID: 7, Offset: 5, Order: 1}
+	if err := w.Write(packet); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A headerless file must be byte-identical to the server format
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	expected := buildTestPacket(EventTypeRegular, 7, "", 5, 1, nil)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("headerless output = %x, want %x", data, expected)
+	}
+
+	r, err := NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer r.Close()
+
+	if r.Header() != nil {
+		t.Error("expected no header for headerless file")
+	}
+	if !r.StartTime().IsZero() {
+		t.Errorf("StartTime = %v, want zero", r.StartTime())
+	}
+	if packets := readAll(t, r); len(packets) != 1 {
+		t.Errorf("read %d packets, want 1", len(packets))
+	}
+}
+
+func TestReadFileHeader_SkipsExtensionAndRejectsFutureVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFileHeader(&buf, &FileHeader{}); err != nil {
+		t.Fatalf("WriteFileHeader failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Grow the header by 4 extension bytes and follow it with a packet
+	extended := append([]byte{}, data...)
+	extended[4] += 4
+	extended = append(extended, 0xde, 0xad, 0xbe, 0xef)
+	extended = append(extended, buildTestPacket(EventTypeRegular, 3, "", 1, 1, nil)...)
+
+	r := bytes.NewReader(extended)
+	if _, err := ReadFileHeader(r); err != nil {
+		t.Fatalf("ReadFileHeader failed: %v", err)
+	}
+	packet, err := ReadPacket(r)
+	if err != nil {
+		t.Fatalf("ReadPacket after extended header failed: %v", err)
+	}
+	if packet.SessionID != 3 {
+		t.Errorf("SessionID = %d, want 3", packet.SessionID)
+	}
+
+	// Unknown future version
+	future := append([]byte{}, data...)
+	future[8] = byte(FileHeaderVersion + 1)
+	if _, err := ReadFileHeader(bytes.NewReader(future)); err == nil {
+		t.Error("expected error for unsupported header version")
+	}
+}
@@ -0,0 +1,34 @@
+package replay
+
+import (
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// AggWritePolicy decides what happens to aggregations ending in $out or $merge
+type AggWritePolicy string
+
+const (
+	// AggWritesSkip drops such aggregations without sending them
+	AggWritesSkip AggWritePolicy = "skip"
+
+	// AggWritesStrip sends them without the write stage, as reads
+	AggWritesStrip AggWritePolicy = "strip"
+)
+
+// blockAggWrite applies BlockAggWrites to a command and reports whether it is skipped
+// Stripped aggregations are rewritten in place and still sent.
+func (r *Replayer) blockAggWrite(stats *Stats, cmd *sender.Command) bool {
+	stage := sender.AggregateWriteStage(cmd)
+	if stage == "" {
+		return false
+	}
+	stats.AggWritesBlocked++
+
+	if r.config.BlockAggWrites == AggWritesSkip {
+		r.logOp("⊘ BLOCKED: %s.aggregate - skipped (pipeline ends in %s)\n", cmd.Database, stage)
+		return true
+	}
+	sender.StripAggregateWriteStage(cmd)
+	r.logOp("⊘ BLOCKED: %s.aggregate - %s stage removed, sent as a read\n", cmd.Database, stage)
+	return false
+}
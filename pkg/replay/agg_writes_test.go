@@ -0,0 +1,70 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRun_BlockAggWrites(t *testing.T) {
+	pipeline := func(stages ...bson.D) bson.D {
+		a := bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "active", Value: true}}}}}
+		for _, s := range stages {
+			a = append(a, s)
+		}
+		return bson.D{{Key: "aggregate", Value: "users"}, {Key: "pipeline", Value: a}, {Key: "cursor", Value: bson.D{}}, {Key: "$db", Value: "app"}}
+	}
+	packets := func() PacketSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, pipeline()),
+			buildCommandPacket(t, 1, 0, pipeline(bson.D{{Key: "$out", Value: "active_users"}})),
+		}}
+	}
+
+	tests := []struct {
+		policy  AggWritePolicy
+		sent    int
+		stages  []int // pipeline length of each sent aggregate
+		skipped int
+		blocked int
+	}{
+		{policy: "", sent: 2, stages: []int{1, 2}},
+		{policy: AggWritesSkip, sent: 1, stages: []int{1}, skipped: 1, blocked: 1},
+		{policy: AggWritesStrip, sent: 2, stages: []int{1, 1}, blocked: 1},
+	}
+
+	for _, tt := range tests {
+		snd := &recordingCommandSender{}
+		r, err := New(Config{Mode: ModeCommand, CommandSender: snd, BlockAggWrites: tt.policy})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), packets())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(snd.commands) != tt.sent || stats.SkippedPackets != tt.skipped || stats.AggWritesBlocked != tt.blocked {
+			t.Errorf("policy %q: sent/skipped/blocked = %d/%d/%d, want %d/%d/%d", tt.policy,
+				len(snd.commands), stats.SkippedPackets, stats.AggWritesBlocked, tt.sent, tt.skipped, tt.blocked)
+			continue
+		}
+		for i, cmd := range snd.commands {
+			if got := len(cmd["pipeline"].(bson.A)); got != tt.stages[i] {
+				t.Errorf("policy %q: aggregate %d sent with %d stages, want %d", tt.policy, i, got, tt.stages[i])
+			}
+		}
+	}
+}
+
+func TestNew_BlockAggWritesValidation(t *testing.T) {
+	if _, err := New(Config{Mode: ModeCommand, DryRun: true, BlockAggWrites: "drop"}); err == nil || !strings.Contains(err.Error(), "aggregate write policy") {
+		t.Errorf("New error = %v, want invalid policy", err)
+	}
+	if _, err := New(Config{Mode: ModeRaw, DryRun: true, BlockAggWrites: AggWritesSkip}); err == nil || !strings.Contains(err.Error(), "command mode") {
+		t.Errorf("New error = %v, want command mode required", err)
+	}
+}
@@ -0,0 +1,145 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// unsupportedReadConcerns are the read concern levels each topology rejects
+var unsupportedReadConcerns = map[sender.Topology]map[string]bool{
+	sender.TopologyStandalone: {"snapshot": true, "linearizable": true},
+}
+
+// readConcernFallbacks are the levels tried, in order, in place of an unsupported level
+var readConcernFallbacks = map[string][]string{
+	"snapshot":     {"majority", "local"},
+	"linearizable": {"majority", "local"},
+	"majority":     {"local"},
+}
+
+// ConcernAdapter downgrades recorded read and write concerns that the target's topology
+// rejects, so the ops run with the closest supported concern instead of failing
+// On a standalone: snapshot and linearizable reads become majority reads, cluster-time
+// options (atClusterTime, afterClusterTime) are dropped, and writes waiting for more
+// than one node (w > 1 or a tag set) wait for w: 1. Replica sets and sharded clusters
+// support every concern, so nothing is changed there.
+type ConcernAdapter struct {
+	topology sender.Topology
+	warnings io.Writer
+
+	// downgrades counts each distinct downgrade, e.g. "readConcern snapshot -> majority"
+	downgrades map[string]int
+}
+
+// NewConcernAdapter creates an adapter for a target topology
+// The first time each distinct downgrade is made, a warning is written to warnings (nil = none).
+func NewConcernAdapter(topology sender.Topology, warnings io.Writer) *ConcernAdapter {
+	if warnings == nil {
+		warnings = io.Discard
+	}
+	return &ConcernAdapter{topology: topology, warnings: warnings, downgrades: make(map[string]int)}
+}
+
+// Transform returns a transform that adapts each command's concerns
+func (a *ConcernAdapter) Transform() TransformFunc {
+	return func(cmd *sender.Command) error {
+		a.adaptReadConcern(cmd)
+		a.adaptWriteConcern(cmd)
+		return nil
+	}
+}
+
+// Downgrades returns how many times each distinct downgrade was made
+func (a *ConcernAdapter) Downgrades() map[string]int {
+	return a.downgrades
+}
+
+// adaptReadConcern replaces an unsupported readConcern level with its first supported fallback
+func (a *ConcernAdapter) adaptReadConcern(cmd *sender.Command) {
+	rc, ok := asDocument(cmd.Document["readConcern"])
+	if !ok || a.topology != sender.TopologyStandalone {
+		return
+	}
+
+	adapted := make(bson.M, len(rc))
+	for k, v := range rc {
+		adapted[k] = v
+	}
+	changed := false
+
+	// Cluster times come from a replica set's oplog, which a standalone doesn't have
+	for _, field := range []string{"atClusterTime", "afterClusterTime"} {
+		if _, ok := adapted[field]; ok {
+			delete(adapted, field)
+			a.note(cmd, "readConcern "+field+" -> removed")
+			changed = true
+		}
+	}
+
+	level, _ := adapted["level"].(string)
+	if unsupportedReadConcerns[a.topology][level] {
+		for _, fallback := range readConcernFallbacks[level] {
+			if !unsupportedReadConcerns[a.topology][fallback] {
+				adapted["level"] = fallback
+				a.note(cmd, fmt.Sprintf("readConcern %s -> %s", level, fallback))
+				changed = true
+				break
+			}
+		}
+	}
+
+	if changed {
+		cmd.Document["readConcern"] = adapted
+	}
+}
+
+// adaptWriteConcern lowers a writeConcern that waits for more nodes than the target has
+func (a *ConcernAdapter) adaptWriteConcern(cmd *sender.Command) {
+	wc, ok := asDocument(cmd.Document["writeConcern"])
+	if !ok || a.topology != sender.TopologyStandalone {
+		return
+	}
+
+	var from string
+	switch w := wc["w"].(type) {
+	case int32:
+		if w > 1 {
+			from = fmt.Sprint(w)
+		}
+	case int64:
+		if w > 1 {
+			from = fmt.Sprint(w)
+		}
+	case float64:
+		if w > 1 {
+			from = fmt.Sprint(w)
+		}
+	case string:
+		// "majority" is accepted by a standalone; any other string names a tag set
+		if w != "majority" {
+			from = fmt.Sprintf("%q", w)
+		}
+	}
+	if from == "" {
+		return
+	}
+
+	adapted := make(bson.M, len(wc))
+	for k, v := range wc {
+		adapted[k] = v
+	}
+	adapted["w"] = int32(1)
+	cmd.Document["writeConcern"] = adapted
+	a.note(cmd, fmt.Sprintf("writeConcern w: %s -> 1", from))
+}
+
+// note counts a downgrade, warning the first time it is made
+func (a *ConcernAdapter) note(cmd *sender.Command, downgrade string) {
+	a.downgrades[downgrade]++
+	if a.downgrades[downgrade] == 1 {
+		fmt.Fprintf(a.warnings, "⚠️  WARNING: %s target - %s (first seen on %s.%s)\n", a.topology, downgrade, cmd.Database, cmd.Name)
+	}
+}
@@ -0,0 +1,89 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConcernAdapter(t *testing.T) {
+	find := func(readConcern bson.D) *sender.Command {
+		return &sender.Command{Database: "app", Name: "find", Document: bson.M{"find": "users", "readConcern": readConcern}}
+	}
+	insert := func(w interface{}) *sender.Command {
+		return &sender.Command{Database: "app", Name: "insert", Document: bson.M{"insert": "users", "writeConcern": bson.D{{Key: "w", Value: w}, {Key: "wtimeout", Value: int32(500)}}}}
+	}
+
+	tests := []struct {
+		name     string
+		topology sender.Topology
+		cmd      *sender.Command
+		field    string
+		want     bson.M // adapted concern (nil = unchanged)
+	}{
+		{"snapshot on standalone", sender.TopologyStandalone, find(bson.D{{Key: "level", Value: "snapshot"}}),
+			"readConcern", bson.M{"level": "majority"}},
+		{"snapshot at a cluster time", sender.TopologyStandalone, find(bson.D{{Key: "level", Value: "snapshot"}, {Key: "atClusterTime", Value: bson.Timestamp{T: 1, I: 1}}}),
+			"readConcern", bson.M{"level": "majority"}},
+		{"linearizable on standalone", sender.TopologyStandalone, find(bson.D{{Key: "level", Value: "linearizable"}}),
+			"readConcern", bson.M{"level": "majority"}},
+		{"afterClusterTime on standalone", sender.TopologyStandalone, find(bson.D{{Key: "level", Value: "local"}, {Key: "afterClusterTime", Value: bson.Timestamp{T: 1, I: 1}}}),
+			"readConcern", bson.M{"level": "local"}},
+		{"majority on standalone", sender.TopologyStandalone, find(bson.D{{Key: "level", Value: "majority"}}), "readConcern", nil},
+		{"snapshot on replica set", sender.TopologyReplicaSet, find(bson.D{{Key: "level", Value: "snapshot"}}), "readConcern", nil},
+		{"w: 3 on standalone", sender.TopologyStandalone, insert(int32(3)),
+			"writeConcern", bson.M{"w": int32(1), "wtimeout": int32(500)}},
+		{"tag set on standalone", sender.TopologyStandalone, insert("dc-east"),
+			"writeConcern", bson.M{"w": int32(1), "wtimeout": int32(500)}},
+		{"w: majority on standalone", sender.TopologyStandalone, insert("majority"), "writeConcern", nil},
+		{"w: 3 on replica set", sender.TopologyReplicaSet, insert(int32(3)), "writeConcern", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.cmd.Document[tt.field]
+			var warnings strings.Builder
+			adapter := NewConcernAdapter(tt.topology, &warnings)
+			if err := adapter.Transform()(tt.cmd); err != nil {
+				t.Fatalf("Transform failed: %v", err)
+			}
+
+			if tt.want == nil {
+				if _, isD := tt.cmd.Document[tt.field].(bson.D); !isD || len(adapter.Downgrades()) > 0 || warnings.Len() > 0 {
+					t.Errorf("%s changed from %v to %v (downgrades %v)", tt.field, before, tt.cmd.Document[tt.field], adapter.Downgrades())
+				}
+				return
+			}
+			got, _ := tt.cmd.Document[tt.field].(bson.M)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%s = %v, want %v", tt.field, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s.%s = %v, want %v", tt.field, k, got[k], v)
+				}
+			}
+			if !strings.Contains(warnings.String(), "WARNING: "+string(tt.topology)) {
+				t.Errorf("expected a warning, got %q", warnings.String())
+			}
+		})
+	}
+}
+
+func TestConcernAdapter_WarnsOnce(t *testing.T) {
+	var warnings strings.Builder
+	adapter := NewConcernAdapter(sender.TopologyStandalone, &warnings)
+	transform := adapter.Transform()
+	for i := 0; i < 3; i++ {
+		transform(&sender.Command{Database: "app", Name: "find", Document: bson.M{"find": "users", "readConcern": bson.M{"level": "snapshot"}}})
+	}
+
+	if n := strings.Count(warnings.String(), "WARNING"); n != 1 {
+		t.Errorf("got %d warnings, want 1:\n%s", n, warnings.String())
+	}
+	if got := adapter.Downgrades()["readConcern snapshot -> majority"]; got != 3 {
+		t.Errorf("Downgrades = %v, want 3 snapshot -> majority", adapter.Downgrades())
+	}
+}
@@ -0,0 +1,197 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// openCursor is a live cursor paired with a recorded one, with what killing it needs
+type openCursor struct {
+	id         int64
+	database   string
+	collection string
+
+	// session is the recorded session that opened it, and sender the one it was opened on
+	session uint64
+	sender  CommandSender
+}
+
+// rewriteCursorIDs replaces recorded cursor ids in a getMore or killCursors with the
+// live ids the target returned, and returns the recorded ids the command referred to
+// Ids without a live cursor are left unchanged and counted as unmapped.
+func (r *Replayer) rewriteCursorIDs(stats *Stats, cmd *sender.Command) []int64 {
+	switch cmd.Name {
+	case "getMore":
+		// { getMore: <id>, collection: "c" }
+		recorded, ok := cmd.Document["getMore"].(int64)
+		if !ok {
+			return nil
+		}
+		if live, ok := r.liveCursor(stats, recorded); ok {
+			cmd.Document["getMore"] = live
+		}
+		return []int64{recorded}
+
+	case "killCursors":
+		// { killCursors: "c", cursors: [<id>...] }
+		ids, ok := cmd.Document["cursors"].(bson.A)
+		if !ok {
+			return nil
+		}
+		var recordedIDs []int64
+		rewritten := make(bson.A, len(ids))
+		for i, value := range ids {
+			rewritten[i] = value
+			recorded, ok := value.(int64)
+			if !ok {
+				continue
+			}
+			recordedIDs = append(recordedIDs, recorded)
+			if live, ok := r.liveCursor(stats, recorded); ok {
+				rewritten[i] = live
+			}
+		}
+		cmd.Document["cursors"] = rewritten
+		return recordedIDs
+	}
+	return nil
+}
+
+// liveCursor returns the live id paired with a recorded cursor id and counts the lookup
+func (r *Replayer) liveCursor(stats *Stats, recorded int64) (int64, bool) {
+	cursor, ok := r.cursors[recorded]
+	if ok {
+		stats.CursorsRewritten++
+	} else {
+		stats.CursorsUnmapped++
+	}
+	return cursor.id, ok
+}
+
+// noteCursor updates the cursor map from a successful command's live response
+// Cursor-opening commands pair their recorded cursor (from CursorResponses) with the
+// live one; getMores that exhaust a cursor and killCursors drop the pairing.
+func (r *Replayer) noteCursor(stats *Stats, snd CommandSender, cmd *sender.Command, recordedIDs []int64, response bson.M) {
+	switch cmd.Name {
+	case "getMore":
+		if len(recordedIDs) == 1 && liveCursorID(response) == 0 {
+			delete(r.cursors, recordedIDs[0])
+		}
+		return
+	case "killCursors":
+		for _, id := range recordedIDs {
+			delete(r.cursors, id)
+		}
+		return
+	}
+
+	if cmd.OriginalPacket == nil {
+		return
+	}
+	recorded, ok := r.config.CursorResponses.Lookup(cmd.OriginalPacket)
+	if !ok || recorded.CursorID == 0 {
+		return
+	}
+
+	// A live cursor exhausted in the first batch has nothing to continue; later
+	// getMores for it are counted as unmapped
+	if live := liveCursorID(response); live != 0 {
+		database, collection := cursorNamespace(cmd, response)
+		r.cursors[recorded.CursorID] = openCursor{
+			id:         live,
+			database:   database,
+			collection: collection,
+			session:    cmd.OriginalPacket.SessionID,
+			sender:     snd,
+		}
+		stats.CursorsMapped++
+	}
+}
+
+// cursorNamespace returns the database and collection to kill a live cursor on: the
+// response's cursor.ns ("db.collection"), else the opening command's own namespace
+func cursorNamespace(cmd *sender.Command, response bson.M) (string, string) {
+	if cursor, ok := asDocument(response["cursor"]); ok {
+		if ns, ok := cursor["ns"].(string); ok {
+			if database, collection, ok := strings.Cut(ns, "."); ok {
+				return database, collection
+			}
+		}
+	}
+	collection, _ := cmd.Document[cmd.Name].(string)
+	return cmd.Database, collection
+}
+
+// killOpenCursors kills the live cursors still open at the end of the replay, with one
+// killCursors per sender, session and namespace, so they don't linger on the target
+func (r *Replayer) killOpenCursors(stats *Stats) {
+	type killKey struct {
+		sender     CommandSender
+		session    uint64
+		database   string
+		collection string
+	}
+	recordedIDs := make([]int64, 0, len(r.cursors))
+	for id := range r.cursors {
+		recordedIDs = append(recordedIDs, id)
+	}
+	sort.Slice(recordedIDs, func(i, j int) bool { return recordedIDs[i] < recordedIDs[j] })
+
+	var keys []killKey
+	batches := make(map[killKey]bson.A)
+	for _, recorded := range recordedIDs {
+		cursor := r.cursors[recorded]
+		key := killKey{cursor.sender, cursor.session, cursor.database, cursor.collection}
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], cursor.id)
+		delete(r.cursors, recorded)
+	}
+	stats.CursorsLeaked += len(recordedIDs)
+
+	for _, key := range keys {
+		ids := batches[key]
+		cmd := &sender.Command{
+			Database:       key.database,
+			Name:           "killCursors",
+			Document:       bson.M{"killCursors": key.collection, "cursors": ids},
+			OriginalPacket: &reader.Packet{SessionID: key.session}, // for send's logical session
+		}
+		result, err := r.send(key.sender, cmd)
+		if err == nil && !result.IsOK() {
+			err = fmt.Errorf("ok=0")
+		}
+		if err != nil {
+			r.logFailure("⚠️  WARNING: failed to kill %d cursors left open on %s.%s - %v\n", len(ids), key.database, key.collection, err)
+			continue
+		}
+		stats.CursorsKilled += len(ids)
+	}
+}
+
+// send sends a command, in the recorded session's own logical session when rewriting
+// cursors and the sender supports it
+func (r *Replayer) send(snd CommandSender, cmd *sender.Command) (*sender.Result, error) {
+	if r.config.CursorResponses != nil && cmd.OriginalPacket != nil {
+		if ss, ok := snd.(SessionCommandSender); ok {
+			return ss.SendCommandInSession(cmd.OriginalPacket.SessionID, cmd.Database, cmd.Document)
+		}
+	}
+	return snd.SendCommand(cmd.Database, cmd.Document)
+}
+
+// liveCursorID returns cursor.id from a response (0 if absent)
+func liveCursorID(response bson.M) int64 {
+	cursor, ok := asDocument(response["cursor"])
+	if !ok {
+		return 0
+	}
+	id, _ := cursor["id"].(int64)
+	return id
+}
@@ -0,0 +1,184 @@
+package replay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// cursorSender opens a live cursor for find and exhausts it on getMore (unless keepOpen)
+type cursorSender struct {
+	liveID   int64
+	keepOpen bool
+
+	// databases are the databases commands were sent to
+	databases []string
+
+	// sessions are the session keys commands were sent with
+	sessions []uint64
+
+	// commands are the commands sent, in order
+	commands []bson.M
+}
+
+func (s *cursorSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	s.commands = append(s.commands, command)
+	s.databases = append(s.databases, database)
+
+	response := bson.M{"ok": 1.0}
+	if _, ok := command["find"]; ok {
+		response["cursor"] = bson.D{{Key: "id", Value: s.liveID}, {Key: "firstBatch", Value: bson.A{}}}
+	}
+	if _, ok := command["getMore"]; ok {
+		id := int64(0)
+		if s.keepOpen {
+			id = s.liveID
+		}
+		response["cursor"] = bson.D{{Key: "id", Value: id}, {Key: "nextBatch", Value: bson.A{}}}
+	}
+	return &sender.Result{Success: true, Response: response}, nil
+}
+
+func (s *cursorSender) SendCommandInSession(key uint64, database string, command bson.M) (*sender.Result, error) {
+	s.sessions = append(s.sessions, key)
+	return s.SendCommand(database, command)
+}
+
+func TestRun_RewriteCursorIDs(t *testing.T) {
+	snd := &cursorSender{liveID: 555}
+	index := ResponseIndex{
+		{SessionID: 3, RequestID: 1}: {OK: true, DocCount: 0, CursorID: 111},
+	}
+
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, CursorResponses: index})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	packet := func(requestID int32, doc bson.D) *reader.Packet {
+		return &reader.Packet{SessionID: 3, Message: buildOpMsg(t, requestID, 0, doc)}
+	}
+	src := &sliceSource{packets: []*reader.Packet{
+		packet(1, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+		packet(2, bson.D{{Key: "getMore", Value: int64(111)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}),
+		// The live cursor was exhausted by the previous getMore
+		packet(3, bson.D{{Key: "getMore", Value: int64(111)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}),
+		packet(4, bson.D{{Key: "killCursors", Value: "users"}, {Key: "cursors", Value: bson.A{int64(999)}}, {Key: "$db", Value: "app"}}),
+	}}
+
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(snd.commands) != 4 {
+		t.Fatalf("sent %d commands, want 4", len(snd.commands))
+	}
+	if got := snd.commands[1]["getMore"]; got != int64(555) {
+		t.Errorf("first getMore id = %v, want the live id 555", got)
+	}
+	if got := snd.commands[2]["getMore"]; got != int64(111) {
+		t.Errorf("getMore after exhaustion id = %v, want the recorded id 111", got)
+	}
+	if got := snd.commands[3]["cursors"].(bson.A)[0]; got != int64(999) {
+		t.Errorf("killCursors id = %v, want the unmapped recorded id 999", got)
+	}
+
+	if stats.CursorsMapped != 1 || stats.CursorsRewritten != 1 || stats.CursorsUnmapped != 2 {
+		t.Errorf("unexpected cursor stats: mapped=%d rewritten=%d unmapped=%d",
+			stats.CursorsMapped, stats.CursorsRewritten, stats.CursorsUnmapped)
+	}
+
+	for i, key := range snd.sessions {
+		if key != 3 {
+			t.Errorf("command %d sent in session %d, want the recorded session 3", i, key)
+		}
+	}
+	if len(snd.sessions) != 4 {
+		t.Errorf("%d commands sent in a session, want 4", len(snd.sessions))
+	}
+}
+
+func TestRun_CursorLifecycle(t *testing.T) {
+	index := ResponseIndex{
+		{SessionID: 3, RequestID: 1}: {OK: true, CursorID: 111},
+	}
+	packet := func(requestID int32, doc bson.D) *reader.Packet {
+		return &reader.Packet{SessionID: 3, Message: buildOpMsg(t, requestID, 0, doc)}
+	}
+	find := packet(1, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})
+	getMore := packet(2, bson.D{{Key: "getMore", Value: int64(111)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}})
+	killCursors := packet(3, bson.D{{Key: "killCursors", Value: "users"}, {Key: "cursors", Value: bson.A{int64(111)}}, {Key: "$db", Value: "app"}})
+
+	t.Run("killCursors translated", func(t *testing.T) {
+		snd := &cursorSender{liveID: 555, keepOpen: true}
+		r, err := New(Config{Mode: ModeCommand, CommandSender: snd, CursorResponses: index})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{find, getMore, killCursors}})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(snd.commands) != 3 {
+			t.Fatalf("sent %d commands, want 3 (no cleanup after the recorded killCursors)", len(snd.commands))
+		}
+		if got := snd.commands[2]["cursors"].(bson.A)[0]; got != int64(555) {
+			t.Errorf("killCursors id = %v, want the live id 555", got)
+		}
+		if stats.CursorsRewritten != 2 || stats.CursorsLeaked != 0 {
+			t.Errorf("rewritten=%d leaked=%d, want 2 and 0", stats.CursorsRewritten, stats.CursorsLeaked)
+		}
+	})
+
+	t.Run("open cursor killed at end", func(t *testing.T) {
+		snd := &cursorSender{liveID: 555, keepOpen: true}
+		r, err := New(Config{Mode: ModeCommand, CommandSender: snd, CursorResponses: index})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{find, getMore}})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(snd.commands) != 3 {
+			t.Fatalf("sent %d commands, want 3 (find, getMore, cleanup killCursors)", len(snd.commands))
+		}
+		cleanup := snd.commands[2]
+		if cleanup["killCursors"] != "users" || snd.databases[2] != "app" || cleanup["cursors"].(bson.A)[0] != int64(555) {
+			t.Errorf("cleanup = %s %v, want killCursors of live cursor 555 on app.users", snd.databases[2], cleanup)
+		}
+		if snd.sessions[2] != 3 {
+			t.Errorf("cleanup sent in session %d, want the opening session 3", snd.sessions[2])
+		}
+		if stats.CursorsLeaked != 1 || stats.CursorsKilled != 1 {
+			t.Errorf("leaked=%d killed=%d, want 1 and 1", stats.CursorsLeaked, stats.CursorsKilled)
+		}
+	})
+
+	t.Run("exhausted cursor not killed", func(t *testing.T) {
+		snd := &cursorSender{liveID: 555}
+		r, err := New(Config{Mode: ModeCommand, CommandSender: snd, CursorResponses: index})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{find, getMore}})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(snd.commands) != 2 || stats.CursorsLeaked != 0 {
+			t.Errorf("sent %d commands, leaked=%d; want 2 and 0", len(snd.commands), stats.CursorsLeaked)
+		}
+	})
+}
+
+func TestNew_CursorResponsesRequiresCommandMode(t *testing.T) {
+	if _, err := New(Config{Mode: ModeRaw, DryRun: true, CursorResponses: ResponseIndex{}}); err == nil {
+		t.Error("expected error for cursor-id rewriting in raw mode")
+	}
+}
@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DuplicatePolicy decides what happens to inserted documents that hit a duplicate key
+type DuplicatePolicy string
+
+const (
+	// DuplicateFail leaves duplicates as write errors, failing the batch
+	DuplicateFail DuplicatePolicy = "fail"
+
+	// DuplicateSkip drops duplicates, as if the document had been inserted
+	DuplicateSkip DuplicatePolicy = "skip"
+
+	// DuplicateUpsert re-sends duplicates as replacements keyed on _id with upsert: true
+	DuplicateUpsert DuplicatePolicy = "upsert"
+)
+
+// handlesDuplicates reports whether cmd's duplicate-key write errors are resolved by OnDuplicate
+func (r *Replayer) handlesDuplicates(cmd *sender.Command) bool {
+	policy := r.config.OnDuplicate
+	return cmd.Name == "insert" && (policy == DuplicateSkip || policy == DuplicateUpsert)
+}
+
+// resolveDuplicates applies OnDuplicate to the duplicate-key errors of an insert and
+// returns the write errors left, with indexes into the original batch
+// An ordered insert stops at its first error, so after a resolved duplicate the rest of
+// the batch is re-sent, and the same is done for its duplicates in turn.
+func (r *Replayer) resolveDuplicates(stats *Stats, snd CommandSender, cmd *sender.Command, writeErrors []sender.WriteError) ([]sender.WriteError, error) {
+	documents, _ := cmd.Document["documents"].(bson.A)
+	ordered := orderedBatch(cmd.Document)
+
+	var remaining []sender.WriteError
+	base := 0
+	for {
+		var duplicates []int
+		for _, e := range writeErrors {
+			e.Index += base
+			if e.IsDuplicateKey() {
+				duplicates = append(duplicates, e.Index)
+			} else {
+				remaining = append(remaining, e)
+			}
+		}
+		if len(duplicates) == 0 {
+			return remaining, nil
+		}
+
+		failed, err := r.resolve(stats, snd, cmd, duplicates)
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, failed...)
+
+		// An unordered batch tried every statement; an ordered one stopped at the duplicate
+		next := duplicates[len(duplicates)-1] + 1
+		if !ordered || len(remaining) > 0 || next >= len(documents) {
+			return remaining, nil
+		}
+
+		rest := insertTail(cmd, next)
+		result, err := r.send(snd, rest)
+		if err != nil {
+			return nil, fmt.Errorf("re-sending statements %d-%d after a duplicate: %w", next, len(documents)-1, err)
+		}
+		if !result.IsOK() {
+			return nil, fmt.Errorf("re-sending statements %d-%d after a duplicate: ok=0", next, len(documents)-1)
+		}
+		writeErrors = result.WriteErrors()
+		base = next
+	}
+}
+
+// resolve skips or upserts the documents of an insert at the given indexes
+// Returns the write errors of the upserts, with indexes into the insert's batch.
+func (r *Replayer) resolve(stats *Stats, snd CommandSender, cmd *sender.Command, duplicates []int) ([]sender.WriteError, error) {
+	if r.config.OnDuplicate == DuplicateSkip {
+		r.logOp("↷ DUPLICATE SKIPPED: %s.%s - %d document(s) %v\n", cmd.Database, cmd.Name, len(duplicates), duplicates)
+		stats.DuplicatesSkipped += len(duplicates)
+		return nil, nil
+	}
+
+	upsert, err := sender.UpsertFromInsert(cmd, duplicates)
+	if err != nil {
+		return nil, fmt.Errorf("cannot upsert duplicates: %w", err)
+	}
+	result, err := r.send(snd, upsert)
+	if err != nil {
+		return nil, fmt.Errorf("upserting duplicates: %w", err)
+	}
+	if !result.IsOK() {
+		return nil, fmt.Errorf("upserting duplicates: ok=0")
+	}
+
+	var failed []sender.WriteError
+	for _, e := range result.WriteErrors() {
+		if e.Index >= 0 && e.Index < len(duplicates) {
+			e.Index = duplicates[e.Index]
+		}
+		failed = append(failed, e)
+	}
+	r.logOp("↻ DUPLICATE UPSERTED: %s.%s - %d document(s) %v\n", cmd.Database, cmd.Name, len(duplicates)-len(failed), duplicates)
+	stats.DuplicatesUpserted += len(duplicates) - len(failed)
+	return failed, nil
+}
+
+// insertTail returns a copy of an insert command with only its documents from index from on
+func insertTail(cmd *sender.Command, from int) *sender.Command {
+	documents := cmd.Document["documents"].(bson.A)
+	doc := make(bson.M, len(cmd.Document))
+	for k, v := range cmd.Document {
+		doc[k] = v
+	}
+	doc["documents"] = documents[from:]
+
+	tail := *cmd
+	tail.Document = doc
+	return &tail
+}
+
+// orderedBatch reports whether a write command is ordered (the default)
+func orderedBatch(doc bson.M) bool {
+	ordered, ok := doc["ordered"].(bool)
+	return !ok || ordered
+}
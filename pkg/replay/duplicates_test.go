@@ -0,0 +1,166 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// collectionSender simulates one collection keyed on _id: inserts of existing _ids
+// return duplicate-key write errors, and upserting updates replace documents
+type collectionSender struct {
+	docs map[interface{}]bson.M
+	sent []string
+}
+
+func (s *collectionSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	ordered, ok := command["ordered"].(bool)
+	ordered = !ok || ordered
+
+	var writeErrors bson.A
+	switch {
+	case command["insert"] != nil:
+		documents := command["documents"].(bson.A)
+		s.sent = append(s.sent, fmt.Sprintf("insert %d", len(documents)))
+		for i, d := range documents {
+			doc, _ := asDocument(d)
+			if _, exists := s.docs[doc["_id"]]; exists {
+				writeErrors = append(writeErrors, bson.M{"index": int32(i), "code": int32(sender.DuplicateKeyCode), "errmsg": "E11000 duplicate key"})
+				if ordered {
+					break
+				}
+				continue
+			}
+			s.docs[doc["_id"]] = doc
+		}
+
+	case command["update"] != nil:
+		updates := command["updates"].(bson.A)
+		s.sent = append(s.sent, fmt.Sprintf("update %d", len(updates)))
+		for _, u := range updates {
+			statement := u.(bson.M)
+			if statement["upsert"] != true {
+				return nil, fmt.Errorf("update without upsert")
+			}
+			doc, _ := asDocument(statement["u"])
+			s.docs[statement["q"].(bson.M)["_id"]] = doc
+		}
+	}
+
+	response := bson.M{"ok": 1.0}
+	if len(writeErrors) > 0 {
+		response["writeErrors"] = writeErrors
+	}
+	return &sender.Result{Success: true, Response: response}, nil
+}
+
+func TestRun_OnDuplicate(t *testing.T) {
+	insert := func(ordered bool, ids ...int32) bson.D {
+		docs := bson.A{}
+		for _, id := range ids {
+			docs = append(docs, bson.D{{Key: "_id", Value: id}, {Key: "v", Value: "new"}})
+		}
+		return bson.D{{Key: "insert", Value: "users"}, {Key: "documents", Value: docs}, {Key: "ordered", Value: ordered}, {Key: "$db", Value: "app"}}
+	}
+
+	tests := []struct {
+		name     string
+		policy   DuplicatePolicy
+		doc      bson.D
+		sent     []string
+		ok       bool
+		skipped  int
+		upserted int
+		values   map[int32]string // v of each _id afterwards
+	}{
+		{
+			name:   "fail",
+			policy: DuplicateFail,
+			doc:    insert(true, 1, 2, 3),
+			sent:   []string{"insert 3"},
+			values: map[int32]string{1: "new", 2: "old"},
+		},
+		{
+			name:    "skip ordered re-sends the rest",
+			policy:  DuplicateSkip,
+			doc:     insert(true, 1, 2, 3),
+			sent:    []string{"insert 3", "insert 1"},
+			ok:      true,
+			skipped: 1,
+			values:  map[int32]string{1: "new", 2: "old", 3: "new"},
+		},
+		{
+			name:    "skip unordered",
+			policy:  DuplicateSkip,
+			doc:     insert(false, 1, 2, 3),
+			sent:    []string{"insert 3"},
+			ok:      true,
+			skipped: 1,
+			values:  map[int32]string{1: "new", 2: "old", 3: "new"},
+		},
+		{
+			name:     "upsert ordered",
+			policy:   DuplicateUpsert,
+			doc:      insert(true, 1, 2, 3),
+			sent:     []string{"insert 3", "update 1", "insert 1"},
+			ok:       true,
+			upserted: 1,
+			values:   map[int32]string{1: "new", 2: "new", 3: "new"},
+		},
+		{
+			name:     "upsert unordered",
+			policy:   DuplicateUpsert,
+			doc:      insert(false, 2, 1),
+			sent:     []string{"insert 2", "update 1"},
+			ok:       true,
+			upserted: 1,
+			values:   map[int32]string{1: "new", 2: "new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snd := &collectionSender{docs: map[interface{}]bson.M{
+				int32(2): {"_id": int32(2), "v": "old"},
+			}}
+			r, err := New(Config{Mode: ModeCommand, CommandSender: snd, OnDuplicate: tt.policy})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{buildCommandPacket(t, 1, 0, tt.doc)}})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if got := strings.Join(snd.sent, ", "); got != strings.Join(tt.sent, ", ") {
+				t.Errorf("sent %s, want %s", got, strings.Join(tt.sent, ", "))
+			}
+			if ok := stats.SuccessfulOps == 1 && stats.FailedOps == 0; ok != tt.ok {
+				t.Errorf("successful/failed = %d/%d, want ok=%v", stats.SuccessfulOps, stats.FailedOps, tt.ok)
+			}
+			if stats.DuplicatesSkipped != tt.skipped || stats.DuplicatesUpserted != tt.upserted {
+				t.Errorf("skipped/upserted = %d/%d, want %d/%d", stats.DuplicatesSkipped, stats.DuplicatesUpserted, tt.skipped, tt.upserted)
+			}
+			for id, want := range tt.values {
+				if got := snd.docs[id]["v"]; got != want {
+					t.Errorf("_id %d has v=%v, want %s", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_OnDuplicateValidation(t *testing.T) {
+	if _, err := New(Config{Mode: ModeCommand, DryRun: true, OnDuplicate: "replace"}); err == nil || !strings.Contains(err.Error(), "duplicate policy") {
+		t.Errorf("New error = %v, want invalid duplicate policy", err)
+	}
+	if _, err := New(Config{Mode: ModeRaw, DryRun: true, OnDuplicate: DuplicateSkip}); err == nil || !strings.Contains(err.Error(), "command mode") {
+		t.Errorf("New error = %v, want command mode required", err)
+	}
+}
@@ -0,0 +1,39 @@
+package replay
+
+import (
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// maxEncryptedPaths is how many encrypted field paths a warning line lists
+const maxEncryptedPaths = 3
+
+// warnEncrypted flags an op whose command holds Client-Side Field Level Encryption
+// ciphertext, which a target without the source's key vault can't decrypt or query by
+// In raw mode the command is extracted from the packet for the check; responses and
+// packets that can't be parsed are not flagged. The op is still sent.
+func (r *Replayer) warnEncrypted(stats *Stats, packet *reader.Packet, cmd *sender.Command) {
+	if cmd == nil {
+		if !packet.IsRequest() {
+			return
+		}
+		var err error
+		if cmd, err = sender.ExtractCommand(packet); err != nil {
+			return
+		}
+	}
+	paths := sender.EncryptedFields(cmd.Document)
+	if len(paths) == 0 {
+		return
+	}
+	stats.EncryptedOps++
+
+	listed := strings.Join(paths, ", ")
+	if len(paths) > maxEncryptedPaths {
+		listed = strings.Join(paths[:maxEncryptedPaths], ", ") + ", ..."
+	}
+	r.logFailure("⚠️  ENCRYPTED: %s.%s - %d encrypted field(s) (%s); results depend on the target having the source's key vault\n",
+		cmd.Database, cmd.Name, len(paths), listed)
+}
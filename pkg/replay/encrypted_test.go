@@ -0,0 +1,47 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRun_WarnEncrypted(t *testing.T) {
+	ciphertext := bson.Binary{Subtype: sender.BinarySubtypeEncrypted, Data: []byte{0x01, 0x02}}
+	packets := func() PacketSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "patients"}, {Key: "filter", Value: bson.D{{Key: "ssn", Value: ciphertext}}}, {Key: "$db", Value: "clinic"}}),
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "patients"}, {Key: "filter", Value: bson.D{{Key: "name", Value: "x"}}}, {Key: "$db", Value: "clinic"}}),
+		}}
+	}
+
+	for _, mode := range []Mode{ModeCommand, ModeRaw} {
+		for _, warn := range []bool{false, true} {
+			var out bytes.Buffer
+			r, err := New(Config{Mode: mode, DryRun: true, WarnEncrypted: warn, Output: &out})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			stats, err := r.Run(context.Background(), packets())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			want := 0
+			if warn {
+				want = 1
+			}
+			if stats.EncryptedOps != want || stats.SuccessfulOps != 2 {
+				t.Errorf("%s mode, warn=%v: encrypted/successful = %d/%d, want %d/2", mode, warn, stats.EncryptedOps, stats.SuccessfulOps, want)
+			}
+			if got := strings.Contains(out.String(), "ENCRYPTED: clinic.find - 1 encrypted field(s) (filter.ssn)"); got != warn {
+				t.Errorf("%s mode, warn=%v: warning printed = %v\n%s", mode, warn, got, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package replay
+
+import (
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// opCompressed is the OP_COMPRESSED wire opcode
+const opCompressed = 2012
+
+// canFallBack reports whether a failed raw send of packet may be retried as a command
+func (r *Replayer) canFallBack(packet *reader.Packet) bool {
+	return r.config.DecompressFallback && packet.GetOpCode() == opCompressed
+}
+
+// sendDecompressed replays an OP_COMPRESSED packet whose raw send failed as a command:
+// the packet is decompressed, its command extracted, and sent through the target's
+// CommandSender. rawErr is the raw send's error, reported if the fallback can't run.
+func (r *Replayer) sendDecompressed(stats *Stats, packet *reader.Packet, driftNote string, rawErr error) {
+	db, name := packet.ExtractDatabase(), packet.ExtractCommandName()
+
+	cmd, err := decompressedCommand(packet, sender.ExtractOptions{
+		PreserveRetryableWrites: r.config.PreserveRetryableWrites,
+		DefaultDatabase:         r.config.DefaultDatabase,
+	})
+	if err != nil {
+		r.notify(packet, nil, rawErr)
+		r.logFailure("❌ FAILED: %s.%s - %v (decompress fallback: %v)\n", db, name, rawErr, err)
+		r.failed(stats)
+		return
+	}
+
+	r.logFailure("↪ FALLBACK: %s.%s - raw OP_COMPRESSED send failed (%v); sending decompressed as a command\n", db, name, rawErr)
+	stats.DecompressFallbacks++
+	r.sendCommand(stats, cmd, driftNote)
+}
+
+// decompressedCommand extracts the command from an OP_COMPRESSED packet
+// The returned command's OriginalPacket is an uncompressed copy of packet (same session,
+// offset and requestID), so response lookups still pair it with the recorded response.
+func decompressedCommand(packet *reader.Packet, opts sender.ExtractOptions) (*sender.Command, error) {
+	message, err := packet.WireMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	plain := &reader.Packet{
+		Size:            packet.Size,
+		EventType:       packet.EventType,
+		SessionID:       packet.SessionID,
+		SessionMetadata: packet.SessionMetadata,
+		Offset:          packet.Offset,
+		Order:           packet.Order,
+		Message:         message,
+	}
+	return sender.ExtractCommandWithOptions(plain, opts)
+}
@@ -0,0 +1,109 @@
+package replay
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"github.com/golang/snappy"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// compressedRejectingSender is a RawSender that fails every OP_COMPRESSED message,
+// like a target that doesn't support the recorded compressor
+type compressedRejectingSender struct {
+	sent int
+}
+
+func (s *compressedRejectingSender) SendRawWireMessage(ctx context.Context, message []byte) (*sender.RawResult, error) {
+	if binary.LittleEndian.Uint32(message[12:16]) == opCompressed {
+		return nil, errors.New("connection closed by peer")
+	}
+	s.sent++
+	return &sender.RawResult{Success: true}, nil
+}
+
+// snappyCompress wraps an uncompressed wire message in a snappy OP_COMPRESSED message
+func snappyCompress(message []byte) []byte {
+	body := message[16:]
+	compressed := snappy.Encode(nil, body)
+
+	out := make([]byte, 0, 25+len(compressed))
+	out = binary.LittleEndian.AppendUint32(out, uint32(25+len(compressed)))
+	out = append(out, message[4:12]...) // requestID and responseTo
+	out = binary.LittleEndian.AppendUint32(out, opCompressed)
+	out = append(out, message[12:16]...) // original opcode
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, reader.CompressorSnappy)
+	return append(out, compressed...)
+}
+
+func TestRun_DecompressFallback(t *testing.T) {
+	insert := bson.D{
+		{Key: "insert", Value: "users"},
+		{Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "Alice"}}}},
+		{Key: "$db", Value: "app"},
+	}
+	packets := func() *sliceSource {
+		return &sliceSource{packets: []*reader.Packet{
+			{SessionID: 1, Message: snappyCompress(buildOpMsg(t, 1, 0, insert))},
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+		}}
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		raw, cmds := &compressedRejectingSender{}, &recordingCommandSender{}
+		var out strings.Builder
+		r, err := New(Config{Mode: ModeRaw, RawSender: raw, CommandSender: cmds, DecompressFallback: true, Output: &out})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), packets())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if stats.SuccessfulOps != 2 || stats.FailedOps != 0 || stats.DecompressFallbacks != 1 {
+			t.Errorf("stats = %d ok / %d failed / %d fallbacks, want 2 / 0 / 1", stats.SuccessfulOps, stats.FailedOps, stats.DecompressFallbacks)
+		}
+		if raw.sent != 1 {
+			t.Errorf("raw sender sent %d messages, want only the uncompressed find", raw.sent)
+		}
+		if len(cmds.commands) != 1 || cmds.databases[0] != "app" {
+			t.Fatalf("command sender got %v, want the decompressed insert on app", cmds.commands)
+		}
+		if docs, ok := cmds.commands[0]["documents"].(bson.A); cmds.commands[0]["insert"] != "users" || !ok || len(docs) != 1 {
+			t.Errorf("fallback command = %v, want insert of one document into users", cmds.commands[0])
+		}
+		if !strings.Contains(out.String(), "FALLBACK") {
+			t.Errorf("missing fallback line in output: %q", out.String())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		r, err := New(Config{Mode: ModeRaw, RawSender: &compressedRejectingSender{}})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), packets())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if stats.FailedOps != 1 || stats.DecompressFallbacks != 0 {
+			t.Errorf("stats = %d failed / %d fallbacks, want 1 / 0", stats.FailedOps, stats.DecompressFallbacks)
+		}
+	})
+}
+
+func TestNew_DecompressFallbackValidation(t *testing.T) {
+	if _, err := New(Config{Mode: ModeRaw, RawSender: &compressedRejectingSender{}, DecompressFallback: true}); err == nil {
+		t.Error("expected error for decompress fallback without a CommandSender")
+	}
+	if _, err := New(Config{Mode: ModeCommand, CommandSender: &recordingCommandSender{}, DecompressFallback: true}); err == nil {
+		t.Error("expected error for decompress fallback in command mode")
+	}
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// latencySubBuckets is the number of buckets per power of two, which bounds the
+// error of a percentile to 1/latencySubBuckets (about 6%)
+const latencySubBuckets = 16
+
+// latencyBuckets covers every microsecond value a uint64 holds
+const latencyBuckets = 64 * latencySubBuckets
+
+// LatencyHistogram counts op latencies in logarithmic buckets, for percentiles in
+// constant memory however many ops are recorded
+// Latencies are bucketed at microsecond resolution. The zero value is empty and ready
+// to use, and copying a histogram copies its counts.
+type LatencyHistogram struct {
+	counts [latencyBuckets]uint64
+	total  uint64
+	max    time.Duration
+}
+
+// Record counts one latency
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[latencyBucket(uint64(d/time.Microsecond))]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Merge adds the counts of other to h
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.total += other.total
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Count returns the number of latencies recorded
+func (h *LatencyHistogram) Count() int {
+	return int(h.total)
+}
+
+// Max returns the largest latency recorded
+func (h *LatencyHistogram) Max() time.Duration {
+	return h.max
+}
+
+// Percentile returns the latency at or below which p percent (0-100) of ops completed
+// The result is the upper bound of the bucket holding that op, capped at Max, so it
+// overstates the exact value by at most one bucket width. Returns 0 if nothing was recorded.
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > h.total {
+		rank = h.total
+	}
+
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			upper := time.Duration(latencyBucketUpper(i)) * time.Microsecond
+			if upper > h.max {
+				upper = h.max
+			}
+			return upper
+		}
+	}
+	return h.max
+}
+
+// latencyBucket returns the bucket for a latency in microseconds
+// Values below latencySubBuckets get a bucket each; above that, each power of two
+// is split into latencySubBuckets equal parts.
+func latencyBucket(us uint64) int {
+	if us < latencySubBuckets {
+		return int(us)
+	}
+	exp := bits.Len64(us) - 5 // us >> exp is in [16, 32)
+	return exp*latencySubBuckets + int(us>>uint(exp))
+}
+
+// latencyBucketUpper returns the largest microsecond value in a bucket
+func latencyBucketUpper(i int) uint64 {
+	if i < 2*latencySubBuckets {
+		return uint64(i)
+	}
+	exp := i/latencySubBuckets - 1
+	mantissa := uint64(i%latencySubBuckets + latencySubBuckets)
+	return (mantissa+1)<<uint(exp) - 1
+}
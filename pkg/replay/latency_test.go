@@ -0,0 +1,73 @@
+package replay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram_Percentile(t *testing.T) {
+	var h LatencyHistogram
+	if got := h.Percentile(50); got != 0 {
+		t.Errorf("empty Percentile(50) = %v, want 0", got)
+	}
+
+	// 1ms..100ms in 1ms steps
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	if h.Count() != 100 || h.Max() != 100*time.Millisecond {
+		t.Fatalf("Count/Max = %d/%v, want 100/100ms", h.Count(), h.Max())
+	}
+
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	} {
+		got := h.Percentile(tt.p)
+		// Results are bucket upper bounds: never below the exact value, within one bucket above
+		if got < tt.want || float64(got) > float64(tt.want)*(1+1.0/latencySubBuckets) {
+			t.Errorf("Percentile(%v) = %v, want %v (+%d%%)", tt.p, got, tt.want, 100/latencySubBuckets)
+		}
+	}
+}
+
+func TestLatencyHistogram_SmallValuesExact(t *testing.T) {
+	var h LatencyHistogram
+	for i := 0; i < 32; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	if got := h.Percentile(50); got != 15*time.Microsecond {
+		t.Errorf("Percentile(50) = %v, want 15µs", got)
+	}
+}
+
+func TestLatencyHistogram_Merge(t *testing.T) {
+	var a, b LatencyHistogram
+	a.Record(time.Millisecond)
+	b.Record(3 * time.Millisecond)
+	b.Record(5 * time.Millisecond)
+
+	a.Merge(&b)
+	if a.Count() != 3 || a.Max() != 5*time.Millisecond {
+		t.Errorf("merged Count/Max = %d/%v, want 3/5ms", a.Count(), a.Max())
+	}
+	if got := a.Percentile(100); got != 5*time.Millisecond {
+		t.Errorf("merged Percentile(100) = %v, want 5ms", got)
+	}
+}
+
+func TestLatencyBucket_Bounds(t *testing.T) {
+	// Every value falls in a bucket whose upper bound is at least the value and
+	// within 1/latencySubBuckets of it
+	for _, us := range []uint64{0, 1, 15, 16, 31, 32, 33, 1000, 123456, 1 << 40} {
+		upper := latencyBucketUpper(latencyBucket(us))
+		if upper < us || float64(upper-us) > float64(us)/latencySubBuckets {
+			t.Errorf("value %d: bucket upper bound %d", us, upper)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package replay
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReplayStats aggregates op outcomes by command and is safe for concurrent use
+// Unlike Stats, which the Replayer returns when a run ends, a ReplayStats can be
+// recorded into from several goroutines and read with Snapshot while a run is going.
+type ReplayStats struct {
+	mu       sync.Mutex
+	skips    int
+	commands map[string]*CommandStats
+}
+
+// CommandStats counts the outcomes of one command name
+type CommandStats struct {
+	// Successes is the number of ops of this command that succeeded
+	Successes int
+
+	// Failures is the number of ops of this command that failed
+	Failures int
+
+	// Latency is the total time taken by the successful ops
+	Latency time.Duration
+
+	// MaxLatency is the time taken by the slowest successful op
+	MaxLatency time.Duration
+
+	// Latencies is the distribution of the successful ops' latencies, for percentiles
+	Latencies LatencyHistogram
+
+	// LastError is the error of the most recent failure that reported one
+	LastError string
+}
+
+// StatsSnapshot is a point-in-time copy of a ReplayStats
+type StatsSnapshot struct {
+	// Successes and Failures are the op counts over all commands
+	Successes int
+	Failures  int
+
+	// Skips is the number of packets skipped without being sent
+	Skips int
+
+	// Latency is the total time taken by successful ops
+	Latency time.Duration
+
+	// Commands are the per-command counts, keyed by command name ("" if unknown)
+	Commands map[string]CommandStats
+}
+
+// NewReplayStats creates an empty ReplayStats
+func NewReplayStats() *ReplayStats {
+	return &ReplayStats{commands: make(map[string]*CommandStats)}
+}
+
+// RecordSuccess counts a successful op of the given command that took d
+func (s *ReplayStats) RecordSuccess(cmd string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.command(cmd)
+	c.Successes++
+	c.Latency += d
+	c.Latencies.Record(d)
+	if d > c.MaxLatency {
+		c.MaxLatency = d
+	}
+}
+
+// RecordFailure counts a failed op of the given command; err may be nil
+func (s *ReplayStats) RecordFailure(cmd string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.command(cmd)
+	c.Failures++
+	if err != nil {
+		c.LastError = err.Error()
+	}
+}
+
+// RecordSkip counts a packet skipped without being sent
+func (s *ReplayStats) RecordSkip() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skips++
+}
+
+// command returns the counts for cmd, creating them on first use; s.mu must be held
+func (s *ReplayStats) command(cmd string) *CommandStats {
+	c, ok := s.commands[cmd]
+	if !ok {
+		c = &CommandStats{}
+		s.commands[cmd] = c
+	}
+	return c
+}
+
+// Snapshot returns a copy of the current counts
+func (s *ReplayStats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := StatsSnapshot{Skips: s.skips, Commands: make(map[string]CommandStats, len(s.commands))}
+	for name, c := range s.commands {
+		snap.Commands[name] = *c
+		snap.Successes += c.Successes
+		snap.Failures += c.Failures
+		snap.Latency += c.Latency
+	}
+	return snap
+}
+
+// Ops returns the number of ops sent (successful plus failed)
+func (s StatsSnapshot) Ops() int {
+	return s.Successes + s.Failures
+}
+
+// Latencies returns the latency distribution of the successful ops of the named commands
+func (s StatsSnapshot) Latencies(names ...string) LatencyHistogram {
+	var h LatencyHistogram
+	for _, name := range names {
+		if c, ok := s.Commands[name]; ok {
+			h.Merge(&c.Latencies)
+		}
+	}
+	return h
+}
+
+// CommandNames returns the recorded command names, most ops first (ties by name)
+func (s StatsSnapshot) CommandNames() []string {
+	names := make([]string, 0, len(s.Commands))
+	for name := range s.Commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := s.Commands[names[i]], s.Commands[names[j]]
+		if a.Successes+a.Failures != b.Successes+b.Failures {
+			return a.Successes+a.Failures > b.Successes+b.Failures
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// skip counts a packet skipped without being sent
+func (r *Replayer) skip(stats *Stats) {
+	stats.SkippedPackets++
+	if r.config.LiveStats != nil {
+		r.config.LiveStats.RecordSkip()
+	}
+}
+
+// succeeded counts the current op as successful, in stats and in LiveStats if set
+func (r *Replayer) succeeded(stats *Stats) {
+	stats.SuccessfulOps++
+	if r.config.LiveStats != nil {
+		r.config.LiveStats.RecordSuccess(r.current.ExtractCommandName(), time.Since(r.opStart))
+	}
+}
+
+// failed counts the current op as failed, in stats and in LiveStats if set, with the
+// error already passed to notify (if any)
+func (r *Replayer) failed(stats *Stats) {
+	stats.FailedOps++
+	if r.config.LiveStats != nil {
+		r.config.LiveStats.RecordFailure(r.current.ExtractCommandName(), r.opErr)
+	}
+}
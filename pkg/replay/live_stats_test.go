@@ -0,0 +1,93 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestReplayStats_Concurrent(t *testing.T) {
+	const goroutines, perGoroutine = 32, 501
+	stats := NewReplayStats()
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			cmd := fmt.Sprintf("cmd%d", g%4)
+			for i := 0; i < perGoroutine; i++ {
+				switch i % 3 {
+				case 0:
+					stats.RecordSuccess(cmd, time.Millisecond)
+				case 1:
+					stats.RecordFailure(cmd, errors.New("boom"))
+				default:
+					stats.RecordSkip()
+				}
+				if i%50 == 0 {
+					stats.Snapshot() // readers race with writers too
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	snap := stats.Snapshot()
+	perKind := goroutines * perGoroutine / 3
+	if snap.Successes != perKind || snap.Failures != perKind || snap.Skips != perKind {
+		t.Errorf("successes/failures/skips = %d/%d/%d, want %d each", snap.Successes, snap.Failures, snap.Skips, perKind)
+	}
+	if snap.Latency != time.Duration(snap.Successes)*time.Millisecond {
+		t.Errorf("latency = %v, want %v", snap.Latency, time.Duration(snap.Successes)*time.Millisecond)
+	}
+	if len(snap.Commands) != 4 || snap.Commands["cmd0"].LastError != "boom" {
+		t.Errorf("commands = %+v, want cmd0-cmd3 with the last error", snap.Commands)
+	}
+}
+
+func TestRun_LiveStats(t *testing.T) {
+	live := NewReplayStats()
+	r, err := New(Config{
+		Mode:          ModeCommand,
+		CommandSender: &failingCommandSender{fail: "delete"},
+		LiveStats:     live,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	del := bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{}}, {Key: "$db", Value: "app"}}
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, find),
+		{SessionID: 1}, // session event, skipped
+		buildCommandPacket(t, 1, 0, find),
+		buildCommandPacket(t, 1, 0, del),
+	}}
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	snap := live.Snapshot()
+	if snap.Successes != stats.SuccessfulOps || snap.Failures != stats.FailedOps || snap.Skips != stats.SkippedPackets {
+		t.Errorf("live %d/%d/%d, stats %d/%d/%d (successes/failures/skips)",
+			snap.Successes, snap.Failures, snap.Skips, stats.SuccessfulOps, stats.FailedOps, stats.SkippedPackets)
+	}
+	if snap.Commands["find"].Successes != 2 || snap.Commands["delete"].Failures != 1 {
+		t.Errorf("commands = %+v, want 2 finds and a failed delete", snap.Commands)
+	}
+	if got := snap.Commands["delete"].LastError; got != "connection reset" {
+		t.Errorf("delete last error = %q, want the send error", got)
+	}
+	if names := snap.CommandNames(); len(names) != 2 || names[0] != "find" {
+		t.Errorf("CommandNames() = %v, want find first", names)
+	}
+}
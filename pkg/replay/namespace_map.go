@@ -0,0 +1,187 @@
+package replay
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// NamespaceMap redirects many namespaces at once, as loaded from a --namespace-map file
+// Collection entries take precedence over database-wide entries ("db" or "db.*", which
+// rewrite the database and keep the collection name), and exact sources over wildcard
+// ones (see reader.NamespaceMatcher); wildcard sources are tried in file order.
+type NamespaceMap struct {
+	// collections maps "db.collection" to its target "db.collection"
+	collections map[string]string
+
+	// databases maps a database to its target database
+	databases map[string]string
+
+	// collectionPatterns and databasePatterns hold the wildcard sources, in file order
+	collectionPatterns []namespacePattern
+	databasePatterns   []namespacePattern
+}
+
+// namespacePattern maps the namespaces matching a wildcard source to a target
+type namespacePattern struct {
+	source  string
+	matcher *reader.NamespaceMatcher
+
+	// target is the "db.collection" for a collection source, or the database otherwise
+	target string
+}
+
+// LoadNamespaceMap reads a namespace map file (see ParseNamespaceMap)
+func LoadNamespaceMap(path string) (*NamespaceMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open namespace map: %w", err)
+	}
+	defer f.Close()
+
+	m, err := ParseNamespaceMap(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseNamespaceMap parses CSV lines of the form "old,new"
+// Each side is "db.collection", "db.*" or "db"; both sides of a line must have the
+// same form. The source may have * and ? wildcards in either part ("tenant_*",
+// "*.events"); the target may not. Blank lines and lines starting with '#' are
+// ignored. A source may only be mapped once.
+func ParseNamespaceMap(r io.Reader) (*NamespaceMap, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+
+	m := &NamespaceMap{
+		collections: make(map[string]string),
+		databases:   make(map[string]string),
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace map: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		if err := m.add(strings.TrimSpace(record[0]), strings.TrimSpace(record[1])); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// add validates and stores one mapping
+func (m *NamespaceMap) add(from, to string) error {
+	fromDB, fromColl := splitNamespace(from)
+	toDB, toColl := splitNamespace(to)
+
+	if fromDB == "" || toDB == "" {
+		return fmt.Errorf("invalid mapping %q -> %q: database is required", from, to)
+	}
+
+	// "db.*" is the same as "db"
+	fromDatabase := fromColl == "" || fromColl == "*"
+	toDatabase := toColl == "" || toColl == "*"
+	if fromDatabase != toDatabase {
+		return fmt.Errorf("invalid mapping %q -> %q: both sides must be 'db', 'db.*' or 'db.collection'", from, to)
+	}
+	if strings.ContainsAny(toDB, "*?") || (!toDatabase && strings.ContainsAny(toColl, "*?")) {
+		return fmt.Errorf("invalid mapping %q -> %q: wildcards are only supported in the source", from, to)
+	}
+
+	if fromDatabase {
+		if strings.ContainsAny(fromDB, "*?") {
+			return m.addPattern(&m.databasePatterns, fromDB, toDB)
+		}
+		if _, exists := m.databases[fromDB]; exists {
+			return fmt.Errorf("database %q is mapped more than once", fromDB)
+		}
+		m.databases[fromDB] = toDB
+		return nil
+	}
+
+	source := fromDB + "." + fromColl
+	if strings.ContainsAny(source, "*?") {
+		return m.addPattern(&m.collectionPatterns, source, toDB+"."+toColl)
+	}
+	if _, exists := m.collections[source]; exists {
+		return fmt.Errorf("namespace %q is mapped more than once", source)
+	}
+	m.collections[source] = toDB + "." + toColl
+	return nil
+}
+
+// addPattern compiles a wildcard source and appends it to patterns
+func (m *NamespaceMap) addPattern(patterns *[]namespacePattern, source, target string) error {
+	for _, p := range *patterns {
+		if p.source == source {
+			return fmt.Errorf("namespace %q is mapped more than once", source)
+		}
+	}
+	matcher, err := reader.NewNamespaceMatcher([]string{source})
+	if err != nil {
+		return err
+	}
+	*patterns = append(*patterns, namespacePattern{source: source, matcher: matcher, target: target})
+	return nil
+}
+
+// Len returns the number of mappings
+func (m *NamespaceMap) Len() int {
+	return len(m.collections) + len(m.databases) + len(m.collectionPatterns) + len(m.databasePatterns)
+}
+
+// Lookup returns the target database and collection for a namespace
+// Namespaces without a mapping are returned unchanged. coll may be "" for
+// commands that don't name a collection; only database-wide entries apply to them.
+func (m *NamespaceMap) Lookup(db, coll string) (string, string) {
+	if coll != "" {
+		if target, ok := m.collections[db+"."+coll]; ok {
+			return splitNamespace(target)
+		}
+		for _, p := range m.collectionPatterns {
+			if p.matcher.Matches(db, coll) {
+				return splitNamespace(p.target)
+			}
+		}
+	}
+	if target, ok := m.databases[db]; ok {
+		return target, coll
+	}
+	for _, p := range m.databasePatterns {
+		if p.matcher.Matches(db, coll) {
+			return p.target, coll
+		}
+	}
+	return db, coll
+}
+
+// Transform returns a transform that applies the map to each command's database and collection
+// The collection is the string value of the command field (e.g. { find: "users" }),
+// or getMore's "collection" field
+func (m *NamespaceMap) Transform() TransformFunc {
+	return func(cmd *sender.Command) error {
+		field := collectionField(cmd)
+		coll, _ := cmd.Document[field].(string)
+		db, newColl := m.Lookup(cmd.Database, coll)
+
+		cmd.Database = db
+		if newColl != coll {
+			cmd.Document[field] = newColl
+		}
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNamespaceMap_Transform(t *testing.T) {
+	m, err := ParseNamespaceMap(strings.NewReader(`# old,new
+prod.users,staging.users_copy
+prod.*,staging.*
+
+legacy, modern
+`))
+	if err != nil {
+		t.Fatalf("ParseNamespaceMap failed: %v", err)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", m.Len())
+	}
+
+	tests := []struct {
+		name         string
+		cmd          *sender.Command
+		field        string
+		expectedDB   string
+		expectedColl string
+	}{
+		{
+			name:         "exact mapping",
+			cmd:          &sender.Command{Database: "prod", Name: "insert", Document: bson.M{"insert": "users"}},
+			expectedDB:   "staging",
+			expectedColl: "users_copy",
+		},
+		{
+			name:         "wildcard mapping",
+			cmd:          &sender.Command{Database: "prod", Name: "find", Document: bson.M{"find": "orders"}},
+			expectedDB:   "staging",
+			expectedColl: "orders",
+		},
+		{
+			name:         "db-only mapping",
+			cmd:          &sender.Command{Database: "legacy", Name: "update", Document: bson.M{"update": "accounts"}},
+			expectedDB:   "modern",
+			expectedColl: "accounts",
+		},
+		{
+			name:         "command without a collection",
+			cmd:          &sender.Command{Database: "legacy", Name: "ping", Document: bson.M{"ping": int32(1)}},
+			field:        "ping",
+			expectedDB:   "modern",
+			expectedColl: "",
+		},
+		{
+			name:         "getMore collection field",
+			cmd:          &sender.Command{Database: "prod", Name: "getMore", Document: bson.M{"getMore": int64(7), "collection": "users"}},
+			field:        "collection",
+			expectedDB:   "staging",
+			expectedColl: "users_copy",
+		},
+		{
+			name:         "unmapped namespace untouched",
+			cmd:          &sender.Command{Database: "test", Name: "find", Document: bson.M{"find": "users"}},
+			expectedDB:   "test",
+			expectedColl: "users",
+		},
+	}
+
+	transform := m.Transform()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := transform(tt.cmd); err != nil {
+				t.Fatalf("transform failed: %v", err)
+			}
+			if tt.cmd.Database != tt.expectedDB {
+				t.Errorf("Database = %q, want %q", tt.cmd.Database, tt.expectedDB)
+			}
+			field := tt.field
+			if field == "" {
+				field = tt.cmd.Name
+			}
+			if coll, _ := tt.cmd.Document[field].(string); coll != tt.expectedColl {
+				t.Errorf("collection = %q, want %q", coll, tt.expectedColl)
+			}
+		})
+	}
+}
+
+func TestNamespaceMap_WildcardSources(t *testing.T) {
+	m, err := ParseNamespaceMap(strings.NewReader(`prod.users,staging.users_copy
+prod.events_*,archive.events
+*.audit,archive.audit
+tenant_?,staging
+tenant_*,shared.*
+`))
+	if err != nil {
+		t.Fatalf("ParseNamespaceMap failed: %v", err)
+	}
+	if m.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", m.Len())
+	}
+
+	tests := []struct {
+		db, coll         string
+		wantDB, wantColl string
+	}{
+		{"prod", "users", "staging", "users_copy"},
+		{"prod", "events_2024", "archive", "events"},
+		{"billing", "audit", "archive", "audit"},
+		{"tenant_1", "orders", "staging", "orders"}, // first matching pattern wins
+		{"tenant_42", "orders", "shared", "orders"}, // ? matches one character only
+		{"tenant_1", "audit", "archive", "audit"},   // collection patterns before database ones
+		{"tenant_42", "", "shared", ""},             // database patterns cover database commands
+		{"prod", "orders", "prod", "orders"},        // unmapped
+	}
+	for _, tt := range tests {
+		if db, coll := m.Lookup(tt.db, tt.coll); db != tt.wantDB || coll != tt.wantColl {
+			t.Errorf("Lookup(%q, %q) = %q, %q, want %q, %q", tt.db, tt.coll, db, coll, tt.wantDB, tt.wantColl)
+		}
+	}
+}
+
+func TestParseNamespaceMap_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"mixed forms", "prod.users,staging\n", "line 1"},
+		{"wildcard to collection", "ok,fine\nprod.*,staging.users\n", "line 2"},
+		{"wildcard target", "prod.user*,staging.user*\n", "wildcards"},
+		{"wildcard target database", "prod?,staging?\n", "wildcards"},
+		{"duplicate wildcard source", "prod.events_*,a.b\nprod.events_*,c.d\n", "more than once"},
+		{"missing database", ".users,staging.users\n", "database is required"},
+		{"duplicate namespace", "prod.users,a.b\nprod.users,c.d\n", "more than once"},
+		{"duplicate database", "prod,a\nprod.*,b.*\n", "more than once"},
+		{"wrong field count", "prod.users\n", "invalid namespace map"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseNamespaceMap(strings.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package replay
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// OrderBy is the packet field an OrderedSource sequences by
+//
+// Order and Offset normally agree, but they can disagree across files in a
+// RecordingSet or in captures merged from several sources. OrderByOrder follows
+// the exact capture sequence, which is what matters for causality within a session
+// (a request before its getMore, a write before the read that depends on it).
+// OrderByOffset follows the wall-clock timestamps, so paced replay dispatches
+// each op at its recorded time without stalls or bursts; ties keep Order.
+type OrderBy string
+
+const (
+	// OrderByOrder sequences packets by ascending Order (the default)
+	OrderByOrder OrderBy = "order"
+
+	// OrderByOffset sequences packets by ascending (Offset, Order)
+	OrderByOffset OrderBy = "offset"
+)
+
+// ParseOrderBy parses an --order-by value
+func ParseOrderBy(s string) (OrderBy, error) {
+	switch OrderBy(s) {
+	case OrderByOrder, OrderByOffset:
+		return OrderBy(s), nil
+	}
+	return "", fmt.Errorf("invalid order-by '%s'. Must be '%s' or '%s'", s, OrderByOrder, OrderByOffset)
+}
+
+// before reports whether a sorts before b
+func (by OrderBy) before(a, b *reader.Packet) bool {
+	if by == OrderByOffset && a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return a.Order < b.Order
+}
+
+// OrderedSource re-sequences packets from another source by ascending Order
+// (or Offset, see NewOrderedSourceBy)
+//
+// Packets are buffered in a sliding window of the given size and the lowest
+// Order in the window is dispatched first. Within one file Order matches read
+// order, but across files in a RecordingSet it may not. Gaps in Order are
+// expected (filtered recordings drop packets), but a packet whose Order is
+// lower than one already dispatched arrived too late for the window to fix,
+// so it is reported as an error rather than replayed out of sequence.
+type OrderedSource struct {
+	src        PacketSource
+	window     int
+	by         OrderBy
+	buffer     packetHeap
+	srcDone    bool
+	dispatched *reader.Packet
+}
+
+// NewOrderedSource wraps src so packets are yielded in ascending Order
+func NewOrderedSource(src PacketSource, window int) (*OrderedSource, error) {
+	return NewOrderedSourceBy(src, window, OrderByOrder)
+}
+
+// NewOrderedSourceBy wraps src so packets are yielded in ascending order of the given field
+func NewOrderedSourceBy(src PacketSource, window int, by OrderBy) (*OrderedSource, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("invalid order window %d (must be >= 1)", window)
+	}
+	if _, err := ParseOrderBy(string(by)); err != nil {
+		return nil, err
+	}
+	return &OrderedSource{
+		src:    src,
+		window: window,
+		by:     by,
+		buffer: packetHeap{by: by},
+	}, nil
+}
+
+// Next returns the packet that sorts first in the window
+// Returns io.EOF once the underlying source and the window are exhausted
+func (o *OrderedSource) Next() (*reader.Packet, error) {
+	// Fill the window
+	for !o.srcDone && o.buffer.Len() < o.window {
+		packet, err := o.src.Next()
+		if err == io.EOF {
+			o.srcDone = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if last := o.dispatched; last != nil && o.by.before(packet, last) {
+			if o.by == OrderByOffset {
+				return nil, fmt.Errorf("packet offset %d arrived after offset %d was already dispatched (out of sequence beyond window of %d)",
+					packet.Offset, last.Offset, o.window)
+			}
+			return nil, fmt.Errorf("packet order %d arrived after order %d was already dispatched (out of sequence beyond window of %d)",
+				packet.Order, last.Order, o.window)
+		}
+		heap.Push(&o.buffer, packet)
+	}
+
+	if o.buffer.Len() == 0 {
+		return nil, io.EOF
+	}
+
+	packet := heap.Pop(&o.buffer).(*reader.Packet)
+	o.dispatched = packet
+	return packet, nil
+}
+
+// Close closes the underlying source if it is an io.Closer
+func (o *OrderedSource) Close() error {
+	if closer, ok := o.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// packetHeap is a min-heap of packets keyed by the by field
+type packetHeap struct {
+	packets []*reader.Packet
+	by      OrderBy
+}
+
+func (h packetHeap) Len() int           { return len(h.packets) }
+func (h packetHeap) Less(i, j int) bool { return h.by.before(h.packets[i], h.packets[j]) }
+func (h packetHeap) Swap(i, j int)      { h.packets[i], h.packets[j] = h.packets[j], h.packets[i] }
+
+func (h *packetHeap) Push(x interface{}) {
+	h.packets = append(h.packets, x.(*reader.Packet))
+}
+
+func (h *packetHeap) Pop() interface{} {
+	old := h.packets
+	n := len(old)
+	packet := old[n-1]
+	old[n-1] = nil
+	h.packets = old[:n-1]
+	return packet
+}
@@ -0,0 +1,108 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// packetsWithOrder builds empty packets carrying the given Order values
+func packetsWithOrder(orders ...uint64) []*reader.Packet {
+	packets := make([]*reader.Packet, len(orders))
+	for i, order := range orders {
+		packets[i] = &reader.Packet{Order: order}
+	}
+	return packets
+}
+
+func TestOrderedSource_ReordersWithinWindow(t *testing.T) {
+	src := &sliceSource{packets: packetsWithOrder(2, 1, 3, 5, 4, 7)}
+	ordered, err := NewOrderedSource(src, 3)
+	if err != nil {
+		t.Fatalf("NewOrderedSource failed: %v", err)
+	}
+
+	var got []uint64
+	for {
+		packet, err := ordered.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, packet.Order)
+	}
+
+	want := []uint64{1, 2, 3, 4, 5, 7}
+	if len(got) != len(want) {
+		t.Fatalf("got orders %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got orders %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOrderedSource_ErrorsBeyondWindow(t *testing.T) {
+	// Order 1 arrives three packets late; a window of 2 cannot absorb it
+	src := &sliceSource{packets: packetsWithOrder(2, 3, 4, 1)}
+	ordered, err := NewOrderedSource(src, 2)
+	if err != nil {
+		t.Fatalf("NewOrderedSource failed: %v", err)
+	}
+
+	for {
+		_, err := ordered.Next()
+		if err == io.EOF {
+			t.Fatal("expected out-of-sequence error, got EOF")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func TestNewOrderedSource_InvalidWindow(t *testing.T) {
+	if _, err := NewOrderedSource(&sliceSource{}, 0); err == nil {
+		t.Error("expected error for window 0")
+	}
+}
+
+func TestOrderedSource_ByOffset(t *testing.T) {
+	// Order and Offset disagree, as across files of a RecordingSet; Offset ties keep Order
+	packets := []*reader.Packet{
+		{Order: 1, Offset: 300},
+		{Order: 2, Offset: 100},
+		{Order: 4, Offset: 200},
+		{Order: 3, Offset: 200},
+	}
+	ordered, err := NewOrderedSourceBy(&sliceSource{packets: packets}, 4, OrderByOffset)
+	if err != nil {
+		t.Fatalf("NewOrderedSourceBy failed: %v", err)
+	}
+
+	var got []uint64
+	for {
+		packet, err := ordered.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, packet.Order)
+	}
+
+	want := []uint64{2, 3, 4, 1}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got orders %v, want %v", got, want)
+	}
+
+	if _, err := NewOrderedSourceBy(&sliceSource{}, 1, "time"); err == nil {
+		t.Error("expected error for an invalid order-by")
+	}
+}
@@ -0,0 +1,50 @@
+package replay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// PoolStatsSender reports its connection-pool checkouts (implemented by sender.Sender
+// and sender.RawSender)
+// Measured op latency includes the checkout, so the summary and the interval reports
+// show how much of it was spent waiting for a connection rather than on the server.
+type PoolStatsSender interface {
+	PoolStats() sender.PoolStats
+}
+
+// poolStats sums the pool stats of every sender the replay has used, each counted once
+// ok is false if none of them report pool stats.
+func (r *Replayer) poolStats() (total sender.PoolStats, ok bool) {
+	seen := make(map[PoolStatsSender]bool)
+	add := func(s any) {
+		p, reports := s.(PoolStatsSender)
+		if !reports || seen[p] {
+			return
+		}
+		seen[p] = true
+		total = total.Add(p.PoolStats())
+	}
+	for _, target := range r.targets {
+		add(target.RawSender)
+		add(target.CommandSender)
+	}
+	for _, s := range r.appSenders {
+		add(s)
+	}
+	return total, len(seen) > 0
+}
+
+// formatPoolReport returns the pool fields appended to a report line: the checkouts
+// made since the window's start (when the pool stats were prev), and how long they took
+func formatPoolReport(prev, cur sender.PoolStats) string {
+	checkouts := cur.Checkouts - prev.Checkouts
+	var avg time.Duration
+	if checkouts > 0 {
+		avg = (cur.CheckoutWait - prev.CheckoutWait) / time.Duration(checkouts)
+	}
+	return fmt.Sprintf(" checkouts=%d checkout_avg=%v pool_exhausted=%d",
+		checkouts, avg.Round(time.Microsecond), cur.Exhausted-prev.Exhausted)
+}
@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// poolCommandSender checks out a connection per command, waiting wait for it each time
+type poolCommandSender struct {
+	recordingCommandSender
+	wait  time.Duration
+	stats sender.PoolStats
+}
+
+func (s *poolCommandSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	s.stats = s.stats.Add(sender.PoolStats{Checkouts: 1, Exhausted: 1, CheckoutWait: s.wait, MaxCheckoutWait: s.wait})
+	return s.recordingCommandSender.SendCommand(database, command)
+}
+
+func (s *poolCommandSender) PoolStats() sender.PoolStats {
+	return s.stats
+}
+
+func TestRun_PoolStats(t *testing.T) {
+	first := &poolCommandSender{wait: time.Millisecond}
+	second := &poolCommandSender{wait: 3 * time.Millisecond}
+	var out strings.Builder
+	r, err := New(Config{
+		Mode:           ModeCommand,
+		Targets:        []Target{{Name: "a", CommandSender: first}, {Name: "b", CommandSender: second}},
+		Speed:          1,
+		ReportInterval: time.Hour,
+		SummaryOnly:    true,
+		Output:         &out,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, find),
+		buildCommandPacket(t, 2, 0, find),
+		buildCommandPacket(t, 3, 0, find),
+	}}
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Round-robin: two commands on the first target, one on the second
+	want := sender.PoolStats{Checkouts: 3, Exhausted: 3, CheckoutWait: 5 * time.Millisecond, MaxCheckoutWait: 3 * time.Millisecond}
+	if stats.Pool != want {
+		t.Errorf("Pool = %+v, want %+v", stats.Pool, want)
+	}
+	if !strings.Contains(out.String(), "checkouts=3 checkout_avg=1.667ms pool_exhausted=3") {
+		t.Errorf("report = %q, want the window's checkouts", out.String())
+	}
+}
+
+func TestRun_PoolStatsCountsSharedSenderOnce(t *testing.T) {
+	snd := &poolCommandSender{wait: time.Millisecond}
+	r, err := New(Config{
+		Mode:          ModeCommand,
+		CommandSender: snd,
+		SummaryOnly:   true,
+		Output:        &strings.Builder{},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	r.appSenders["app"] = snd
+
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{buildCommandPacket(t, 1, 0, find)}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Pool.Checkouts != 1 {
+		t.Errorf("Pool.Checkouts = %d, want 1 (one sender, counted once)", stats.Pool.Checkouts)
+	}
+}
+
+func TestRun_PoolStatsWithoutReportingSenders(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{
+		Mode:           ModeCommand,
+		CommandSender:  &recordingCommandSender{},
+		ReportInterval: time.Hour,
+		SummaryOnly:    true,
+		Output:         &out,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{buildCommandPacket(t, 1, 0, find)}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Pool != (sender.PoolStats{}) {
+		t.Errorf("Pool = %+v, want zero", stats.Pool)
+	}
+	if strings.Contains(out.String(), "checkouts=") {
+		t.Errorf("report = %q, want no pool fields", out.String())
+	}
+}
@@ -0,0 +1,52 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// PacketWriter receives the packets of a new recording (implemented by reader.RecordingWriter)
+type PacketWriter interface {
+	Write(packet *reader.Packet) error
+}
+
+// readsResponses reports whether raw sends must read the target's response
+func (r *Replayer) readsResponses() bool {
+	return r.config.RecordedResponses != nil || r.config.RecordResponses != nil
+}
+
+// recordExchange writes a sent request and the target's response to RecordResponses
+// Both packets keep the request's session; offsets are the send and receive times since
+// the replay started, and the response's responseTo is set to the request's id.
+func (r *Replayer) recordExchange(stats *Stats, packet *reader.Packet, sent time.Time, result *sender.RawResult) {
+	if r.config.RecordResponses == nil || r.recordErr != nil || len(packet.Message) < reader.WireHeaderSize || len(result.ResponseBytes) < reader.WireHeaderSize {
+		return
+	}
+
+	request := *packet
+	request.Offset = uint64(sent.Sub(r.runStart).Microseconds())
+	request.Order = r.recordOrder
+	if err := r.config.RecordResponses.Write(&request); err != nil {
+		r.recordErr = err
+		return
+	}
+
+	message := append([]byte(nil), result.ResponseBytes...)
+	copy(message[8:12], packet.Message[4:8])
+	response := reader.Packet{
+		SessionID:       packet.SessionID,
+		SessionMetadata: packet.SessionMetadata,
+		Offset:          request.Offset + uint64(result.Duration.Microseconds()),
+		Order:           r.recordOrder + 1,
+		Message:         message,
+	}
+	if err := r.config.RecordResponses.Write(&response); err != nil {
+		r.recordErr = err
+		return
+	}
+
+	r.recordOrder += 2
+	stats.ResponsesRecorded++
+}
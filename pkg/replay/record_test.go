@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/wiremessage"
+)
+
+// respondingRawSender answers every raw message with an {ok: 1} OP_MSG
+type respondingRawSender struct {
+	t *testing.T
+}
+
+func (s *respondingRawSender) SendRawWireMessage(ctx context.Context, wireMessageBytes []byte) (*sender.RawResult, error) {
+	return &sender.RawResult{Success: true}, nil
+}
+
+func (s *respondingRawSender) SendRawWireMessageWithResponse(ctx context.Context, wireMessageBytes []byte) (*sender.RawResult, error) {
+	return &sender.RawResult{
+		Success:       true,
+		Duration:      2 * time.Millisecond,
+		OpCode:        wiremessage.OpMsg,
+		ResponseBytes: buildOpMsg(s.t, 900, 0, bson.D{{Key: "ok", Value: 1.0}}),
+	}, nil
+}
+
+// packetCollector is a PacketWriter that keeps what it is given
+type packetCollector struct {
+	packets []*reader.Packet
+}
+
+func (c *packetCollector) Write(packet *reader.Packet) error {
+	c.packets = append(c.packets, packet)
+	return nil
+}
+
+func TestRun_RecordResponses(t *testing.T) {
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	request := &reader.Packet{SessionID: 7, SessionMetadata: `{"remote":"10.0.0.1:5000"}`, Offset: 5_000_000, Order: 40, Message: buildOpMsg(t, 42, 0, find)}
+	out := &packetCollector{}
+
+	r, err := New(Config{Mode: ModeRaw, RawSender: &respondingRawSender{t: t}, RecordResponses: out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{request, buildCommandPacket(t, 8, 5_000_100, find)}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.ResponsesRecorded != 2 || len(out.packets) != 4 {
+		t.Fatalf("recorded %d exchanges in %d packets, want 2 in 4", stats.ResponsesRecorded, len(out.packets))
+	}
+
+	req, resp := out.packets[0], out.packets[1]
+	if req.SessionID != 7 || resp.SessionID != 7 || resp.SessionMetadata != request.SessionMetadata {
+		t.Errorf("sessions = %d/%d (metadata %q), want both in session 7", req.SessionID, resp.SessionID, resp.SessionMetadata)
+	}
+	if req.Order != 0 || resp.Order != 1 || out.packets[2].Order != 2 || out.packets[3].Order != 3 {
+		t.Errorf("orders = %d, %d, %d, %d, want 0-3", req.Order, resp.Order, out.packets[2].Order, out.packets[3].Order)
+	}
+	if req.Offset > uint64(time.Second.Microseconds()) || resp.Offset != req.Offset+2000 {
+		t.Errorf("offsets = %d/%d, want replay-relative with the response 2ms after the request", req.Offset, resp.Offset)
+	}
+	if request.Offset != 5_000_000 || request.Order != 40 {
+		t.Errorf("source packet was modified: offset %d, order %d", request.Offset, request.Order)
+	}
+	if responseTo := int32(binary.LittleEndian.Uint32(resp.Message[8:12])); responseTo != 42 || resp.IsRequest() {
+		t.Errorf("response responseTo = %d, want 42", responseTo)
+	}
+}
+
+func TestNew_RecordResponsesValidation(t *testing.T) {
+	out := &packetCollector{}
+	if _, err := New(Config{Mode: ModeCommand, CommandSender: &recordingCommandSender{}, RecordResponses: out}); err == nil || !strings.Contains(err.Error(), "raw mode") {
+		t.Errorf("New error = %v, want raw mode required", err)
+	}
+	if _, err := New(Config{Mode: ModeRaw, DryRun: true, RecordResponses: out}); err == nil || !strings.Contains(err.Error(), "dry run") {
+		t.Errorf("New error = %v, want dry run rejected", err)
+	}
+}
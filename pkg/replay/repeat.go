@@ -0,0 +1,110 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// RepeatSource yields a recording several times in a row, reopening it for each pass
+//
+// Pacing follows packet offsets, so each pass's offsets are shifted to continue after
+// the previous pass. By default a pass is placed as if the recording restarted at the
+// previous pass's last op: the recorded time from capture start to the first packet is
+// replayed as a pause at every loop boundary. Seamless repeats drop that pause, so the
+// first op of a pass follows the last op of the previous one immediately, for sustained
+// load without dips.
+type RepeatSource struct {
+	current  PacketSource
+	reopen   func() (PacketSource, error)
+	passes   int
+	seamless bool
+
+	// pass is the 1-based number of the current pass
+	pass int
+
+	// base is added to the current pass's offsets
+	base uint64
+
+	// minOffset and maxOffset are the recorded offset range of the current pass so far
+	minOffset uint64
+	maxOffset uint64
+	yielded   bool
+}
+
+// NewRepeatSource replays first, then passes-1 more sources returned by reopen
+// Finished sources that implement io.Closer are closed.
+func NewRepeatSource(first PacketSource, reopen func() (PacketSource, error), passes int, seamless bool) (*RepeatSource, error) {
+	if passes < 1 {
+		return nil, fmt.Errorf("invalid repeat count %d (must be >= 1)", passes)
+	}
+	return &RepeatSource{
+		current:  first,
+		reopen:   reopen,
+		passes:   passes,
+		seamless: seamless,
+		pass:     1,
+	}, nil
+}
+
+// Next returns the next packet of the current pass, with its offset shifted
+// Returns io.EOF after the last pass, or early if a pass yields no packets
+func (s *RepeatSource) Next() (*reader.Packet, error) {
+	for {
+		packet, err := s.current.Next()
+		if err == io.EOF {
+			if err := s.nextPass(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !s.yielded || packet.Offset < s.minOffset {
+			s.minOffset = packet.Offset
+		}
+		if !s.yielded || packet.Offset > s.maxOffset {
+			s.maxOffset = packet.Offset
+		}
+		s.yielded = true
+
+		packet.Offset += s.base
+		return packet, nil
+	}
+}
+
+// nextPass closes the finished pass and opens the next one
+// Returns io.EOF if there are no more passes
+func (s *RepeatSource) nextPass() error {
+	if closer, ok := s.current.(io.Closer); ok {
+		closer.Close()
+	}
+	if s.pass >= s.passes || !s.yielded {
+		return io.EOF
+	}
+
+	src, err := s.reopen()
+	if err != nil {
+		return fmt.Errorf("failed to reopen recording for pass %d: %w", s.pass+1, err)
+	}
+
+	if s.seamless {
+		// The next pass's first op lands on this pass's last op
+		s.base += s.maxOffset - s.minOffset
+	} else {
+		// The next pass's capture start lands on this pass's last op
+		s.base += s.maxOffset
+	}
+	s.current = src
+	s.pass++
+	s.yielded = false
+	return nil
+}
+
+// Pass returns the 1-based number of the pass being read
+func (s *RepeatSource) Pass() int {
+	return s.pass
+}
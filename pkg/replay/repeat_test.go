@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// closingSource is a sliceSource that records being closed
+type closingSource struct {
+	sliceSource
+	closed bool
+}
+
+func (s *closingSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRepeatSource_Offsets(t *testing.T) {
+	// Capture started 1000µs before the first packet; the pass spans 1000..1500
+	recording := func() *closingSource {
+		return &closingSource{sliceSource: sliceSource{packets: []*reader.Packet{
+			{SessionID: 1, Offset: 1000},
+			{SessionID: 1, Offset: 1200},
+			{SessionID: 1, Offset: 1500},
+		}}}
+	}
+
+	tests := []struct {
+		name     string
+		seamless bool
+		want     []uint64
+	}{
+		{"per-pass reset", false, []uint64{1000, 1200, 1500, 2500, 2700, 3000, 4000, 4200, 4500}},
+		{"seamless", true, []uint64{1000, 1200, 1500, 1500, 1700, 2000, 2000, 2200, 2500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opened []*closingSource
+			reopen := func() (PacketSource, error) {
+				src := recording()
+				opened = append(opened, src)
+				return src, nil
+			}
+			first := recording()
+			opened = append(opened, first)
+
+			src, err := NewRepeatSource(first, reopen, 3, tt.seamless)
+			if err != nil {
+				t.Fatalf("NewRepeatSource failed: %v", err)
+			}
+
+			var got []uint64
+			for {
+				packet, err := src.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+				got = append(got, packet.Offset)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("offsets = %v, want %v", got, tt.want)
+			}
+			if len(opened) != 3 || src.Pass() != 3 {
+				t.Errorf("opened %d sources, pass %d; want 3 and 3", len(opened), src.Pass())
+			}
+			for i, s := range opened {
+				if !s.closed {
+					t.Errorf("pass %d source not closed", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestRepeatSource_EmptyPassStops(t *testing.T) {
+	reopen := func() (PacketSource, error) {
+		t.Fatal("reopened after an empty pass")
+		return nil, nil
+	}
+	src, err := NewRepeatSource(&sliceSource{}, reopen, 5, false)
+	if err != nil {
+		t.Fatalf("NewRepeatSource failed: %v", err)
+	}
+	if _, err := src.Next(); err != io.EOF {
+		t.Errorf("Next = %v, want io.EOF", err)
+	}
+
+	if _, err := NewRepeatSource(&sliceSource{}, reopen, 0, false); err == nil {
+		t.Error("expected error for 0 passes")
+	}
+}
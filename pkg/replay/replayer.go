@@ -0,0 +1,1018 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/scriptgen"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Mode selects how recorded packets are sent to the target
+type Mode string
+
+const (
+	// ModeRaw sends the exact wire protocol bytes from the recording
+	ModeRaw Mode = "raw"
+	// ModeCommand parses each packet and re-executes it via RunCommand
+	ModeCommand Mode = "command"
+)
+
+// PacketSource yields recorded packets in order
+// Both reader.RecordingReader and reader.RecordingSet satisfy this interface
+type PacketSource interface {
+	Next() (*reader.Packet, error)
+}
+
+// RawSender sends raw wire protocol messages (implemented by sender.RawSender)
+type RawSender interface {
+	SendRawWireMessage(ctx context.Context, wireMessageBytes []byte) (*sender.RawResult, error)
+}
+
+// RawResponseSender sends raw wire messages and reads the response (implemented by sender.RawSender)
+// Raw mode needs it to compare responses against RecordedResponses
+type RawResponseSender interface {
+	SendRawWireMessageWithResponse(ctx context.Context, wireMessageBytes []byte) (*sender.RawResult, error)
+}
+
+// CommandSender sends parsed commands (implemented by sender.Sender)
+type CommandSender interface {
+	SendCommand(database string, command bson.M) (*sender.Result, error)
+}
+
+// SessionCommandSender sends commands within a logical session per key (implemented by sender.Sender)
+// Cursor-id rewriting uses it so each recorded session's getMores run in the session
+// that opened the cursor
+type SessionCommandSender interface {
+	SendCommandInSession(key uint64, database string, command bson.M) (*sender.Result, error)
+}
+
+// Config controls filtering, pacing and output of a replay
+type Config struct {
+	// Mode selects raw or command replay (default: raw)
+	Mode Mode
+
+	// RequestsOnly skips responses
+	RequestsOnly bool
+
+	// UserOpsOnly skips packets that aren't likely user operations
+	UserOpsOnly bool
+
+	// Namespaces, if set, skips requests outside its namespaces and the responses to them
+	Namespaces *reader.NamespaceFilter
+
+	// CommandFilter, if set, skips the packets it doesn't keep, after the filters above;
+	// e.g. reader.CategoryFilter("crud", "read") replays CRUD traffic only
+	CommandFilter reader.Filter
+
+	// DryRun parses and validates packets without sending them
+	DryRun bool
+
+	// ShowDrift appends the per-op drift versus the recorded timeline to output lines
+	ShowDrift bool
+
+	// ShowTiming previews pacing in a dry run: instead of sleeping, each op's output line
+	// shows the sleep before it and its time on the simulated timeline at the configured
+	// Speed (requires DryRun; injected latency is not included)
+	ShowTiming bool
+
+	// ShowSession prefixes per-op output lines with the recorded session ID, to trace
+	// which original connection an operation (or failure) came from
+	ShowSession bool
+
+	// SessionSample replays only a deterministic fraction of sessions, chosen by hashing
+	// the session ID; chosen sessions are replayed in full (0 = every session)
+	SessionSample float64
+
+	// Limit stops the replay after this many operations (0 = unlimited)
+	Limit int
+
+	// MaxDuration stops the replay once this much wall-clock time has passed (0 = unlimited)
+	MaxDuration time.Duration
+
+	// Speed is the replay speed multiplier (1.0 = original timing, 0 = fast-forward)
+	Speed float64
+
+	// StartAt, if set, anchors pacing to a wall-clock time: each op is sent at StartAt plus
+	// its recorded offset (scaled by Speed), so ops keep their time of day relative to the
+	// capture start instead of starting with the first op. Ops already due when the
+	// replay starts are sent immediately (requires Speed > 0)
+	StartAt time.Time
+
+	// SkipPast skips ops that were due before the replay started instead of sending them
+	// immediately (requires StartAt)
+	SkipPast bool
+
+	// PaceByResponse models closed-loop clients: a request is sent the recorded think time
+	// (the gap between its session's previous response and the request, scaled by Speed)
+	// after the replay finished the session's previous request, rather than at its own
+	// offset. A slow target then delays a session's later requests as it would have delayed
+	// the recorded client. Requests with no earlier response in their session are paced by
+	// offset (requires Speed > 0)
+	PaceByResponse bool
+
+	// ReportInterval, if set, prints a one-line summary of each window of this length
+	// (ops, rate, failures and average latency within the window, not cumulative)
+	// to Output, even when SummaryOnly is set
+	ReportInterval time.Duration
+
+	// InjectLatency is an extra delay added before every send, on top of offset-based pacing
+	InjectLatency time.Duration
+
+	// InjectJitter randomizes the injected delay uniformly within ±InjectJitter
+	InjectJitter time.Duration
+
+	// Seed seeds the jitter RNG so a run's delays are reproducible
+	Seed int64
+
+	// RawSender is required in raw mode unless DryRun is set
+	RawSender RawSender
+
+	// CommandSender is required in command mode unless DryRun is set
+	CommandSender CommandSender
+
+	// Targets, if set, replaces RawSender/CommandSender with several destinations;
+	// each op is sent to one of them as chosen by Routing, and Stats.Targets counts
+	// the ops per target
+	Targets []Target
+
+	// Routing picks the target for each op when there are several (default RouteRoundRobin)
+	Routing Routing
+
+	// CommandSenderForApp, if set, sends each recorded session's commands through a sender
+	// whose client reports the session's recorded appName (command mode only). The appName
+	// comes from the session metadata, or failing that the session's handshake request;
+	// sessions without one use CommandSender. It is called once per distinct appName.
+	CommandSenderForApp func(appName string) (CommandSender, error)
+
+	// DecompressFallback retries an OP_COMPRESSED packet whose raw send failed (e.g. the
+	// target doesn't support its compressor) as a command: it is decompressed, its command
+	// extracted and sent through the target's CommandSender, which raw mode then requires
+	// (raw mode only)
+	DecompressFallback bool
+
+	// PreserveRetryableWrites keeps lsid/txnNumber on retryable writes outside
+	// transactions (command mode only; raw mode always sends them unchanged)
+	PreserveRetryableWrites bool
+
+	// DefaultDatabase is the database for commands whose $db can't be extracted; without
+	// it such commands are skipped (command mode only)
+	DefaultDatabase string
+
+	// TolerateWriteErrors counts a write batch whose response has writeErrors for only some
+	// of its statements as successful (with each error logged as a warning) instead of
+	// failed (command mode only). A batch where every statement failed still fails.
+	TolerateWriteErrors bool
+
+	// OnDuplicate picks what happens to inserted documents that fail with a duplicate
+	// key (default DuplicateFail); statements an ordered insert didn't reach because of
+	// a skipped or upserted duplicate are re-sent (command mode only)
+	OnDuplicate DuplicatePolicy
+
+	// BlockAggWrites, if set, keeps aggregations whose pipeline ends in $out or $merge
+	// from writing: they are skipped, or sent with the write stage removed (command
+	// mode only; counted in Stats.AggWritesBlocked)
+	BlockAggWrites AggWritePolicy
+
+	// WarnEncrypted flags ops whose command holds encrypted (binary subtype 6) values, from
+	// a source using Client-Side Field Level Encryption: the ciphertext is sent unchanged,
+	// so such ops only behave as recorded on a target that shares the source's key vault
+	// (counted in Stats.EncryptedOps)
+	WarnEncrypted bool
+
+	// Shadow sends only reads (ShadowReadCommands) and skips writes, DDL and every other
+	// command, so a production-adjacent target is exercised without being changed; the
+	// skipped writes are counted in Stats.ShadowWritesSkipped (command mode only)
+	Shadow bool
+
+	// Transforms are applied in order to each command before it is sent (command mode only)
+	Transforms []TransformFunc
+
+	// RecordedResponses enables response assertion: each live response is compared with
+	// the recorded response to the same request, and a divergence fails the op (nil = off)
+	RecordedResponses ResponseIndex
+
+	// ResponseFields selects the keys summarized from live responses for comparison
+	// (0 = DefaultResponseFields); it should match the fields RecordedResponses was built with
+	ResponseFields ResponseFields
+
+	// IgnoreFields are dot-paths left out of FieldBody comparison on top of
+	// sender.DefaultVolatileFields (e.g. "lastCommittedOpTime"); RecordedResponses should
+	// be built with the same list (IndexResponsesIgnoring)
+	IgnoreFields []string
+
+	// CursorResponses enables cursor-id rewriting (command mode only; nil = off): the
+	// recorded cursor id of each cursor-opening command, looked up in this index, is paired
+	// with the id the target returns, and later getMore/killCursors are sent with the live
+	// id. If the sender implements SessionCommandSender, each recorded session's commands
+	// run in their own logical session. Cursors whose opening command was not replayed
+	// keep their recorded id and fail on the target (counted in Stats.CursorsUnmapped).
+	// Live cursors still open when the replay ends are killed (Stats.CursorsLeaked).
+	CursorResponses ResponseIndex
+
+	// SummaryOnly suppresses per-op output lines (failures too, unless ShowFailures is set)
+	SummaryOnly bool
+
+	// ShowFailures keeps per-op failure lines when SummaryOnly is set
+	ShowFailures bool
+
+	// Output receives per-op progress lines (nil discards them)
+	Output io.Writer
+
+	// ScriptOutput receives the mongosh representation of each command as it is sent,
+	// in script-gen's format (command mode only; nil = off)
+	ScriptOutput io.Writer
+
+	// ScriptFailuresOnly limits ScriptOutput to commands that failed
+	ScriptFailuresOnly bool
+
+	// OnResult, if set, is called after each op is sent, in addition to the Stats counters
+	// Dry-run ops report a nil result. Ops that fail before or during the send (transform
+	// or send errors) report the error, as do responses that don't match the recording
+	// (with RecordedResponses). Raw-mode results carry the response document only when
+	// the response is read (with RecordedResponses).
+	OnResult func(packet *reader.Packet, res *sender.Result, err error)
+
+	// RecordResponses, if set, receives each request sent together with the target's
+	// response to it, turning the replay into a new recording of the target's behavior
+	// (raw mode only; responses are read, which requires a RawResponseSender). Requests
+	// that fail or are resent by DecompressFallback are not recorded.
+	RecordResponses PacketWriter
+
+	// LiveStats, if set, is also counted into as ops are sent and packets skipped, by
+	// command name; unlike the returned Stats it can be read (Snapshot) during the run
+	LiveStats *ReplayStats
+}
+
+// Replayer drives the replay of recorded packets against a target
+type Replayer struct {
+	config Config
+	out    io.Writer
+	rng    *rand.Rand
+
+	// appNames maps each session seen so far to its recorded appName ("" if none yet)
+	appNames map[uint64]string
+
+	// appSenders caches the sender created for each appName
+	appSenders map[string]CommandSender
+
+	// cursors maps recorded cursor ids to the live cursors the target returned
+	cursors map[int64]openCursor
+
+	// targets are the destinations ops are routed over; target is the one chosen for
+	// the current packet and nextTarget the next round-robin choice
+	targets    []Target
+	target     int
+	nextTarget int
+
+	// current is the packet being dispatched, for ShowSession tags
+	current *reader.Packet
+
+	// report accumulates the current ReportInterval window
+	report intervalReport
+
+	// sessionsSeen records, for each session seen so far, whether SessionSample selected it
+	sessionsSeen map[uint64]bool
+
+	// timing is the simulated timeline for ShowTiming
+	timing timingPlan
+
+	// responsePacing pairs recorded requests and responses for PaceByResponse (nil = off)
+	responsePacing *responsePacing
+
+	// opErr is the error reported to notify for the current packet, for LiveStats
+	opErr error
+
+	// opStart is when the current packet was dispatched, for LiveStats latencies
+	opStart time.Time
+
+	// runStart is when Run started, for SkipPast and RecordResponses offsets
+	runStart time.Time
+
+	// recordOrder is the order number of the next packet written to RecordResponses
+	recordOrder uint64
+
+	// recordErr is the first error writing to RecordResponses, which stops the run
+	recordErr error
+
+	// filter combines Namespaces, RequestsOnly, UserOpsOnly and CommandFilter (nil = keep all)
+	filter reader.Filter
+}
+
+// New creates a Replayer from the given configuration
+func New(config Config) (*Replayer, error) {
+	if config.Mode == "" {
+		config.Mode = ModeRaw
+	}
+
+	if config.Mode != ModeRaw && config.Mode != ModeCommand {
+		return nil, fmt.Errorf("invalid mode '%s'. Must be 'raw' or 'command'", config.Mode)
+	}
+
+	targets, err := validateTargets(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.DecompressFallback && config.Mode != ModeRaw {
+		return nil, fmt.Errorf("decompress fallback requires raw mode")
+	}
+
+	switch config.OnDuplicate {
+	case "", DuplicateFail:
+	case DuplicateSkip, DuplicateUpsert:
+		if config.Mode != ModeCommand {
+			return nil, fmt.Errorf("duplicate handling requires command mode")
+		}
+	default:
+		return nil, fmt.Errorf("invalid duplicate policy '%s'. Must be '%s', '%s' or '%s'", config.OnDuplicate, DuplicateSkip, DuplicateUpsert, DuplicateFail)
+	}
+
+	switch config.BlockAggWrites {
+	case "":
+	case AggWritesSkip, AggWritesStrip:
+		if config.Mode != ModeCommand {
+			return nil, fmt.Errorf("blocking aggregate writes requires command mode")
+		}
+	default:
+		return nil, fmt.Errorf("invalid aggregate write policy '%s'. Must be '%s' or '%s'", config.BlockAggWrites, AggWritesSkip, AggWritesStrip)
+	}
+
+	if config.Shadow && config.Mode != ModeCommand {
+		return nil, fmt.Errorf("shadow mode requires command mode")
+	}
+
+	if config.ShowTiming && !config.DryRun {
+		return nil, fmt.Errorf("showing planned timing requires dry run")
+	}
+
+	if config.RecordResponses != nil && (config.Mode != ModeRaw || config.DryRun) {
+		return nil, fmt.Errorf("recording responses requires raw mode without dry run")
+	}
+
+	if config.CursorResponses != nil && config.Mode != ModeCommand {
+		return nil, fmt.Errorf("cursor-id rewriting requires command mode")
+	}
+
+	if config.MaxDuration < 0 {
+		return nil, fmt.Errorf("invalid max duration %v (must be >= 0)", config.MaxDuration)
+	}
+
+	if config.SessionSample < 0 || config.SessionSample > 1 {
+		return nil, fmt.Errorf("invalid session sample %v (must be between 0 and 1)", config.SessionSample)
+	}
+
+	if config.ReportInterval < 0 {
+		return nil, fmt.Errorf("invalid report interval %v (must be >= 0)", config.ReportInterval)
+	}
+
+	if config.Speed < 0 {
+		return nil, fmt.Errorf("invalid speed %v (must be >= 0)", config.Speed)
+	}
+
+	if !config.StartAt.IsZero() {
+		if config.Speed <= 0 {
+			return nil, fmt.Errorf("a start time requires paced replay (speed > 0)")
+		}
+		if config.ShowTiming {
+			return nil, fmt.Errorf("a start time cannot be combined with showing planned timing")
+		}
+	} else if config.SkipPast {
+		return nil, fmt.Errorf("skipping past-due ops requires a start time")
+	}
+
+	if config.PaceByResponse {
+		if config.Speed <= 0 {
+			return nil, fmt.Errorf("pacing by response requires paced replay (speed > 0)")
+		}
+		if config.ShowTiming {
+			return nil, fmt.Errorf("pacing by response cannot be combined with showing planned timing")
+		}
+	}
+
+	if config.InjectLatency < 0 || config.InjectJitter < 0 {
+		return nil, fmt.Errorf("injected latency and jitter must be >= 0")
+	}
+
+	out := config.Output
+	if out == nil {
+		out = io.Discard
+	}
+
+	r := &Replayer{
+		config:     config,
+		out:        out,
+		rng:        rand.New(rand.NewSource(config.Seed)),
+		appNames:   make(map[uint64]string),
+		appSenders: make(map[string]CommandSender),
+		cursors:    make(map[int64]openCursor),
+		targets:    targets,
+		filter:     packetFilter(config),
+
+		sessionsSeen: make(map[uint64]bool),
+	}
+	if config.PaceByResponse {
+		r.responsePacing = newResponsePacing()
+	}
+	return r, nil
+}
+
+// packetFilter combines the configured packet filters, or returns nil if there are none
+// Namespaces goes first since it must see every response to pair it with its request.
+func packetFilter(config Config) reader.Filter {
+	var filters []reader.Filter
+	if config.Namespaces != nil {
+		filters = append(filters, config.Namespaces)
+	}
+	if config.RequestsOnly {
+		filters = append(filters, reader.RequestsOnly())
+	}
+	if config.UserOpsOnly {
+		filters = append(filters, reader.UserOpsOnly())
+	}
+	if config.CommandFilter != nil {
+		filters = append(filters, config.CommandFilter)
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return reader.AllFilters(filters...)
+}
+
+// Config returns the replayer's configuration
+func (r *Replayer) Config() Config {
+	return r.config
+}
+
+// Run replays every packet from src and returns the replay statistics
+// The replay stops early, with the statistics so far, when ctx is cancelled or
+// MaxDuration passes. An error is returned only if reading from src or writing to
+// RecordResponses fails; send failures are counted in Stats.
+func (r *Replayer) Run(ctx context.Context, src PacketSource) (*Stats, error) {
+	stats := &Stats{Speed: r.config.Speed}
+	for _, target := range r.config.Targets {
+		stats.Targets = append(stats.Targets, TargetStats{Name: target.Name})
+	}
+	wallClockStart := time.Now()
+	r.runStart = wallClockStart
+	r.startReport(wallClockStart)
+	defer func() {
+		stats.Duration = time.Since(wallClockStart)
+		r.finishReport(time.Now())
+		if len(r.cursors) > 0 {
+			r.killOpenCursors(stats)
+		}
+		stats.Pool, _ = r.poolStats()
+	}()
+
+	// loopCtx bounds the loop and pacing; sends keep ctx so the deadline
+	// doesn't cut off an operation already in flight
+	loopCtx := ctx
+	if r.config.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		loopCtx, cancel = context.WithTimeout(ctx, r.config.MaxDuration)
+		defer cancel()
+	}
+
+	for {
+		if loopCtx.Err() != nil {
+			r.reportStop(ctx, stats)
+			return stats, nil
+		}
+
+		packet, err := src.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("error reading packet: %w", err)
+		}
+
+		stats.TotalPackets++
+
+		// Handshakes are usually filtered out below, so note appNames first
+		if r.config.Mode == ModeCommand && r.config.CommandSenderForApp != nil {
+			r.noteAppName(packet)
+		}
+		// Likewise responses, which RequestsOnly drops
+		if r.responsePacing != nil {
+			r.responsePacing.note(packet)
+		}
+
+		// Apply filters
+		if r.config.SessionSample > 0 && !r.sampleSession(stats, packet.SessionID) {
+			r.skip(stats)
+			continue
+		}
+
+		if r.filter != nil && !r.filter.Keep(packet) {
+			r.skip(stats)
+			continue
+		}
+
+		var cmd *sender.Command
+		if r.config.Mode == ModeCommand {
+			cmd, err = sender.ExtractCommandWithOptions(packet, sender.ExtractOptions{
+				PreserveRetryableWrites: r.config.PreserveRetryableWrites,
+				DefaultDatabase:         r.config.DefaultDatabase,
+			})
+			if err != nil {
+				// Skip packets that can't be parsed
+				r.skip(stats)
+				continue
+			}
+			if r.config.Shadow && !r.shadowRead(stats, packet, cmd) {
+				r.skip(stats)
+				continue
+			}
+			if r.config.BlockAggWrites != "" && r.blockAggWrite(stats, cmd) {
+				r.skip(stats)
+				continue
+			}
+		} else if len(packet.Message) == 0 {
+			// No wire message to send
+			r.skip(stats)
+			continue
+		}
+
+		// Check limit
+		if r.config.Limit > 0 && stats.Ops() >= r.config.Limit {
+			fmt.Fprintf(r.out, "\nReached limit of %d operations\n", r.config.Limit)
+			return stats, nil
+		}
+
+		if r.config.SkipPast && r.scheduledAt(packet).Before(r.runStart) {
+			stats.PastDueSkipped++
+			r.skip(stats)
+			continue
+		}
+
+		driftNote := ""
+		if r.config.ShowTiming {
+			driftNote = r.planTiming(stats, packet)
+		} else {
+			drift := r.pace(loopCtx, stats, packet)
+			if loopCtx.Err() != nil {
+				// Stopped while waiting for the packet's turn; it is not sent
+				r.reportStop(ctx, stats)
+				return stats, nil
+			}
+			if r.config.ShowDrift && r.config.Speed > 0 {
+				driftNote = formatDrift(drift)
+			}
+
+			r.injectDelay(stats)
+		}
+
+		r.current = packet
+		if r.config.WarnEncrypted {
+			r.warnEncrypted(stats, packet, cmd)
+		}
+		r.target = r.route(packet)
+		before := *stats
+		r.opErr = nil
+		r.opStart = time.Now()
+		if r.config.Mode == ModeCommand {
+			r.sendCommand(stats, cmd, driftNote)
+		} else {
+			r.sendRaw(ctx, stats, packet, driftNote)
+		}
+		r.countTarget(stats, before)
+		if r.responsePacing != nil && packet.IsRequest() {
+			r.responsePacing.done(packet.SessionID, time.Now())
+		}
+		r.countInterval(stats, before, time.Since(r.opStart))
+		r.reportDue(time.Now())
+		if r.recordErr != nil {
+			return stats, fmt.Errorf("failed to record response: %w", r.recordErr)
+		}
+
+		// Track timing for last processed operation
+		stats.LastOffset = packet.Offset
+		stats.ReplayEnd = time.Now()
+	}
+}
+
+// sampleSession reports whether SessionSample selected a session, counting each
+// distinct session once in Stats
+func (r *Replayer) sampleSession(stats *Stats, sessionID uint64) bool {
+	if selected, seen := r.sessionsSeen[sessionID]; seen {
+		return selected
+	}
+	selected := reader.SessionSampled(sessionID, r.config.SessionSample)
+	r.sessionsSeen[sessionID] = selected
+	stats.SessionsSeen++
+	if selected {
+		stats.SessionsSampled++
+	}
+	return selected
+}
+
+// pace sleeps until the packet's recorded offset (scaled by speed) and returns the drift
+// With PaceByResponse, a request whose session has a recorded response waits out its
+// think time after the session's previous request instead (see responsePacing).
+// Drift is how far the replay lags the recorded timeline at dispatch;
+// a steadily growing drift means the target can't keep up. The sleep ends early if ctx is done.
+func (r *Replayer) pace(ctx context.Context, stats *Stats, packet *reader.Packet) time.Duration {
+	// Fast-forward mode: no delays
+	if r.config.Speed <= 0 {
+		return 0
+	}
+
+	if stats.ReplayStart.IsZero() {
+		if r.config.StartAt.IsZero() {
+			stats.ReplayStart = time.Now()
+			stats.FirstOffset = packet.Offset
+			return 0
+		}
+		// Offsets count from the capture start, which StartAt stands in for
+		stats.ReplayStart = r.config.StartAt
+	}
+
+	if r.responsePacing != nil {
+		if due, ok := r.responsePacing.due(packet, r.config.Speed); ok {
+			if !r.sleepUntil(ctx, due) {
+				return 0
+			}
+			drift := time.Since(due)
+			if drift > stats.MaxDrift {
+				stats.MaxDrift = drift
+			}
+			return drift
+		}
+	}
+
+	// Calculate target time based on recording offset
+	elapsedInRecording := packet.Offset - stats.FirstOffset // microseconds
+	targetElapsed := time.Duration(float64(elapsedInRecording)/r.config.Speed) * time.Microsecond
+	targetTime := stats.ReplayStart.Add(targetElapsed)
+
+	// Sleep until target time (if we're ahead of schedule)
+	if !r.sleepUntil(ctx, targetTime) {
+		return 0
+	}
+
+	drift := time.Since(stats.ReplayStart) - targetElapsed
+	if drift > stats.MaxDrift {
+		stats.MaxDrift = drift
+	}
+	return drift
+}
+
+// scheduledAt returns the wall-clock time a packet is due at with StartAt set
+func (r *Replayer) scheduledAt(packet *reader.Packet) time.Time {
+	return r.config.StartAt.Add(time.Duration(float64(packet.Offset)/r.config.Speed) * time.Microsecond)
+}
+
+// reportStop notes why the replay stopped early: ctx was cancelled, or MaxDuration passed
+func (r *Replayer) reportStop(ctx context.Context, stats *Stats) {
+	stats.Stopped = true
+	if ctx.Err() != nil {
+		fmt.Fprintf(r.out, "\nReplay interrupted\n")
+		return
+	}
+	fmt.Fprintf(r.out, "\nReached max duration of %v\n", r.config.MaxDuration)
+}
+
+// injectDelay sleeps for the configured artificial latency plus jitter
+// Pacing targets the recorded timeline, so an injected delay shows up as drift and
+// is absorbed by later gaps in the recording; it only accumulates once it exceeds them
+func (r *Replayer) injectDelay(stats *Stats) {
+	delay := r.config.InjectLatency
+	if r.config.InjectJitter > 0 {
+		delay += time.Duration(r.rng.Int63n(int64(2*r.config.InjectJitter)+1)) - r.config.InjectJitter
+	}
+	if delay <= 0 {
+		return
+	}
+
+	time.Sleep(delay)
+	stats.InjectedDelay += delay
+}
+
+// sendRaw sends (or in dry-run mode, just reports) a raw wire message
+func (r *Replayer) sendRaw(ctx context.Context, stats *Stats, packet *reader.Packet, driftNote string) {
+	if r.config.DryRun {
+		cmd := packet.ExtractCommandName()
+		db := packet.ExtractDatabase()
+		r.logOp("[DRY RUN] %s.%s (raw wire message, %d bytes)%s\n", db, cmd, len(packet.Message), driftNote)
+		r.notify(packet, nil, nil)
+		r.succeeded(stats)
+		return
+	}
+
+	var result *sender.RawResult
+	var err error
+	rawSender := r.targets[r.target].RawSender
+	sent := time.Now()
+	if r.readsResponses() {
+		result, err = rawSender.(RawResponseSender).SendRawWireMessageWithResponse(ctx, packet.Message)
+	} else {
+		result, err = rawSender.SendRawWireMessage(ctx, packet.Message)
+	}
+	if err != nil && r.canFallBack(packet) {
+		r.sendDecompressed(stats, packet, driftNote, err)
+		return
+	}
+	if err != nil {
+		cmd := packet.ExtractCommandName()
+		db := packet.ExtractDatabase()
+		r.notify(packet, nil, err)
+		r.logFailure("❌ FAILED: %s.%s - %v\n", db, cmd, err)
+		r.failed(stats)
+		return
+	}
+	r.recordExchange(stats, packet, sent, result)
+
+	var diffs []string
+	if r.config.RecordedResponses != nil {
+		live, err := summarizeMessage(result.ResponseBytes, r.config.ResponseFields, r.config.IgnoreFields)
+		if err != nil {
+			live = &ResponseSummary{DocCount: -1}
+		}
+		diffs = r.assertResponse(stats, packet, live)
+	}
+	var res *sender.Result
+	if r.config.OnResult != nil {
+		res = rawResult(result)
+	}
+	r.notify(packet, res, mismatchError(diffs))
+	if len(diffs) > 0 {
+		r.logFailure("❌ RESPONSE MISMATCH: %s.%s - %s\n", packet.ExtractDatabase(), packet.ExtractCommandName(), strings.Join(diffs, "; "))
+		r.failed(stats)
+		return
+	}
+
+	r.logOp("✓ %s (reqID=%d, took %v)%s\n", result.OpCode.String(), result.RequestID, result.Duration, driftNote)
+	r.succeeded(stats)
+}
+
+// sendCommand applies transforms and sends (or in dry-run mode, just reports) a command
+func (r *Replayer) sendCommand(stats *Stats, cmd *sender.Command, driftNote string) {
+	for _, transform := range r.config.Transforms {
+		if err := transform(cmd); err != nil {
+			// Failed before the echo point below, so echo here in every-op mode
+			if !r.config.ScriptFailuresOnly {
+				r.echoScript(cmd)
+			}
+			r.notify(r.current, nil, err)
+			r.commandFailed(stats, cmd, "❌ TRANSFORM FAILED: %s.%s - %v\n", cmd.Database, cmd.Name, err)
+			return
+		}
+	}
+
+	if !r.config.ScriptFailuresOnly {
+		r.echoScript(cmd)
+	}
+
+	if r.config.DryRun {
+		r.logOp("[DRY RUN] %s.%s%s\n", cmd.Database, cmd.Name, driftNote)
+		r.notify(r.current, nil, nil)
+		r.succeeded(stats)
+		return
+	}
+
+	snd, err := r.commandSender(cmd)
+	if err != nil {
+		r.notify(r.current, nil, err)
+		r.commandFailed(stats, cmd, "❌ FAILED: %s.%s - %v\n", cmd.Database, cmd.Name, err)
+		return
+	}
+
+	var recordedCursors []int64
+	if r.config.CursorResponses != nil {
+		recordedCursors = r.rewriteCursorIDs(stats, cmd)
+	}
+
+	result, err := r.send(snd, cmd)
+	if err != nil {
+		r.notify(r.current, result, err)
+		r.commandFailed(stats, cmd, "❌ FAILED: %s.%s - %v\n", cmd.Database, cmd.Name, err)
+		return
+	}
+
+	if r.config.CursorResponses != nil {
+		r.noteCursor(stats, snd, cmd, recordedCursors, result.Response)
+	}
+
+	// ok=0 is acceptable when asserting if the recorded response was ok=0 too
+	asserted := r.config.RecordedResponses != nil && cmd.OriginalPacket != nil
+	var diffs []string
+	if asserted {
+		diffs = r.assertResponse(stats, cmd.OriginalPacket, summarizeResponse(result.Response, r.config.ResponseFields, r.config.IgnoreFields))
+	}
+	r.notify(r.current, result, mismatchError(diffs))
+	if len(diffs) > 0 {
+		r.commandFailed(stats, cmd, "❌ RESPONSE MISMATCH: %s.%s - %s\n", cmd.Database, cmd.Name, strings.Join(diffs, "; "))
+		return
+	}
+	if !asserted && !result.IsOK() {
+		r.commandFailed(stats, cmd, "⚠️  WARNING: %s.%s - ok=0 (took %v)\n", cmd.Database, cmd.Name, result.Duration)
+		return
+	}
+
+	writeErrors := result.WriteErrors()
+	if len(writeErrors) > 0 && r.handlesDuplicates(cmd) {
+		writeErrors, err = r.resolveDuplicates(stats, snd, cmd, writeErrors)
+		if err != nil {
+			r.commandFailed(stats, cmd, "❌ FAILED: %s.%s - %v\n", cmd.Database, cmd.Name, err)
+			return
+		}
+	}
+
+	if len(writeErrors) > 0 {
+		statements := batchSize(cmd.Document)
+		if !r.config.TolerateWriteErrors || (statements > 0 && len(writeErrors) >= statements) {
+			r.commandFailed(stats, cmd, "❌ WRITE ERRORS: %s.%s - %d of %d statements failed (took %v)\n",
+				cmd.Database, cmd.Name, len(writeErrors), statements, result.Duration)
+			r.logWriteErrors(writeErrors)
+			return
+		}
+		r.logFailure("⚠️  PARTIAL WRITE: %s.%s - %d of %d statements failed (took %v)%s\n",
+			cmd.Database, cmd.Name, len(writeErrors), statements, result.Duration, driftNote)
+		r.logWriteErrors(writeErrors)
+		stats.PartialWrites++
+		r.succeeded(stats)
+		return
+	}
+
+	r.logOp("✓ %s.%s (took %v)%s\n", cmd.Database, cmd.Name, result.Duration, driftNote)
+	r.succeeded(stats)
+}
+
+// logWriteErrors writes one line per failed statement of a write batch
+func (r *Replayer) logWriteErrors(writeErrors []sender.WriteError) {
+	for _, e := range writeErrors {
+		r.logFailure("    writeErrors%s\n", e)
+	}
+}
+
+// batchSize returns the number of statements in an insert/update/delete command
+// (0 if it has no statement array)
+func batchSize(doc bson.M) int {
+	for _, field := range []string{"documents", "updates", "deletes"} {
+		if statements, ok := doc[field].(bson.A); ok {
+			return len(statements)
+		}
+	}
+	return 0
+}
+
+// notify passes an op's outcome to the OnResult callback, if set
+func (r *Replayer) notify(packet *reader.Packet, res *sender.Result, err error) {
+	if err != nil {
+		r.opErr = err
+	}
+	if r.config.OnResult != nil {
+		r.config.OnResult(packet, res, err)
+	}
+}
+
+// mismatchError reports the differences found by assertResponse as an error, or nil
+// when there are none
+func mismatchError(diffs []string) error {
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("response mismatch: %s", strings.Join(diffs, "; "))
+}
+
+// rawResult adapts a raw send result for OnResult, decoding the response if it was read
+func rawResult(raw *sender.RawResult) *sender.Result {
+	res := &sender.Result{Success: raw.Success, Error: raw.Error, Duration: raw.Duration}
+	if len(raw.ResponseBytes) == 0 {
+		return res
+	}
+	if doc, err := decodeResponse(raw.ResponseBytes); err == nil {
+		var m bson.M
+		if bson.Unmarshal(doc, &m) == nil {
+			res.Response = m
+		}
+	}
+	return res
+}
+
+// commandFailed logs a failed command, counts it, and echoes its script if only failures are echoed
+func (r *Replayer) commandFailed(stats *Stats, cmd *sender.Command, format string, args ...interface{}) {
+	r.logFailure(format, args...)
+	r.failed(stats)
+	if r.config.ScriptFailuresOnly {
+		r.echoScript(cmd)
+	}
+}
+
+// echoScript writes the command's mongosh representation to ScriptOutput, if set
+func (r *Replayer) echoScript(cmd *sender.Command) {
+	if r.config.ScriptOutput == nil {
+		return
+	}
+	fmt.Fprintf(r.config.ScriptOutput, "%s\n\n", scriptgen.GenerateCommand(cmd))
+}
+
+// noteAppName records a session's appName from its metadata or handshake request
+// Metadata is parsed on the session's first packet; handshakes are checked until a name is found
+func (r *Replayer) noteAppName(packet *reader.Packet) {
+	name, seen := r.appNames[packet.SessionID]
+	if name != "" {
+		return
+	}
+
+	if !seen {
+		r.appNames[packet.SessionID] = ""
+		if meta, err := packet.ParseSessionMetadata(); err == nil && meta.AppName != "" {
+			r.appNames[packet.SessionID] = meta.AppName
+			return
+		}
+	}
+
+	if packet.IsRequest() {
+		if name := sender.HandshakeAppName(packet); name != "" {
+			r.appNames[packet.SessionID] = name
+		}
+	}
+}
+
+// commandSender returns the sender for a command: the one for its session's appName
+// if CommandSenderForApp is set and the appName is known, else the routed target's
+func (r *Replayer) commandSender(cmd *sender.Command) (CommandSender, error) {
+	if r.config.CommandSenderForApp == nil || cmd.OriginalPacket == nil {
+		return r.targets[r.target].CommandSender, nil
+	}
+
+	name := r.appNames[cmd.OriginalPacket.SessionID]
+	if name == "" {
+		return r.targets[r.target].CommandSender, nil
+	}
+
+	if snd, ok := r.appSenders[name]; ok {
+		return snd, nil
+	}
+	snd, err := r.config.CommandSenderForApp(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sender for appName %q: %w", name, err)
+	}
+	r.appSenders[name] = snd
+	return snd, nil
+}
+
+// assertResponse compares a live response with the recorded response to the same request
+// Requests without a recorded response are counted as unpaired and pass
+func (r *Replayer) assertResponse(stats *Stats, packet *reader.Packet, live *ResponseSummary) []string {
+	recorded, ok := r.config.RecordedResponses.Lookup(packet)
+	if !ok {
+		stats.ResponsesUnpaired++
+		return nil
+	}
+
+	stats.ResponsesCompared++
+	diffs := recorded.Compare(live)
+	if len(diffs) > 0 {
+		stats.ResponseMismatches++
+	}
+	for _, diff := range diffs {
+		if strings.HasPrefix(diff, orderDiffPrefix) {
+			stats.OrderMismatches++
+		}
+	}
+	return diffs
+}
+
+// logOp writes a per-op progress line unless output is summary-only
+func (r *Replayer) logOp(format string, args ...interface{}) {
+	if r.config.SummaryOnly {
+		return
+	}
+	r.writeOpLine(format, args...)
+}
+
+// logFailure writes a per-op failure line unless output is summary-only without ShowFailures
+func (r *Replayer) logFailure(format string, args ...interface{}) {
+	if r.config.SummaryOnly && !r.config.ShowFailures {
+		return
+	}
+	r.writeOpLine(format, args...)
+}
+
+// writeOpLine writes a per-op line, tagged with the packet's session if ShowSession is set
+func (r *Replayer) writeOpLine(format string, args ...interface{}) {
+	if r.config.ShowSession && r.current != nil {
+		fmt.Fprintf(r.out, "[session=%d] ", r.current.SessionID)
+	}
+	fmt.Fprintf(r.out, format, args...)
+}
+
+// formatDrift renders a per-op drift annotation (positive = behind the recorded timeline)
+func formatDrift(drift time.Duration) string {
+	sign := "+"
+	if drift < 0 {
+		sign = "-"
+		drift = -drift
+	}
+	return fmt.Sprintf(" [drift %s%v]", sign, drift.Round(time.Microsecond))
+}
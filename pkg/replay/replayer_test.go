@@ -0,0 +1,742 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// buildOpMsg creates an OP_MSG wire message with a single kind-0 body section
+func buildOpMsg(t *testing.T, requestID, responseTo int32, doc bson.D) []byte {
+	t.Helper()
+
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+4+1+len(body)))
+	binary.Write(buf, binary.LittleEndian, requestID)
+	binary.Write(buf, binary.LittleEndian, responseTo)
+	binary.Write(buf, binary.LittleEndian, int32(2013))
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // flags
+	buf.WriteByte(0)                                  // section kind 0
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// buildCommandPacket creates a request packet carrying the given command document
+func buildCommandPacket(t *testing.T, sessionID, offset uint64, doc bson.D) *reader.Packet {
+	t.Helper()
+	return &reader.Packet{
+		SessionID: sessionID,
+		Offset:    offset,
+		Message:   buildOpMsg(t, 1, 0, doc),
+	}
+}
+
+// sliceSource is a PacketSource backed by a slice
+type sliceSource struct {
+	packets []*reader.Packet
+	idx     int
+}
+
+func (s *sliceSource) Next() (*reader.Packet, error) {
+	if s.idx >= len(s.packets) {
+		return nil, io.EOF
+	}
+	p := s.packets[s.idx]
+	s.idx++
+	return p, nil
+}
+
+// recordingCommandSender records every command it is asked to send
+type recordingCommandSender struct {
+	databases []string
+	commands  []bson.M
+}
+
+func (s *recordingCommandSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	s.databases = append(s.databases, database)
+	s.commands = append(s.commands, command)
+	return &sender.Result{Success: true, Response: bson.M{"ok": 1.0}}, nil
+}
+
+func TestNew_Validation(t *testing.T) {
+	if _, err := New(Config{Mode: ModeRaw}); err == nil {
+		t.Error("expected error for raw mode without a RawSender")
+	}
+	if _, err := New(Config{Mode: ModeCommand}); err == nil {
+		t.Error("expected error for command mode without a CommandSender")
+	}
+	if _, err := New(Config{Mode: "bogus", DryRun: true}); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+	if _, err := New(Config{DryRun: true, Speed: -1}); err == nil {
+		t.Error("expected error for negative speed")
+	}
+	r, err := New(Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if r.Config().Mode != ModeRaw {
+		t.Errorf("default mode = %v, want %v", r.Config().Mode, ModeRaw)
+	}
+}
+
+func TestRun_CommandModeCounts(t *testing.T) {
+	snd := &recordingCommandSender{}
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+		{SessionID: 1, Offset: 10}, // empty message, unparseable
+		buildCommandPacket(t, 1, 20, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if stats.TotalPackets != 3 || stats.SkippedPackets != 1 || stats.SuccessfulOps != 2 || stats.FailedOps != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(snd.commands) != 2 {
+		t.Fatalf("sent %d commands, want 2", len(snd.commands))
+	}
+}
+
+func TestRun_Limit(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{Mode: ModeCommand, DryRun: true, Limit: 1, Output: &out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "a"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "b"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Ops() != 1 {
+		t.Errorf("Ops() = %d, want 1", stats.Ops())
+	}
+	if !strings.Contains(out.String(), "Reached limit of 1 operations") {
+		t.Errorf("expected limit message in output, got %q", out.String())
+	}
+}
+
+func TestRun_ExcludeNamespace(t *testing.T) {
+	namespaces, err := reader.NewNamespaceFilter(nil, []string{"app.events"})
+	if err != nil {
+		t.Fatalf("NewNamespaceFilter failed: %v", err)
+	}
+	snd := &recordingCommandSender{}
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Namespaces: namespaces})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "insert", Value: "events"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.SkippedPackets != 1 || len(snd.commands) != 1 || snd.commands[0]["insert"] != "users" {
+		t.Errorf("skipped %d, sent %v; want only the users insert sent", stats.SkippedPackets, snd.commands)
+	}
+}
+
+func TestRun_CommandFilterCRUDOnly(t *testing.T) {
+	crud, err := reader.CategoryFilter("crud", "read")
+	if err != nil {
+		t.Fatalf("CategoryFilter failed: %v", err)
+	}
+	snd := &recordingCommandSender{}
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, CommandFilter: crud})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "admin"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "createIndexes", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.SkippedPackets != 2 || len(snd.commands) != 2 || snd.commands[0]["insert"] != "users" || snd.commands[1]["find"] != "users" {
+		t.Errorf("skipped %d, sent %v; want only the insert and find sent", stats.SkippedPackets, snd.commands)
+	}
+}
+
+func TestRun_TransformReachesSentCommand(t *testing.T) {
+	snd := &recordingCommandSender{}
+	hook := func(cmd *sender.Command) error {
+		cmd.Database = "rewritten"
+		cmd.Document["hint"] = bson.M{"_id": 1}
+		return nil
+	}
+
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Transforms: []TransformFunc{hook}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+	if _, err := r.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(snd.commands) != 1 {
+		t.Fatalf("sent %d commands, want 1", len(snd.commands))
+	}
+	if snd.databases[0] != "rewritten" {
+		t.Errorf("database = %q, want %q", snd.databases[0], "rewritten")
+	}
+	if _, ok := snd.commands[0]["hint"]; !ok {
+		t.Errorf("hint injected by transform missing from sent command: %v", snd.commands[0])
+	}
+}
+
+func TestRun_TransformErrorFailsOp(t *testing.T) {
+	snd := &recordingCommandSender{}
+	hook := func(cmd *sender.Command) error {
+		return errors.New("boom")
+	}
+
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Transforms: []TransformFunc{hook}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if stats.FailedOps != 1 {
+		t.Errorf("FailedOps = %d, want 1", stats.FailedOps)
+	}
+	if len(snd.commands) != 0 {
+		t.Errorf("command was sent despite transform error")
+	}
+}
+
+func TestRun_SummaryOnly(t *testing.T) {
+	failing := func(cmd *sender.Command) error {
+		if cmd.Document["find"] == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	packets := func() PacketSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "good"}, {Key: "$db", Value: "app"}}),
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "bad"}, {Key: "$db", Value: "app"}}),
+		}}
+	}
+
+	tests := []struct {
+		name         string
+		showFailures bool
+		wantFailure  bool
+	}{
+		{"suppresses everything", false, false},
+		{"keeps failures with ShowFailures", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			r, err := New(Config{
+				Mode:         ModeCommand,
+				DryRun:       true,
+				SummaryOnly:  true,
+				ShowFailures: tt.showFailures,
+				Transforms:   []TransformFunc{failing},
+				Output:       &out,
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			stats, err := r.Run(context.Background(), packets())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if stats.SuccessfulOps != 1 || stats.FailedOps != 1 {
+				t.Errorf("unexpected stats: %+v", stats)
+			}
+
+			if strings.Contains(out.String(), "[DRY RUN]") {
+				t.Errorf("per-op success line printed in summary-only mode: %q", out.String())
+			}
+			if got := strings.Contains(out.String(), "FAILED"); got != tt.wantFailure {
+				t.Errorf("failure line printed = %v, want %v (output %q)", got, tt.wantFailure, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_InjectedLatencyIsSeeded(t *testing.T) {
+	run := func(seed int64) time.Duration {
+		r, err := New(Config{
+			Mode:          ModeCommand,
+			DryRun:        true,
+			InjectLatency: 100 * time.Microsecond,
+			InjectJitter:  50 * time.Microsecond,
+			Seed:          seed,
+		})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+
+		var packets []*reader.Packet
+		for i := 0; i < 5; i++ {
+			packets = append(packets, buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}))
+		}
+		stats, err := r.Run(context.Background(), &sliceSource{packets: packets})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return stats.InjectedDelay
+	}
+
+	first := run(42)
+	if first < 5*50*time.Microsecond || first > 5*150*time.Microsecond {
+		t.Errorf("InjectedDelay = %v, want within [250µs, 750µs]", first)
+	}
+	if second := run(42); second != first {
+		t.Errorf("same seed gave %v then %v", first, second)
+	}
+}
+
+func TestRun_CommandSenderForApp(t *testing.T) {
+	fromMetadata := buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})
+	fromMetadata.SessionMetadata = `{ remote: "10.0.0.1:5000", appName: "orders-svc" }`
+
+	handshake := buildCommandPacket(t, 2, 0, bson.D{
+		{Key: "hello", Value: int32(1)},
+		{Key: "client", Value: bson.D{{Key: "application", Value: bson.D{{Key: "name", Value: "billing"}}}}},
+		{Key: "$db", Value: "admin"},
+	})
+
+	src := &sliceSource{packets: []*reader.Packet{
+		fromMetadata,
+		handshake,
+		buildCommandPacket(t, 2, 0, bson.D{{Key: "insert", Value: "invoices"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 3, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	defaultSender := &recordingCommandSender{}
+	appSenders := make(map[string]*recordingCommandSender)
+	r, err := New(Config{
+		Mode:          ModeCommand,
+		CommandSender: defaultSender,
+		CommandSenderForApp: func(appName string) (CommandSender, error) {
+			if _, ok := appSenders[appName]; ok {
+				t.Errorf("sender for %q created twice", appName)
+			}
+			appSenders[appName] = &recordingCommandSender{}
+			return appSenders[appName], nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, err := r.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := map[string]int{"orders-svc": 1, "billing": 2}
+	for name, count := range want {
+		if snd := appSenders[name]; snd == nil || len(snd.commands) != count {
+			t.Errorf("appName %q: sender %v, want %d commands", name, snd, count)
+		}
+	}
+	if len(defaultSender.commands) != 1 {
+		t.Errorf("default sender got %d commands, want 1 (session without appName)", len(defaultSender.commands))
+	}
+}
+
+func TestRun_EchoScript(t *testing.T) {
+	failing := func(cmd *sender.Command) error {
+		if cmd.Document["find"] == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	packets := func() PacketSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "good"}, {Key: "$db", Value: "app"}}),
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "bad"}, {Key: "$db", Value: "app"}}),
+		}}
+	}
+
+	tests := []struct {
+		name         string
+		failuresOnly bool
+		wantGood     bool
+	}{
+		{"every op", false, true},
+		{"failures only", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var script strings.Builder
+			r, err := New(Config{
+				Mode:               ModeCommand,
+				DryRun:             true,
+				Transforms:         []TransformFunc{failing},
+				ScriptOutput:       &script,
+				ScriptFailuresOnly: tt.failuresOnly,
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			if _, err := r.Run(context.Background(), packets()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if !strings.Contains(script.String(), `db.getSiblingDB("app").bad.find({});`) {
+				t.Errorf("failed op not echoed: %q", script.String())
+			}
+			if got := strings.Contains(script.String(), `.good.find(`); got != tt.wantGood {
+				t.Errorf("successful op echoed = %v, want %v (script %q)", got, tt.wantGood, script.String())
+			}
+		})
+	}
+}
+
+func TestRun_MaxDuration(t *testing.T) {
+	snd := &recordingCommandSender{}
+	var out strings.Builder
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Speed: 1, MaxDuration: 50 * time.Millisecond, Output: &out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// The second packet is due 10s into the replay, well past the deadline
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 10_000_000, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+
+	start := time.Now()
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run took %v, want it to stop near the 50ms deadline", elapsed)
+	}
+
+	if !stats.Stopped || stats.SuccessfulOps != 1 || len(snd.commands) != 1 {
+		t.Errorf("unexpected stats: %+v (sent %d)", stats, len(snd.commands))
+	}
+	if !strings.Contains(out.String(), "Reached max duration") {
+		t.Errorf("missing max duration note: %q", out.String())
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{Mode: ModeCommand, DryRun: true, Output: &out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stats, err := r.Run(ctx, &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !stats.Stopped || stats.Ops() != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if !strings.Contains(out.String(), "interrupted") {
+		t.Errorf("missing interrupt note: %q", out.String())
+	}
+}
+
+func TestRun_ShowSession(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{Mode: ModeCommand, DryRun: true, ShowSession: true, Output: &out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 7, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 9, 0, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}}
+	if _, err := r.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, want := range []string{"[session=7] [DRY RUN] app.find", "[session=9] [DRY RUN] app.insert"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q: %q", want, out.String())
+		}
+	}
+}
+
+// failingCommandSender fails every command named in fail and succeeds on the rest
+type failingCommandSender struct {
+	fail string
+}
+
+func (s *failingCommandSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	if _, ok := command[s.fail]; ok {
+		return nil, errors.New("connection reset")
+	}
+	return &sender.Result{Success: true, Response: bson.M{"ok": 1.0}}, nil
+}
+
+func TestRun_OnResult(t *testing.T) {
+	type outcome struct {
+		session uint64
+		res     *sender.Result
+		err     error
+	}
+
+	src := func() *sliceSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}),
+			buildCommandPacket(t, 2, 0, bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{}}, {Key: "$db", Value: "app"}}),
+		}}
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		var results []outcome
+		onResult := func(packet *reader.Packet, res *sender.Result, err error) {
+			results = append(results, outcome{packet.SessionID, res, err})
+		}
+		r, err := New(Config{Mode: ModeCommand, CommandSender: &failingCommandSender{fail: "delete"}, OnResult: onResult})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if _, err := r.Run(context.Background(), src()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(results) != 2 {
+			t.Fatalf("OnResult called %d times, want 2", len(results))
+		}
+		if results[0].session != 1 || results[0].err != nil || results[0].res == nil || !results[0].res.IsOK() {
+			t.Errorf("find outcome = %+v, want an ok result from session 1", results[0])
+		}
+		if results[1].session != 2 || results[1].err == nil {
+			t.Errorf("delete outcome = %+v, want the send error from session 2", results[1])
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		var results []outcome
+		onResult := func(packet *reader.Packet, res *sender.Result, err error) {
+			results = append(results, outcome{packet.SessionID, res, err})
+		}
+		r, err := New(Config{Mode: ModeCommand, DryRun: true, OnResult: onResult})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if _, err := r.Run(context.Background(), src()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(results) != 2 {
+			t.Fatalf("OnResult called %d times, want 2", len(results))
+		}
+		for _, o := range results {
+			if o.res != nil || o.err != nil {
+				t.Errorf("dry-run outcome = %+v, want nil result and error", o)
+			}
+		}
+	})
+
+	t.Run("response mismatch", func(t *testing.T) {
+		find := func(reqID int32) *reader.Packet {
+			return &reader.Packet{SessionID: 1, Message: buildOpMsg(t, reqID, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})}
+		}
+		recording := []*reader.Packet{
+			find(1),
+			{SessionID: 1, Message: buildOpMsg(t, 101, 1, cursorResponse(1, 2))},
+		}
+		index, err := IndexResponses(&sliceSource{packets: recording}, 0)
+		if err != nil {
+			t.Fatalf("IndexResponses failed: %v", err)
+		}
+
+		configs := map[string]Config{
+			"command": {Mode: ModeCommand, CommandSender: &scriptedCommandSender{responses: []bson.M{{"cursor": bson.M{"firstBatch": bson.A{}}, "ok": 1.0}}}},
+			"raw":     {Mode: ModeRaw, RawSender: &respondingRawSender{t: t}},
+		}
+		for name, config := range configs {
+			t.Run(name, func(t *testing.T) {
+				var results []outcome
+				config.RequestsOnly = true
+				config.RecordedResponses = index
+				config.LiveStats = NewReplayStats()
+				config.OnResult = func(packet *reader.Packet, res *sender.Result, err error) {
+					results = append(results, outcome{packet.SessionID, res, err})
+				}
+				r, err := New(config)
+				if err != nil {
+					t.Fatalf("New failed: %v", err)
+				}
+				stats, err := r.Run(context.Background(), &sliceSource{packets: recording})
+				if err != nil {
+					t.Fatalf("Run failed: %v", err)
+				}
+
+				if len(results) != 1 {
+					t.Fatalf("OnResult called %d times, want 1", len(results))
+				}
+				if results[0].res == nil || results[0].err == nil || !strings.Contains(results[0].err.Error(), "response mismatch") {
+					t.Errorf("outcome = %+v, want the result and a mismatch error", results[0])
+				}
+				if stats.FailedOps != 1 || stats.ResponseMismatches != 1 {
+					t.Errorf("FailedOps=%d ResponseMismatches=%d, want 1 and 1", stats.FailedOps, stats.ResponseMismatches)
+				}
+				if c := config.LiveStats.Snapshot().Commands["find"]; c.Failures != 1 || !strings.Contains(c.LastError, "response mismatch") {
+					t.Errorf("live find stats = %+v, want one failure with the mismatch", c)
+				}
+			})
+		}
+	})
+}
+
+func TestRun_WriteErrors(t *testing.T) {
+	insert := bson.D{
+		{Key: "insert", Value: "users"},
+		{Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "_id", Value: 2}}, bson.D{{Key: "_id", Value: 3}}}},
+		{Key: "$db", Value: "app"},
+	}
+	writeErrors := func(n int) bson.M {
+		var errs bson.A
+		for i := 0; i < n; i++ {
+			errs = append(errs, bson.D{{Key: "index", Value: int32(i)}, {Key: "code", Value: int32(11000)}, {Key: "errmsg", Value: "E11000 duplicate key error"}})
+		}
+		return bson.M{"n": int32(3 - n), "writeErrors": errs, "ok": 1.0}
+	}
+
+	tests := []struct {
+		name        string
+		tolerate    bool
+		failed      int
+		wantSuccess int
+		wantPartial int
+	}{
+		{"fails by default", false, 1, 0, 0},
+		{"partial batch tolerated", true, 1, 1, 1},
+		{"whole batch failed", true, 3, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			snd := &scriptedCommandSender{responses: []bson.M{writeErrors(tt.failed)}}
+			r, err := New(Config{Mode: ModeCommand, CommandSender: snd, TolerateWriteErrors: tt.tolerate, Output: &out})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{buildCommandPacket(t, 1, 0, insert)}})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if stats.SuccessfulOps != tt.wantSuccess || stats.FailedOps != 1-tt.wantSuccess || stats.PartialWrites != tt.wantPartial {
+				t.Errorf("SuccessfulOps=%d FailedOps=%d PartialWrites=%d, want %d, %d, %d",
+					stats.SuccessfulOps, stats.FailedOps, stats.PartialWrites, tt.wantSuccess, 1-tt.wantSuccess, tt.wantPartial)
+			}
+			if !strings.Contains(out.String(), "code 11000") {
+				t.Errorf("individual write errors not logged: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestRun_SessionSample(t *testing.T) {
+	var packets []*reader.Packet
+	for op := 0; op < 3; op++ {
+		for session := uint64(1); session <= 200; session++ {
+			packets = append(packets, buildCommandPacket(t, session, 0,
+				bson.D{{Key: "find", Value: "users"}, {Key: "comment", Value: int64(session)}, {Key: "$db", Value: "app"}}))
+		}
+	}
+
+	snd := &recordingCommandSender{}
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, SessionSample: 0.25})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	stats, err := r.Run(context.Background(), &sliceSource{packets: packets})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Every selected session is replayed in full, and no other session at all
+	sent := make(map[int64]int)
+	for _, cmd := range snd.commands {
+		sent[cmd["comment"].(int64)]++
+	}
+	for session := uint64(1); session <= 200; session++ {
+		want := 0
+		if reader.SessionSampled(session, 0.25) {
+			want = 3
+		}
+		if sent[int64(session)] != want {
+			t.Errorf("session %d: sent %d ops, want %d", session, sent[int64(session)], want)
+		}
+	}
+
+	if stats.SessionsSeen != 200 || stats.SessionsSampled != len(sent) {
+		t.Errorf("sessions = %d sampled of %d, want %d of 200", stats.SessionsSampled, stats.SessionsSeen, len(sent))
+	}
+	if stats.SessionsSampled == 0 || stats.SessionsSampled == 200 {
+		t.Errorf("sampled %d of 200 sessions, want a fraction", stats.SessionsSampled)
+	}
+	if stats.SkippedPackets != 600-3*stats.SessionsSampled {
+		t.Errorf("SkippedPackets = %d, want %d", stats.SkippedPackets, 600-3*stats.SessionsSampled)
+	}
+}
@@ -0,0 +1,117 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// intervalReport accumulates the ops of the current ReportInterval window
+type intervalReport struct {
+	// start is when the replay started; windowStart when the current window opened
+	start       time.Time
+	windowStart time.Time
+
+	ops     int
+	failed  int
+	latency time.Duration
+
+	// pool holds the senders' pool stats when the window opened (see PoolStatsSender)
+	pool sender.PoolStats
+}
+
+// startReport opens the first report window
+func (r *Replayer) startReport(now time.Time) {
+	r.report = intervalReport{start: now, windowStart: now}
+	r.report.pool, _ = r.poolStats()
+}
+
+// countInterval adds one dispatched op, taking took, to the current window
+// The op's outcome is read from the counters that changed since before.
+func (r *Replayer) countInterval(stats *Stats, before Stats, took time.Duration) {
+	if r.config.ReportInterval <= 0 {
+		return
+	}
+	r.report.ops += stats.Ops() - before.Ops()
+	r.report.failed += stats.FailedOps - before.FailedOps
+	r.report.latency += took
+}
+
+// reportDue prints a line for every window that has ended by now, resetting the counters
+// Windows with no ops still get a line, so a stalled target shows up as ops=0.
+func (r *Replayer) reportDue(now time.Time) {
+	if r.config.ReportInterval <= 0 {
+		return
+	}
+	for !now.Before(r.report.windowStart.Add(r.config.ReportInterval)) {
+		r.printReport(r.config.ReportInterval)
+	}
+}
+
+// finishReport prints the final, partial window if any ops landed in it
+func (r *Replayer) finishReport(now time.Time) {
+	if r.config.ReportInterval <= 0 || r.report.ops == 0 {
+		return
+	}
+	r.printReport(now.Sub(r.report.windowStart))
+}
+
+// printReport prints the current window's line and opens the next window
+// Report lines are written even with SummaryOnly; they are meant for dashboards.
+func (r *Replayer) printReport(length time.Duration) {
+	w := &r.report
+	end := w.windowStart.Add(length)
+
+	rate, errPct := 0.0, 0.0
+	var avg time.Duration
+	if length > 0 {
+		rate = float64(w.ops) / length.Seconds()
+	}
+	if w.ops > 0 {
+		errPct = float64(w.failed) / float64(w.ops) * 100
+		avg = w.latency / time.Duration(w.ops)
+	}
+
+	poolNote := ""
+	pool, reportsPool := r.poolStats()
+	if reportsPool {
+		poolNote = formatPoolReport(w.pool, pool)
+	}
+
+	fmt.Fprintf(r.out, "REPORT t=%v interval=%v ops=%d ops/s=%.1f failed=%d error%%=%.1f avg=%v%s\n",
+		end.Sub(w.start).Round(time.Millisecond), length.Round(time.Millisecond),
+		w.ops, rate, w.failed, errPct, avg.Round(time.Microsecond), poolNote)
+
+	r.report = intervalReport{start: w.start, windowStart: end, pool: pool}
+}
+
+// sleepUntil sleeps until t, waking at report window boundaries to print due reports
+// Returns false if ctx is done first.
+func (r *Replayer) sleepUntil(ctx context.Context, t time.Time) bool {
+	for {
+		wake := t
+		if r.config.ReportInterval > 0 {
+			if due := r.report.windowStart.Add(r.config.ReportInterval); due.Before(wake) {
+				wake = due
+			}
+		}
+
+		if d := time.Until(wake); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			}
+		}
+
+		now := time.Now()
+		r.reportDue(now)
+		if !now.Before(t) {
+			return true
+		}
+	}
+}
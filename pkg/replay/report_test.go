@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRun_ReportInterval(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{
+		Mode:           ModeCommand,
+		CommandSender:  &failingCommandSender{fail: "delete"},
+		Speed:          1,
+		ReportInterval: 40 * time.Millisecond,
+		SummaryOnly:    true,
+		Output:         &out,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Two ops in the first window, none in the second, one failure in the third
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	del := bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{}}, {Key: "$db", Value: "app"}}
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, find),
+		buildCommandPacket(t, 1, 0, find),
+		buildCommandPacket(t, 1, 100_000, del),
+	}}
+	if _, err := r.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "REPORT ") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d report lines, want 3:\n%s", len(lines), out.String())
+	}
+
+	want := []string{"ops=2 ", "ops=0 ", "ops=1 "}
+	for i, line := range lines {
+		if !strings.Contains(line, want[i]) {
+			t.Errorf("report %d = %q, want %s", i, line, strings.TrimSpace(want[i]))
+		}
+	}
+	if !strings.Contains(lines[0], "interval=40ms") || !strings.Contains(lines[0], "failed=0 ") {
+		t.Errorf("first report = %q, want a full 40ms window without failures", lines[0])
+	}
+	if !strings.Contains(lines[2], "failed=1 error%=100.0") {
+		t.Errorf("last report = %q, want the failed delete", lines[2])
+	}
+}
+
+func TestNew_ReportIntervalValidation(t *testing.T) {
+	_, err := New(Config{Mode: ModeCommand, DryRun: true, ReportInterval: -time.Second})
+	if err == nil || !strings.Contains(err.Error(), "report interval") {
+		t.Errorf("New error = %v, want invalid report interval", err)
+	}
+}
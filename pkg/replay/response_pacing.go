@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// responsePacing tracks what PaceByResponse paces each session's requests against
+//
+// A closed-loop client sends a request, waits for the response, thinks, and sends the
+// next one. The think time is the recorded gap between a response and the session's next
+// request; replaying it after the live op completes keeps a slow target from being
+// sent requests the recorded client would still have been waiting to send.
+type responsePacing struct {
+	// lastRequest is the wire requestID of each session's latest recorded request
+	lastRequest map[uint64]int32
+
+	// lastResponse is the recorded offset of the response to a session's latest request
+	lastResponse map[uint64]uint64
+
+	// lastDone is when the replay finished sending each session's latest request
+	lastDone map[uint64]time.Time
+}
+
+func newResponsePacing() *responsePacing {
+	return &responsePacing{
+		lastRequest:  make(map[uint64]int32),
+		lastResponse: make(map[uint64]uint64),
+		lastDone:     make(map[uint64]time.Time),
+	}
+}
+
+// note pairs recorded responses with requests; it sees every packet, before filtering
+// Only a response to the session's latest request counts, since a closed-loop client
+// waits for that one before thinking about the next.
+func (p *responsePacing) note(packet *reader.Packet) {
+	if len(packet.Message) < reader.WireHeaderSize {
+		return
+	}
+	if packet.IsRequest() {
+		p.lastRequest[packet.SessionID] = int32(binary.LittleEndian.Uint32(packet.Message[4:8]))
+		return
+	}
+	responseTo := int32(binary.LittleEndian.Uint32(packet.Message[8:12]))
+	if last, ok := p.lastRequest[packet.SessionID]; ok && last == responseTo {
+		p.lastResponse[packet.SessionID] = packet.Offset
+	}
+}
+
+// done records when the replay finished sending a session's request
+func (p *responsePacing) done(sessionID uint64, at time.Time) {
+	p.lastDone[sessionID] = at
+}
+
+// due returns when a request is due: the replay's completion of the session's previous
+// request plus the recorded think time, scaled by speed. ok is false for requests with
+// no such anchor (the first of a session, or none of its responses seen yet), which
+// are paced by offset instead.
+func (p *responsePacing) due(packet *reader.Packet, speed float64) (at time.Time, ok bool) {
+	done, sent := p.lastDone[packet.SessionID]
+	response, answered := p.lastResponse[packet.SessionID]
+	if !sent || !answered {
+		return time.Time{}, false
+	}
+	var think uint64
+	if packet.Offset > response {
+		think = packet.Offset - response
+	}
+	return done.Add(time.Duration(float64(think)/speed) * time.Microsecond), true
+}
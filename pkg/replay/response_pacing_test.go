@@ -0,0 +1,105 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// slowCommandSender takes delay to send each command and records when each send started
+type slowCommandSender struct {
+	delay  time.Duration
+	starts []time.Time
+}
+
+func (s *slowCommandSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	s.starts = append(s.starts, time.Now())
+	time.Sleep(s.delay)
+	return &sender.Result{Success: true, Response: bson.M{"ok": 1.0}}, nil
+}
+
+func TestRun_PaceByResponse(t *testing.T) {
+	snd := &slowCommandSender{delay: 50 * time.Millisecond}
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Speed: 1, PaceByResponse: true, RequestsOnly: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// The recorded client thought for 40ms after the first response and 20ms after the
+	// second. Responses are dropped by RequestsOnly but still paired.
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	ok := bson.D{{Key: "ok", Value: 1.0}}
+	src := &sliceSource{packets: []*reader.Packet{
+		{SessionID: 1, Offset: 0, Message: buildOpMsg(t, 1, 0, find)},
+		{SessionID: 1, Offset: 1_000, Message: buildOpMsg(t, 100, 1, ok)},
+		{SessionID: 1, Offset: 41_000, Message: buildOpMsg(t, 2, 0, find)},
+		{SessionID: 1, Offset: 480_000, Message: buildOpMsg(t, 101, 2, ok)},
+		{SessionID: 1, Offset: 500_000, Message: buildOpMsg(t, 3, 0, find)},
+	}}
+
+	start := time.Now()
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	elapsed := time.Since(start)
+	if stats.SuccessfulOps != 3 || len(snd.starts) != 3 {
+		t.Fatalf("unexpected stats: %+v (sent %d)", stats, len(snd.starts))
+	}
+
+	// Offset pacing would send the second find 41ms in, while the first is still running;
+	// here it waits the 40ms think time after the first completes (50ms)
+	if gap := snd.starts[1].Sub(snd.starts[0]); gap < 90*time.Millisecond {
+		t.Errorf("second find sent %v after the first, want >= 90ms (50ms send + 40ms think)", gap)
+	}
+	// The third is due 20ms after the second completes, not at its 500ms offset
+	if gap := snd.starts[2].Sub(snd.starts[1]); gap < 70*time.Millisecond {
+		t.Errorf("third find sent %v after the second, want >= 70ms (50ms send + 20ms think)", gap)
+	}
+	if elapsed > 400*time.Millisecond {
+		t.Errorf("Run took %v, want the third find paced by think time, not its 500ms offset", elapsed)
+	}
+}
+
+func TestResponsePacing_PairsLatestRequest(t *testing.T) {
+	p := newResponsePacing()
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	ok := bson.D{{Key: "ok", Value: 1.0}}
+
+	first := &reader.Packet{SessionID: 1, Offset: 0, Message: buildOpMsg(t, 1, 0, find)}
+	if _, ok := p.due(first, 1); ok {
+		t.Error("first request of a session should be paced by offset")
+	}
+	p.note(first)
+	done := time.Now()
+	p.done(1, done)
+
+	// A response to another session's request, or to an older request, doesn't count
+	p.note(&reader.Packet{SessionID: 2, Offset: 5_000, Message: buildOpMsg(t, 100, 1, ok)})
+	p.note(&reader.Packet{SessionID: 1, Offset: 6_000, Message: buildOpMsg(t, 101, 7, ok)})
+	second := &reader.Packet{SessionID: 1, Offset: 30_000, Message: buildOpMsg(t, 2, 0, find)}
+	if _, ok := p.due(second, 1); ok {
+		t.Error("request without a paired response should be paced by offset")
+	}
+
+	p.note(&reader.Packet{SessionID: 1, Offset: 10_000, Message: buildOpMsg(t, 102, 1, ok)})
+	at, paced := p.due(second, 2)
+	if !paced {
+		t.Fatal("expected the request to be paced by response")
+	}
+	if want := done.Add(10 * time.Millisecond); !at.Equal(want) {
+		t.Errorf("due = +%v, want +10ms (20ms think at 2x)", at.Sub(done))
+	}
+}
+
+func TestNew_PaceByResponseRequiresSpeed(t *testing.T) {
+	_, err := New(Config{Mode: ModeCommand, DryRun: true, PaceByResponse: true})
+	if err == nil || !strings.Contains(err.Error(), "speed > 0") {
+		t.Errorf("New error = %v, want paced replay required", err)
+	}
+}
@@ -0,0 +1,520 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ResponseKey identifies a request within a recording, keying its recorded response
+type ResponseKey = reader.RequestKey
+
+// ResponseSummary is the part of a response compared by --assert-responses
+type ResponseSummary struct {
+	// OK is true if the response has ok: 1
+	OK bool
+
+	// DocCount is the cursor batch size or write count "n" (-1 if the response has neither)
+	DocCount int
+
+	// IDs are the _id values of returned cursor documents, formatted and sorted
+	IDs []string
+
+	// Order is IDs in the order the documents were returned (only with FieldOrder)
+	Order []string
+
+	// CursorID is the id of the cursor the response left open (0 if none or exhausted)
+	// Only whether it is open is compared (with FieldCursorID); cursor-id rewriting uses
+	// it to pair recorded and live cursors
+	CursorID int64
+
+	// Body maps each top-level field of the normalized response (see FieldBody) to a
+	// digest of its value (only with FieldBody)
+	Body map[string]string
+
+	// fields are the keys this summary was built from
+	fields ResponseFields
+}
+
+// ResponseFields selects the response keys summarized and compared by --assert-responses
+// Leaving out FieldIDs keeps recorded summaries small on large result sets.
+type ResponseFields uint8
+
+const (
+	// FieldOK is "ok"; it is always compared
+	FieldOK ResponseFields = 1 << iota
+
+	// FieldN is the write count "n"
+	FieldN
+
+	// FieldCursorID compares whether the response left a cursor open ("cursor.id")
+	FieldCursorID
+
+	// FieldBatchLen is the length of cursor.firstBatch (or nextBatch)
+	FieldBatchLen
+
+	// FieldIDs are the _ids of the returned cursor documents
+	FieldIDs
+
+	// FieldOrder also compares the order of the returned documents, by _id; order
+	// differences are reported separately from missing or unexpected documents
+	FieldOrder
+
+	// FieldBody compares the whole response document after sender.NormalizeResponse
+	// removes its volatile fields; differing top-level fields are reported by name
+	FieldBody
+)
+
+// DefaultResponseFields are the keys compared when no fields are selected
+const DefaultResponseFields = FieldOK | FieldN | FieldBatchLen | FieldIDs
+
+// responseFieldNames maps --response-fields names to fields
+var responseFieldNames = map[string]ResponseFields{
+	"ok":                FieldOK,
+	"n":                 FieldN,
+	"cursor.id":         FieldCursorID,
+	"cursor.firstBatch": FieldBatchLen,
+	"_id":               FieldIDs,
+	"body":              FieldBody,
+}
+
+// ParseResponseFields parses a comma-separated list of response keys
+// (ok, n, cursor.id, cursor.firstBatch, _id, body); ok is always included
+func ParseResponseFields(list string) (ResponseFields, error) {
+	fields := FieldOK
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		field, ok := responseFieldNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown response field %q (want ok, n, cursor.id, cursor.firstBatch, _id or body)", name)
+		}
+		fields |= field
+	}
+	return fields, nil
+}
+
+// orDefault returns DefaultResponseFields for the zero value
+func (f ResponseFields) orDefault() ResponseFields {
+	if f == 0 {
+		return DefaultResponseFields
+	}
+	return f
+}
+
+// ResponseIndex maps each recorded request to a summary of its recorded response
+type ResponseIndex map[ResponseKey]*ResponseSummary
+
+// IndexResponses reads every packet from src and summarizes each OP_MSG response
+// by the request it answers, keeping only the selected fields (0 = DefaultResponseFields).
+// Responses that can't be decoded are skipped.
+func IndexResponses(src PacketSource, fields ResponseFields) (ResponseIndex, error) {
+	return IndexResponsesIgnoring(src, fields, nil)
+}
+
+// IndexResponsesIgnoring is IndexResponses with extra volatile fields (dot-paths) left
+// out of FieldBody comparison, in addition to sender.DefaultVolatileFields
+func IndexResponsesIgnoring(src PacketSource, fields ResponseFields, ignore []string) (ResponseIndex, error) {
+	index := make(ResponseIndex)
+	for {
+		packet, err := src.Next()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading packet: %w", err)
+		}
+
+		key, ok := packet.PairingKey()
+		if !ok || packet.IsRequest() {
+			continue
+		}
+
+		summary, err := summarizeMessage(packet.Message, fields, ignore)
+		if err != nil {
+			continue
+		}
+
+		index[key] = summary
+	}
+}
+
+// Lookup returns the recorded response summary for a request packet
+func (idx ResponseIndex) Lookup(packet *reader.Packet) (*ResponseSummary, bool) {
+	key, ok := packet.PairingKey()
+	if !ok {
+		return nil, false
+	}
+	summary, ok := idx[key]
+	return summary, ok
+}
+
+// SummarizeResponseMessage decodes an OP_MSG (or OP_COMPRESSED OP_MSG) response and summarizes it
+// Only the selected fields (0 = DefaultResponseFields) are read from the raw document, so
+// large batches aren't unmarshaled.
+func SummarizeResponseMessage(message []byte, fields ResponseFields) (*ResponseSummary, error) {
+	return summarizeMessage(message, fields, nil)
+}
+
+// summarizeMessage is SummarizeResponseMessage with the extra volatile fields for FieldBody
+func summarizeMessage(message []byte, fields ResponseFields, ignore []string) (*ResponseSummary, error) {
+	doc, err := decodeResponse(message)
+	if err != nil {
+		return nil, err
+	}
+	fields = fields.orDefault()
+	summary, err := summarizeRaw(doc, fields)
+	if err != nil || fields&FieldBody == 0 {
+		return summary, err
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	summary.Body = bodyDigests(m, ignore)
+	return summary, nil
+}
+
+// decodeResponse returns the body document of an OP_MSG (or OP_COMPRESSED OP_MSG) response
+func decodeResponse(message []byte) (bson.Raw, error) {
+	if len(message) >= 16 && binary.LittleEndian.Uint32(message[12:16]) == 2012 {
+		decompressed, err := reader.DecompressMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		message = decompressed
+	}
+
+	body, err := sender.DecodeBody(message)
+	if err != nil {
+		return nil, err
+	}
+	return body.Document, nil
+}
+
+// summarizeRaw is SummarizeResponse for an undecoded response document
+func summarizeRaw(doc bson.Raw, fields ResponseFields) (*ResponseSummary, error) {
+	summary := &ResponseSummary{OK: true, DocCount: -1, fields: fields}
+
+	// Mirrors sender.Result.IsOK: a missing or non-numeric ok counts as success
+	ok := doc.Lookup("ok")
+	if d, isDouble := ok.DoubleOK(); isDouble {
+		summary.OK = d == 1
+	} else if n, isInt := ok.AsInt64OK(); isInt {
+		summary.OK = n == 1
+	}
+
+	if cursor, isDoc := doc.Lookup("cursor").DocumentOK(); isDoc {
+		summary.CursorID, _ = cursor.Lookup("id").Int64OK()
+		if fields&(FieldBatchLen|FieldIDs) == 0 {
+			return summary, nil
+		}
+
+		batch, isArray := cursor.Lookup("firstBatch").ArrayOK()
+		if !isArray {
+			batch, isArray = cursor.Lookup("nextBatch").ArrayOK()
+		}
+		var values []bson.RawValue
+		if isArray {
+			var err error
+			if values, err = batch.Values(); err != nil {
+				return nil, fmt.Errorf("failed to read cursor batch: %w", err)
+			}
+		}
+		if fields&FieldBatchLen != 0 {
+			summary.DocCount = len(values)
+		}
+		if fields&FieldIDs != 0 {
+			for _, item := range values {
+				d, isDoc := item.DocumentOK()
+				if !isDoc {
+					continue
+				}
+				var id interface{}
+				if rv := d.Lookup("_id"); rv.Type != 0 && rv.Unmarshal(&id) == nil {
+					summary.IDs = append(summary.IDs, fmt.Sprintf("%v", id))
+				}
+			}
+			summary.sortIDs()
+		}
+		return summary, nil
+	}
+
+	if fields&FieldN != 0 {
+		if n, ok := doc.Lookup("n").AsInt64OK(); ok {
+			summary.DocCount = int(n)
+		}
+	}
+	return summary, nil
+}
+
+// SummarizeResponse extracts ok, the returned document count and _ids from a response document
+// Only the selected fields (0 = DefaultResponseFields) are extracted.
+func SummarizeResponse(doc bson.M, fields ResponseFields) *ResponseSummary {
+	return summarizeResponse(doc, fields, nil)
+}
+
+// summarizeResponse is SummarizeResponse with the extra volatile fields for FieldBody
+func summarizeResponse(doc bson.M, fields ResponseFields, ignore []string) *ResponseSummary {
+	fields = fields.orDefault()
+	summary := &ResponseSummary{
+		OK:       (&sender.Result{Success: true, Response: doc}).IsOK(),
+		DocCount: -1,
+		fields:   fields,
+	}
+	if fields&FieldBody != 0 {
+		summary.Body = bodyDigests(doc, ignore)
+	}
+
+	if cursor, ok := asDocument(doc["cursor"]); ok {
+		batch, ok := cursor["firstBatch"].(bson.A)
+		if !ok {
+			batch, _ = cursor["nextBatch"].(bson.A)
+		}
+		if fields&FieldBatchLen != 0 {
+			summary.DocCount = len(batch)
+		}
+		if fields&FieldIDs != 0 {
+			for _, item := range batch {
+				if d, ok := asDocument(item); ok {
+					if id, ok := d["_id"]; ok {
+						summary.IDs = append(summary.IDs, fmt.Sprintf("%v", id))
+					}
+				}
+			}
+			summary.sortIDs()
+		}
+		summary.CursorID, _ = cursor["id"].(int64)
+		return summary
+	}
+
+	if fields&FieldN != 0 {
+		switch n := doc["n"].(type) {
+		case int32:
+			summary.DocCount = int(n)
+		case int64:
+			summary.DocCount = int(n)
+		case float64:
+			summary.DocCount = int(n)
+		}
+	}
+	return summary
+}
+
+// bodyDigests normalizes a response and digests each of its top-level fields
+// Digests keep recorded summaries small while still naming the fields that differ.
+func bodyDigests(doc bson.M, ignore []string) map[string]string {
+	normalized := sender.NormalizeResponse(doc, ignore)
+	digests := make(map[string]string, len(normalized))
+	for key, value := range normalized {
+		var b strings.Builder
+		writeCanonical(&b, value)
+		sum := sha256.Sum256([]byte(b.String()))
+		digests[key] = hex.EncodeToString(sum[:8])
+	}
+	return digests
+}
+
+// writeCanonical writes a value with document keys sorted and scalar types spelled out,
+// so equal values always produce the same string
+func writeCanonical(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case bson.M:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%q:", key)
+			writeCanonical(b, v[key])
+		}
+		b.WriteByte('}')
+	case bson.A:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, item)
+		}
+		b.WriteByte(']')
+	default:
+		fmt.Fprintf(b, "%T(%v)", v, v)
+	}
+}
+
+// bodyDiff names the top-level fields that differ between two normalized responses
+func bodyDiff(recorded, live map[string]string) string {
+	var changed, missing, extra []string
+	for key, digest := range recorded {
+		liveDigest, ok := live[key]
+		switch {
+		case !ok:
+			missing = append(missing, key)
+		case liveDigest != digest:
+			changed = append(changed, key)
+		}
+	}
+	for key := range live {
+		if _, ok := recorded[key]; !ok {
+			extra = append(extra, key)
+		}
+	}
+	if len(changed)+len(missing)+len(extra) == 0 {
+		return ""
+	}
+
+	var parts []string
+	for _, group := range []struct {
+		label string
+		keys  []string
+	}{{"differ", changed}, {"missing", missing}, {"unexpected", extra}} {
+		if len(group.keys) > 0 {
+			sort.Strings(group.keys)
+			parts = append(parts, fmt.Sprintf("%s %s", group.label, strings.Join(group.keys, ", ")))
+		}
+	}
+	return "body: " + strings.Join(parts, "; ")
+}
+
+// sortIDs sorts IDs, first keeping their returned order in Order if FieldOrder is set
+func (s *ResponseSummary) sortIDs() {
+	if s.fields&FieldOrder != 0 {
+		s.Order = append([]string(nil), s.IDs...)
+	}
+	sort.Strings(s.IDs)
+}
+
+// asDocument returns v as a bson.M; nested documents unmarshal as bson.D by default
+func asDocument(v interface{}) (bson.M, bool) {
+	switch d := v.(type) {
+	case bson.M:
+		return d, true
+	case bson.D:
+		m := make(bson.M, len(d))
+		for _, e := range d {
+			m[e.Key] = e.Value
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// Compare returns the differences between a recorded summary and a live one (empty if equivalent)
+// Document counts and _ids are only compared when both responses succeeded and carry them
+func (s *ResponseSummary) Compare(live *ResponseSummary) []string {
+	if s.OK != live.OK {
+		return []string{fmt.Sprintf("ok: recorded %v, live %v", s.OK, live.OK)}
+	}
+	if !s.OK {
+		return nil
+	}
+
+	var diffs []string
+	if s.fields&live.fields&FieldCursorID != 0 && (s.CursorID != 0) != (live.CursorID != 0) {
+		diffs = append(diffs, fmt.Sprintf("cursor: recorded %s, live %s", cursorState(s.CursorID), cursorState(live.CursorID)))
+	}
+	if s.DocCount >= 0 && live.DocCount >= 0 && s.DocCount != live.DocCount {
+		diffs = append(diffs, fmt.Sprintf("doc count: recorded %d, live %d", s.DocCount, live.DocCount))
+	}
+
+	if len(s.IDs) > 0 || len(live.IDs) > 0 {
+		missing, extra := diffSorted(s.IDs, live.IDs)
+		if missing > 0 || extra > 0 {
+			diffs = append(diffs, fmt.Sprintf("_ids: %d missing, %d unexpected", missing, extra))
+		}
+	}
+	if s.fields&live.fields&FieldOrder != 0 {
+		if diff := orderDiff(s.Order, live.Order); diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	if s.fields&live.fields&FieldBody != 0 {
+		if diff := bodyDiff(s.Body, live.Body); diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// orderDiffPrefix starts the Compare difference reported for out-of-order documents
+const orderDiffPrefix = "order: "
+
+// orderDiff compares the order of the _ids returned in both responses
+// Documents only one side returned are left out, so a missing document is reported as
+// a content difference without also shifting every later document out of order.
+func orderDiff(recorded, live []string) string {
+	recorded, live = commonInOrder(recorded, live), commonInOrder(live, recorded)
+
+	moved, first := 0, -1
+	for i := range recorded {
+		if recorded[i] != live[i] {
+			moved++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if moved == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s%d of %d documents out of place, first at position %d (recorded _id %s, live _id %s)",
+		orderDiffPrefix, moved, len(recorded), first, recorded[first], live[first])
+}
+
+// commonInOrder returns the entries of a that also occur in b, in a's order
+// Repeated entries are matched by count, so both results have the same length.
+func commonInOrder(a, b []string) []string {
+	remaining := make(map[string]int, len(b))
+	for _, id := range b {
+		remaining[id]++
+	}
+	var common []string
+	for _, id := range a {
+		if remaining[id] > 0 {
+			remaining[id]--
+			common = append(common, id)
+		}
+	}
+	return common
+}
+
+// cursorState describes a response's cursor id for mismatch messages
+func cursorState(id int64) string {
+	if id == 0 {
+		return "exhausted"
+	}
+	return "open"
+}
+
+// diffSorted counts entries only in a (missing) and only in b (extra) for two sorted slices
+func diffSorted(a, b []string) (missing, extra int) {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case a[i] < b[j]:
+			missing++
+			i++
+		default:
+			extra++
+			j++
+		}
+	}
+	return missing + len(a) - i, extra + len(b) - j
+}
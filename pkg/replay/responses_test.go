@@ -0,0 +1,299 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// scriptedCommandSender returns the given responses in order
+type scriptedCommandSender struct {
+	responses []bson.M
+	calls     int
+}
+
+func (s *scriptedCommandSender) SendCommand(database string, command bson.M) (*sender.Result, error) {
+	resp := s.responses[s.calls]
+	s.calls++
+	return &sender.Result{Success: true, Response: resp}, nil
+}
+
+func cursorResponse(ids ...int32) bson.D {
+	batch := bson.A{}
+	for _, id := range ids {
+		batch = append(batch, bson.D{{Key: "_id", Value: id}})
+	}
+	return bson.D{
+		{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.users"}, {Key: "firstBatch", Value: batch}}},
+		{Key: "ok", Value: 1.0},
+	}
+}
+
+func TestIndexResponses_PairsBySessionAndRequestID(t *testing.T) {
+	src := &sliceSource{packets: []*reader.Packet{
+		{SessionID: 1, Message: buildOpMsg(t, 10, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})},
+		{SessionID: 2, Message: buildOpMsg(t, 10, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})},
+		{SessionID: 2, Message: buildOpMsg(t, 99, 10, cursorResponse(1))},
+		{SessionID: 1, Message: buildOpMsg(t, 98, 10, cursorResponse(1, 2, 3))},
+	}}
+
+	index, err := IndexResponses(src, 0)
+	if err != nil {
+		t.Fatalf("IndexResponses failed: %v", err)
+	}
+	if len(index) != 2 {
+		t.Fatalf("indexed %d responses, want 2", len(index))
+	}
+
+	got, ok := index.Lookup(src.packets[0])
+	if !ok || got.DocCount != 3 || len(got.IDs) != 3 {
+		t.Errorf("session 1 summary = %+v, want 3 docs", got)
+	}
+	got, ok = index.Lookup(src.packets[1])
+	if !ok || got.DocCount != 1 {
+		t.Errorf("session 2 summary = %+v, want 1 doc", got)
+	}
+}
+
+func TestResponseSummary_Compare(t *testing.T) {
+	summarize := func(doc bson.D) *ResponseSummary {
+		raw, _ := bson.Marshal(doc)
+		var m bson.M
+		bson.Unmarshal(raw, &m)
+		return SummarizeResponse(m, 0)
+	}
+
+	tests := []struct {
+		name      string
+		recorded  bson.D
+		live      bson.D
+		wantDiffs int
+	}{
+		{"identical cursor", cursorResponse(1, 2), cursorResponse(2, 1), 0},
+		{"missing document", cursorResponse(1, 2), cursorResponse(1), 2},
+		{"different ids", cursorResponse(1, 2), cursorResponse(1, 3), 1},
+		{"write count", bson.D{{Key: "n", Value: int32(3)}, {Key: "ok", Value: 1.0}}, bson.D{{Key: "n", Value: int32(2)}, {Key: "ok", Value: 1.0}}, 1},
+		{"ok differs", bson.D{{Key: "ok", Value: 1.0}}, bson.D{{Key: "ok", Value: 0.0}}, 1},
+		{"both failed", bson.D{{Key: "ok", Value: 0.0}, {Key: "code", Value: 11000}}, bson.D{{Key: "ok", Value: 0.0}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := summarize(tt.recorded).Compare(summarize(tt.live))
+			if len(diffs) != tt.wantDiffs {
+				t.Errorf("got diffs %v, want %d", diffs, tt.wantDiffs)
+			}
+		})
+	}
+}
+
+func TestRun_AssertResponses(t *testing.T) {
+	find := func(reqID int32) *reader.Packet {
+		return &reader.Packet{SessionID: 1, Message: buildOpMsg(t, reqID, 0, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})}
+	}
+	recording := []*reader.Packet{
+		find(1),
+		{SessionID: 1, Message: buildOpMsg(t, 101, 1, cursorResponse(1, 2))},
+		find(2),
+		{SessionID: 1, Message: buildOpMsg(t, 102, 2, cursorResponse(3))},
+		find(3), // no recorded response
+	}
+
+	index, err := IndexResponses(&sliceSource{packets: recording}, 0)
+	if err != nil {
+		t.Fatalf("IndexResponses failed: %v", err)
+	}
+
+	snd := &scriptedCommandSender{responses: []bson.M{
+		{"cursor": bson.M{"firstBatch": bson.A{bson.M{"_id": int32(1)}, bson.M{"_id": int32(2)}}}, "ok": 1.0},
+		{"cursor": bson.M{"firstBatch": bson.A{}}, "ok": 1.0},
+		{"ok": 1.0},
+	}}
+	r, err := New(Config{Mode: ModeCommand, RequestsOnly: true, CommandSender: snd, RecordedResponses: index})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	stats, err := r.Run(context.Background(), &sliceSource{packets: recording})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if stats.SuccessfulOps != 2 || stats.FailedOps != 1 {
+		t.Errorf("SuccessfulOps=%d FailedOps=%d, want 2 and 1", stats.SuccessfulOps, stats.FailedOps)
+	}
+	if stats.ResponsesCompared != 2 || stats.ResponseMismatches != 1 || stats.ResponsesUnpaired != 1 {
+		t.Errorf("unexpected response stats: %+v", stats)
+	}
+}
+
+func TestParseResponseFields(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    ResponseFields
+		wantErr bool
+	}{
+		{"n", FieldOK | FieldN, false},
+		{"ok, cursor.id,cursor.firstBatch", FieldOK | FieldCursorID | FieldBatchLen, false},
+		{"_id", FieldOK | FieldIDs, false},
+		{"nModified", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResponseFields(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResponseFields(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseResponseFields(%q) = %b, want %b", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeResponseMessage_Fields(t *testing.T) {
+	open := bson.D{
+		{Key: "cursor", Value: bson.D{
+			{Key: "id", Value: int64(42)},
+			{Key: "firstBatch", Value: bson.A{bson.D{{Key: "_id", Value: int32(2)}}, bson.D{{Key: "_id", Value: int32(1)}}}},
+		}},
+		{Key: "ok", Value: 1.0},
+	}
+	message := buildOpMsg(t, 1, 0, open)
+
+	tests := []struct {
+		name         string
+		fields       ResponseFields
+		wantDocCount int
+		wantIDs      int
+	}{
+		{"default", 0, 2, 2},
+		{"batch length only", FieldOK | FieldBatchLen, 2, 0},
+		{"cursor id only", FieldOK | FieldCursorID, -1, 0},
+		{"ordered", DefaultResponseFields | FieldOrder, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SummarizeResponseMessage(message, tt.fields)
+			if err != nil {
+				t.Fatalf("SummarizeResponseMessage failed: %v", err)
+			}
+			if !got.OK || got.DocCount != tt.wantDocCount || len(got.IDs) != tt.wantIDs || got.CursorID != 42 {
+				t.Errorf("summary = %+v, want ok, %d docs, %d ids, cursor 42", got, tt.wantDocCount, tt.wantIDs)
+			}
+
+			// The raw summary must agree with the one built from an unmarshaled live response
+			raw, _ := bson.Marshal(open)
+			var m bson.M
+			bson.Unmarshal(raw, &m)
+			if diffs := got.Compare(SummarizeResponse(m, tt.fields)); len(diffs) > 0 {
+				t.Errorf("raw and unmarshaled summaries differ: %v", diffs)
+			}
+		})
+	}
+}
+
+func TestResponseSummary_CompareCursorID(t *testing.T) {
+	exhausted := bson.M{"cursor": bson.M{"id": int64(0), "firstBatch": bson.A{}}, "ok": 1.0}
+	open := bson.M{"cursor": bson.M{"id": int64(7), "firstBatch": bson.A{}}, "ok": 1.0}
+
+	if diffs := SummarizeResponse(exhausted, 0).Compare(SummarizeResponse(open, 0)); len(diffs) != 0 {
+		t.Errorf("cursor state compared without cursor.id selected: %v", diffs)
+	}
+	fields := FieldOK | FieldCursorID
+	if diffs := SummarizeResponse(exhausted, fields).Compare(SummarizeResponse(open, fields)); len(diffs) != 1 {
+		t.Errorf("got diffs %v, want an open/exhausted cursor mismatch", diffs)
+	}
+}
+
+func TestResponseSummary_CompareOrder(t *testing.T) {
+	ordered := DefaultResponseFields | FieldOrder
+	summarize := func(doc bson.D, fields ResponseFields) *ResponseSummary {
+		summary, err := SummarizeResponseMessage(buildOpMsg(t, 1, 0, doc), fields)
+		if err != nil {
+			t.Fatalf("SummarizeResponseMessage failed: %v", err)
+		}
+		return summary
+	}
+
+	tests := []struct {
+		name      string
+		recorded  bson.D
+		live      bson.D
+		wantDiffs []string
+	}{
+		{"same order", cursorResponse(1, 2, 3), cursorResponse(1, 2, 3), nil},
+		{"swapped", cursorResponse(1, 2, 3), cursorResponse(2, 1, 3), []string{"order: 2 of 3 documents out of place, first at position 0 (recorded _id 1, live _id 2)"}},
+		{"missing only", cursorResponse(1, 2, 3), cursorResponse(1, 3), []string{"doc count: recorded 3, live 2", "_ids: 1 missing, 0 unexpected"}},
+		{"missing and reordered", cursorResponse(1, 2, 3), cursorResponse(3, 1), []string{"doc count: recorded 3, live 2", "_ids: 1 missing, 0 unexpected", "order: 2 of 2 documents out of place, first at position 0 (recorded _id 1, live _id 3)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := summarize(tt.recorded, ordered).Compare(summarize(tt.live, ordered))
+			if strings.Join(diffs, "\n") != strings.Join(tt.wantDiffs, "\n") {
+				t.Errorf("got diffs %q, want %q", diffs, tt.wantDiffs)
+			}
+		})
+	}
+
+	// Without FieldOrder documents are compared as a set
+	if diffs := summarize(cursorResponse(1, 2), 0).Compare(summarize(cursorResponse(2, 1), 0)); len(diffs) != 0 {
+		t.Errorf("order compared without FieldOrder: %v", diffs)
+	}
+}
+
+func TestResponseSummary_CompareBody(t *testing.T) {
+	recorded := bson.D{
+		{Key: "ok", Value: 1.0},
+		{Key: "ismaster", Value: true},
+		{Key: "lastCommittedOpTime", Value: bson.Timestamp{T: 100, I: 1}},
+		{Key: "repl", Value: bson.D{{Key: "setName", Value: "rs0"}, {Key: "lastWrite", Value: bson.D{{Key: "opTime", Value: int64(5)}}}}},
+		{Key: "operationTime", Value: bson.Timestamp{T: 100, I: 1}},
+	}
+	live := bson.M{
+		"ok":                  1.0,
+		"ismaster":            true,
+		"lastCommittedOpTime": bson.Timestamp{T: 200, I: 7},
+		"repl":                bson.M{"setName": "rs0", "lastWrite": bson.M{"opTime": int64(9)}},
+		"operationTime":       bson.Timestamp{T: 200, I: 7},
+		"$clusterTime":        bson.M{"clusterTime": bson.Timestamp{T: 200, I: 7}},
+	}
+	fields := FieldOK | FieldBody
+
+	tests := []struct {
+		name     string
+		ignore   []string
+		wantDiff string
+	}{
+		{"defaults only", nil, "body: differ lastCommittedOpTime, repl"},
+		{"top-level path", []string{"lastCommittedOpTime"}, "body: differ repl"},
+		{"custom paths", []string{"lastCommittedOpTime", "repl.lastWrite.opTime"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := summarizeMessage(buildOpMsg(t, 1, 0, recorded), fields, tt.ignore)
+			if err != nil {
+				t.Fatalf("summarizeMessage failed: %v", err)
+			}
+			diffs := summary.Compare(summarizeResponse(live, fields, tt.ignore))
+			if got := strings.Join(diffs, "; "); got != tt.wantDiff {
+				t.Errorf("diffs = %q, want %q", got, tt.wantDiff)
+			}
+		})
+	}
+
+	// A field only one side has is reported as missing or unexpected
+	extra := bson.M{"ok": 1.0, "ismaster": true, "msg": "isdbgrid"}
+	diffs := summarizeResponse(bson.M{"ok": 1.0, "ismaster": true, "hosts": bson.A{"a"}}, fields, nil).Compare(summarizeResponse(extra, fields, nil))
+	if want := "body: missing hosts; unexpected msg"; strings.Join(diffs, "; ") != want {
+		t.Errorf("diffs = %v, want %q", diffs, want)
+	}
+}
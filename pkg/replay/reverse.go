@@ -0,0 +1,78 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// ReverseSource yields another source's packets last to first
+//
+// It replays the recorded operations in reverse order, not their logical inverses: a
+// recorded insert is still an insert. It is meant for teardown-style runs, e.g.
+// replaying only the drops and deletes of a recording newest first.
+//
+// The whole source is buffered on the first call to Next. Offsets are mirrored within
+// the recorded span (the last packet gets the first packet's offset and so on), so
+// paced replay keeps the recorded gaps between ops, walked backwards.
+type ReverseSource struct {
+	src     PacketSource
+	packets []*reader.Packet
+	loaded  bool
+}
+
+// NewReverseSource wraps src so its packets are yielded in reverse order
+func NewReverseSource(src PacketSource) *ReverseSource {
+	return &ReverseSource{src: src}
+}
+
+// Next returns the next packet, reading and reversing the whole source first
+func (s *ReverseSource) Next() (*reader.Packet, error) {
+	if !s.loaded {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.packets) == 0 {
+		return nil, io.EOF
+	}
+
+	last := len(s.packets) - 1
+	packet := s.packets[last]
+	s.packets[last] = nil
+	s.packets = s.packets[:last]
+	return packet, nil
+}
+
+// load buffers every packet of the source and mirrors their offsets
+// The source is closed once read if it implements io.Closer.
+func (s *ReverseSource) load() error {
+	s.loaded = true
+	for {
+		packet, err := s.src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to buffer packet %d for reverse replay: %w", len(s.packets)+1, err)
+		}
+		s.packets = append(s.packets, packet)
+	}
+	if closer, ok := s.src.(io.Closer); ok {
+		closer.Close()
+	}
+
+	if len(s.packets) == 0 {
+		return nil
+	}
+	first, last := s.packets[0].Offset, s.packets[0].Offset
+	for _, p := range s.packets {
+		first = min(first, p.Offset)
+		last = max(last, p.Offset)
+	}
+	for _, p := range s.packets {
+		p.Offset = first + (last - p.Offset)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestReverseSource_OrderAndOffsets(t *testing.T) {
+	src := &closingSource{sliceSource: sliceSource{packets: []*reader.Packet{
+		{Order: 1, Offset: 1000},
+		{Order: 2, Offset: 1200},
+		{Order: 3, Offset: 1500},
+	}}}
+	rev := NewReverseSource(src)
+
+	var orders, offsets []uint64
+	for {
+		packet, err := rev.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		orders = append(orders, packet.Order)
+		offsets = append(offsets, packet.Offset)
+	}
+
+	if want := []uint64{3, 2, 1}; !reflect.DeepEqual(orders, want) {
+		t.Errorf("orders = %v, want %v", orders, want)
+	}
+	// The gaps (300µs, then 200µs) are kept, walked backwards from the first offset
+	if want := []uint64{1000, 1300, 1500}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+	if !src.closed {
+		t.Error("source was not closed after buffering")
+	}
+}
+
+func TestReverseSource_Empty(t *testing.T) {
+	rev := NewReverseSource(&sliceSource{})
+	if _, err := rev.Next(); err != io.EOF {
+		t.Errorf("Next error = %v, want io.EOF", err)
+	}
+}
+
+// failingSource yields its packets and then an error
+type failingSource struct {
+	sliceSource
+}
+
+func (s *failingSource) Next() (*reader.Packet, error) {
+	if s.idx >= len(s.packets) {
+		return nil, errors.New("truncated packet")
+	}
+	return s.sliceSource.Next()
+}
+
+func TestReverseSource_ReadError(t *testing.T) {
+	rev := NewReverseSource(&failingSource{sliceSource{packets: []*reader.Packet{{Offset: 1}}}})
+	if _, err := rev.Next(); err == nil || !strings.Contains(err.Error(), "truncated packet") {
+		t.Errorf("Next error = %v, want the source's error", err)
+	}
+}
+
+func TestRun_ReverseDispatchOrder(t *testing.T) {
+	snd := &recordingCommandSender{}
+	r, err := New(Config{
+		Mode:          ModeCommand,
+		CommandSender: snd,
+		SummaryOnly:   true,
+		Output:        &strings.Builder{},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	src := NewReverseSource(&sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 100, bson.D{{Key: "create", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 200, bson.D{{Key: "insert", Value: "users"}, {Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: 1}}}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 300, bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 400, bson.D{{Key: "drop", Value: "users"}, {Key: "$db", Value: "app"}}),
+	}})
+	if _, err := r.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var got []string
+	for _, cmd := range snd.commands {
+		for _, name := range []string{"create", "insert", "delete", "drop"} {
+			if _, ok := cmd[name]; ok {
+				got = append(got, name)
+			}
+		}
+	}
+	if want := []string{"drop", "delete", "insert", "create"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+package replay
+
+import (
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// ShadowReadCommands are the commands Shadow sends; every other command is skipped
+var ShadowReadCommands = []string{"find", "aggregate", "count", "distinct", "getMore"}
+
+// shadowRead reports whether Shadow lets an op through: reads are sent, and writes, DDL
+// and every other command are skipped without an output line
+// Aggregations ending in $out or $merge write, so they are skipped as writes.
+func (r *Replayer) shadowRead(stats *Stats, packet *reader.Packet, cmd *sender.Command) bool {
+	switch packet.GetCommandCategory() {
+	case "read":
+		if sender.AggregateWriteStage(cmd) == "" {
+			return true
+		}
+	case "read-continuation":
+		return true
+	default:
+		if !packet.IsWriteOperation() {
+			return false
+		}
+	}
+	stats.ShadowWritesSkipped++
+	return false
+}
@@ -0,0 +1,60 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRun_Shadow(t *testing.T) {
+	packets := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "insert", Value: "users"}, {Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: 1}}}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "find", Value: "users"}, {Key: "filter", Value: bson.D{}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "aggregate", Value: "users"}, {Key: "pipeline", Value: bson.A{bson.D{{Key: "$out", Value: "copy"}}}}, {Key: "cursor", Value: bson.D{}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "count", Value: "users"}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "createIndexes", Value: "users"}, {Key: "indexes", Value: bson.A{}}, {Key: "$db", Value: "app"}}),
+		buildCommandPacket(t, 1, 0, bson.D{{Key: "ping", Value: 1}, {Key: "$db", Value: "admin"}}),
+	}}
+
+	snd := &recordingCommandSender{}
+	live := NewReplayStats()
+	r, err := New(Config{Mode: ModeCommand, CommandSender: snd, Shadow: true, LiveStats: live})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	stats, err := r.Run(context.Background(), packets)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var sent []string
+	for _, cmd := range snd.commands {
+		for _, name := range ShadowReadCommands {
+			if _, ok := cmd[name]; ok {
+				sent = append(sent, name)
+			}
+		}
+	}
+	if got := strings.Join(sent, ","); got != "find,getMore,count" {
+		t.Errorf("sent %s, want find,getMore,count", got)
+	}
+	// insert, the $out aggregate and createIndexes are writes; ping is skipped too
+	if stats.ShadowWritesSkipped != 3 || stats.SkippedPackets != 4 {
+		t.Errorf("writes skipped/skipped packets = %d/%d, want 3/4", stats.ShadowWritesSkipped, stats.SkippedPackets)
+	}
+
+	reads := live.Snapshot().Latencies(ShadowReadCommands...)
+	if reads.Count() != 3 {
+		t.Errorf("read latencies recorded = %d, want 3", reads.Count())
+	}
+}
+
+func TestNew_ShadowRequiresCommandMode(t *testing.T) {
+	if _, err := New(Config{Mode: ModeRaw, DryRun: true, Shadow: true}); err == nil || !strings.Contains(err.Error(), "command mode") {
+		t.Errorf("New error = %v, want command mode required", err)
+	}
+}
@@ -0,0 +1,150 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+)
+
+// Stats holds the counters and timing collected during a replay
+type Stats struct {
+	// TotalPackets is the number of packets read from the source
+	TotalPackets int
+
+	// SkippedPackets is the number of packets filtered out or unparseable
+	SkippedPackets int
+
+	// SuccessfulOps is the number of operations sent successfully
+	SuccessfulOps int
+
+	// FailedOps is the number of operations that failed
+	FailedOps int
+
+	// PartialWrites is the number of write batches counted as successful although some
+	// of their statements failed (TolerateWriteErrors)
+	PartialWrites int
+
+	// DuplicatesSkipped and DuplicatesUpserted count inserted documents that hit a
+	// duplicate key and were skipped or re-sent as upserts (OnDuplicate)
+	DuplicatesSkipped  int
+	DuplicatesUpserted int
+
+	// AggWritesBlocked is the number of $out/$merge aggregations skipped or stripped by
+	// BlockAggWrites; skipped ones are also counted in SkippedPackets
+	AggWritesBlocked int
+
+	// ShadowWritesSkipped is the number of writes and DDL commands Shadow kept from being
+	// sent; they are also counted in SkippedPackets
+	ShadowWritesSkipped int
+
+	// PastDueSkipped is the number of ops skipped by SkipPast because they were due
+	// before the replay started; they are also counted in SkippedPackets
+	PastDueSkipped int
+
+	// EncryptedOps is the number of ops flagged by WarnEncrypted for holding encrypted fields
+	EncryptedOps int
+
+	// SessionsSeen and SessionsSampled count the distinct sessions read and the ones
+	// SessionSample selected (both 0 unless SessionSample is set)
+	SessionsSeen    int
+	SessionsSampled int
+
+	// DecompressFallbacks is the number of OP_COMPRESSED packets whose raw send failed
+	// and that were sent as commands instead (DecompressFallback)
+	DecompressFallbacks int
+
+	// Stopped is true if the replay was interrupted or hit MaxDuration before the recording ended
+	Stopped bool
+
+	// Duration is the total wall-clock time of the replay
+	Duration time.Duration
+
+	// Speed is the speed multiplier the replay ran at
+	Speed float64
+
+	// FirstOffset is the recorded offset (microseconds) of the first paced operation
+	// (0 with StartAt, whose timeline starts at the capture start)
+	FirstOffset uint64
+
+	// LastOffset is the recorded offset (microseconds) of the last processed operation
+	LastOffset uint64
+
+	// ReplayStart is when the first paced operation was dispatched (zero in fast-forward mode)
+	ReplayStart time.Time
+
+	// ReplayEnd is when the last operation finished
+	ReplayEnd time.Time
+
+	// MaxDrift is the largest lag behind the recorded timeline seen at dispatch
+	MaxDrift time.Duration
+
+	// InjectedDelay is the total artificial latency added before sends
+	InjectedDelay time.Duration
+
+	// PlannedDuration is the simulated replay duration previewed by ShowTiming
+	PlannedDuration time.Duration
+
+	// ResponsesCompared is the number of live responses compared with a recorded response
+	ResponsesCompared int
+
+	// ResponseMismatches is the number of compared responses that diverged
+	ResponseMismatches int
+
+	// OrderMismatches is the number of compared responses that returned documents in a
+	// different order (FieldOrder); they are also counted in ResponseMismatches
+	OrderMismatches int
+
+	// ResponsesUnpaired is the number of requests with no recorded response to compare against
+	ResponsesUnpaired int
+
+	// ResponsesRecorded is the number of request/response pairs written to RecordResponses
+	ResponsesRecorded int
+
+	// CursorsMapped is the number of recorded cursors paired with a live cursor
+	CursorsMapped int
+
+	// CursorsRewritten is the number of getMore/killCursors cursor ids replaced with live ids
+	CursorsRewritten int
+
+	// CursorsUnmapped is the number of getMore/killCursors cursor ids with no live cursor
+	// (the opening command was filtered out, failed, or predates the recording)
+	CursorsUnmapped int
+
+	// CursorsLeaked is the number of live cursors still open when the replay ended, which
+	// the recording neither exhausted nor killed; CursorsKilled of them were killed then
+	CursorsLeaked int
+	CursorsKilled int
+
+	// Pool sums the connection checkouts of the senders that report them (PoolStatsSender)
+	// since they connected; it is zero if none do
+	Pool sender.PoolStats
+
+	// Targets counts ops per destination, in Config.Targets order (empty unless Targets is set)
+	Targets []TargetStats
+}
+
+// Ops returns the number of operations attempted (successful + failed)
+func (s *Stats) Ops() int {
+	return s.SuccessfulOps + s.FailedOps
+}
+
+// RecordingDuration returns the recorded time span covered by the replayed operations
+func (s *Stats) RecordingDuration() time.Duration {
+	return time.Duration(s.LastOffset-s.FirstOffset) * time.Microsecond
+}
+
+// ExpectedDuration returns how long the replay should have taken at the configured speed
+func (s *Stats) ExpectedDuration() time.Duration {
+	if s.Speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(s.RecordingDuration()) / s.Speed)
+}
+
+// ActualDuration returns how long the paced portion of the replay actually took
+func (s *Stats) ActualDuration() time.Duration {
+	if s.ReplayStart.IsZero() || s.ReplayEnd.IsZero() {
+		return 0
+	}
+	return s.ReplayEnd.Sub(s.ReplayStart)
+}
@@ -0,0 +1,211 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// SummarySchemaVersion is the version of the ReplaySummary JSON schema
+// Fields may be added within a version; renaming or removing one bumps it.
+const SummarySchemaVersion = 1
+
+// ReplaySummary is the machine-readable result of a replay, for CI to gate on
+// Every field is always present (zero rather than omitted) so the schema doesn't
+// depend on which options a run used. Durations are milliseconds.
+type ReplaySummary struct {
+	SchemaVersion int  `json:"schema_version"`
+	Stopped       bool `json:"stopped"`
+
+	Packets        int `json:"packets"`
+	SkippedPackets int `json:"skipped_packets"`
+	SuccessfulOps  int `json:"successful_ops"`
+	FailedOps      int `json:"failed_ops"`
+	PartialWrites  int `json:"partial_writes"`
+
+	ShadowWritesSkipped int `json:"shadow_writes_skipped"`
+	PastDueSkipped      int `json:"past_due_skipped"`
+	AggWritesBlocked    int `json:"agg_writes_blocked"`
+	EncryptedOps        int `json:"encrypted_ops"`
+	DuplicatesSkipped   int `json:"duplicates_skipped"`
+	DuplicatesUpserted  int `json:"duplicates_upserted"`
+	DecompressFallbacks int `json:"decompress_fallbacks"`
+	SessionsSeen        int `json:"sessions_seen"`
+	SessionsSampled     int `json:"sessions_sampled"`
+
+	ResponsesCompared  int `json:"responses_compared"`
+	ResponseMismatches int `json:"response_mismatches"`
+	OrderMismatches    int `json:"order_mismatches"`
+	ResponsesUnpaired  int `json:"responses_unpaired"`
+	ResponsesRecorded  int `json:"responses_recorded"`
+
+	CursorsMapped    int `json:"cursors_mapped"`
+	CursorsRewritten int `json:"cursors_rewritten"`
+	CursorsUnmapped  int `json:"cursors_unmapped"`
+	CursorsLeaked    int `json:"cursors_leaked"`
+	CursorsKilled    int `json:"cursors_killed"`
+
+	// Speed is the speed multiplier (0 = fast-forward); the expected and actual
+	// durations cover the paced portion of the replay and are 0 without pacing
+	Speed               float64 `json:"speed"`
+	DurationMs          float64 `json:"duration_ms"`
+	RecordingDurationMs float64 `json:"recording_duration_ms"`
+	ExpectedDurationMs  float64 `json:"expected_duration_ms"`
+	ActualDurationMs    float64 `json:"actual_duration_ms"`
+	MaxDriftMs          float64 `json:"max_drift_ms"`
+	InjectedDelayMs     float64 `json:"injected_delay_ms"`
+
+	// Latency is over the successful ops of every command
+	Latency LatencySummary `json:"latency"`
+
+	// Pool counts the senders' connection checkouts (zero in a dry run)
+	Pool PoolSummary `json:"pool"`
+
+	// Commands lists per-command counts, most ops first
+	Commands []CommandSummary `json:"commands"`
+
+	// Targets lists per-target counts (empty with a single target)
+	Targets []TargetSummary `json:"targets"`
+}
+
+// LatencySummary describes a latency distribution; percentiles are upper bounds
+// within about 6% (see LatencyHistogram)
+type LatencySummary struct {
+	Count  int     `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// PoolSummary holds the connection checkout counts of a replay's senders
+type PoolSummary struct {
+	Checkouts          int     `json:"checkouts"`
+	Exhausted          int     `json:"exhausted"`
+	MeanCheckoutWaitMs float64 `json:"mean_checkout_wait_ms"`
+	MaxCheckoutWaitMs  float64 `json:"max_checkout_wait_ms"`
+	CheckoutFailures   int     `json:"checkout_failures"`
+	CheckoutTimeouts   int     `json:"checkout_timeouts"`
+}
+
+// CommandSummary holds the counts and latencies of one command name
+type CommandSummary struct {
+	Name          string         `json:"name"`
+	SuccessfulOps int            `json:"successful_ops"`
+	FailedOps     int            `json:"failed_ops"`
+	Latency       LatencySummary `json:"latency"`
+	LastError     string         `json:"last_error"`
+}
+
+// TargetSummary holds the counts of one target
+type TargetSummary struct {
+	Name          string `json:"name"`
+	SuccessfulOps int    `json:"successful_ops"`
+	FailedOps     int    `json:"failed_ops"`
+}
+
+// NewReplaySummary builds the summary of a run from its Stats and a snapshot of the
+// LiveStats it counted into (an empty snapshot leaves latency and commands empty)
+func NewReplaySummary(stats *Stats, live StatsSnapshot) ReplaySummary {
+	s := ReplaySummary{
+		SchemaVersion: SummarySchemaVersion,
+		Stopped:       stats.Stopped,
+
+		Packets:        stats.TotalPackets,
+		SkippedPackets: stats.SkippedPackets,
+		SuccessfulOps:  stats.SuccessfulOps,
+		FailedOps:      stats.FailedOps,
+		PartialWrites:  stats.PartialWrites,
+
+		ShadowWritesSkipped: stats.ShadowWritesSkipped,
+		PastDueSkipped:      stats.PastDueSkipped,
+		AggWritesBlocked:    stats.AggWritesBlocked,
+		EncryptedOps:        stats.EncryptedOps,
+		DuplicatesSkipped:   stats.DuplicatesSkipped,
+		DuplicatesUpserted:  stats.DuplicatesUpserted,
+		DecompressFallbacks: stats.DecompressFallbacks,
+		SessionsSeen:        stats.SessionsSeen,
+		SessionsSampled:     stats.SessionsSampled,
+
+		ResponsesCompared:  stats.ResponsesCompared,
+		ResponseMismatches: stats.ResponseMismatches,
+		OrderMismatches:    stats.OrderMismatches,
+		ResponsesUnpaired:  stats.ResponsesUnpaired,
+		ResponsesRecorded:  stats.ResponsesRecorded,
+
+		CursorsMapped:    stats.CursorsMapped,
+		CursorsRewritten: stats.CursorsRewritten,
+		CursorsUnmapped:  stats.CursorsUnmapped,
+		CursorsLeaked:    stats.CursorsLeaked,
+		CursorsKilled:    stats.CursorsKilled,
+
+		Speed:           stats.Speed,
+		DurationMs:      millis(stats.Duration),
+		MaxDriftMs:      millis(stats.MaxDrift),
+		InjectedDelayMs: millis(stats.InjectedDelay),
+
+		Pool: PoolSummary{
+			Checkouts:          stats.Pool.Checkouts,
+			Exhausted:          stats.Pool.Exhausted,
+			MeanCheckoutWaitMs: millis(stats.Pool.AverageCheckoutWait()),
+			MaxCheckoutWaitMs:  millis(stats.Pool.MaxCheckoutWait),
+			CheckoutFailures:   stats.Pool.CheckoutFailures,
+			CheckoutTimeouts:   stats.Pool.CheckoutTimeouts,
+		},
+
+		Commands: []CommandSummary{},
+		Targets:  []TargetSummary{},
+	}
+	if stats.Ops() > 0 && stats.Speed > 0 && !stats.ReplayStart.IsZero() && !stats.ReplayEnd.IsZero() {
+		s.RecordingDurationMs = millis(stats.RecordingDuration())
+		s.ExpectedDurationMs = millis(stats.ExpectedDuration())
+		s.ActualDurationMs = millis(stats.ActualDuration())
+	}
+
+	var all LatencyHistogram
+	for _, name := range live.CommandNames() {
+		c := live.Commands[name]
+		all.Merge(&c.Latencies)
+		s.Commands = append(s.Commands, CommandSummary{
+			Name:          name,
+			SuccessfulOps: c.Successes,
+			FailedOps:     c.Failures,
+			Latency:       summarizeLatency(&c.Latencies, c.Latency),
+			LastError:     c.LastError,
+		})
+	}
+	s.Latency = summarizeLatency(&all, live.Latency)
+
+	for _, t := range stats.Targets {
+		s.Targets = append(s.Targets, TargetSummary{Name: t.Name, SuccessfulOps: t.SuccessfulOps, FailedOps: t.FailedOps})
+	}
+	return s
+}
+
+// WriteJSON writes the summary as an indented JSON document
+func (s ReplaySummary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// summarizeLatency describes h; total is the sum of its latencies, for the mean
+func summarizeLatency(h *LatencyHistogram, total time.Duration) LatencySummary {
+	l := LatencySummary{
+		Count: h.Count(),
+		P50Ms: millis(h.Percentile(50)),
+		P95Ms: millis(h.Percentile(95)),
+		P99Ms: millis(h.Percentile(99)),
+		MaxMs: millis(h.Max()),
+	}
+	if l.Count > 0 {
+		l.MeanMs = millis(total / time.Duration(l.Count))
+	}
+	return l
+}
+
+// millis converts d to fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
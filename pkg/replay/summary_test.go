@@ -0,0 +1,79 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplaySummary_JSON(t *testing.T) {
+	live := NewReplayStats()
+	for i := 0; i < 3; i++ {
+		live.RecordSuccess("find", 2*time.Millisecond)
+	}
+	live.RecordSuccess("insert", 10*time.Millisecond)
+	live.RecordFailure("insert", errors.New("duplicate key"))
+
+	start := time.Now()
+	stats := &Stats{
+		TotalPackets:  7,
+		SuccessfulOps: 4,
+		FailedOps:     1,
+		Speed:         2,
+		FirstOffset:   0,
+		LastOffset:    1_000_000,
+		ReplayStart:   start,
+		ReplayEnd:     start.Add(600 * time.Millisecond),
+		Duration:      700 * time.Millisecond,
+		MaxDrift:      1500 * time.Microsecond,
+	}
+
+	var buf bytes.Buffer
+	if err := NewReplaySummary(stats, live.Snapshot()).WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	for key, want := range map[string]interface{}{
+		"schema_version":        float64(SummarySchemaVersion),
+		"stopped":               false,
+		"packets":               7.0,
+		"successful_ops":        4.0,
+		"failed_ops":            1.0,
+		"speed":                 2.0,
+		"duration_ms":           700.0,
+		"recording_duration_ms": 1000.0,
+		"expected_duration_ms":  500.0,
+		"actual_duration_ms":    600.0,
+		"max_drift_ms":          1.5,
+		"cursors_leaked":        0.0,
+	} {
+		if got[key] != want {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+
+	latency := got["latency"].(map[string]interface{})
+	if latency["count"] != 4.0 || latency["max_ms"] != 10.0 || latency["p99_ms"] != 10.0 || latency["mean_ms"] != 4.0 {
+		t.Errorf("latency = %v, want 4 ops, mean 4ms, p99 and max 10ms", latency)
+	}
+
+	commands := got["commands"].([]interface{})
+	if len(commands) != 2 {
+		t.Fatalf("got %d commands, want 2", len(commands))
+	}
+	insert := commands[1].(map[string]interface{})
+	if insert["name"] != "insert" || insert["successful_ops"] != 1.0 || insert["failed_ops"] != 1.0 || insert["last_error"] != "duplicate key" {
+		t.Errorf("insert = %v", insert)
+	}
+
+	// Empty lists are [] rather than null, so consumers can iterate without a check
+	if targets, ok := got["targets"].([]interface{}); !ok || len(targets) != 0 {
+		t.Errorf("targets = %v, want []", got["targets"])
+	}
+}
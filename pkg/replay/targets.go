@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// Routing decides which target each op is sent to when there are several
+type Routing string
+
+const (
+	// RouteRoundRobin sends each op to the next target in turn
+	RouteRoundRobin Routing = "round-robin"
+
+	// RouteSession sends every op of a recorded session to the same target
+	RouteSession Routing = "session"
+)
+
+// Target is one replay destination with its own senders
+type Target struct {
+	// Name labels the target in per-target stats (e.g. its URI)
+	Name string
+
+	// RawSender is required in raw mode unless DryRun is set
+	RawSender RawSender
+
+	// CommandSender is required in command mode unless DryRun is set
+	CommandSender CommandSender
+}
+
+// TargetStats counts the ops sent to one target
+type TargetStats struct {
+	// Name is the target's name
+	Name string
+
+	// SuccessfulOps is the number of operations sent to the target successfully
+	SuccessfulOps int
+
+	// FailedOps is the number of operations sent to the target that failed
+	FailedOps int
+}
+
+// validateTargets checks the configured targets and returns the list to route over
+// A config with only RawSender/CommandSender is treated as a single unnamed target.
+func validateTargets(config Config) ([]Target, error) {
+	targets := config.Targets
+	if len(targets) == 0 {
+		targets = []Target{{RawSender: config.RawSender, CommandSender: config.CommandSender}}
+	} else if config.RawSender != nil || config.CommandSender != nil {
+		return nil, fmt.Errorf("set either Targets or RawSender/CommandSender, not both")
+	}
+
+	switch config.Routing {
+	case "", RouteRoundRobin, RouteSession:
+	default:
+		return nil, fmt.Errorf("invalid routing '%s'. Must be '%s' or '%s'", config.Routing, RouteRoundRobin, RouteSession)
+	}
+
+	if len(targets) > 1 {
+		if config.CommandSenderForApp != nil {
+			return nil, fmt.Errorf("per-appName senders cannot be combined with multiple targets")
+		}
+		if config.CursorResponses != nil && config.Routing != RouteSession {
+			return nil, fmt.Errorf("cursor-id rewriting with multiple targets requires session routing")
+		}
+	}
+
+	if config.DryRun {
+		return targets, nil
+	}
+	for i, target := range targets {
+		name := target.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		switch config.Mode {
+		case ModeRaw:
+			if target.RawSender == nil {
+				if len(config.Targets) == 0 {
+					return nil, fmt.Errorf("raw mode requires a RawSender")
+				}
+				return nil, fmt.Errorf("raw mode requires a RawSender for target %s", name)
+			}
+			if config.RecordedResponses != nil || config.RecordResponses != nil {
+				if _, ok := target.RawSender.(RawResponseSender); !ok {
+					return nil, fmt.Errorf("asserting or recording responses in raw mode requires a RawSender that reads responses")
+				}
+			}
+			if config.DecompressFallback && target.CommandSender == nil {
+				if len(config.Targets) == 0 {
+					return nil, fmt.Errorf("decompress fallback requires a CommandSender")
+				}
+				return nil, fmt.Errorf("decompress fallback requires a CommandSender for target %s", name)
+			}
+		case ModeCommand:
+			if target.CommandSender == nil {
+				if len(config.Targets) == 0 {
+					return nil, fmt.Errorf("command mode requires a CommandSender")
+				}
+				return nil, fmt.Errorf("command mode requires a CommandSender for target %s", name)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// route picks the target for a packet
+func (r *Replayer) route(packet *reader.Packet) int {
+	n := len(r.targets)
+	if n <= 1 {
+		return 0
+	}
+	if r.config.Routing == RouteSession {
+		return int(packet.SessionID % uint64(n))
+	}
+	i := r.nextTarget
+	r.nextTarget = (r.nextTarget + 1) % n
+	return i
+}
+
+// countTarget adds the ops counted since before to the current target's stats
+func (r *Replayer) countTarget(stats *Stats, before Stats) {
+	if len(stats.Targets) == 0 {
+		return
+	}
+	ts := &stats.Targets[r.target]
+	ts.SuccessfulOps += stats.SuccessfulOps - before.SuccessfulOps
+	ts.FailedOps += stats.FailedOps - before.FailedOps
+}
@@ -0,0 +1,109 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// targetPackets builds six finds spread over sessions 1-3, two per session
+// Each find's comment is its session ID, so senders can tell where it came from.
+func targetPackets(t *testing.T) []*reader.Packet {
+	var packets []*reader.Packet
+	for i := 0; i < 6; i++ {
+		session := i%3 + 1
+		packets = append(packets, buildCommandPacket(t, uint64(session), 0,
+			bson.D{{Key: "find", Value: "users"}, {Key: "comment", Value: int32(session)}, {Key: "$db", Value: "app"}}))
+	}
+	return packets
+}
+
+// commentSessions returns the session IDs carried in the comments of sent commands
+func commentSessions(s *recordingCommandSender) []int32 {
+	var sessions []int32
+	for _, cmd := range s.commands {
+		session, _ := cmd["comment"].(int32)
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func TestRun_TargetsRoundRobin(t *testing.T) {
+	a, b := &recordingCommandSender{}, &failingCommandSender{fail: "find"}
+	r, err := New(Config{
+		Mode: ModeCommand,
+		Targets: []Target{
+			{Name: "a", CommandSender: a},
+			{Name: "b", CommandSender: b},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	stats, err := r.Run(context.Background(), &sliceSource{packets: targetPackets(t)})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(a.commands) != 3 {
+		t.Errorf("target a got %d commands, want 3", len(a.commands))
+	}
+	want := []TargetStats{{Name: "a", SuccessfulOps: 3}, {Name: "b", FailedOps: 3}}
+	if len(stats.Targets) != 2 || stats.Targets[0] != want[0] || stats.Targets[1] != want[1] {
+		t.Errorf("Targets = %+v, want %+v", stats.Targets, want)
+	}
+	if stats.SuccessfulOps != 3 || stats.FailedOps != 3 {
+		t.Errorf("totals = %d ok / %d failed, want 3 / 3", stats.SuccessfulOps, stats.FailedOps)
+	}
+}
+
+func TestRun_TargetsSessionAffinity(t *testing.T) {
+	senders := []*recordingCommandSender{{}, {}}
+	r, err := New(Config{
+		Mode:    ModeCommand,
+		Routing: RouteSession,
+		Targets: []Target{
+			{Name: "a", CommandSender: senders[0]},
+			{Name: "b", CommandSender: senders[1]},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := r.Run(context.Background(), &sliceSource{packets: targetPackets(t)}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Session ID mod 2: sessions 1 and 3 go to b, session 2 to a
+	if got := fmt.Sprint(commentSessions(senders[0])); got != "[2 2]" {
+		t.Errorf("target a sessions = %s, want [2 2]", got)
+	}
+	if got := fmt.Sprint(commentSessions(senders[1])); got != "[1 3 1 3]" {
+		t.Errorf("target b sessions = %s, want [1 3 1 3]", got)
+	}
+}
+
+func TestNew_TargetsValidation(t *testing.T) {
+	snd := &recordingCommandSender{}
+	two := []Target{{Name: "a", CommandSender: snd}, {Name: "b", CommandSender: snd}}
+
+	if _, err := New(Config{Mode: ModeCommand, CommandSender: snd, Targets: two}); err == nil {
+		t.Error("expected error for both CommandSender and Targets")
+	}
+	if _, err := New(Config{Mode: ModeCommand, Targets: []Target{{Name: "a", CommandSender: snd}, {Name: "b"}}}); err == nil {
+		t.Error("expected error for a target without a CommandSender")
+	}
+	if _, err := New(Config{Mode: ModeCommand, Targets: two, Routing: "random"}); err == nil {
+		t.Error("expected error for unknown routing")
+	}
+	if _, err := New(Config{Mode: ModeCommand, Targets: two, CursorResponses: ResponseIndex{}}); err == nil {
+		t.Error("expected error for cursor rewriting with round-robin routing")
+	}
+	if _, err := New(Config{Mode: ModeCommand, Targets: two, CursorResponses: ResponseIndex{}, Routing: RouteSession}); err != nil {
+		t.Errorf("cursor rewriting with session routing: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package replay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// timingPlan tracks the simulated timeline ShowTiming prints instead of sleeping
+type timingPlan struct {
+	started     bool
+	firstOffset uint64
+
+	// at is the simulated time since the first op at which the last op was dispatched
+	at time.Duration
+}
+
+// planTiming computes the sleep pace would take before packet and returns the note
+// appended to its output line. Nothing sleeps: the simulated clock jumps to the op's
+// scaled offset. Packets recorded earlier than the op before them are dispatched
+// without sleeping, as in a timed replay, so the clock never runs backwards.
+func (r *Replayer) planTiming(stats *Stats, packet *reader.Packet) string {
+	plan := &r.timing
+	if !plan.started {
+		plan.started = true
+		plan.firstOffset = packet.Offset
+	}
+
+	at := plan.at
+	if r.config.Speed > 0 && packet.Offset > plan.firstOffset {
+		at = time.Duration(float64(packet.Offset-plan.firstOffset)/r.config.Speed) * time.Microsecond
+	}
+	var sleep time.Duration
+	if at > plan.at {
+		sleep = at - plan.at
+		plan.at = at
+	}
+	stats.PlannedDuration = plan.at
+
+	return fmt.Sprintf(" [sleep %v, at +%v]", sleep.Round(time.Microsecond), plan.at.Round(time.Microsecond))
+}
@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRun_ShowTiming(t *testing.T) {
+	var out strings.Builder
+	r, err := New(Config{Mode: ModeCommand, DryRun: true, ShowTiming: true, Speed: 2, Output: &out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Recorded at 0s, 10s, 8s (out of order) and 20s; at 2x the plan spans 10s
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+	src := &sliceSource{packets: []*reader.Packet{
+		buildCommandPacket(t, 1, 0, find),
+		buildCommandPacket(t, 1, 10_000_000, find),
+		buildCommandPacket(t, 2, 8_000_000, find),
+		buildCommandPacket(t, 1, 20_000_000, find),
+	}}
+
+	start := time.Now()
+	stats, err := r.Run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run took %v; ShowTiming should not sleep", elapsed)
+	}
+	if stats.PlannedDuration != 10*time.Second {
+		t.Errorf("PlannedDuration = %v, want 10s", stats.PlannedDuration)
+	}
+
+	want := []string{"[sleep 0s, at +0s]", "[sleep 5s, at +5s]", "[sleep 0s, at +5s]", "[sleep 5s, at +10s]"}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d = %q, want suffix %q", i, line, want[i])
+		}
+	}
+}
+
+func TestNew_ShowTimingRequiresDryRun(t *testing.T) {
+	_, err := New(Config{Mode: ModeCommand, CommandSender: &recordingCommandSender{}, ShowTiming: true})
+	if err == nil || !strings.Contains(err.Error(), "dry run") {
+		t.Errorf("New error = %v, want dry run required", err)
+	}
+}
+
+func TestRun_StartAt(t *testing.T) {
+	find := bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}
+
+	// Anchored 100ms ahead, the first op (recorded at 0s) waits for the anchor
+	start := time.Now()
+	r, err := New(Config{Mode: ModeCommand, DryRun: true, Speed: 1, StartAt: start.Add(100 * time.Millisecond)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	stats, err := r.Run(context.Background(), &sliceSource{packets: []*reader.Packet{buildCommandPacket(t, 1, 0, find)}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Run took %v; the op should wait for StartAt", elapsed)
+	}
+	if stats.SuccessfulOps != 1 {
+		t.Errorf("SuccessfulOps = %d, want 1", stats.SuccessfulOps)
+	}
+
+	// Anchored 1s back, ops recorded at 0s and 0.5s are past due and 1.05s is still ahead
+	packets := func() PacketSource {
+		return &sliceSource{packets: []*reader.Packet{
+			buildCommandPacket(t, 1, 0, find),
+			buildCommandPacket(t, 1, 500_000, find),
+			buildCommandPacket(t, 1, 1_050_000, find),
+		}}
+	}
+	for _, skipPast := range []bool{false, true} {
+		r, err := New(Config{Mode: ModeCommand, DryRun: true, Speed: 1, StartAt: time.Now().Add(-time.Second), SkipPast: skipPast})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		stats, err := r.Run(context.Background(), packets())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		wantSent, wantSkipped := 3, 0
+		if skipPast {
+			wantSent, wantSkipped = 1, 2
+		}
+		if stats.SuccessfulOps != wantSent || stats.PastDueSkipped != wantSkipped || stats.SkippedPackets != wantSkipped {
+			t.Errorf("SkipPast=%v: sent/past-due/skipped = %d/%d/%d, want %d/%d/%d", skipPast,
+				stats.SuccessfulOps, stats.PastDueSkipped, stats.SkippedPackets, wantSent, wantSkipped, wantSkipped)
+		}
+		if stats.Duration > time.Second {
+			t.Errorf("SkipPast=%v: Run took %v; past-due ops should not wait", skipPast, stats.Duration)
+		}
+	}
+}
+
+func TestNew_StartAtValidation(t *testing.T) {
+	at := time.Now()
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{Mode: ModeCommand, DryRun: true, StartAt: at}, "speed > 0"},
+		{Config{Mode: ModeCommand, DryRun: true, Speed: 1, ShowTiming: true, StartAt: at}, "planned timing"},
+		{Config{Mode: ModeCommand, DryRun: true, Speed: 1, SkipPast: true}, "requires a start time"},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.config); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New error = %v, want %q", err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TransformFunc mutates a command before it is sent in command mode
+// Transforms may rewrite cmd.Document and cmd.Database; returning an error fails the op
+type TransformFunc func(cmd *sender.Command) error
+
+// readConcernCommands are the commands that accept a readConcern
+var readConcernCommands = map[string]bool{
+	"find":      true,
+	"aggregate": true,
+	"count":     true,
+	"distinct":  true,
+}
+
+// writeConcernCommands are the commands that accept a writeConcern
+var writeConcernCommands = map[string]bool{
+	"insert":           true,
+	"update":           true,
+	"delete":           true,
+	"findAndModify":    true,
+	"create":           true,
+	"drop":             true,
+	"createIndexes":    true,
+	"dropIndexes":      true,
+	"collMod":          true,
+	"renameCollection": true,
+}
+
+// RenameNamespace returns a transform that redirects commands from one namespace to another
+// Namespaces are either "db" (rewrite the database only) or "db.collection"
+// (rewrite both the database and the collection named by the command, including
+// getMore's "collection" field so cursors follow their find or aggregate)
+func RenameNamespace(from, to string) (TransformFunc, error) {
+	fromDB, fromColl := splitNamespace(from)
+	toDB, toColl := splitNamespace(to)
+
+	if fromDB == "" || toDB == "" {
+		return nil, fmt.Errorf("invalid namespace rename %q -> %q: database is required", from, to)
+	}
+	if (fromColl == "") != (toColl == "") {
+		return nil, fmt.Errorf("invalid namespace rename %q -> %q: both sides must be 'db' or 'db.collection'", from, to)
+	}
+
+	return func(cmd *sender.Command) error {
+		if cmd.Database != fromDB {
+			return nil
+		}
+
+		if fromColl == "" {
+			cmd.Database = toDB
+			return nil
+		}
+
+		field := collectionField(cmd)
+		coll, ok := cmd.Document[field].(string)
+		if !ok || coll != fromColl {
+			return nil
+		}
+		cmd.Database = toDB
+		cmd.Document[field] = toColl
+		return nil
+	}, nil
+}
+
+// collectionField returns the command field naming the collection: the command field
+// itself (e.g. { find: "users" }), or "collection" for getMore, whose command value is
+// the cursor id
+func collectionField(cmd *sender.Command) string {
+	if cmd.Name == "getMore" {
+		return "collection"
+	}
+	return cmd.Name
+}
+
+// OverrideReadConcern returns a transform that sets readConcern.level on read commands
+func OverrideReadConcern(level string) TransformFunc {
+	return func(cmd *sender.Command) error {
+		if readConcernCommands[cmd.Name] {
+			cmd.Document["readConcern"] = bson.M{"level": level}
+		}
+		return nil
+	}
+}
+
+// OverrideWriteConcern returns a transform that sets the writeConcern on write commands
+func OverrideWriteConcern(writeConcern bson.M) TransformFunc {
+	return func(cmd *sender.Command) error {
+		if writeConcernCommands[cmd.Name] {
+			cmd.Document["writeConcern"] = writeConcern
+		}
+		return nil
+	}
+}
+
+// StripMaxTime returns a transform that removes maxTimeMS from every command
+// A recorded time limit can make ops time out spuriously on a slower target
+func StripMaxTime() TransformFunc {
+	return func(cmd *sender.Command) error {
+		delete(cmd.Document, "maxTimeMS")
+		return nil
+	}
+}
+
+// InjectComment returns a transform that sets (or overrides) the comment on every command
+// so replayed ops can be picked out in the target's logs and profiler
+func InjectComment(comment string) TransformFunc {
+	return func(cmd *sender.Command) error {
+		cmd.Document["comment"] = comment
+		return nil
+	}
+}
+
+// splitNamespace splits "db.collection" into its parts
+// Collection names may themselves contain dots, so only the first dot separates them
+func splitNamespace(ns string) (string, string) {
+	if idx := strings.Index(ns, "."); idx >= 0 {
+		return ns[:idx], ns[idx+1:]
+	}
+	return ns, ""
+}
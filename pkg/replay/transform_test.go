@@ -0,0 +1,163 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRenameNamespace(t *testing.T) {
+	tests := []struct {
+		name         string
+		from, to     string
+		cmd          *sender.Command
+		expectedDB   string
+		expectedColl string
+	}{
+		{
+			name:         "database only",
+			from:         "prod",
+			to:           "staging",
+			cmd:          &sender.Command{Database: "prod", Name: "find", Document: bson.M{"find": "users"}},
+			expectedDB:   "staging",
+			expectedColl: "users",
+		},
+		{
+			name:         "database and collection",
+			from:         "prod.users",
+			to:           "staging.users_copy",
+			cmd:          &sender.Command{Database: "prod", Name: "insert", Document: bson.M{"insert": "users"}},
+			expectedDB:   "staging",
+			expectedColl: "users_copy",
+		},
+		{
+			name:         "other collection untouched",
+			from:         "prod.users",
+			to:           "staging.users_copy",
+			cmd:          &sender.Command{Database: "prod", Name: "insert", Document: bson.M{"insert": "orders"}},
+			expectedDB:   "prod",
+			expectedColl: "orders",
+		},
+		{
+			name:         "other database untouched",
+			from:         "prod",
+			to:           "staging",
+			cmd:          &sender.Command{Database: "test", Name: "find", Document: bson.M{"find": "users"}},
+			expectedDB:   "test",
+			expectedColl: "users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := RenameNamespace(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("RenameNamespace failed: %v", err)
+			}
+			if err := transform(tt.cmd); err != nil {
+				t.Fatalf("transform failed: %v", err)
+			}
+			if tt.cmd.Database != tt.expectedDB {
+				t.Errorf("Database = %q, want %q", tt.cmd.Database, tt.expectedDB)
+			}
+			if coll := tt.cmd.Document[tt.cmd.Name]; coll != tt.expectedColl {
+				t.Errorf("collection = %v, want %q", coll, tt.expectedColl)
+			}
+		})
+	}
+}
+
+func TestRenameNamespace_GetMoreFollowsFind(t *testing.T) {
+	transform, err := RenameNamespace("prod.users", "staging.users_copy")
+	if err != nil {
+		t.Fatalf("RenameNamespace failed: %v", err)
+	}
+
+	find := &sender.Command{Database: "prod", Name: "find", Document: bson.M{"find": "users", "batchSize": int32(2)}}
+	getMore := &sender.Command{Database: "prod", Name: "getMore", Document: bson.M{"getMore": int64(123), "collection": "users"}}
+	other := &sender.Command{Database: "prod", Name: "getMore", Document: bson.M{"getMore": int64(456), "collection": "orders"}}
+	for _, cmd := range []*sender.Command{find, getMore, other} {
+		if err := transform(cmd); err != nil {
+			t.Fatalf("transform failed: %v", err)
+		}
+	}
+
+	if find.Database != "staging" || find.Document["find"] != "users_copy" {
+		t.Errorf("find = %s %v, want staging.users_copy", find.Database, find.Document)
+	}
+	if getMore.Database != "staging" || getMore.Document["collection"] != "users_copy" || getMore.Document["getMore"] != int64(123) {
+		t.Errorf("getMore = %s %v, want staging.users_copy with the cursor id kept", getMore.Database, getMore.Document)
+	}
+	if other.Database != "prod" || other.Document["collection"] != "orders" {
+		t.Errorf("getMore on another collection = %s %v, want it untouched", other.Database, other.Document)
+	}
+}
+
+func TestRenameNamespace_Invalid(t *testing.T) {
+	if _, err := RenameNamespace("prod.users", "staging"); err == nil {
+		t.Error("expected error when mixing 'db' and 'db.collection'")
+	}
+	if _, err := RenameNamespace("", "staging"); err == nil {
+		t.Error("expected error for empty database")
+	}
+}
+
+func TestOverrideConcerns(t *testing.T) {
+	find := &sender.Command{Database: "app", Name: "find", Document: bson.M{"find": "users"}}
+	insert := &sender.Command{Database: "app", Name: "insert", Document: bson.M{"insert": "users"}}
+
+	readConcern := OverrideReadConcern("majority")
+	writeConcern := OverrideWriteConcern(bson.M{"w": "majority"})
+
+	for _, cmd := range []*sender.Command{find, insert} {
+		if err := readConcern(cmd); err != nil {
+			t.Fatalf("read concern transform failed: %v", err)
+		}
+		if err := writeConcern(cmd); err != nil {
+			t.Fatalf("write concern transform failed: %v", err)
+		}
+	}
+
+	if _, ok := find.Document["readConcern"]; !ok {
+		t.Error("readConcern not set on find")
+	}
+	if _, ok := find.Document["writeConcern"]; ok {
+		t.Error("writeConcern unexpectedly set on find")
+	}
+	if _, ok := insert.Document["writeConcern"]; !ok {
+		t.Error("writeConcern not set on insert")
+	}
+	if _, ok := insert.Document["readConcern"]; ok {
+		t.Error("readConcern unexpectedly set on insert")
+	}
+}
+
+func TestStripMaxTime(t *testing.T) {
+	cmd := &sender.Command{Database: "app", Name: "find", Document: bson.M{"find": "users", "maxTimeMS": int64(50), "comment": "dashboard"}}
+
+	if err := StripMaxTime()(cmd); err != nil {
+		t.Fatalf("transform failed: %v", err)
+	}
+	if _, ok := cmd.Document["maxTimeMS"]; ok {
+		t.Error("maxTimeMS not removed")
+	}
+	if cmd.Document["comment"] != "dashboard" || cmd.Document["find"] != "users" {
+		t.Errorf("other fields changed: %v", cmd.Document)
+	}
+}
+
+func TestInjectComment(t *testing.T) {
+	recorded := &sender.Command{Database: "app", Name: "find", Document: bson.M{"find": "users", "comment": "dashboard"}}
+	bare := &sender.Command{Database: "app", Name: "insert", Document: bson.M{"insert": "users"}}
+
+	inject := InjectComment("replay-run-7")
+	for _, cmd := range []*sender.Command{recorded, bare} {
+		if err := inject(cmd); err != nil {
+			t.Fatalf("transform failed: %v", err)
+		}
+		if got := cmd.Document["comment"]; got != "replay-run-7" {
+			t.Errorf("%s comment = %v, want replay-run-7", cmd.Name, got)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+// Package scriptgen renders recorded MongoDB commands as mongosh statements
+// It backs the script-gen tool and replay's --echo-script output.
+package scriptgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Generate returns the mongosh representation of a command document
+// Internal driver/server fields are removed from a copy of doc first; doc is not modified.
+// Commands without a dedicated shell helper are rendered as runCommand.
+func Generate(database, command string, doc bson.M) (string, error) {
+	doc, err := cleanInternalFields(doc, 1)
+	if err != nil {
+		return "", err
+	}
+
+	switch command {
+	case "insert":
+		return Insert(doc, database)
+	case "update":
+		return Update(doc, database)
+	case "delete":
+		return Delete(doc, database)
+	case "find":
+		return Find(doc, database)
+	case "aggregate":
+		return Aggregate(doc, database)
+	case "findAndModify":
+		return FindAndModify(doc, database)
+	case "createIndexes":
+		return CreateIndexes(doc, database)
+	case "dropIndexes":
+		return DropIndexes(doc, database)
+	case "create":
+		return Create(doc, database)
+	case "drop":
+		return Drop(doc, database)
+	default:
+		// For other commands, just output as runCommand
+		// (already cleaned of internal fields above)
+		return RunCommand(doc, command, database)
+	}
+}
+
+// GenerateCommand returns the mongosh representation of an extracted command
+// Commands that can't be rendered come back as a single "//" comment line naming
+// the command and the reason, so the result is always safe to paste into a script.
+func GenerateCommand(cmd *sender.Command) string {
+	script, err := Generate(cmd.Database, cmd.Name, cmd.Document)
+	if err != nil {
+		return fmt.Sprintf("// %s.%s: could not generate script: %v", cmd.Database, cmd.Name, err)
+	}
+	return script
+}
+
+// GeneratePacket returns the mongosh representation of a recorded OP_MSG request
+// database is the target database (the packet's $db, or a placeholder if it has none)
+func GeneratePacket(packet *reader.Packet, database string) (string, error) {
+	command := packet.ExtractCommandName()
+	if command == "" {
+		return "", fmt.Errorf("failed to extract command name")
+	}
+
+	opCode := packet.GetOpCode()
+	if opCode != 2013 { // OP_MSG
+		return "", fmt.Errorf("unsupported opcode: %d", opCode)
+	}
+
+	// OP_MSG structure:
+	// Header: 16 bytes (already part of Message)
+	// Flags: 4 bytes
+	// Sections: variable
+	//   Section kind: 1 byte
+	//   For kind 0: BSON document
+
+	if len(packet.Message) < reader.OpMsgBodyOffset()+reader.BSONLengthSize {
+		return "", fmt.Errorf("packet too short")
+	}
+
+	// Skip to BSON document (after header + flags + section kind)
+	offset := reader.OpMsgBodyOffset()
+
+	var doc bson.M
+	if err := bson.Unmarshal(packet.Message[offset:], &doc); err != nil {
+		return "", fmt.Errorf("failed to unmarshal BSON: %w", err)
+	}
+
+	return Generate(database, command, doc)
+}
+
+// maxCleanDepth bounds the nesting cleanInternalFields recurses into
+const maxCleanDepth = 100
+
+// cleanInternalFields removes driver/server internal fields from BSON documents
+// Nested bson.D values (how the driver decodes embedded documents into a bson.M)
+// become bson.M so the generators can look fields up by name.
+// depth is the document's nesting level (top level = 1)
+func cleanInternalFields(doc bson.M, depth int) (bson.M, error) {
+	if depth > maxCleanDepth {
+		return nil, fmt.Errorf("document nesting exceeds %d levels", maxCleanDepth)
+	}
+
+	cleaned := bson.M{}
+
+	// List of internal driver/server fields to remove
+	internalFields := map[string]bool{
+		"$clusterTime":     true,
+		"$db":              true,
+		"$readPreference":  true,
+		"lsid":             true,
+		"txnNumber":        true,
+		"autocommit":       true,
+		"startTransaction": true,
+	}
+
+	for key, value := range doc {
+		// Skip internal fields
+		if internalFields[key] {
+			continue
+		}
+
+		// Recursively clean nested documents
+		var err error
+		switch v := value.(type) {
+		case bson.M:
+			cleaned[key], err = cleanInternalFields(v, depth+1)
+		case bson.D:
+			cleaned[key], err = cleanInternalFields(toM(v), depth+1)
+		case bson.A:
+			cleaned[key], err = cleanInternalFieldsArray(v, depth+1)
+		default:
+			cleaned[key] = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cleaned, nil
+}
+
+// cleanInternalFieldsArray recursively cleans arrays
+func cleanInternalFieldsArray(arr bson.A, depth int) (bson.A, error) {
+	if depth > maxCleanDepth {
+		return nil, fmt.Errorf("document nesting exceeds %d levels", maxCleanDepth)
+	}
+
+	cleaned := bson.A{}
+
+	for _, item := range arr {
+		switch v := item.(type) {
+		case bson.M:
+			c, err := cleanInternalFields(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			cleaned = append(cleaned, c)
+		case bson.D:
+			c, err := cleanInternalFields(toM(v), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			cleaned = append(cleaned, c)
+		case bson.A:
+			c, err := cleanInternalFieldsArray(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			cleaned = append(cleaned, c)
+		default:
+			cleaned = append(cleaned, item)
+		}
+	}
+
+	return cleaned, nil
+}
+
+// The per-command functions below expect a document already cleaned of internal
+// fields with nested documents as bson.M, as Generate passes them
+
+// toM converts an ordered document to a map
+func toM(d bson.D) bson.M {
+	m := make(bson.M, len(d))
+	for _, e := range d {
+		m[e.Key] = e.Value
+	}
+	return m
+}
+
+// Insert renders an insert command as insertOne (one document) or insertMany
+func Insert(doc bson.M, database string) (string, error) {
+	coll, ok := doc["insert"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	documents, ok := doc["documents"].(bson.A)
+	if !ok {
+		return "", fmt.Errorf("missing documents array")
+	}
+
+	// Use insertMany if multiple documents, insertOne if single document
+	if len(documents) == 1 {
+		jsonBytes, err := json.MarshalIndent(documents[0], "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.insertOne(%s);", database, coll, string(jsonBytes)), nil
+	}
+
+	// Multiple documents - use insertMany
+	jsonBytes, err := json.MarshalIndent(documents, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.insertMany(%s);", database, coll, string(jsonBytes)), nil
+}
+
+// Update renders each update statement as replaceOne, updateOne or updateMany
+func Update(doc bson.M, database string) (string, error) {
+	coll, ok := doc["update"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	updates, ok := doc["updates"].(bson.A)
+	if !ok {
+		return "", fmt.Errorf("missing updates array")
+	}
+
+	var lines []string
+	for _, u := range updates {
+		update, ok := u.(bson.M)
+		if !ok {
+			continue
+		}
+
+		filter := update["q"]
+		updateDoc := update["u"]
+		multi := update["multi"]
+
+		filterJSON, _ := json.MarshalIndent(filter, "", "  ")
+		updateJSON, _ := json.MarshalIndent(updateDoc, "", "  ")
+
+		// Check if updateDoc is a replacement (no atomic operators) or an update
+		isReplacement := true
+		if updateDocMap, ok := updateDoc.(bson.M); ok {
+			for key := range updateDocMap {
+				if strings.HasPrefix(key, "$") {
+					isReplacement = false
+					break
+				}
+			}
+		}
+
+		if isReplacement {
+			// Full document replacement - use replaceOne
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.replaceOne(\n  %s,\n  %s\n);",
+				database, coll, string(filterJSON), string(updateJSON)))
+		} else if multi == true {
+			// Update with operators - updateMany
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.updateMany(\n  %s,\n  %s\n);",
+				database, coll, string(filterJSON), string(updateJSON)))
+		} else {
+			// Update with operators - updateOne
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.updateOne(\n  %s,\n  %s\n);",
+				database, coll, string(filterJSON), string(updateJSON)))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Delete renders each delete statement as deleteOne (limit 1) or deleteMany
+func Delete(doc bson.M, database string) (string, error) {
+	coll, ok := doc["delete"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	deletes, ok := doc["deletes"].(bson.A)
+	if !ok {
+		return "", fmt.Errorf("missing deletes array")
+	}
+
+	var lines []string
+	for _, d := range deletes {
+		del, ok := d.(bson.M)
+		if !ok {
+			continue
+		}
+
+		filter := del["q"]
+		limit := del["limit"]
+
+		filterJSON, _ := json.MarshalIndent(filter, "", "  ")
+
+		// limit: 0 = deleteMany, limit: 1 = deleteOne
+		if limit == int32(1) || limit == int64(1) {
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.deleteOne(%s);", database, coll, string(filterJSON)))
+		} else {
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.deleteMany(%s);", database, coll, string(filterJSON)))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Find renders a find command with its cursor modifiers chained
+func Find(doc bson.M, database string) (string, error) {
+	coll, ok := doc["find"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	filter := doc["filter"]
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	filterJSON, _ := json.MarshalIndent(filter, "", "  ")
+
+	// Chain cursor modifiers in the order mongosh applies them
+	var modifiers []string
+
+	if projection, ok := doc["projection"].(bson.M); ok && len(projection) > 0 {
+		projJSON, _ := json.MarshalIndent(projection, "", "  ")
+		modifiers = append(modifiers, fmt.Sprintf(".project(%s)", string(projJSON)))
+	}
+
+	if sort, ok := doc["sort"].(bson.M); ok && len(sort) > 0 {
+		sortJSON, _ := json.MarshalIndent(sort, "", "  ")
+		modifiers = append(modifiers, fmt.Sprintf(".sort(%s)", string(sortJSON)))
+	}
+
+	limit, hasLimit := toInt64(doc["limit"])
+	batchSize, hasBatchSize := toInt64(doc["batchSize"])
+	singleBatch, _ := doc["singleBatch"].(bool)
+
+	if singleBatch && (hasLimit || hasBatchSize) {
+		// A negative limit returns a single batch of at most n documents and closes the cursor
+		n := limit
+		if !hasLimit || (hasBatchSize && batchSize < limit) {
+			n = batchSize
+		}
+		modifiers = append(modifiers, fmt.Sprintf(".limit(-%d)", n))
+	} else {
+		if hasLimit {
+			modifiers = append(modifiers, fmt.Sprintf(".limit(%d)", limit))
+		}
+		if hasBatchSize {
+			modifiers = append(modifiers, fmt.Sprintf(".batchSize(%d)", batchSize))
+		}
+	}
+
+	if len(modifiers) == 0 {
+		return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(%s);", database, coll, string(filterJSON)), nil
+	}
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.find(\n  %s\n)%s;", database, coll, string(filterJSON), strings.Join(modifiers, "")), nil
+}
+
+// toInt64 converts a BSON numeric value to int64
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// Aggregate renders an aggregate command
+func Aggregate(doc bson.M, database string) (string, error) {
+	coll, ok := doc["aggregate"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	pipeline, ok := doc["pipeline"].(bson.A)
+	if !ok {
+		return "", fmt.Errorf("missing pipeline")
+	}
+
+	pipelineJSON, _ := json.MarshalIndent(pipeline, "", "  ")
+
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.aggregate(%s);", database, coll, string(pipelineJSON)), nil
+}
+
+// FindAndModify renders a findAndModify command with its options as the argument
+func FindAndModify(doc bson.M, database string) (string, error) {
+	coll, ok := doc["findAndModify"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	// The collection name is the method's receiver, not an argument
+	args := bson.M{}
+	for key, value := range doc {
+		if key != "findAndModify" {
+			args[key] = value
+		}
+	}
+
+	argsJSON, _ := json.MarshalIndent(args, "", "  ")
+
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.findAndModify(%s);", database, coll, string(argsJSON)), nil
+}
+
+// CreateIndexes renders each index spec as createIndex
+func CreateIndexes(doc bson.M, database string) (string, error) {
+	coll, ok := doc["createIndexes"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	indexes, ok := doc["indexes"].(bson.A)
+	if !ok {
+		return "", fmt.Errorf("missing indexes array")
+	}
+
+	var lines []string
+	for _, idx := range indexes {
+		indexDoc, ok := idx.(bson.M)
+		if !ok {
+			continue
+		}
+
+		key := indexDoc["key"]
+		keyJSON, _ := json.MarshalIndent(key, "", "  ")
+
+		// Build options
+		options := bson.M{}
+		if name, ok := indexDoc["name"].(string); ok {
+			options["name"] = name
+		}
+		if unique, ok := indexDoc["unique"].(bool); ok && unique {
+			options["unique"] = true
+		}
+
+		if len(options) > 0 {
+			optJSON, _ := json.MarshalIndent(options, "", "  ")
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.createIndex(%s, %s);", database, coll, string(keyJSON), string(optJSON)))
+		} else {
+			lines = append(lines, fmt.Sprintf("db.getSiblingDB(\"%s\").%s.createIndex(%s);", database, coll, string(keyJSON)))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// DropIndexes renders a dropIndexes command as dropIndex
+func DropIndexes(doc bson.M, database string) (string, error) {
+	coll, ok := doc["dropIndexes"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	index := doc["index"]
+	indexJSON, _ := json.Marshal(index)
+
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.dropIndex(%s);", database, coll, string(indexJSON)), nil
+}
+
+// Create renders a create command as createCollection
+func Create(doc bson.M, database string) (string, error) {
+	coll, ok := doc["create"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").createCollection(\"%s\");", database, coll), nil
+}
+
+// Drop renders a drop command
+func Drop(doc bson.M, database string) (string, error) {
+	coll, ok := doc["drop"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing collection name")
+	}
+
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").%s.drop();", database, coll), nil
+}
+
+// RunCommand renders any command document as runCommand
+func RunCommand(doc bson.M, cmd string, database string) (string, error) {
+	// Document is already cleaned by cleanInternalFields()
+	docJSON, _ := json.MarshalIndent(doc, "", "  ")
+	return fmt.Sprintf("db.getSiblingDB(\"%s\").runCommand(%s);", database, string(docJSON)), nil
+}
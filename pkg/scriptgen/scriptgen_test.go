@@ -0,0 +1,268 @@
+package scriptgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestGenerate_Commands(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		doc     bson.M
+		want    string
+	}{
+		{
+			name:    "insertOne",
+			command: "insert",
+			doc:     bson.M{"insert": "users", "documents": bson.A{bson.M{"a": int32(1)}}},
+			want:    "db.getSiblingDB(\"app\").users.insertOne({\n  \"a\": 1\n});",
+		},
+		{
+			name:    "insertMany",
+			command: "insert",
+			doc:     bson.M{"insert": "users", "documents": bson.A{bson.M{"a": int32(1)}, bson.M{"a": int32(2)}}},
+			want:    "db.getSiblingDB(\"app\").users.insertMany([\n  {\n    \"a\": 1\n  },\n  {\n    \"a\": 2\n  }\n]);",
+		},
+		{
+			name:    "replaceOne",
+			command: "update",
+			doc:     bson.M{"update": "users", "updates": bson.A{bson.M{"q": bson.M{}, "u": bson.M{"a": int32(1)}}}},
+			want:    "db.getSiblingDB(\"app\").users.replaceOne(\n  {},\n  {\n  \"a\": 1\n}\n);",
+		},
+		{
+			name:    "updateMany",
+			command: "update",
+			doc:     bson.M{"update": "users", "updates": bson.A{bson.M{"q": bson.M{}, "u": bson.M{"$inc": bson.M{"a": int32(1)}}, "multi": true}}},
+			want:    "db.getSiblingDB(\"app\").users.updateMany(\n  {},\n  {\n  \"$inc\": {\n    \"a\": 1\n  }\n}\n);",
+		},
+		{
+			name:    "deleteOne",
+			command: "delete",
+			doc:     bson.M{"delete": "users", "deletes": bson.A{bson.M{"q": bson.M{}, "limit": int32(1)}}},
+			want:    `db.getSiblingDB("app").users.deleteOne({});`,
+		},
+		{
+			name:    "deleteMany",
+			command: "delete",
+			doc:     bson.M{"delete": "users", "deletes": bson.A{bson.M{"q": bson.M{}, "limit": int32(0)}}},
+			want:    `db.getSiblingDB("app").users.deleteMany({});`,
+		},
+		{
+			name:    "aggregate",
+			command: "aggregate",
+			doc:     bson.M{"aggregate": "users", "pipeline": bson.A{}, "cursor": bson.M{}},
+			want:    `db.getSiblingDB("app").users.aggregate([]);`,
+		},
+		{
+			name:    "findAndModify",
+			command: "findAndModify",
+			doc:     bson.M{"findAndModify": "users", "remove": true},
+			want:    "db.getSiblingDB(\"app\").users.findAndModify({\n  \"remove\": true\n});",
+		},
+		{
+			name:    "createIndex with options",
+			command: "createIndexes",
+			doc:     bson.M{"createIndexes": "users", "indexes": bson.A{bson.M{"key": bson.M{"a": int32(1)}, "name": "a_1", "unique": true}}},
+			want:    "db.getSiblingDB(\"app\").users.createIndex({\n  \"a\": 1\n}, {\n  \"name\": \"a_1\",\n  \"unique\": true\n});",
+		},
+		{
+			name:    "dropIndex",
+			command: "dropIndexes",
+			doc:     bson.M{"dropIndexes": "users", "index": "a_1"},
+			want:    `db.getSiblingDB("app").users.dropIndex("a_1");`,
+		},
+		{
+			name:    "createCollection",
+			command: "create",
+			doc:     bson.M{"create": "users"},
+			want:    `db.getSiblingDB("app").createCollection("users");`,
+		},
+		{
+			name:    "drop",
+			command: "drop",
+			doc:     bson.M{"drop": "users"},
+			want:    `db.getSiblingDB("app").users.drop();`,
+		},
+		{
+			name:    "runCommand without internal fields",
+			command: "ping",
+			doc:     bson.M{"ping": int32(1), "$db": "app", "lsid": bson.M{"id": "x"}},
+			want:    "db.getSiblingDB(\"app\").runCommand({\n  \"ping\": 1\n});",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Generate("app", tt.command, tt.doc)
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Generate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		doc     bson.M
+	}{
+		{"insert without documents", "insert", bson.M{"insert": "users"}},
+		{"update without collection", "update", bson.M{"updates": bson.A{}}},
+		{"aggregate without pipeline", "aggregate", bson.M{"aggregate": "users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate("app", tt.command, tt.doc); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestGenerate_TooDeep(t *testing.T) {
+	doc := bson.M{"x": int32(1)}
+	for i := 0; i < maxCleanDepth; i++ {
+		doc = bson.M{"x": doc}
+	}
+	doc["ping"] = int32(1)
+
+	if _, err := Generate("app", "ping", doc); err == nil {
+		t.Error("expected an error for a document nested deeper than maxCleanDepth")
+	}
+}
+
+func TestGeneratePacket(t *testing.T) {
+	body, err := bson.Marshal(bson.D{{Key: "drop", Value: "users"}, {Key: "$db", Value: "app"}})
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+4+1+len(body)))
+	binary.Write(buf, binary.LittleEndian, int32(1))
+	binary.Write(buf, binary.LittleEndian, int32(0))
+	binary.Write(buf, binary.LittleEndian, int32(2013))
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // flags
+	buf.WriteByte(0)                                  // section kind 0
+	buf.Write(body)
+
+	got, err := GeneratePacket(&reader.Packet{Message: buf.Bytes()}, "app")
+	if err != nil {
+		t.Fatalf("GeneratePacket failed: %v", err)
+	}
+	if want := `db.getSiblingDB("app").users.drop();`; got != want {
+		t.Errorf("GeneratePacket() = %q, want %q", got, want)
+	}
+
+	if _, err := GeneratePacket(&reader.Packet{}, "app"); err == nil {
+		t.Error("expected an error for an empty packet")
+	}
+}
+
+func TestFindAndModify_DoesNotModifyDocument(t *testing.T) {
+	doc := bson.M{"findAndModify": "users", "remove": true}
+	if _, err := FindAndModify(doc, "app"); err != nil {
+		t.Fatalf("FindAndModify failed: %v", err)
+	}
+	if doc["findAndModify"] != "users" {
+		t.Error("FindAndModify removed the collection name from the caller's document")
+	}
+}
+
+func TestFind_CursorModifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  bson.M
+		want string
+	}{
+		{
+			name: "no modifiers",
+			doc:  bson.M{"find": "users"},
+			want: `db.getSiblingDB("app").users.find({});`,
+		},
+		{
+			name: "sort, limit and batchSize chain",
+			doc: bson.M{
+				"find":      "users",
+				"sort":      bson.M{"age": int32(1)},
+				"limit":     int64(50),
+				"batchSize": int32(10),
+			},
+			want: ".sort({\n  \"age\": 1\n}).limit(50).batchSize(10);",
+		},
+		{
+			name: "singleBatch with limit",
+			doc:  bson.M{"find": "users", "limit": int32(5), "singleBatch": true},
+			want: ").limit(-5);",
+		},
+		{
+			name: "singleBatch with smaller batchSize",
+			doc:  bson.M{"find": "users", "limit": int32(20), "batchSize": int32(3), "singleBatch": true},
+			want: ").limit(-3);",
+		},
+		{
+			name: "singleBatch with batchSize only",
+			doc:  bson.M{"find": "users", "batchSize": int32(7), "singleBatch": true},
+			want: ").limit(-7);",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(tt.doc, "app")
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			if !strings.HasSuffix(got, tt.want) {
+				t.Errorf("Find() = %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCommand_NestedDocuments(t *testing.T) {
+	// Embedded documents decode into a bson.M as bson.D
+	cmd := &sender.Command{
+		Database: "app",
+		Name:     "update",
+		Document: bson.M{
+			"update": "users",
+			"updates": bson.A{
+				bson.D{
+					{Key: "q", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+					{Key: "u", Value: bson.D{{Key: "$set", Value: bson.D{{Key: "n", Value: int32(2)}}}}},
+				},
+			},
+			"lsid": bson.D{{Key: "id", Value: "x"}},
+		},
+	}
+
+	got := GenerateCommand(cmd)
+	want := "db.getSiblingDB(\"app\").users.updateOne(\n  {\n  \"_id\": 1\n},\n  {\n  \"$set\": {\n    \"n\": 2\n  }\n}\n);"
+	if got != want {
+		t.Errorf("GenerateCommand() = %q, want %q", got, want)
+	}
+	if _, ok := cmd.Document["lsid"]; !ok {
+		t.Error("GenerateCommand modified the command document")
+	}
+}
+
+func TestGenerateCommand_Unrenderable(t *testing.T) {
+	cmd := &sender.Command{Database: "app", Name: "insert", Document: bson.M{"insert": "users"}}
+
+	got := GenerateCommand(cmd)
+	if !strings.HasPrefix(got, "// app.insert: could not generate script: ") {
+		t.Errorf("GenerateCommand() = %q, want a comment line", got)
+	}
+}
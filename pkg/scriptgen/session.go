@@ -0,0 +1,139 @@
+package scriptgen
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"github.com/fsnow/traffic-replay/pkg/sender"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// authCommands are the requests a driver sends to authenticate a connection
+var authCommands = map[string]bool{
+	"saslStart":    true,
+	"saslContinue": true,
+	"authenticate": true,
+	"getnonce":     true,
+}
+
+// SessionStats counts what WriteSessionScript wrote
+type SessionStats struct {
+	// Packets is the number of the session's packets read (requests and responses)
+	Packets int
+
+	// Operations is the number of requests written as runnable statements
+	Operations int
+
+	// Commented is the number of requests written as explanatory comments instead:
+	// cursor continuations, handshakes, authentication and unparseable requests
+	Commented int
+}
+
+// WriteSessionScript writes a mongosh script of one recorded session's requests, in order
+// Packets of other sessions and responses are skipped. Each statement is preceded by
+// a comment with its offset from the session's first packet. Requests mongosh can't
+// re-run as recorded (getMore/killCursors on recorded cursor ids, the connection
+// handshake, authentication) are written as comments explaining why. The returned
+// stats have Packets == 0 if the session isn't in the recording.
+func WriteSessionScript(w io.Writer, src reader.PacketIterator, sessionID uint64) (*SessionStats, error) {
+	stats := &SessionStats{}
+	var first uint64
+	for {
+		packet, err := src.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		if packet.SessionID != sessionID {
+			continue
+		}
+
+		stats.Packets++
+		if stats.Packets == 1 {
+			first = packet.Offset
+			fmt.Fprintf(w, "// Session %d\n", sessionID)
+			if packet.SessionMetadata != "" {
+				fmt.Fprintf(w, "// Metadata: %s\n", packet.SessionMetadata)
+			}
+			fmt.Fprintln(w)
+		}
+		if len(packet.Message) == 0 || !packet.IsRequest() {
+			continue
+		}
+
+		var elapsed uint64
+		if packet.Offset > first {
+			elapsed = packet.Offset - first
+		}
+		script, runnable := sessionStatement(packet)
+		fmt.Fprintf(w, "// [+%s]\n%s\n\n", reader.FormatOffset(elapsed), script)
+		if runnable {
+			stats.Operations++
+		} else {
+			stats.Commented++
+		}
+	}
+}
+
+// sessionStatement renders one request, reporting whether it is a runnable statement
+// rather than an explanatory comment
+func sessionStatement(packet *reader.Packet) (string, bool) {
+	name := packet.ExtractCommandName()
+	if _, ok := sender.HandshakeClient(packet); ok {
+		return fmt.Sprintf("// %s (connection handshake): mongosh performs its own when it connects", name), false
+	}
+	if authCommands[name] {
+		return fmt.Sprintf("// %s (authentication): mongosh authenticates when it connects", name), false
+	}
+
+	cmd, err := sender.ExtractCommand(packet)
+	if err != nil {
+		return fmt.Sprintf("// %s: could not parse request: %v", orUnknown(name), err), false
+	}
+
+	switch cmd.Name {
+	case "getMore":
+		coll, _ := cmd.Document["collection"].(string)
+		return fmt.Sprintf("// getMore %s.%s (cursor %v): fetches the next batch of a cursor opened earlier in\n"+
+			"// the session; the statement that opened it returns a cursor mongosh iterates itself",
+			cmd.Database, coll, cmd.Document["getMore"]), false
+	case "killCursors":
+		coll, _ := cmd.Document["killCursors"].(string)
+		return fmt.Sprintf("// killCursors %s.%s (cursors %v): closed recorded cursors early; mongosh closes\n"+
+			"// its own cursors when they are exhausted",
+			cmd.Database, coll, cursorIDs(cmd.Document["cursors"])), false
+	}
+
+	script, err := Generate(cmd.Database, cmd.Name, cmd.Document)
+	if err != nil {
+		return fmt.Sprintf("// %s.%s: could not generate script: %v", cmd.Database, cmd.Name, err), false
+	}
+	return script, true
+}
+
+// cursorIDs formats killCursors' cursor id array
+func cursorIDs(v interface{}) string {
+	ids, ok := v.(bson.A)
+	if !ok {
+		return "?"
+	}
+	s := ""
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprint(id)
+	}
+	return s
+}
+
+// orUnknown labels a request whose command name couldn't be extracted
+func orUnknown(name string) string {
+	if name == "" {
+		return "(unknown command)"
+	}
+	return name
+}
@@ -0,0 +1,109 @@
+package scriptgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// packetSlice yields packets in order
+type packetSlice struct {
+	packets []*reader.Packet
+}
+
+func (s *packetSlice) Next() (*reader.Packet, error) {
+	if len(s.packets) == 0 {
+		return nil, io.EOF
+	}
+	p := s.packets[0]
+	s.packets = s.packets[1:]
+	return p, nil
+}
+
+// opMsgPacket builds an OP_MSG packet of session with a single body section
+func opMsgPacket(t *testing.T, session, offset uint64, requestID, responseTo int32, doc bson.D) *reader.Packet {
+	t.Helper()
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+4+1+len(body)))
+	binary.Write(buf, binary.LittleEndian, requestID)
+	binary.Write(buf, binary.LittleEndian, responseTo)
+	binary.Write(buf, binary.LittleEndian, int32(2013))
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // flags
+	buf.WriteByte(0)                                  // section kind 0
+	buf.Write(body)
+	return &reader.Packet{SessionID: session, Offset: offset, Message: buf.Bytes()}
+}
+
+func TestWriteSessionScript(t *testing.T) {
+	ok := bson.D{{Key: "ok", Value: 1.0}}
+	src := &packetSlice{packets: []*reader.Packet{
+		{SessionID: 1, Offset: 0, SessionMetadata: `{"remote":"10.0.0.1:5000"}`},
+		opMsgPacket(t, 1, 100, 1, 0, bson.D{
+			{Key: "hello", Value: 1},
+			{Key: "client", Value: bson.D{{Key: "application", Value: bson.D{{Key: "name", Value: "app1"}}}}},
+			{Key: "$db", Value: "admin"},
+		}),
+		opMsgPacket(t, 2, 150, 1, 0, bson.D{{Key: "insert", Value: "other"}, {Key: "documents", Value: bson.A{bson.D{}}}, {Key: "$db", Value: "app"}}),
+		opMsgPacket(t, 1, 200, 2, 0, bson.D{{Key: "saslStart", Value: 1}, {Key: "$db", Value: "admin"}}),
+		opMsgPacket(t, 1, 1200, 3, 0, bson.D{{Key: "find", Value: "users"}, {Key: "filter", Value: bson.D{{Key: "a", Value: int32(1)}}}, {Key: "$db", Value: "app"}}),
+		opMsgPacket(t, 1, 1300, 100, 3, ok),
+		opMsgPacket(t, 2, 1400, 2, 0, bson.D{{Key: "drop", Value: "other"}, {Key: "$db", Value: "app"}}),
+		opMsgPacket(t, 1, 1500, 4, 0, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "users"}, {Key: "$db", Value: "app"}}),
+		opMsgPacket(t, 1, 1600, 5, 0, bson.D{{Key: "killCursors", Value: "users"}, {Key: "cursors", Value: bson.A{int64(42)}}, {Key: "$db", Value: "app"}}),
+		opMsgPacket(t, 1, 1700, 6, 0, bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{bson.D{{Key: "q", Value: bson.D{}}, {Key: "limit", Value: int32(0)}}}}, {Key: "$db", Value: "app"}}),
+	}}
+
+	var out bytes.Buffer
+	stats, err := WriteSessionScript(&out, src, 1)
+	if err != nil {
+		t.Fatalf("WriteSessionScript failed: %v", err)
+	}
+	if stats.Packets != 8 || stats.Operations != 2 || stats.Commented != 4 {
+		t.Errorf("stats = %+v, want 8 packets, 2 operations, 4 commented", *stats)
+	}
+
+	script := out.String()
+	for _, want := range []string{
+		"// Session 1\n// Metadata: {\"remote\":\"10.0.0.1:5000\"}\n",
+		"// hello (connection handshake)",
+		"// saslStart (authentication)",
+		"// [+1.2ms]\ndb.getSiblingDB(\"app\").users.find(",
+		"// getMore app.users (cursor 42)",
+		"// killCursors app.users (cursors 42)",
+		"db.getSiblingDB(\"app\").users.deleteMany({});",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "other") {
+		t.Errorf("script includes session 2's operations:\n%s", script)
+	}
+	if find, del := strings.Index(script, ".find("), strings.Index(script, ".deleteMany("); find > del {
+		t.Errorf("operations out of order:\n%s", script)
+	}
+}
+
+func TestWriteSessionScript_UnknownSession(t *testing.T) {
+	src := &packetSlice{packets: []*reader.Packet{
+		opMsgPacket(t, 1, 0, 1, 0, bson.D{{Key: "ping", Value: 1}, {Key: "$db", Value: "admin"}}),
+	}}
+
+	var out bytes.Buffer
+	stats, err := WriteSessionScript(&out, src, 7)
+	if err != nil {
+		t.Fatalf("WriteSessionScript failed: %v", err)
+	}
+	if stats.Packets != 0 || out.Len() != 0 {
+		t.Errorf("got %d packets and %q for a session not in the recording", stats.Packets, out.String())
+	}
+}
@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// aggregateWriteStages are the pipeline stages that write their results to a collection
+var aggregateWriteStages = map[string]bool{
+	"$out":   true,
+	"$merge": true,
+}
+
+// AggregateWriteStage returns the terminal write stage ("$out" or "$merge") of an
+// aggregate command's pipeline, or "" if it has none or cmd is not an aggregate
+// Only the last stage is checked: the server rejects $out and $merge anywhere else.
+func AggregateWriteStage(cmd *Command) string {
+	if cmd.Name != "aggregate" {
+		return ""
+	}
+	pipeline, ok := cmd.Document["pipeline"].(bson.A)
+	if !ok || len(pipeline) == 0 {
+		return ""
+	}
+	return writeStageName(pipeline[len(pipeline)-1])
+}
+
+// StripAggregateWriteStage removes the terminal $out/$merge stage of an aggregate,
+// turning it into a read that returns the documents it would have written
+// Returns the removed stage's name, or "" if there was none (cmd is then unchanged).
+func StripAggregateWriteStage(cmd *Command) string {
+	stage := AggregateWriteStage(cmd)
+	if stage == "" {
+		return ""
+	}
+	pipeline := cmd.Document["pipeline"].(bson.A)
+	cmd.Document["pipeline"] = append(bson.A(nil), pipeline[:len(pipeline)-1]...)
+	return stage
+}
+
+// writeStageName returns a pipeline stage's operator if it is a write stage, else ""
+func writeStageName(stage interface{}) string {
+	var name string
+	switch s := stage.(type) {
+	case bson.D:
+		if len(s) == 1 {
+			name = s[0].Key
+		}
+	case bson.M:
+		if len(s) == 1 {
+			for k := range s {
+				name = k
+			}
+		}
+	}
+	if aggregateWriteStages[name] {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestAggregateWriteStage(t *testing.T) {
+	match := bson.D{{Key: "$match", Value: bson.D{{Key: "active", Value: true}}}}
+	aggregate := func(stages ...interface{}) *Command {
+		return &Command{Database: "app", Name: "aggregate", Document: bson.M{
+			"aggregate": "users",
+			"pipeline":  bson.A(stages),
+			"cursor":    bson.M{},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		cmd    *Command
+		want   string
+		remain int
+	}{
+		{"read only", aggregate(match), "", 1},
+		{"$out", aggregate(match, bson.D{{Key: "$out", Value: "active_users"}}), "$out", 1},
+		{"$merge as bson.M", aggregate(match, bson.M{"$merge": bson.M{"into": "active_users"}}), "$merge", 1},
+		{"$out not last", aggregate(bson.D{{Key: "$out", Value: "x"}}, match), "", 2},
+		{"empty pipeline", aggregate(), "", 0},
+		{"not an aggregate", &Command{Name: "find", Document: bson.M{"find": "users", "pipeline": bson.A{bson.D{{Key: "$out", Value: "x"}}}}}, "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AggregateWriteStage(tt.cmd); got != tt.want {
+				t.Errorf("AggregateWriteStage = %q, want %q", got, tt.want)
+			}
+			if got := StripAggregateWriteStage(tt.cmd); got != tt.want {
+				t.Errorf("StripAggregateWriteStage = %q, want %q", got, tt.want)
+			}
+			if got := len(tt.cmd.Document["pipeline"].(bson.A)); got != tt.remain {
+				t.Errorf("pipeline has %d stages after stripping, want %d", got, tt.remain)
+			}
+			if AggregateWriteStage(tt.cmd) != "" {
+				t.Errorf("write stage still present after stripping: %v", tt.cmd.Document["pipeline"])
+			}
+		})
+	}
+}
@@ -20,11 +20,40 @@ type Command struct {
 
 	// OriginalPacket is a reference to the original packet (optional)
 	OriginalPacket *reader.Packet
+
+	// RetryableWrite is true if lsid/txnNumber were preserved so the server
+	// can deduplicate re-sends of this write (see ExtractOptions)
+	RetryableWrite bool
+}
+
+// ExtractOptions controls how a Command is extracted from a packet
+type ExtractOptions struct {
+	// PreserveRetryableWrites keeps lsid and txnNumber on retryable writes that
+	// are not part of a transaction, so the server's retryable-write dedup
+	// prevents a re-sent write from being applied twice
+	PreserveRetryableWrites bool
+
+	// DefaultDatabase is used when no database can be extracted from the packet (no
+	// $db, or the extractor can't find it) instead of failing ("" = fail)
+	DefaultDatabase string
+}
+
+// retryableWriteCommands are the write commands the server can deduplicate by (lsid, txnNumber)
+var retryableWriteCommands = map[string]bool{
+	"insert":        true,
+	"update":        true,
+	"delete":        true,
+	"findAndModify": true,
 }
 
 // ExtractCommand extracts a Command from a recorded packet
 // It parses the BSON document and cleans internal fields
 func ExtractCommand(packet *reader.Packet) (*Command, error) {
+	return ExtractCommandWithOptions(packet, ExtractOptions{})
+}
+
+// ExtractCommandWithOptions extracts a Command from a recorded packet using the given options
+func ExtractCommandWithOptions(packet *reader.Packet, opts ExtractOptions) (*Command, error) {
 	// Only support OP_MSG (2013)
 	opCode := packet.GetOpCode()
 	if opCode != 2013 {
@@ -39,39 +68,99 @@ func ExtractCommand(packet *reader.Packet) (*Command, error) {
 
 	// Extract database name
 	database := packet.ExtractDatabase()
+	if database == "" {
+		database = opts.DefaultDatabase
+	}
 	if database == "" {
 		return nil, fmt.Errorf("failed to extract database name")
 	}
 
-	// Extract BSON document from OP_MSG
-	// Structure: Header (16) + Flags (4) + Section kind (1) + BSON document
-	if len(packet.Message) < 21 {
-		return nil, fmt.Errorf("packet too short to contain BSON document")
+	// Decode the OP_MSG sections (exactly one kind-0 body, any number of kind-1 sequences)
+	body, err := DecodeBody(packet.Message)
+	if err != nil {
+		return nil, err
 	}
 
-	offset := 16 + 4 + 1 // Skip header, flags, section kind
-	bsonData := packet.Message[offset:]
-
 	// Parse BSON document
 	var doc bson.M
-	if err := bson.Unmarshal(bsonData, &doc); err != nil {
+	if err := bson.Unmarshal(body.Document, &doc); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal BSON: %w", err)
 	}
 
+	// Fold document sequences (e.g. insert "documents") back into the command,
+	// since RunCommand sends a single document
+	for _, seq := range body.Sequences {
+		if _, exists := doc[seq.Identifier]; exists {
+			return nil, fmt.Errorf("document sequence %q duplicates a body field", seq.Identifier)
+		}
+		docs := make(bson.A, 0, len(seq.Documents))
+		for _, raw := range seq.Documents {
+			var d bson.M
+			if err := bson.Unmarshal(raw, &d); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %q sequence document: %w", seq.Identifier, err)
+			}
+			docs = append(docs, d)
+		}
+		doc[seq.Identifier] = docs
+	}
+
+	// Retryable writes keep their session identity when requested
+	retryable := opts.PreserveRetryableWrites && isRetryableWrite(cmdName, doc)
+	lsid, txnNumber := doc["lsid"], doc["txnNumber"]
+
 	// Clean internal fields
-	doc = cleanInternalFields(doc)
+	doc, err = cleanInternalFields(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if retryable {
+		doc["lsid"] = lsid
+		doc["txnNumber"] = txnNumber
+	}
 
 	return &Command{
 		Database:       database,
 		Name:           cmdName,
 		Document:       doc,
 		OriginalPacket: packet,
+		RetryableWrite: retryable,
 	}, nil
 }
 
+// isRetryableWrite returns true if the command is a retryable write outside a transaction
+// Transactions carry autocommit/startTransaction; their txnNumber belongs to the
+// transaction, not to a single retryable statement
+func isRetryableWrite(cmdName string, doc bson.M) bool {
+	if !retryableWriteCommands[cmdName] {
+		return false
+	}
+
+	if _, ok := doc["lsid"]; !ok {
+		return false
+	}
+	if _, ok := doc["txnNumber"]; !ok {
+		return false
+	}
+
+	_, hasAutocommit := doc["autocommit"]
+	_, hasStartTransaction := doc["startTransaction"]
+	return !hasAutocommit && !hasStartTransaction
+}
+
 // cleanInternalFields removes driver/server internal fields from BSON documents
 // This is the same logic used in script-gen
-func cleanInternalFields(doc bson.M) bson.M {
+// Returns an error wrapping ErrDocumentTooDeep if nesting exceeds MaxDocumentDepth
+func cleanInternalFields(doc bson.M) (bson.M, error) {
+	return cleanDocument(doc, 1)
+}
+
+// cleanDocument cleans a document at the given nesting depth (top level = 1)
+func cleanDocument(doc bson.M, depth int) (bson.M, error) {
+	if depth > MaxDocumentDepth {
+		return nil, fmt.Errorf("%w: more than %d levels", ErrDocumentTooDeep, MaxDocumentDepth)
+	}
+
 	cleaned := bson.M{}
 
 	// List of internal driver/server fields to remove
@@ -94,33 +183,45 @@ func cleanInternalFields(doc bson.M) bson.M {
 		}
 
 		// Recursively clean nested documents
+		var err error
 		switch v := value.(type) {
 		case bson.M:
-			cleaned[key] = cleanInternalFields(v)
+			cleaned[key], err = cleanDocument(v, depth+1)
 		case bson.A:
-			cleaned[key] = cleanInternalFieldsArray(v)
+			cleaned[key], err = cleanArray(v, depth+1)
 		default:
 			cleaned[key] = value
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return cleaned
+	return cleaned, nil
 }
 
-// cleanInternalFieldsArray recursively cleans internal fields from BSON arrays
-func cleanInternalFieldsArray(arr bson.A) bson.A {
+// cleanArray recursively cleans internal fields from a BSON array at the given depth
+func cleanArray(arr bson.A, depth int) (bson.A, error) {
+	if depth > MaxDocumentDepth {
+		return nil, fmt.Errorf("%w: more than %d levels", ErrDocumentTooDeep, MaxDocumentDepth)
+	}
+
 	cleaned := make(bson.A, len(arr))
 
 	for i, item := range arr {
+		var err error
 		switch v := item.(type) {
 		case bson.M:
-			cleaned[i] = cleanInternalFields(v)
+			cleaned[i], err = cleanDocument(v, depth+1)
 		case bson.A:
-			cleaned[i] = cleanInternalFieldsArray(v)
+			cleaned[i], err = cleanArray(v, depth+1)
 		default:
 			cleaned[i] = item
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return cleaned
+	return cleaned, nil
 }
@@ -1,8 +1,11 @@
 package sender
 
 import (
+	"bytes"
+	"encoding/binary"
 	"testing"
 
+	"github.com/fsnow/traffic-replay/pkg/reader"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -120,7 +123,10 @@ func TestCleanInternalFields(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := cleanInternalFields(tt.input)
+			result, err := cleanInternalFields(tt.input)
+			if err != nil {
+				t.Fatalf("cleanInternalFields() failed: %v", err)
+			}
 
 			// Compare the results
 			if !bsonEqual(result, tt.expected) {
@@ -205,3 +211,115 @@ func bsonArrayEqual(a, b bson.A) bool {
 
 	return true
 }
+
+// buildCommandPacket creates an OP_MSG request packet carrying the given command document
+func buildCommandPacket(t *testing.T, doc bson.D) *reader.Packet {
+	t.Helper()
+
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+4+1+len(body)))
+	binary.Write(buf, binary.LittleEndian, int32(1))    // requestID
+	binary.Write(buf, binary.LittleEndian, int32(0))    // responseTo
+	binary.Write(buf, binary.LittleEndian, int32(2013)) // OP_MSG
+	binary.Write(buf, binary.LittleEndian, uint32(0))   // flags
+	buf.WriteByte(0)                                    // section kind 0
+	buf.Write(body)
+
+	return &reader.Packet{Message: buf.Bytes()}
+}
+
+func TestExtractCommandWithOptions_RetryableWrites(t *testing.T) {
+	lsid := bson.D{{Key: "id", Value: bson.Binary{Subtype: 4, Data: make([]byte, 16)}}}
+
+	retryableInsert := bson.D{
+		{Key: "insert", Value: "users"},
+		{Key: "documents", Value: bson.A{bson.D{{Key: "name", Value: "Alice"}}}},
+		{Key: "lsid", Value: lsid},
+		{Key: "txnNumber", Value: int64(7)},
+		{Key: "$db", Value: "app"},
+	}
+	transactionInsert := bson.D{
+		{Key: "insert", Value: "users"},
+		{Key: "documents", Value: bson.A{bson.D{{Key: "name", Value: "Bob"}}}},
+		{Key: "lsid", Value: lsid},
+		{Key: "txnNumber", Value: int64(8)},
+		{Key: "autocommit", Value: false},
+		{Key: "startTransaction", Value: true},
+		{Key: "$db", Value: "app"},
+	}
+	retryableFind := bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "lsid", Value: lsid},
+		{Key: "txnNumber", Value: int64(9)},
+		{Key: "$db", Value: "app"},
+	}
+
+	tests := []struct {
+		name      string
+		doc       bson.D
+		preserve  bool
+		retryable bool
+	}{
+		{"preserved on retryable write", retryableInsert, true, true},
+		{"stripped by default", retryableInsert, false, false},
+		{"stripped inside a transaction", transactionInsert, true, false},
+		{"stripped on reads", retryableFind, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := ExtractCommandWithOptions(buildCommandPacket(t, tt.doc), ExtractOptions{
+				PreserveRetryableWrites: tt.preserve,
+			})
+			if err != nil {
+				t.Fatalf("ExtractCommandWithOptions failed: %v", err)
+			}
+
+			if cmd.RetryableWrite != tt.retryable {
+				t.Errorf("RetryableWrite = %v, want %v", cmd.RetryableWrite, tt.retryable)
+			}
+
+			_, hasLsid := cmd.Document["lsid"]
+			_, hasTxnNumber := cmd.Document["txnNumber"]
+			if hasLsid != tt.retryable || hasTxnNumber != tt.retryable {
+				t.Errorf("lsid present = %v, txnNumber present = %v, want both %v", hasLsid, hasTxnNumber, tt.retryable)
+			}
+
+			// Transaction fields are never preserved
+			if _, ok := cmd.Document["autocommit"]; ok {
+				t.Error("autocommit should always be stripped")
+			}
+		})
+	}
+}
+
+func TestExtractCommandWithOptions_DefaultDatabase(t *testing.T) {
+	packet := buildCommandPacket(t, bson.D{{Key: "listDatabases", Value: int32(1)}})
+
+	if _, err := ExtractCommand(packet); err == nil {
+		t.Error("expected an error for a command without $db")
+	}
+
+	cmd, err := ExtractCommandWithOptions(packet, ExtractOptions{DefaultDatabase: "admin"})
+	if err != nil {
+		t.Fatalf("ExtractCommandWithOptions failed: %v", err)
+	}
+	if cmd.Database != "admin" || cmd.Name != "listDatabases" {
+		t.Errorf("got %s.%s, want admin.listDatabases", cmd.Database, cmd.Name)
+	}
+
+	// A recorded $db still wins over the default
+	packet = buildCommandPacket(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})
+	cmd, err = ExtractCommandWithOptions(packet, ExtractOptions{DefaultDatabase: "admin"})
+	if err != nil {
+		t.Fatalf("ExtractCommandWithOptions failed: %v", err)
+	}
+	if cmd.Database != "app" {
+		t.Errorf("Database = %q, want app", cmd.Database)
+	}
+}
@@ -0,0 +1,175 @@
+package sender
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MaxDocumentDepth is the deepest document/array nesting DecodeBody and the field
+// cleaners accept (the top-level document is depth 1)
+// The driver's BSON validation and decoding recurse once per level, so a bound keeps
+// crafted or corrupt documents from exhausting the stack.
+var MaxDocumentDepth = 100
+
+// ErrDocumentTooDeep is returned (wrapped) for documents nested deeper than MaxDocumentDepth
+var ErrDocumentTooDeep = errors.New("document nesting too deep")
+
+// CheckDocumentDepth returns an error if doc nests documents or arrays deeper than limit
+// It walks the raw bytes with an explicit stack, so it is safe to run before any
+// recursive validation. Malformed documents are also reported as errors.
+func CheckDocumentDepth(doc []byte, limit int) error {
+	if len(doc) < 5 {
+		return fmt.Errorf("truncated document")
+	}
+
+	// ends holds the end offset of each open document, innermost last
+	var ends []int
+	pos := 0
+
+	open := func() error {
+		if len(ends) >= limit {
+			return fmt.Errorf("%w: more than %d levels", ErrDocumentTooDeep, limit)
+		}
+		if pos+5 > len(doc) {
+			return fmt.Errorf("truncated document at offset %d", pos)
+		}
+		size := int(int32(binary.LittleEndian.Uint32(doc[pos:])))
+		end := pos + size
+		if size < 5 || end > len(doc) || (len(ends) > 0 && end > ends[len(ends)-1]) {
+			return fmt.Errorf("invalid document size %d at offset %d", size, pos)
+		}
+		ends = append(ends, end)
+		pos += 4
+		return nil
+	}
+
+	if err := open(); err != nil {
+		return err
+	}
+
+	for len(ends) > 0 {
+		end := ends[len(ends)-1]
+		if pos == end-1 {
+			if doc[pos] != 0 {
+				return fmt.Errorf("missing document terminator at offset %d", pos)
+			}
+			pos++
+			ends = ends[:len(ends)-1]
+			continue
+		}
+		if pos >= end {
+			return fmt.Errorf("element overruns document at offset %d", pos)
+		}
+
+		elemType := doc[pos]
+		pos++
+
+		// Element name (cstring)
+		for pos < end && doc[pos] != 0 {
+			pos++
+		}
+		pos++
+		if pos >= end {
+			return fmt.Errorf("unterminated element name")
+		}
+
+		if elemType == 0x03 || elemType == 0x04 { // embedded document, array
+			if err := open(); err != nil {
+				return err
+			}
+			continue
+		}
+		if elemType == 0x0F { // code with scope: int32 total + string + scope document
+			if pos+8 > end {
+				return fmt.Errorf("truncated code with scope at offset %d", pos)
+			}
+			codeLen := int(int32(binary.LittleEndian.Uint32(doc[pos+4:])))
+			if codeLen < 1 || pos+8+codeLen > end {
+				return fmt.Errorf("invalid code with scope at offset %d", pos)
+			}
+			pos += 8 + codeLen
+			if err := open(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		size, err := valueSize(elemType, doc[pos:end])
+		if err != nil {
+			return fmt.Errorf("offset %d: %w", pos, err)
+		}
+		pos += size
+	}
+
+	return nil
+}
+
+// valueSize returns the encoded size of a non-container BSON value at the start of data
+func valueSize(elemType byte, data []byte) (int, error) {
+	int32At := func(off int) (int, error) {
+		if off+4 > len(data) {
+			return 0, fmt.Errorf("truncated value")
+		}
+		return int(int32(binary.LittleEndian.Uint32(data[off:]))), nil
+	}
+	cstringAt := func(off int) (int, error) {
+		for i := off; i < len(data); i++ {
+			if data[i] == 0 {
+				return i - off + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated string")
+	}
+
+	var size int
+	switch elemType {
+	case 0x06, 0x0A, 0x7F, 0xFF: // undefined, null, maxKey, minKey
+		size = 0
+	case 0x08: // bool
+		size = 1
+	case 0x10: // int32
+		size = 4
+	case 0x01, 0x09, 0x11, 0x12: // double, datetime, timestamp, int64
+		size = 8
+	case 0x07: // ObjectId
+		size = 12
+	case 0x13: // decimal128
+		size = 16
+	case 0x02, 0x0D, 0x0E: // string, JavaScript, symbol
+		n, err := int32At(0)
+		if err != nil {
+			return 0, err
+		}
+		size = 4 + n
+	case 0x0C: // DBPointer: string + ObjectId
+		n, err := int32At(0)
+		if err != nil {
+			return 0, err
+		}
+		size = 4 + n + 12
+	case 0x05: // binary: length + subtype + bytes
+		n, err := int32At(0)
+		if err != nil {
+			return 0, err
+		}
+		size = 4 + 1 + n
+	case 0x0B: // regex: pattern and options cstrings
+		pattern, err := cstringAt(0)
+		if err != nil {
+			return 0, err
+		}
+		opts, err := cstringAt(pattern)
+		if err != nil {
+			return 0, err
+		}
+		size = pattern + opts
+	default:
+		return 0, fmt.Errorf("unknown BSON type 0x%02x", elemType)
+	}
+
+	if size < 0 || size > len(data) {
+		return 0, fmt.Errorf("value size %d exceeds remaining %d bytes", size, len(data))
+	}
+	return size, nil
+}
@@ -0,0 +1,75 @@
+package sender
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// nestedDoc returns a document nested levels deep: {a: {a: ... {leaf: 1}}}
+func nestedDoc(levels int) bson.D {
+	doc := bson.D{{Key: "leaf", Value: int32(1)}}
+	for i := 1; i < levels; i++ {
+		doc = bson.D{{Key: "a", Value: doc}}
+	}
+	return doc
+}
+
+func TestDecodeBody_RejectsDeepNesting(t *testing.T) {
+	deep := buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{{
+		{Key: "insert", Value: "users"},
+		{Key: "filter", Value: nestedDoc(200)},
+		{Key: "$db", Value: "app"},
+	}}})
+
+	_, err := DecodeBody(deep)
+	if !errors.Is(err, ErrDocumentTooDeep) {
+		t.Fatalf("DecodeBody error = %v, want ErrDocumentTooDeep", err)
+	}
+
+	shallow := buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{{
+		{Key: "insert", Value: "users"},
+		{Key: "filter", Value: nestedDoc(MaxDocumentDepth - 1)},
+		{Key: "$db", Value: "app"},
+	}}})
+	if _, err := DecodeBody(shallow); err != nil {
+		t.Errorf("DecodeBody rejected a document at the depth limit: %v", err)
+	}
+}
+
+func TestCleanInternalFields_DepthLimit(t *testing.T) {
+	doc := bson.M{"leaf": int32(1)}
+	for i := 1; i < 200; i++ {
+		doc = bson.M{"a": bson.A{doc}}
+	}
+
+	if _, err := cleanInternalFields(doc); !errors.Is(err, ErrDocumentTooDeep) {
+		t.Fatalf("cleanInternalFields error = %v, want ErrDocumentTooDeep", err)
+	}
+}
+
+func TestCheckDocumentDepth(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{
+		{Key: "s", Value: "x"},
+		{Key: "arr", Value: bson.A{int32(1), bson.D{{Key: "b", Value: true}}}},
+		{Key: "bin", Value: bson.Binary{Subtype: 0, Data: []byte{1, 2, 3}}},
+		{Key: "re", Value: bson.Regex{Pattern: "^a", Options: "i"}},
+		{Key: "oid", Value: bson.NewObjectID()},
+		{Key: "null", Value: nil},
+		{Key: "code", Value: bson.CodeWithScope{Code: "x", Scope: bson.D{{Key: "y", Value: int64(2)}}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	if err := CheckDocumentDepth(raw, 3); err != nil {
+		t.Errorf("CheckDocumentDepth(limit 3) = %v, want nil", err)
+	}
+	if err := CheckDocumentDepth(raw, 2); !errors.Is(err, ErrDocumentTooDeep) {
+		t.Errorf("CheckDocumentDepth(limit 2) = %v, want ErrDocumentTooDeep", err)
+	}
+	if err := CheckDocumentDepth(raw[:len(raw)-3], 10); err == nil {
+		t.Error("expected an error for a truncated document")
+	}
+}
@@ -0,0 +1,53 @@
+package sender
+
+import (
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// BinarySubtypeEncrypted is the BSON binary subtype of Client-Side Field Level Encryption
+// and Queryable Encryption ciphertext
+const BinarySubtypeEncrypted = 0x06
+
+// EncryptedFields returns the dot-paths of the encrypted (binary subtype 6) values in doc
+// Array elements are addressed by index (e.g. "documents.0.ssn"). Paths are sorted; nil
+// means the document holds no ciphertext. Ciphertext is only meaningful to a client with
+// access to the key vault and data keys it was encrypted with.
+func EncryptedFields(doc bson.M) []string {
+	var paths []string
+	collectEncrypted(doc, "", &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+// collectEncrypted appends the paths of the encrypted values in value, which is at path
+func collectEncrypted(value interface{}, path string, paths *[]string) {
+	switch v := value.(type) {
+	case bson.Binary:
+		if v.Subtype == BinarySubtypeEncrypted {
+			*paths = append(*paths, path)
+		}
+	case bson.M:
+		for key, item := range v {
+			collectEncrypted(item, joinPath(path, key), paths)
+		}
+	case bson.D:
+		for _, e := range v {
+			collectEncrypted(e.Value, joinPath(path, e.Key), paths)
+		}
+	case bson.A:
+		for i, item := range v {
+			collectEncrypted(item, joinPath(path, strconv.Itoa(i)), paths)
+		}
+	}
+}
+
+// joinPath appends a key to a dot-path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
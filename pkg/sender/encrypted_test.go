@@ -0,0 +1,38 @@
+package sender
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestEncryptedFields(t *testing.T) {
+	ciphertext := bson.Binary{Subtype: BinarySubtypeEncrypted, Data: []byte{0x01, 0x02, 0x03}}
+	uuid := bson.Binary{Subtype: 0x04, Data: make([]byte, 16)}
+
+	cmd := bson.M{
+		"insert": "patients",
+		"documents": bson.A{
+			bson.D{{Key: "_id", Value: uuid}, {Key: "ssn", Value: ciphertext}},
+			bson.D{{Key: "_id", Value: 2}, {Key: "contact", Value: bson.M{"phone": ciphertext, "name": "x"}}},
+		},
+		"$db": "clinic",
+	}
+
+	got := EncryptedFields(cmd)
+	want := []string{"documents.0.ssn", "documents.1.contact.phone"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EncryptedFields = %v, want %v", got, want)
+	}
+}
+
+func TestEncryptedFields_None(t *testing.T) {
+	cmd := bson.M{
+		"find":   "users",
+		"filter": bson.D{{Key: "token", Value: bson.Binary{Subtype: 0x00, Data: []byte("abc")}}},
+	}
+	if got := EncryptedFields(cmd); got != nil {
+		t.Errorf("EncryptedFields = %v, want nil", got)
+	}
+}
@@ -0,0 +1,144 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// handshakeCommands are the command names a driver uses for the connection handshake
+var handshakeCommands = map[string]bool{
+	"hello":    true,
+	"isMaster": true,
+	"ismaster": true,
+}
+
+// ClientInfo is the client metadata a driver sends in its connection handshake
+type ClientInfo struct {
+	// AppName is client.application.name
+	AppName string
+
+	// DriverName and DriverVersion are client.driver.name/version
+	// (wrapping drivers append theirs, e.g. "mongo-go-driver|mgo")
+	DriverName    string
+	DriverVersion string
+
+	// OSType, OSName and Architecture describe client.os
+	OSType       string
+	OSName       string
+	Architecture string
+
+	// Platform is client.platform (e.g. "go1.25.3")
+	Platform string
+}
+
+// HandshakeAppName returns client.application.name from a recorded handshake request
+// Drivers send the handshake as OP_QUERY on "admin.$cmd" (older drivers, or before the
+// server version is known) or as OP_MSG. Returns "" if the packet isn't a handshake
+// or the client didn't set an appName.
+func HandshakeAppName(packet *reader.Packet) string {
+	info, ok := HandshakeClient(packet)
+	if !ok {
+		return ""
+	}
+	return info.AppName
+}
+
+// HandshakeClient returns the client metadata of a recorded handshake request
+// Returns false if the packet isn't a handshake (see HandshakeAppName) or carries no
+// client document; handshakes after the first on a connection usually omit it.
+func HandshakeClient(packet *reader.Packet) (*ClientInfo, bool) {
+	doc := handshakeDocument(packet)
+	if doc == nil {
+		return nil, false
+	}
+
+	client, ok := doc.Lookup("client").DocumentOK()
+	if !ok {
+		return nil, false
+	}
+
+	str := func(path ...string) string {
+		v, _ := client.Lookup(path...).StringValueOK()
+		return v
+	}
+	return &ClientInfo{
+		AppName:       str("application", "name"),
+		DriverName:    str("driver", "name"),
+		DriverVersion: str("driver", "version"),
+		OSType:        str("os", "type"),
+		OSName:        str("os", "name"),
+		Architecture:  str("os", "architecture"),
+		Platform:      str("platform"),
+	}, true
+}
+
+// handshakeDocument returns the command document of a hello/isMaster request, or nil
+func handshakeDocument(packet *reader.Packet) bson.Raw {
+	msg, err := packet.WireMessage()
+	if err != nil || len(msg) < 16 {
+		return nil
+	}
+
+	var doc bson.Raw
+	switch binary.LittleEndian.Uint32(msg[12:16]) {
+	case 2013:
+		if !handshakeCommands[packet.ExtractCommandName()] {
+			return nil
+		}
+		body, err := DecodeBody(msg)
+		if err != nil {
+			return nil
+		}
+		doc = body.Document
+	case 2004:
+		doc = opQueryDocument(msg)
+	}
+	if doc == nil {
+		return nil
+	}
+
+	first, err := doc.IndexErr(0)
+	if err != nil || !handshakeCommands[first.Key()] {
+		return nil
+	}
+	return doc
+}
+
+// opQueryDocument returns the query document of an OP_QUERY (2004) command message, or nil
+//
+// OP_QUERY format:
+//   header             : 16 bytes
+//   flags              : int32
+//   fullCollectionName : cstring ("db.$cmd" for commands)
+//   numberToSkip       : int32
+//   numberToReturn     : int32
+//   query              : BSON document
+func opQueryDocument(msg []byte) bson.Raw {
+	if len(msg) < 20 {
+		return nil
+	}
+	rest := msg[20:]
+
+	nul := bytes.IndexByte(rest, 0)
+	if nul < 0 || !strings.HasSuffix(string(rest[:nul]), ".$cmd") {
+		return nil
+	}
+	rest = rest[nul+1:]
+
+	if len(rest) < 8 {
+		return nil
+	}
+	framed, err := reader.SplitDocument(rest[8:])
+	if err != nil {
+		return nil
+	}
+	doc, err := validateDocument(framed)
+	if err != nil {
+		return nil
+	}
+	return doc
+}
@@ -0,0 +1,123 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// handshakeDoc is a driver handshake with the given appName
+func handshakeDoc(cmd, appName string) bson.D {
+	return bson.D{
+		{Key: cmd, Value: int32(1)},
+		{Key: "client", Value: bson.D{
+			{Key: "application", Value: bson.D{{Key: "name", Value: appName}}},
+			{Key: "driver", Value: bson.D{{Key: "name", Value: "mongo-go-driver"}, {Key: "version", Value: "v2.4.0"}}},
+		}},
+		{Key: "$db", Value: "admin"},
+	}
+}
+
+// buildOpQuery creates a legacy OP_QUERY wire message against the given namespace
+func buildOpQuery(t *testing.T, ns string, doc bson.D) []byte {
+	t.Helper()
+
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+
+	payload := new(bytes.Buffer)
+	binary.Write(payload, binary.LittleEndian, int32(0)) // flags
+	payload.WriteString(ns)
+	payload.WriteByte(0)
+	binary.Write(payload, binary.LittleEndian, int32(0))  // numberToSkip
+	binary.Write(payload, binary.LittleEndian, int32(-1)) // numberToReturn
+	payload.Write(body)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+payload.Len()))
+	binary.Write(buf, binary.LittleEndian, int32(1))    // requestID
+	binary.Write(buf, binary.LittleEndian, int32(0))    // responseTo
+	binary.Write(buf, binary.LittleEndian, int32(2004)) // OP_QUERY
+	buf.Write(payload.Bytes())
+	return buf.Bytes()
+}
+
+func TestHandshakeAppName(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		want    string
+	}{
+		{
+			name:    "OP_MSG hello",
+			message: buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{handshakeDoc("hello", "orders-svc")}}),
+			want:    "orders-svc",
+		},
+		{
+			name:    "OP_QUERY isMaster",
+			message: buildOpQuery(t, "admin.$cmd", handshakeDoc("isMaster", "billing")),
+			want:    "billing",
+		},
+		{
+			name:    "OP_QUERY on a collection",
+			message: buildOpQuery(t, "app.users", handshakeDoc("isMaster", "billing")),
+			want:    "",
+		},
+		{
+			name: "not a handshake",
+			message: buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{{
+				{Key: "find", Value: "users"},
+				{Key: "client", Value: bson.D{{Key: "application", Value: bson.D{{Key: "name", Value: "x"}}}}},
+				{Key: "$db", Value: "app"},
+			}}}),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HandshakeAppName(&reader.Packet{Message: tt.message}); got != tt.want {
+				t.Errorf("HandshakeAppName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandshakeClient(t *testing.T) {
+	doc := bson.D{
+		{Key: "hello", Value: int32(1)},
+		{Key: "client", Value: bson.D{
+			{Key: "driver", Value: bson.D{{Key: "name", Value: "nodejs"}, {Key: "version", Value: "6.9.0"}}},
+			{Key: "os", Value: bson.D{{Key: "type", Value: "Linux"}, {Key: "name", Value: "linux"}, {Key: "architecture", Value: "x64"}}},
+			{Key: "platform", Value: "Node.js v20.11.0, LE"},
+		}},
+		{Key: "$db", Value: "admin"},
+	}
+
+	info, ok := HandshakeClient(&reader.Packet{Message: buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{doc}})})
+	if !ok {
+		t.Fatal("HandshakeClient() found no client metadata")
+	}
+	want := ClientInfo{
+		DriverName:    "nodejs",
+		DriverVersion: "6.9.0",
+		OSType:        "Linux",
+		OSName:        "linux",
+		Architecture:  "x64",
+		Platform:      "Node.js v20.11.0, LE",
+	}
+	if *info != want {
+		t.Errorf("HandshakeClient() = %+v, want %+v", *info, want)
+	}
+
+	// Later handshakes on a connection carry no client document
+	noClient := bson.D{{Key: "hello", Value: int32(1)}, {Key: "$db", Value: "admin"}}
+	if _, ok := HandshakeClient(&reader.Packet{Message: buildOpMsgSections(t, opMsgSection{kind: 0, docs: []bson.D{noClient}})}); ok {
+		t.Error("HandshakeClient() reported client metadata for a handshake without a client document")
+	}
+}
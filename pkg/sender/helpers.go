@@ -0,0 +1,707 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// Driver helper dispatch
+//
+// With UseDriverHelpers enabled, SendCommand sends insert, find, update, delete and
+// aggregate through the typed collection helpers (InsertMany, Find, BulkWrite, Aggregate)
+// instead of RunCommand, so the driver's own behavior applies: retryable writes with a
+// driver-generated lsid/txnNumber, CSOT, and its write-concern defaults.
+//
+// Fidelity tradeoffs:
+//   - The driver builds the wire command, so field order, batching of large inserts and
+//     implicit sessions can differ from the recording
+//   - Find and aggregate exhaust the cursor (issuing getMores), and the response carries
+//     every document in firstBatch; recorded getMores on the old cursor still fail
+//   - Commands with options the helpers can't express (e.g. maxTimeMS, explain) are
+//     sent with RunCommand instead
+
+// helperCommands lists the commands that can be dispatched through driver helpers,
+// with the field that must accompany the command name (update and delete share their
+// names with findAndModify/legacy fields, so the statement array is checked too)
+var helperCommands = []struct {
+	name     string
+	required string
+}{
+	{"insert", "documents"},
+	{"find", ""},
+	{"update", "updates"},
+	{"delete", "deletes"},
+	{"aggregate", "pipeline"},
+}
+
+// UseDriverHelpers makes SendCommand dispatch recognized commands through typed driver helpers
+func (s *Sender) UseDriverHelpers(enabled bool) {
+	s.useHelpers = enabled
+}
+
+// helperCommandName returns the helper-dispatchable command in doc, or "" if there is none
+func helperCommandName(doc bson.M) string {
+	for _, cmd := range helperCommands {
+		if _, ok := doc[cmd.name]; !ok {
+			continue
+		}
+		if cmd.required != "" {
+			if _, ok := doc[cmd.required]; !ok {
+				continue
+			}
+		}
+		return cmd.name
+	}
+	return ""
+}
+
+// sendViaHelper runs a command through the matching collection helper
+// Returns handled=false if the command can't be expressed with helpers
+func (s *Sender) sendViaHelper(ctx context.Context, database string, command bson.M) (result bson.M, handled bool, err error) {
+	name := helperCommandName(command)
+	if name == "" {
+		return nil, false, nil
+	}
+
+	collOpts, err := collectionOptions(command)
+	if err != nil {
+		return nil, false, nil
+	}
+	db := s.client.Database(database)
+
+	switch name {
+	case "insert":
+		call, err := translateInsert(command)
+		if err != nil {
+			return nil, false, nil
+		}
+		res, err := db.Collection(call.collection, collOpts).InsertMany(ctx, call.documents, call.opts)
+		if err != nil {
+			return nil, true, err
+		}
+		return bson.M{"ok": 1.0, "n": int32(len(res.InsertedIDs))}, true, nil
+
+	case "find":
+		call, err := translateFind(command)
+		if err != nil {
+			return nil, false, nil
+		}
+		cursor, err := db.Collection(call.collection, collOpts).Find(ctx, call.filter, call.opts)
+		if err != nil {
+			return nil, true, err
+		}
+		return cursorResponse(ctx, cursor, database+"."+call.collection)
+
+	case "update", "delete":
+		translate := translateUpdate
+		if name == "delete" {
+			translate = translateDelete
+		}
+		call, err := translate(command)
+		if err != nil {
+			return nil, false, nil
+		}
+		res, err := db.Collection(call.collection, collOpts).BulkWrite(ctx, call.models, call.opts)
+		if err != nil {
+			return nil, true, err
+		}
+		if name == "delete" {
+			return bson.M{"ok": 1.0, "n": int32(res.DeletedCount)}, true, nil
+		}
+		return bson.M{
+			"ok":        1.0,
+			"n":         int32(res.MatchedCount + res.UpsertedCount),
+			"nModified": int32(res.ModifiedCount),
+		}, true, nil
+
+	case "aggregate":
+		call, err := translateAggregate(command)
+		if err != nil {
+			return nil, false, nil
+		}
+		var cursor *mongo.Cursor
+		ns := database + "." + call.collection
+		if call.collection == "" {
+			cursor, err = db.Aggregate(ctx, call.pipeline, call.opts)
+			ns = database + ".$cmd.aggregate"
+		} else {
+			cursor, err = db.Collection(call.collection, collOpts).Aggregate(ctx, call.pipeline, call.opts)
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		return cursorResponse(ctx, cursor, ns)
+	}
+
+	return nil, false, nil
+}
+
+// cursorResponse drains a cursor into a find/aggregate-shaped response document
+func cursorResponse(ctx context.Context, cursor *mongo.Cursor, ns string) (bson.M, bool, error) {
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, true, err
+	}
+
+	batch := make(bson.A, len(docs))
+	for i, d := range docs {
+		batch[i] = d
+	}
+	return bson.M{
+		"ok":     1.0,
+		"cursor": bson.M{"id": int64(0), "ns": ns, "firstBatch": batch},
+	}, true, nil
+}
+
+// insertCall is an insert command translated for Collection.InsertMany
+type insertCall struct {
+	collection string
+	documents  []interface{}
+	opts       *options.InsertManyOptionsBuilder
+}
+
+// translateInsert maps an insert command onto InsertMany arguments
+func translateInsert(doc bson.M) (*insertCall, error) {
+	call := &insertCall{opts: options.InsertMany()}
+	for key, value := range doc {
+		var err error
+		switch key {
+		case "insert":
+			call.collection, err = stringField(key, value)
+		case "documents":
+			docs, ok := value.(bson.A)
+			if !ok || len(docs) == 0 {
+				err = fmt.Errorf("documents must be a non-empty array")
+			}
+			call.documents = docs
+		case "ordered":
+			var ordered bool
+			ordered, err = boolField(key, value)
+			call.opts.SetOrdered(ordered)
+		case "bypassDocumentValidation":
+			var bypass bool
+			bypass, err = boolField(key, value)
+			call.opts.SetBypassDocumentValidation(bypass)
+		case "comment":
+			call.opts.SetComment(value)
+		case "writeConcern":
+			// Applied to the collection (see collectionOptions)
+		default:
+			err = fmt.Errorf("insert option %q has no InsertMany equivalent", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return call, nil
+}
+
+// findCall is a find command translated for Collection.Find
+type findCall struct {
+	collection string
+	filter     interface{}
+	opts       *options.FindOptionsBuilder
+}
+
+// translateFind maps a find command onto Find arguments
+// singleBatch is expressed as a negative limit, which is how the driver requests it
+func translateFind(doc bson.M) (*findCall, error) {
+	call := &findCall{filter: bson.D{}, opts: options.Find()}
+
+	var limit, batchSize int64
+	var singleBatch, tailable, awaitData bool
+
+	for key, value := range doc {
+		var err error
+		switch key {
+		case "find":
+			call.collection, err = stringField(key, value)
+		case "filter":
+			call.filter = value
+		case "sort":
+			call.opts.SetSort(value)
+		case "projection":
+			call.opts.SetProjection(value)
+		case "hint":
+			call.opts.SetHint(value)
+		case "comment":
+			call.opts.SetComment(value)
+		case "let":
+			call.opts.SetLet(value)
+		case "min":
+			call.opts.SetMin(value)
+		case "max":
+			call.opts.SetMax(value)
+		case "skip":
+			var skip int64
+			skip, err = intField(key, value)
+			call.opts.SetSkip(skip)
+		case "limit":
+			limit, err = intField(key, value)
+		case "batchSize":
+			batchSize, err = intField(key, value)
+		case "singleBatch":
+			singleBatch, err = boolField(key, value)
+		case "tailable":
+			tailable, err = boolField(key, value)
+		case "awaitData":
+			awaitData, err = boolField(key, value)
+		case "collation":
+			var collation *options.Collation
+			collation, err = collationField(value)
+			call.opts.SetCollation(collation)
+		case "allowDiskUse", "allowPartialResults", "noCursorTimeout", "returnKey", "showRecordId":
+			var b bool
+			b, err = boolField(key, value)
+			setFindFlag(call.opts, key, b)
+		case "readConcern":
+			// Applied to the collection (see collectionOptions)
+		default:
+			err = fmt.Errorf("find option %q has no Find equivalent", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if batchSize > 0 {
+		call.opts.SetBatchSize(int32(batchSize))
+	}
+	switch {
+	case singleBatch && limit == 0 && batchSize == 0:
+		return nil, fmt.Errorf("singleBatch without limit or batchSize has no Find equivalent")
+	case singleBatch && (limit == 0 || (batchSize > 0 && batchSize < limit)):
+		call.opts.SetLimit(-batchSize)
+	case singleBatch:
+		call.opts.SetLimit(-limit)
+	case limit > 0:
+		call.opts.SetLimit(limit)
+	}
+	switch {
+	case tailable && awaitData:
+		call.opts.SetCursorType(options.TailableAwait)
+	case tailable:
+		call.opts.SetCursorType(options.Tailable)
+	}
+
+	return call, nil
+}
+
+// setFindFlag applies a boolean find option by its command field name
+func setFindFlag(opts *options.FindOptionsBuilder, key string, value bool) {
+	switch key {
+	case "allowDiskUse":
+		opts.SetAllowDiskUse(value)
+	case "allowPartialResults":
+		opts.SetAllowPartialResults(value)
+	case "noCursorTimeout":
+		opts.SetNoCursorTimeout(value)
+	case "returnKey":
+		opts.SetReturnKey(value)
+	case "showRecordId":
+		opts.SetShowRecordID(value)
+	}
+}
+
+// bulkCall is an update or delete command translated for Collection.BulkWrite
+type bulkCall struct {
+	collection string
+	models     []mongo.WriteModel
+	opts       *options.BulkWriteOptionsBuilder
+}
+
+// translateUpdate maps an update command's statements onto BulkWrite models
+func translateUpdate(doc bson.M) (*bulkCall, error) {
+	return translateBulk(doc, "update", "updates", updateModel)
+}
+
+// translateDelete maps a delete command's statements onto BulkWrite models
+func translateDelete(doc bson.M) (*bulkCall, error) {
+	return translateBulk(doc, "delete", "deletes", deleteModel)
+}
+
+// translateBulk maps the shared update/delete command fields onto BulkWrite arguments
+func translateBulk(doc bson.M, name, statementsField string, model func(bson.M) (mongo.WriteModel, error)) (*bulkCall, error) {
+	call := &bulkCall{opts: options.BulkWrite()}
+	for key, value := range doc {
+		var err error
+		switch key {
+		case name:
+			call.collection, err = stringField(key, value)
+		case statementsField:
+			statements, ok := value.(bson.A)
+			if !ok || len(statements) == 0 {
+				return nil, fmt.Errorf("%s must be a non-empty array", key)
+			}
+			for i, s := range statements {
+				statement, ok := toDocument(s)
+				if !ok {
+					return nil, fmt.Errorf("%s[%d] is not a document", key, i)
+				}
+				m, err := model(statement)
+				if err != nil {
+					return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+				}
+				call.models = append(call.models, m)
+			}
+		case "ordered":
+			var ordered bool
+			ordered, err = boolField(key, value)
+			call.opts.SetOrdered(ordered)
+		case "bypassDocumentValidation":
+			var bypass bool
+			bypass, err = boolField(key, value)
+			call.opts.SetBypassDocumentValidation(bypass)
+		case "comment":
+			call.opts.SetComment(value)
+		case "let":
+			call.opts.SetLet(value)
+		case "writeConcern":
+			// Applied to the collection (see collectionOptions)
+		default:
+			err = fmt.Errorf("%s option %q has no BulkWrite equivalent", name, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return call, nil
+}
+
+// updateModel maps one update statement onto UpdateOne/UpdateMany/ReplaceOne
+// A document without $-operators is a replacement; an array is an update pipeline
+func updateModel(statement bson.M) (mongo.WriteModel, error) {
+	var multi, upsert bool
+	var collation *options.Collation
+	var arrayFilters []interface{}
+	for key, value := range statement {
+		var err error
+		switch key {
+		case "q", "u", "hint":
+		case "multi":
+			multi, err = boolField(key, value)
+		case "upsert":
+			upsert, err = boolField(key, value)
+		case "collation":
+			collation, err = collationField(value)
+		case "arrayFilters":
+			filters, ok := value.(bson.A)
+			if !ok {
+				err = fmt.Errorf("arrayFilters must be an array")
+			}
+			arrayFilters = filters
+		default:
+			err = fmt.Errorf("update statement option %q has no model equivalent", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filter, update, hint := statement["q"], statement["u"], statement["hint"]
+	if filter == nil || update == nil {
+		return nil, fmt.Errorf("update statement needs q and u")
+	}
+
+	if replacement, ok := toDocument(update); ok && !hasOperators(replacement) {
+		if multi {
+			return nil, fmt.Errorf("multi is not allowed with a replacement document")
+		}
+		m := mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(update).SetUpsert(upsert)
+		if hint != nil {
+			m.SetHint(hint)
+		}
+		if collation != nil {
+			m.SetCollation(collation)
+		}
+		return m, nil
+	}
+
+	if multi {
+		m := mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(update).SetUpsert(upsert)
+		if hint != nil {
+			m.SetHint(hint)
+		}
+		if collation != nil {
+			m.SetCollation(collation)
+		}
+		if arrayFilters != nil {
+			m.SetArrayFilters(arrayFilters)
+		}
+		return m, nil
+	}
+
+	m := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(upsert)
+	if hint != nil {
+		m.SetHint(hint)
+	}
+	if collation != nil {
+		m.SetCollation(collation)
+	}
+	if arrayFilters != nil {
+		m.SetArrayFilters(arrayFilters)
+	}
+	return m, nil
+}
+
+// deleteModel maps one delete statement onto DeleteOne (limit 1) or DeleteMany (limit 0)
+func deleteModel(statement bson.M) (mongo.WriteModel, error) {
+	var limit int64
+	var collation *options.Collation
+	for key, value := range statement {
+		var err error
+		switch key {
+		case "q", "hint":
+		case "limit":
+			limit, err = intField(key, value)
+		case "collation":
+			collation, err = collationField(value)
+		default:
+			err = fmt.Errorf("delete statement option %q has no model equivalent", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filter, hint := statement["q"], statement["hint"]
+	if filter == nil {
+		return nil, fmt.Errorf("delete statement needs q")
+	}
+
+	if limit == 1 {
+		m := mongo.NewDeleteOneModel().SetFilter(filter)
+		if hint != nil {
+			m.SetHint(hint)
+		}
+		if collation != nil {
+			m.SetCollation(collation)
+		}
+		return m, nil
+	}
+
+	m := mongo.NewDeleteManyModel().SetFilter(filter)
+	if hint != nil {
+		m.SetHint(hint)
+	}
+	if collation != nil {
+		m.SetCollation(collation)
+	}
+	return m, nil
+}
+
+// aggregateCall is an aggregate command translated for Collection.Aggregate
+// collection is empty for database-level aggregations ({aggregate: 1})
+type aggregateCall struct {
+	collection string
+	pipeline   interface{}
+	opts       *options.AggregateOptionsBuilder
+}
+
+// translateAggregate maps an aggregate command onto Aggregate arguments
+func translateAggregate(doc bson.M) (*aggregateCall, error) {
+	call := &aggregateCall{opts: options.Aggregate()}
+	for key, value := range doc {
+		var err error
+		switch key {
+		case "aggregate":
+			// A collection name, or 1 for database-level aggregations
+			call.collection, _ = value.(string)
+		case "pipeline":
+			if _, ok := value.(bson.A); !ok {
+				err = fmt.Errorf("pipeline must be an array")
+			}
+			call.pipeline = value
+		case "cursor":
+			cursor, ok := toDocument(value)
+			if !ok {
+				err = fmt.Errorf("cursor must be a document")
+				break
+			}
+			if bs, ok := cursor["batchSize"]; ok {
+				var batchSize int64
+				batchSize, err = intField("cursor.batchSize", bs)
+				call.opts.SetBatchSize(int32(batchSize))
+			}
+		case "allowDiskUse":
+			var allow bool
+			allow, err = boolField(key, value)
+			call.opts.SetAllowDiskUse(allow)
+		case "bypassDocumentValidation":
+			var bypass bool
+			bypass, err = boolField(key, value)
+			call.opts.SetBypassDocumentValidation(bypass)
+		case "collation":
+			var collation *options.Collation
+			collation, err = collationField(value)
+			call.opts.SetCollation(collation)
+		case "hint":
+			call.opts.SetHint(value)
+		case "comment":
+			call.opts.SetComment(value)
+		case "let":
+			call.opts.SetLet(value)
+		case "readConcern", "writeConcern":
+			// Applied to the collection (see collectionOptions)
+		default:
+			err = fmt.Errorf("aggregate option %q has no Aggregate equivalent", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return call, nil
+}
+
+// collectionOptions maps a command's readConcern/writeConcern onto collection options
+// Both are stripped from recorded commands, so they're only present when a replay
+// transform (--read-concern, --write-concern) added them
+func collectionOptions(doc bson.M) (*options.CollectionOptionsBuilder, error) {
+	opts := options.Collection()
+
+	if value, ok := doc["readConcern"]; ok {
+		rc, ok := toDocument(value)
+		if !ok {
+			return nil, fmt.Errorf("readConcern must be a document")
+		}
+		level, _ := rc["level"].(string)
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: level})
+	}
+
+	if value, ok := doc["writeConcern"]; ok {
+		wc, ok := toDocument(value)
+		if !ok {
+			return nil, fmt.Errorf("writeConcern must be a document")
+		}
+		concern := &writeconcern.WriteConcern{}
+		for key, v := range wc {
+			switch key {
+			case "w":
+				if w, ok := v.(string); ok {
+					concern.W = w
+				} else {
+					w, err := intField("writeConcern.w", v)
+					if err != nil {
+						return nil, err
+					}
+					concern.W = int(w)
+				}
+			case "j":
+				j, err := boolField("writeConcern.j", v)
+				if err != nil {
+					return nil, err
+				}
+				concern.Journal = &j
+			default:
+				return nil, fmt.Errorf("writeConcern option %q is not supported", key)
+			}
+		}
+		opts.SetWriteConcern(concern)
+	}
+
+	return opts, nil
+}
+
+// collationField maps a collation document onto options.Collation
+func collationField(value interface{}) (*options.Collation, error) {
+	doc, ok := toDocument(value)
+	if !ok {
+		return nil, fmt.Errorf("collation must be a document")
+	}
+
+	collation := &options.Collation{}
+	for key, v := range doc {
+		var err error
+		switch key {
+		case "locale":
+			collation.Locale, err = stringField(key, v)
+		case "caseFirst":
+			collation.CaseFirst, err = stringField(key, v)
+		case "alternate":
+			collation.Alternate, err = stringField(key, v)
+		case "maxVariable":
+			collation.MaxVariable, err = stringField(key, v)
+		case "strength":
+			var strength int64
+			strength, err = intField(key, v)
+			collation.Strength = int(strength)
+		case "caseLevel":
+			collation.CaseLevel, err = boolField(key, v)
+		case "numericOrdering":
+			collation.NumericOrdering, err = boolField(key, v)
+		case "normalization":
+			collation.Normalization, err = boolField(key, v)
+		case "backwards":
+			collation.Backwards, err = boolField(key, v)
+		default:
+			err = fmt.Errorf("collation option %q is not supported", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return collation, nil
+}
+
+// hasOperators returns true if any top-level key is an update operator ($set, $inc, ...)
+func hasOperators(doc bson.M) bool {
+	for key := range doc {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// toDocument returns v as a bson.M; nested documents unmarshal as bson.D by default
+func toDocument(v interface{}) (bson.M, bool) {
+	switch d := v.(type) {
+	case bson.M:
+		return d, true
+	case bson.D:
+		m := make(bson.M, len(d))
+		for _, e := range d {
+			m[e.Key] = e.Value
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// stringField returns a string command field
+func stringField(key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string, got %T", key, value)
+	}
+	return s, nil
+}
+
+// boolField returns a boolean command field
+func boolField(key string, value interface{}) (bool, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be a boolean, got %T", key, value)
+	}
+	return b, nil
+}
+
+// intField returns a numeric command field as int64
+func intField(key string, value interface{}) (int64, error) {
+	switch n := value.(type) {
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("%s must be a number, got %T", key, value)
+}
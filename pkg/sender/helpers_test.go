@@ -0,0 +1,124 @@
+package sender
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestHelperCommandName(t *testing.T) {
+	tests := []struct {
+		doc  bson.M
+		want string
+	}{
+		{bson.M{"insert": "users", "documents": bson.A{bson.M{"_id": 1}}}, "insert"},
+		{bson.M{"find": "users", "filter": bson.M{}}, "find"},
+		{bson.M{"update": "users", "updates": bson.A{}}, "update"},
+		{bson.M{"findAndModify": "users", "query": bson.M{}, "update": bson.M{"$set": bson.M{"a": 1}}}, ""},
+		{bson.M{"aggregate": int32(1), "pipeline": bson.A{}}, "aggregate"},
+		{bson.M{"count": "users"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := helperCommandName(tt.doc); got != tt.want {
+			t.Errorf("helperCommandName(%v) = %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateInsert(t *testing.T) {
+	docs := bson.A{bson.D{{Key: "_id", Value: int32(1)}}, bson.D{{Key: "_id", Value: int32(2)}}}
+	call, err := translateInsert(bson.M{
+		"insert":       "users",
+		"documents":    docs,
+		"ordered":      false,
+		"comment":      "bulk load",
+		"writeConcern": bson.M{"w": "majority"},
+	})
+	if err != nil {
+		t.Fatalf("translateInsert failed: %v", err)
+	}
+
+	if call.collection != "users" || len(call.documents) != 2 {
+		t.Fatalf("got collection %q with %d documents, want users with 2", call.collection, len(call.documents))
+	}
+
+	opts := &options.InsertManyOptions{}
+	for _, apply := range call.opts.List() {
+		if err := apply(opts); err != nil {
+			t.Fatalf("applying options failed: %v", err)
+		}
+	}
+	if opts.Ordered == nil || *opts.Ordered {
+		t.Errorf("Ordered = %v, want false", opts.Ordered)
+	}
+	if opts.Comment != "bulk load" {
+		t.Errorf("Comment = %v, want %q", opts.Comment, "bulk load")
+	}
+
+	if _, err := translateInsert(bson.M{"insert": "users", "documents": docs, "maxTimeMS": int32(100)}); err == nil {
+		t.Error("expected an error for an option InsertMany can't express")
+	}
+}
+
+func TestTranslateFind(t *testing.T) {
+	filter := bson.D{{Key: "status", Value: "active"}}
+	call, err := translateFind(bson.M{
+		"find":       "users",
+		"filter":     filter,
+		"sort":       bson.D{{Key: "age", Value: int32(-1)}},
+		"projection": bson.D{{Key: "name", Value: int32(1)}},
+		"skip":       int32(5),
+		"limit":      int64(20),
+		"batchSize":  int32(10),
+		"collation":  bson.D{{Key: "locale", Value: "fr"}, {Key: "strength", Value: int32(2)}},
+	})
+	if err != nil {
+		t.Fatalf("translateFind failed: %v", err)
+	}
+	if call.collection != "users" {
+		t.Errorf("collection = %q, want users", call.collection)
+	}
+
+	opts := applyFindOptions(t, call.opts)
+	if opts.Skip == nil || *opts.Skip != 5 {
+		t.Errorf("Skip = %v, want 5", opts.Skip)
+	}
+	if opts.Limit == nil || *opts.Limit != 20 {
+		t.Errorf("Limit = %v, want 20", opts.Limit)
+	}
+	if opts.BatchSize == nil || *opts.BatchSize != 10 {
+		t.Errorf("BatchSize = %v, want 10", opts.BatchSize)
+	}
+	if opts.Sort == nil || opts.Projection == nil {
+		t.Error("sort/projection not carried over")
+	}
+	if opts.Collation == nil || opts.Collation.Locale != "fr" || opts.Collation.Strength != 2 {
+		t.Errorf("Collation = %+v, want locale fr strength 2", opts.Collation)
+	}
+
+	// singleBatch becomes a negative limit
+	call, err = translateFind(bson.M{"find": "users", "limit": int32(20), "batchSize": int32(5), "singleBatch": true})
+	if err != nil {
+		t.Fatalf("translateFind failed: %v", err)
+	}
+	if opts := applyFindOptions(t, call.opts); opts.Limit == nil || *opts.Limit != -5 {
+		t.Errorf("singleBatch Limit = %v, want -5", opts.Limit)
+	}
+
+	if _, err := translateFind(bson.M{"find": "users", "maxTimeMS": int32(100)}); err == nil {
+		t.Error("expected an error for an option Find can't express")
+	}
+}
+
+func applyFindOptions(t *testing.T, builder *options.FindOptionsBuilder) *options.FindOptions {
+	t.Helper()
+	opts := &options.FindOptions{}
+	for _, apply := range builder.List() {
+		if err := apply(opts); err != nil {
+			t.Fatalf("applying options failed: %v", err)
+		}
+	}
+	return opts
+}
@@ -0,0 +1,72 @@
+package sender
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DefaultVolatileFields are the response fields that differ between any two servers (or
+// runs) for reasons unrelated to the command: cluster-time gossip and cursor ids
+var DefaultVolatileFields = []string{
+	"$clusterTime",
+	"$configTime",
+	"$topologyTime",
+	"operationTime",
+	"cursor.id",
+}
+
+// NormalizeResponse returns a copy of a response document without its volatile fields
+// The DefaultVolatileFields are always removed, along with the dot-paths in ignore (e.g.
+// "lastCommittedOpTime", "repl.lastWrite.opTime"). A path that reaches an array applies
+// to each document in it, so "cursor.firstBatch.updatedAt" removes the field from every
+// returned document. Nested documents are returned as bson.M; doc is not modified.
+func NormalizeResponse(doc bson.M, ignore []string) bson.M {
+	paths := make([][]string, 0, len(DefaultVolatileFields)+len(ignore))
+	for _, path := range append(append([]string(nil), DefaultVolatileFields...), ignore...) {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, strings.Split(path, "."))
+		}
+	}
+	return normalizeDocument(doc, paths)
+}
+
+// normalizeDocument copies doc, dropping the fields whose remaining path is complete and
+// descending into the ones that are a prefix of a path
+func normalizeDocument(doc bson.M, paths [][]string) bson.M {
+	out := make(bson.M, len(doc))
+	for key, value := range doc {
+		var nested [][]string
+		drop := false
+		for _, path := range paths {
+			if path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				drop = true
+				break
+			}
+			nested = append(nested, path[1:])
+		}
+		if !drop {
+			out[key] = normalizeValue(value, nested)
+		}
+	}
+	return out
+}
+
+// normalizeValue copies a value, applying paths to it if it is a document or an array
+func normalizeValue(value interface{}, paths [][]string) interface{} {
+	switch v := value.(type) {
+	case bson.M, bson.D:
+		doc, _ := toDocument(v)
+		return normalizeDocument(doc, paths)
+	case bson.A:
+		arr := make(bson.A, len(v))
+		for i, item := range v {
+			arr[i] = normalizeValue(item, paths)
+		}
+		return arr
+	}
+	return value
+}
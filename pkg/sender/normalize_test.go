@@ -0,0 +1,58 @@
+package sender
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNormalizeResponse(t *testing.T) {
+	response := bson.M{
+		"ok":                  1.0,
+		"operationTime":       bson.Timestamp{T: 1, I: 1},
+		"$clusterTime":        bson.D{{Key: "clusterTime", Value: bson.Timestamp{T: 1, I: 1}}},
+		"lastCommittedOpTime": bson.Timestamp{T: 1, I: 2},
+		"repl": bson.D{
+			{Key: "lastWrite", Value: bson.D{{Key: "opTime", Value: int64(5)}, {Key: "majorityOpTime", Value: int64(4)}}},
+			{Key: "setName", Value: "rs0"},
+		},
+		"cursor": bson.D{
+			{Key: "id", Value: int64(42)},
+			{Key: "ns", Value: "app.users"},
+			{Key: "firstBatch", Value: bson.A{
+				bson.D{{Key: "_id", Value: 1}, {Key: "updatedAt", Value: "yesterday"}},
+				bson.D{{Key: "_id", Value: 2}},
+			}},
+		},
+	}
+
+	got := NormalizeResponse(response, []string{"lastCommittedOpTime", "repl.lastWrite.opTime", " cursor.firstBatch.updatedAt ", ""})
+	want := bson.M{
+		"ok": 1.0,
+		"repl": bson.M{
+			"lastWrite": bson.M{"majorityOpTime": int64(4)},
+			"setName":   "rs0",
+		},
+		"cursor": bson.M{
+			"ns": "app.users",
+			"firstBatch": bson.A{
+				bson.M{"_id": 1},
+				bson.M{"_id": 2},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeResponse() = %v, want %v", got, want)
+	}
+
+	// The input is left untouched
+	if _, ok := response["operationTime"]; !ok {
+		t.Error("NormalizeResponse modified its input")
+	}
+
+	// Defaults apply without an ignore list
+	if got := NormalizeResponse(bson.M{"ok": 1.0, "$clusterTime": 1}, nil); !reflect.DeepEqual(got, bson.M{"ok": 1.0}) {
+		t.Errorf("NormalizeResponse(nil) = %v, want only ok", got)
+	}
+}
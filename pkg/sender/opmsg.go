@@ -0,0 +1,219 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// OP_MSG flag bits
+const (
+	// opMsgChecksumPresent means a CRC-32C checksum trails the sections
+	opMsgChecksumPresent = reader.OpMsgChecksumPresent
+
+	// opMsgMoreToCome means the sender will not wait for (or send) a reply
+	opMsgMoreToCome uint32 = 1 << 1
+)
+
+// DocumentSequence is an OP_MSG kind-1 section: a named sequence of documents
+// Drivers use these for bulk payloads, e.g. identifier "documents" for insert
+type DocumentSequence struct {
+	// Identifier is the command field the documents belong to
+	Identifier string
+
+	// Documents are the raw BSON documents in the sequence
+	Documents []bson.Raw
+}
+
+// OpMsgBody is the decoded section content of an OP_MSG message
+type OpMsgBody struct {
+	// Flags is the OP_MSG flagBits field
+	Flags uint32
+
+	// Document is the single kind-0 body document
+	Document bson.Raw
+
+	// Sequences are the kind-1 document sequences, in message order
+	Sequences []DocumentSequence
+}
+
+// DecodeBody walks the sections of an OP_MSG (2013) wire message
+//
+// OP_MSG format:
+//   header    : 16 bytes  - Standard wire header
+//   flagBits  : uint32 LE
+//   sections  : one or more of
+//     kind 0  : uint8(0) + BSON document (the command body)
+//     kind 1  : uint8(1) + int32 size + cstring identifier + BSON documents
+//   checksum  : uint32 LE - Only if the checksumPresent flag is set
+//
+// The framing is split by reader.SplitOpMsgSections; each document is then checked for
+// depth and validated. Exactly one kind-0 section is allowed; a message with none or
+// several is rejected rather than misparsed
+func DecodeBody(message []byte) (*OpMsgBody, error) {
+	flags, sections, _, err := reader.SplitOpMsgSections(message)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &OpMsgBody{Flags: flags}
+	bodySections := 0
+	for i, section := range sections {
+		if section.Kind == 0 {
+			doc, err := validateDocument(section.Documents[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid kind-0 section %d: %w", i, err)
+			}
+			bodySections++
+			body.Document = doc
+			continue
+		}
+
+		seq := DocumentSequence{Identifier: section.Identifier}
+		for j, raw := range section.Documents {
+			doc, err := validateDocument(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid kind-1 section %d: sequence %q document %d: %w", i, seq.Identifier, j, err)
+			}
+			seq.Documents = append(seq.Documents, doc)
+		}
+		body.Sequences = append(body.Sequences, seq)
+	}
+
+	if bodySections != 1 {
+		return nil, fmt.Errorf("OP_MSG must contain exactly one kind-0 body section, found %d", bodySections)
+	}
+
+	return body, nil
+}
+
+// StripFields removes top-level fields from an OP_MSG body document and re-encodes the message
+// The sections keep their order, and a checksum, if present, is recomputed.
+// Returns the original message (and false) if none of the fields were present
+func StripFields(message []byte, fields []string) ([]byte, bool, error) {
+	flags, sections, checksum, err := reader.SplitOpMsgSections(message)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bodyIndex, bodySections := -1, 0
+	for i, section := range sections {
+		if section.Kind == 0 {
+			bodyIndex = i
+			bodySections++
+		}
+	}
+	if bodySections != 1 {
+		return nil, false, fmt.Errorf("OP_MSG must contain exactly one kind-0 body section, found %d", bodySections)
+	}
+
+	strip := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		strip[f] = true
+	}
+
+	elements, err := bson.Raw(sections[bodyIndex].Documents[0]).Elements()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read body document: %w", err)
+	}
+
+	kept := make([]bson.RawElement, 0, len(elements))
+	for _, e := range elements {
+		if !strip[e.Key()] {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(elements) {
+		return message, false, nil
+	}
+
+	// Document layout: int32 size + elements + 0x00
+	docSize := 5
+	for _, e := range kept {
+		docSize += len(e)
+	}
+	doc := make([]byte, 0, docSize)
+	doc = binary.LittleEndian.AppendUint32(doc, uint32(docSize))
+	for _, e := range kept {
+		doc = append(doc, e...)
+	}
+	doc = append(doc, 0)
+	sections[bodyIndex].Documents = [][]byte{doc}
+
+	return rebuildOpMsg(message, flags, sections, checksum != nil), true, nil
+}
+
+// ChecksumMode selects what SetChecksum does with an OP_MSG checksum
+type ChecksumMode string
+
+const (
+	// ChecksumKeep leaves checksums as recorded
+	ChecksumKeep ChecksumMode = "keep"
+
+	// ChecksumStrip clears the checksumPresent flag and removes the trailing checksum
+	ChecksumStrip ChecksumMode = "strip"
+
+	// ChecksumRecompute replaces the trailing checksum with the CRC-32C of the message
+	ChecksumRecompute ChecksumMode = "recompute"
+)
+
+// ParseChecksumMode parses "keep", "strip" or "recompute"
+func ParseChecksumMode(s string) (ChecksumMode, error) {
+	switch mode := ChecksumMode(s); mode {
+	case ChecksumKeep, ChecksumStrip, ChecksumRecompute:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid checksum mode '%s'. Must be 'keep', 'strip' or 'recompute'", s)
+}
+
+// SetChecksum strips or recomputes the checksum of an OP_MSG message
+// The sections are split only to re-emit them unchanged, in their recorded order.
+// Returns the original message (and false) if nothing changed: ChecksumKeep, messages
+// other than OP_MSG (including OP_COMPRESSED), messages without a checksum (recompute
+// doesn't add one), and checksums that were already correct.
+func SetChecksum(message []byte, mode ChecksumMode) ([]byte, bool, error) {
+	if mode == ChecksumKeep || len(message) < 16 || binary.LittleEndian.Uint32(message[12:16]) != 2013 {
+		return message, false, nil
+	}
+	if mode != ChecksumStrip && mode != ChecksumRecompute {
+		return nil, false, fmt.Errorf("invalid checksum mode '%s'", mode)
+	}
+	flags, sections, checksum, err := reader.SplitOpMsgSections(message)
+	if err != nil {
+		return nil, false, err
+	}
+	if checksum == nil {
+		return message, false, nil
+	}
+
+	out := rebuildOpMsg(message, flags, sections, mode == ChecksumRecompute)
+	if bytes.Equal(out, message) {
+		return message, false, nil
+	}
+	return out, true, nil
+}
+
+// rebuildOpMsg re-encodes an OP_MSG from its split sections, keeping the requestID and
+// responseTo of the original message
+func rebuildOpMsg(message []byte, flags uint32, sections []reader.Section, withChecksum bool) []byte {
+	requestID := int32(binary.LittleEndian.Uint32(message[4:8]))
+	responseTo := int32(binary.LittleEndian.Uint32(message[8:12]))
+	return reader.BuildOpMsg(requestID, responseTo, flags, sections, withChecksum)
+}
+
+// validateDocument checks a framed BSON document's nesting depth and contents
+func validateDocument(data []byte) (bson.Raw, error) {
+	// Bound the nesting before Validate, which recurses once per level
+	if err := CheckDocumentDepth(data, MaxDocumentDepth); err != nil {
+		return nil, err
+	}
+
+	doc := bson.Raw(data)
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
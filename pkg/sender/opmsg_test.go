@@ -0,0 +1,258 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// opMsgSection is one section of a test OP_MSG message
+type opMsgSection struct {
+	kind       byte
+	identifier string   // kind 1 only
+	docs       []bson.D // kind 0 uses docs[0]
+}
+
+// buildOpMsgSections creates an OP_MSG wire message with the given sections
+func buildOpMsgSections(t *testing.T, sections ...opMsgSection) []byte {
+	t.Helper()
+
+	marshal := func(doc bson.D) []byte {
+		b, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatalf("failed to marshal BSON: %v", err)
+		}
+		return b
+	}
+
+	payload := new(bytes.Buffer)
+	binary.Write(payload, binary.LittleEndian, uint32(0)) // flags
+	for _, s := range sections {
+		payload.WriteByte(s.kind)
+		if s.kind == 0 {
+			payload.Write(marshal(s.docs[0]))
+			continue
+		}
+		seq := new(bytes.Buffer)
+		seq.WriteString(s.identifier)
+		seq.WriteByte(0)
+		for _, d := range s.docs {
+			seq.Write(marshal(d))
+		}
+		binary.Write(payload, binary.LittleEndian, int32(4+seq.Len()))
+		payload.Write(seq.Bytes())
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, int32(16+payload.Len()))
+	binary.Write(buf, binary.LittleEndian, int32(1))    // requestID
+	binary.Write(buf, binary.LittleEndian, int32(0))    // responseTo
+	binary.Write(buf, binary.LittleEndian, int32(2013)) // OP_MSG
+	buf.Write(payload.Bytes())
+	return buf.Bytes()
+}
+
+func TestDecodeBody_RejectsMultipleBodySections(t *testing.T) {
+	msg := buildOpMsgSections(t,
+		opMsgSection{kind: 0, docs: []bson.D{{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}}}},
+		opMsgSection{kind: 0, docs: []bson.D{{{Key: "delete", Value: "users"}, {Key: "$db", Value: "app"}}}},
+	)
+
+	_, err := DecodeBody(msg)
+	if err == nil || !strings.Contains(err.Error(), "exactly one kind-0") {
+		t.Fatalf("DecodeBody error = %v, want exactly-one-kind-0 error", err)
+	}
+
+	if _, err := ExtractCommand(&reader.Packet{Message: msg}); err == nil {
+		t.Error("ExtractCommand accepted a message with two kind-0 sections")
+	}
+}
+
+func TestDecodeBody_RejectsMissingBodySection(t *testing.T) {
+	msg := buildOpMsgSections(t,
+		opMsgSection{kind: 1, identifier: "documents", docs: []bson.D{{{Key: "_id", Value: 1}}}},
+	)
+
+	if _, err := DecodeBody(msg); err == nil {
+		t.Error("expected error for message without a kind-0 section")
+	}
+}
+
+func TestExtractCommand_FoldsDocumentSequences(t *testing.T) {
+	msg := buildOpMsgSections(t,
+		opMsgSection{kind: 0, docs: []bson.D{{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}}},
+		opMsgSection{kind: 1, identifier: "documents", docs: []bson.D{
+			{{Key: "_id", Value: 1}},
+			{{Key: "_id", Value: 2}},
+		}},
+	)
+
+	body, err := DecodeBody(msg)
+	if err != nil {
+		t.Fatalf("DecodeBody failed: %v", err)
+	}
+	if len(body.Sequences) != 1 || body.Sequences[0].Identifier != "documents" || len(body.Sequences[0].Documents) != 2 {
+		t.Fatalf("unexpected sequences: %+v", body.Sequences)
+	}
+
+	cmd, err := ExtractCommand(&reader.Packet{Message: msg})
+	if err != nil {
+		t.Fatalf("ExtractCommand failed: %v", err)
+	}
+	docs, ok := cmd.Document["documents"].(bson.A)
+	if !ok || len(docs) != 2 {
+		t.Fatalf("documents = %#v, want 2 folded sequence documents", cmd.Document["documents"])
+	}
+}
+
+func TestStripFields_RoundTrip(t *testing.T) {
+	msg := buildOpMsgSections(t,
+		opMsgSection{kind: 0, docs: []bson.D{{
+			{Key: "insert", Value: "users"},
+			{Key: "comment", Value: strings.Repeat("x", 512)},
+			{Key: "$clusterTime", Value: bson.D{{Key: "clusterTime", Value: bson.Timestamp{T: 1, I: 1}}}},
+			{Key: "$db", Value: "app"},
+		}}},
+		opMsgSection{kind: 1, identifier: "documents", docs: []bson.D{{{Key: "_id", Value: 1}}}},
+	)
+
+	stripped, changed, err := StripFields(msg, []string{"comment", "$clusterTime"})
+	if err != nil {
+		t.Fatalf("StripFields failed: %v", err)
+	}
+	if !changed || len(stripped) >= len(msg) {
+		t.Fatalf("expected a smaller message: changed=%v, %d -> %d bytes", changed, len(msg), len(stripped))
+	}
+	if got := binary.LittleEndian.Uint32(stripped[0:4]); int(got) != len(stripped) {
+		t.Errorf("wire length %d, want %d", got, len(stripped))
+	}
+	if !bytes.Equal(stripped[4:12], msg[4:12]) {
+		t.Error("requestID/responseTo not preserved")
+	}
+
+	body, err := DecodeBody(stripped)
+	if err != nil {
+		t.Fatalf("stripped message doesn't parse: %v", err)
+	}
+	for _, field := range []string{"comment", "$clusterTime"} {
+		if _, err := body.Document.LookupErr(field); err == nil {
+			t.Errorf("field %q still present", field)
+		}
+	}
+
+	cmd, err := ExtractCommand(&reader.Packet{Message: stripped})
+	if err != nil {
+		t.Fatalf("ExtractCommand failed on stripped message: %v", err)
+	}
+	if cmd.Name != "insert" || cmd.Database != "app" {
+		t.Errorf("got command %q on %q, want insert on app", cmd.Name, cmd.Database)
+	}
+	if docs, ok := cmd.Document["documents"].(bson.A); !ok || len(docs) != 1 {
+		t.Errorf("documents sequence lost: %#v", cmd.Document["documents"])
+	}
+
+	if again, changed, err := StripFields(stripped, []string{"comment"}); err != nil || changed || !bytes.Equal(again, stripped) {
+		t.Errorf("stripping an absent field changed the message (changed=%v, err=%v)", changed, err)
+	}
+}
+
+func TestStripFields_KeepsSectionOrder(t *testing.T) {
+	msg := buildOpMsgSections(t,
+		opMsgSection{kind: 1, identifier: "documents", docs: []bson.D{{{Key: "_id", Value: 1}}, {{Key: "_id", Value: 2}}}},
+		opMsgSection{kind: 0, docs: []bson.D{{
+			{Key: "insert", Value: "users"},
+			{Key: "comment", Value: "load test"},
+			{Key: "$db", Value: "app"},
+		}}},
+	)
+
+	stripped, changed, err := StripFields(msg, []string{"comment"})
+	if err != nil || !changed {
+		t.Fatalf("StripFields = changed %v, err %v; want a rewrite", changed, err)
+	}
+
+	_, sections, _, err := reader.SplitOpMsgSections(stripped)
+	if err != nil {
+		t.Fatalf("stripped message doesn't split: %v", err)
+	}
+	if len(sections) != 2 || sections[0].Kind != 1 || sections[1].Kind != 0 {
+		t.Fatalf("sections = %+v, want the kind-1 sequence before the body", sections)
+	}
+	if _, err := bson.Raw(sections[1].Documents[0]).LookupErr("comment"); err == nil {
+		t.Error("comment still present")
+	}
+	// The sequence is copied through byte for byte
+	_, original, _, _ := reader.SplitOpMsgSections(msg)
+	if !reflect.DeepEqual(sections[0], original[0]) {
+		t.Errorf("sequence changed: %+v, want %+v", sections[0], original[0])
+	}
+}
+
+func TestSetChecksum_RoundTrip(t *testing.T) {
+	doc, err := bson.Marshal(bson.D{{Key: "ping", Value: 1}, {Key: "$db", Value: "admin"}})
+	if err != nil {
+		t.Fatalf("failed to marshal BSON: %v", err)
+	}
+	sections := []reader.Section{{Kind: 0, Documents: [][]byte{doc}}}
+	withChecksum := reader.BuildOpMsg(7, 0, 0, sections, true)
+	without := reader.BuildOpMsg(7, 0, 0, sections, false)
+
+	// A checksum made stale by an earlier rewrite
+	stale := append([]byte(nil), withChecksum...)
+	binary.LittleEndian.PutUint32(stale[len(stale)-4:], 0xdeadbeef)
+
+	tests := []struct {
+		name        string
+		message     []byte
+		mode        ChecksumMode
+		want        []byte
+		wantChanged bool
+	}{
+		{"keep", stale, ChecksumKeep, stale, false},
+		{"strip", withChecksum, ChecksumStrip, without, true},
+		{"strip without checksum", without, ChecksumStrip, without, false},
+		{"recompute stale", stale, ChecksumRecompute, withChecksum, true},
+		{"recompute correct", withChecksum, ChecksumRecompute, withChecksum, false},
+		{"recompute without checksum", without, ChecksumRecompute, without, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]byte(nil), tt.message...)
+			got, changed, err := SetChecksum(tt.message, tt.mode)
+			if err != nil {
+				t.Fatalf("SetChecksum failed: %v", err)
+			}
+			if changed != tt.wantChanged || !bytes.Equal(got, tt.want) {
+				t.Errorf("SetChecksum = %x (changed=%v), want %x (changed=%v)", got, changed, tt.want, tt.wantChanged)
+			}
+			if !bytes.Equal(tt.message, original) {
+				t.Error("SetChecksum modified its input")
+			}
+
+			decoded, err := DecodeBody(got)
+			if err != nil {
+				t.Fatalf("DecodeBody failed after %s: %v", tt.mode, err)
+			}
+			if !bytes.Equal(decoded.Document, doc) {
+				t.Error("body document changed in round trip")
+			}
+		})
+	}
+}
+
+func TestParseChecksumMode(t *testing.T) {
+	for _, s := range []string{"keep", "strip", "recompute"} {
+		if mode, err := ParseChecksumMode(s); err != nil || string(mode) != s {
+			t.Errorf("ParseChecksumMode(%q) = %q, %v", s, mode, err)
+		}
+	}
+	if _, err := ParseChecksumMode("drop"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
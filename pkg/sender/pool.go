@@ -0,0 +1,134 @@
+package sender
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// PoolStats counts connection checkouts from a client's pools
+// Measured latency includes the checkout, so a replay whose ops wait for connections
+// (or for new ones to be established) reports client-side time as server time.
+type PoolStats struct {
+	// Checkouts counts connections checked out for operations
+	Checkouts int
+
+	// Exhausted counts checkouts started while every connection the pool may open
+	// (maxPoolSize) was in use, so they had to wait for one to be checked in
+	Exhausted int
+
+	// CheckoutWait is the total time checkouts took, including opening new
+	// connections; MaxCheckoutWait is the longest one
+	CheckoutWait    time.Duration
+	MaxCheckoutWait time.Duration
+
+	// CheckoutFailures counts failed checkouts; CheckoutTimeouts those that gave up
+	// waiting for a connection
+	CheckoutFailures int
+	CheckoutTimeouts int
+}
+
+// Add returns the sum of s and o (the max wait is the larger of the two)
+func (s PoolStats) Add(o PoolStats) PoolStats {
+	s.Checkouts += o.Checkouts
+	s.Exhausted += o.Exhausted
+	s.CheckoutWait += o.CheckoutWait
+	s.MaxCheckoutWait = max(s.MaxCheckoutWait, o.MaxCheckoutWait)
+	s.CheckoutFailures += o.CheckoutFailures
+	s.CheckoutTimeouts += o.CheckoutTimeouts
+	return s
+}
+
+// AverageCheckoutWait returns the mean time a checkout took (0 without checkouts)
+func (s PoolStats) AverageCheckoutWait() time.Duration {
+	if s.Checkouts == 0 {
+		return 0
+	}
+	return s.CheckoutWait / time.Duration(s.Checkouts)
+}
+
+// PoolMonitor counts a client's connection-pool events into PoolStats
+// The driver publishes them from the goroutines running operations, so it is safe for
+// concurrent use.
+type PoolMonitor struct {
+	mu    sync.Mutex
+	stats PoolStats
+
+	// maxSize is each server's pool size limit (0 = unlimited); inUse its connections
+	// checked out, both by server address
+	maxSize map[string]uint64
+	inUse   map[string]uint64
+
+	// next is a monitor the caller configured, which still receives every event
+	next *event.PoolMonitor
+}
+
+func newPoolMonitor(next *event.PoolMonitor) *PoolMonitor {
+	return &PoolMonitor{
+		maxSize: make(map[string]uint64),
+		inUse:   make(map[string]uint64),
+		next:    next,
+	}
+}
+
+// Stats returns the counts so far
+func (m *PoolMonitor) Stats() PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// event counts one pool event
+func (m *PoolMonitor) event(e *event.PoolEvent) {
+	m.mu.Lock()
+	switch e.Type {
+	case event.ConnectionPoolCreated:
+		if e.PoolOptions != nil {
+			m.maxSize[e.Address] = e.PoolOptions.MaxPoolSize
+		}
+	case event.ConnectionCheckOutStarted:
+		if limit := m.maxSize[e.Address]; limit > 0 && m.inUse[e.Address] >= limit {
+			m.stats.Exhausted++
+		}
+	case event.ConnectionCheckedOut:
+		m.inUse[e.Address]++
+		m.stats.Checkouts++
+		m.stats.CheckoutWait += e.Duration
+		m.stats.MaxCheckoutWait = max(m.stats.MaxCheckoutWait, e.Duration)
+	case event.ConnectionCheckOutFailed:
+		m.stats.CheckoutFailures++
+		if e.Reason == event.ReasonTimedOut {
+			m.stats.CheckoutTimeouts++
+		}
+	case event.ConnectionCheckedIn:
+		if m.inUse[e.Address] > 0 {
+			m.inUse[e.Address]--
+		}
+	}
+	m.mu.Unlock()
+
+	if m.next != nil && m.next.Event != nil {
+		m.next.Event(e)
+	}
+}
+
+// connect connects a client with uri and opts, monitoring its connection pools
+func connect(uri string, opts []*options.ClientOptions) (*mongo.Client, *PoolMonitor, error) {
+	clientOpts := options.Client().ApplyURI(uri)
+	for _, opt := range opts {
+		clientOpts = options.MergeClientOptions(clientOpts, opt)
+	}
+	monitor := newPoolMonitor(clientOpts.PoolMonitor)
+	clientOpts.SetPoolMonitor(&event.PoolMonitor{Event: monitor.event})
+
+	// v2 API: Connect doesn't take context
+	client, err := mongo.Connect(clientOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	return client, monitor, nil
+}
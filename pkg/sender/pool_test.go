@@ -0,0 +1,149 @@
+package sender
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestPoolMonitor_TinyPool(t *testing.T) {
+	var forwarded []string
+	m := newPoolMonitor(&event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		forwarded = append(forwarded, e.Type)
+	}})
+
+	const addr = "db1:27017"
+	events := []*event.PoolEvent{
+		{Type: event.ConnectionPoolCreated, Address: addr, PoolOptions: &event.MonitorPoolOptions{MaxPoolSize: 1}},
+		{Type: event.ConnectionCheckOutStarted, Address: addr},
+		{Type: event.ConnectionCheckedOut, Address: addr, Duration: 2 * time.Millisecond},
+
+		// The only connection is in use: the next checkout waits for it
+		{Type: event.ConnectionCheckOutStarted, Address: addr},
+		{Type: event.ConnectionCheckedIn, Address: addr},
+		{Type: event.ConnectionCheckedOut, Address: addr, Duration: 10 * time.Millisecond},
+
+		// A third gives up waiting
+		{Type: event.ConnectionCheckOutStarted, Address: addr},
+		{Type: event.ConnectionCheckOutFailed, Address: addr, Reason: event.ReasonTimedOut},
+		{Type: event.ConnectionCheckedIn, Address: addr},
+
+		// With the connection back, a checkout doesn't wait
+		{Type: event.ConnectionCheckOutStarted, Address: addr},
+		{Type: event.ConnectionCheckedOut, Address: addr, Duration: 0},
+		{Type: event.ConnectionCheckedIn, Address: addr},
+	}
+	for _, e := range events {
+		m.event(e)
+	}
+
+	got := m.Stats()
+	want := PoolStats{
+		Checkouts:        3,
+		Exhausted:        2,
+		CheckoutWait:     12 * time.Millisecond,
+		MaxCheckoutWait:  10 * time.Millisecond,
+		CheckoutFailures: 1,
+		CheckoutTimeouts: 1,
+	}
+	if got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if avg := got.AverageCheckoutWait(); avg != 4*time.Millisecond {
+		t.Errorf("AverageCheckoutWait() = %v, want 4ms", avg)
+	}
+	if len(forwarded) != len(events) {
+		t.Errorf("forwarded %d events to the caller's monitor, want %d", len(forwarded), len(events))
+	}
+}
+
+func TestPoolMonitor_UnlimitedPoolNeverExhausted(t *testing.T) {
+	m := newPoolMonitor(nil)
+	m.event(&event.PoolEvent{Type: event.ConnectionPoolCreated, Address: "db1:27017", PoolOptions: &event.MonitorPoolOptions{MaxPoolSize: 0}})
+	for i := 0; i < 3; i++ {
+		m.event(&event.PoolEvent{Type: event.ConnectionCheckOutStarted, Address: "db1:27017"})
+		m.event(&event.PoolEvent{Type: event.ConnectionCheckedOut, Address: "db1:27017"})
+	}
+	if got := m.Stats(); got.Checkouts != 3 || got.Exhausted != 0 {
+		t.Errorf("Stats() = %+v, want 3 checkouts and none exhausted", got)
+	}
+}
+
+func TestPoolStats_Add(t *testing.T) {
+	a := PoolStats{Checkouts: 2, Exhausted: 1, CheckoutWait: 3 * time.Millisecond, MaxCheckoutWait: 2 * time.Millisecond}
+	b := PoolStats{Checkouts: 1, CheckoutWait: 5 * time.Millisecond, MaxCheckoutWait: 5 * time.Millisecond, CheckoutFailures: 1}
+	got := a.Add(b)
+	want := PoolStats{Checkouts: 3, Exhausted: 1, CheckoutWait: 8 * time.Millisecond, MaxCheckoutWait: 5 * time.Millisecond, CheckoutFailures: 1}
+	if got != want {
+		t.Errorf("Add = %+v, want %+v", got, want)
+	}
+}
+
+// TestConnect_MonitorsPool checks that connect installs the monitor on the client: the
+// pool is created (with the URI's maxPoolSize) on connect, before any server answers
+func TestConnect_MonitorsPool(t *testing.T) {
+	var callerEvents int
+	var mu sync.Mutex
+	caller := options.Client().SetPoolMonitor(&event.PoolMonitor{Event: func(*event.PoolEvent) {
+		mu.Lock()
+		callerEvents++
+		mu.Unlock()
+	}})
+
+	client, monitor, err := connect("mongodb://127.0.0.1:1/?maxPoolSize=1&directConnection=true", []*options.ClientOptions{caller})
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	monitor.mu.Lock()
+	size, ok := monitor.maxSize["127.0.0.1:1"]
+	monitor.mu.Unlock()
+	if !ok || size != 1 {
+		t.Errorf("pool size for 127.0.0.1:1 = %d (seen %v), want 1", size, ok)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if callerEvents == 0 {
+		t.Error("the caller's pool monitor received no events")
+	}
+}
+
+// TestPoolMonitorIntegration saturates a one-connection pool against a real MongoDB
+// Set MONGODB_URI environment variable to run this test
+func TestPoolMonitorIntegration(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("Skipping integration test: MONGODB_URI not set")
+	}
+
+	ctx := context.Background()
+	s, err := New(ctx, uri, options.Client().SetMaxPoolSize(1))
+	if err != nil {
+		t.Fatalf("Failed to create sender: %v", err)
+	}
+	defer s.Close()
+
+	// Each command holds the only connection for 50ms, so the others queue for it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.client.Database("admin").RunCommand(ctx, bson.D{{Key: "sleep", Value: 1}, {Key: "millis", Value: 50}, {Key: "lock", Value: "none"}})
+		}()
+	}
+	wg.Wait()
+
+	stats := s.PoolStats()
+	if stats.Checkouts < 4 || stats.Exhausted == 0 || stats.MaxCheckoutWait < 50*time.Millisecond {
+		t.Errorf("PoolStats() = %+v, want >= 4 checkouts with some waiting >= 50ms on an exhausted pool", stats)
+	}
+}
@@ -0,0 +1,147 @@
+package sender
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// maxWireMessageSize bounds replies read from a caller-provided connection
+// (the server's default maxMessageSizeBytes)
+const maxWireMessageSize = 48 * 1000 * 1000
+
+// SendRawWireMessageOnConn writes a raw wire message to conn and reads the reply from it
+// The connection is used as is, bypassing the driver's server selection and pool: no
+// handshake, authentication or TLS is done unless the caller set them up on conn (e.g.
+// a tls.Conn, or a conn whose first messages were the recorded handshake). OP_MSG
+// requests with the moreToCome flag get no reply, so none is read for them.
+func (s *RawSender) SendRawWireMessageOnConn(conn net.Conn, wireMessageBytes []byte) (*RawResult, error) {
+	startTime := time.Now()
+
+	header, err := s.validateWireMessage(wireMessageBytes)
+	if err != nil {
+		return &RawResult{
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(startTime),
+		}, err
+	}
+	result := &RawResult{
+		OpCode:     header.OpCode,
+		RequestID:  header.RequestID,
+		ResponseTo: header.ResponseTo,
+	}
+	fail := func(err error) (*RawResult, error) {
+		result.Error = err
+		result.Duration = time.Since(startTime)
+		return result, err
+	}
+
+	if _, err := conn.Write(wireMessageBytes); err != nil {
+		return fail(fmt.Errorf("failed to write wire message: %w", err))
+	}
+
+	if !expectsReply(wireMessageBytes) {
+		result.Success = true
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	responseBytes, err := readWireMessage(conn)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read reply: %w", err))
+	}
+
+	result.Success = true
+	result.Duration = time.Since(startTime)
+	result.ResponseBytes = responseBytes
+	return result, nil
+}
+
+// expectsReply reports whether the server replies to a wire message
+// Only OP_MSG can opt out of a reply, through its moreToCome flag.
+func expectsReply(wireMessageBytes []byte) bool {
+	if binary.LittleEndian.Uint32(wireMessageBytes[12:16]) != 2013 || len(wireMessageBytes) < reader.OpMsgSectionsOffset {
+		return true
+	}
+	flags := binary.LittleEndian.Uint32(wireMessageBytes[reader.WireHeaderSize:reader.OpMsgSectionsOffset])
+	return flags&opMsgMoreToCome == 0
+}
+
+// readWireMessage reads one length-prefixed wire message
+func readWireMessage(r io.Reader) ([]byte, error) {
+	var lengthBytes [4]byte
+	if _, err := io.ReadFull(r, lengthBytes[:]); err != nil {
+		return nil, err
+	}
+	length := int(int32(binary.LittleEndian.Uint32(lengthBytes[:])))
+	if length < reader.WireHeaderSize || length > maxWireMessageSize {
+		return nil, fmt.Errorf("invalid wire message length %d", length)
+	}
+
+	message := make([]byte, length)
+	copy(message, lengthBytes[:])
+	if _, err := io.ReadFull(r, message[4:]); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// ConnSender sends raw wire messages over one caller-provided connection, e.g. a plain
+// TCP connection to a proxy under test. It implements the replay package's raw sender
+// interfaces; messages are sent one at a time, each waiting for its reply.
+type ConnSender struct {
+	conn net.Conn
+	raw  RawSender
+	mu   sync.Mutex
+}
+
+// NewConnSender creates a ConnSender over conn; closing the sender closes conn
+func NewConnSender(conn net.Conn) *ConnSender {
+	return &ConnSender{conn: conn}
+}
+
+// DialConnSender dials a plain TCP connection to addr (host:port) for a ConnSender
+// The connection has no authentication or TLS.
+func DialConnSender(ctx context.Context, addr string) (*ConnSender, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	return NewConnSender(conn), nil
+}
+
+// SendRawWireMessage sends a wire message and waits for its reply, which is discarded
+// The reply must be read even when unused, or it would be taken as the next message's.
+func (c *ConnSender) SendRawWireMessage(ctx context.Context, wireMessageBytes []byte) (*RawResult, error) {
+	result, err := c.SendRawWireMessageWithResponse(ctx, wireMessageBytes)
+	if result != nil {
+		result.ResponseBytes = nil
+	}
+	return result, err
+}
+
+// SendRawWireMessageWithResponse sends a wire message and reads its reply
+// The context's deadline, if any, bounds the write and read.
+func (c *ConnSender) SendRawWireMessageWithResponse(ctx context.Context, wireMessageBytes []byte) (*RawResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return &RawResult{Success: false, Error: err}, err
+	}
+	return c.raw.SendRawWireMessageOnConn(c.conn, wireMessageBytes)
+}
+
+// Close closes the connection
+func (c *ConnSender) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,103 @@
+package sender
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// echoServer answers each wire message read from conn with an OP_MSG reply to it,
+// and reports the request IDs it read on the returned channel
+func echoServer(t *testing.T, conn net.Conn) <-chan int32 {
+	t.Helper()
+	reply := buildCommandPacket(t, bson.D{{Key: "ok", Value: 1.0}}).Message
+	seen := make(chan int32, 10)
+	go func() {
+		defer close(seen)
+		for {
+			msg, err := readWireMessage(conn)
+			if err != nil {
+				return
+			}
+			requestID := int32(binary.LittleEndian.Uint32(msg[4:8]))
+			seen <- requestID
+			if !expectsReply(msg) {
+				continue
+			}
+			out := append([]byte(nil), reply...)
+			binary.LittleEndian.PutUint32(out[8:12], uint32(requestID))
+			if _, err := conn.Write(out); err != nil {
+				return
+			}
+		}
+	}()
+	return seen
+}
+
+func TestConnSender_SendsAndReadsReply(t *testing.T) {
+	client, server := net.Pipe()
+	seen := echoServer(t, server)
+	snd := NewConnSender(client)
+	defer snd.Close()
+
+	msg := buildCommandPacket(t, bson.D{{Key: "ping", Value: int32(1)}, {Key: "$db", Value: "admin"}}).Message
+	result, err := snd.SendRawWireMessageWithResponse(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("SendRawWireMessageWithResponse failed: %v", err)
+	}
+	if !result.Success || len(result.ResponseBytes) == 0 {
+		t.Fatalf("result = %+v, want a successful send with a reply", result)
+	}
+	if responseTo := int32(binary.LittleEndian.Uint32(result.ResponseBytes[8:12])); responseTo != 1 {
+		t.Errorf("reply responseTo = %d, want 1", responseTo)
+	}
+	if id := <-seen; id != 1 {
+		t.Errorf("server read request %d, want 1", id)
+	}
+
+	// Without a response the reply is still consumed, so the next reply lines up
+	binary.LittleEndian.PutUint32(msg[4:8], 2)
+	if result, err := snd.SendRawWireMessage(context.Background(), msg); err != nil || result.ResponseBytes != nil {
+		t.Fatalf("SendRawWireMessage = %+v, %v; want success without response bytes", result, err)
+	}
+	binary.LittleEndian.PutUint32(msg[4:8], 3)
+	result, err = snd.SendRawWireMessageWithResponse(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("SendRawWireMessageWithResponse failed: %v", err)
+	}
+	if responseTo := int32(binary.LittleEndian.Uint32(result.ResponseBytes[8:12])); responseTo != 3 {
+		t.Errorf("reply responseTo = %d, want 3", responseTo)
+	}
+}
+
+func TestConnSender_MoreToComeReadsNoReply(t *testing.T) {
+	client, server := net.Pipe()
+	seen := echoServer(t, server)
+	snd := NewConnSender(client)
+	defer snd.Close()
+
+	msg := buildCommandPacket(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "app"}}).Message
+	binary.LittleEndian.PutUint32(msg[16:20], opMsgMoreToCome)
+
+	// With no reply to wait for, this would block forever if the sender tried to read one
+	result, err := snd.SendRawWireMessageWithResponse(context.Background(), msg)
+	if err != nil || !result.Success || result.ResponseBytes != nil {
+		t.Fatalf("result = %+v, %v; want success without a reply", result, err)
+	}
+	<-seen
+}
+
+func TestConnSender_RejectsInvalidMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	snd := NewConnSender(client)
+	defer snd.Close()
+
+	msg := buildCommandPacket(t, bson.D{{Key: "ping", Value: int32(1)}}).Message
+	if _, err := snd.SendRawWireMessage(context.Background(), msg[:len(msg)-1]); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
@@ -23,21 +23,17 @@ type RawSender struct {
 	client     *mongo.Client
 	deployment driver.Deployment
 	ctx        context.Context
+
+	// pool counts the client's connection checkouts
+	pool *PoolMonitor
 }
 
 // NewRawSender creates a new RawSender with a connection to MongoDB
 // It uses the driver's connection pool for auth, TLS, and connection management
 func NewRawSender(ctx context.Context, uri string, opts ...*options.ClientOptions) (*RawSender, error) {
-	// Build combined options (v2 API: Connect doesn't take context)
-	clientOpts := options.Client().ApplyURI(uri)
-	for _, opt := range opts {
-		clientOpts = options.MergeClientOptions(clientOpts, opt)
-	}
-
-	// Connect to MongoDB (v2: Connect doesn't take ctx as first parameter)
-	client, err := mongo.Connect(clientOpts)
+	client, pool, err := connect(uri, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, err
 	}
 
 	// Ping to verify connection
@@ -57,9 +53,16 @@ func NewRawSender(ctx context.Context, uri string, opts ...*options.ClientOption
 		client:     client,
 		deployment: deployment,
 		ctx:        ctx,
+		pool:       pool,
 	}, nil
 }
 
+// PoolStats returns the client's connection checkout counts so far
+// Raw messages are sent on connections checked out from the same pools.
+func (s *RawSender) PoolStats() PoolStats {
+	return s.pool.Stats()
+}
+
 // getDeploymentFromClient uses reflection to extract the private deployment field
 // from a mongo.Client. This is necessary because the Go driver doesn't expose
 // the topology/deployment for raw wire message access.
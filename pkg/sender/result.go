@@ -59,3 +59,54 @@ func (r *Result) IsOK() bool {
 	// If no "ok" field, assume success since the command didn't error
 	return true
 }
+
+// DuplicateKeyCode is the server error code for a duplicate key (E11000)
+const DuplicateKeyCode = 11000
+
+// WriteError is one entry of a write command's writeErrors array
+type WriteError struct {
+	// Index is the position of the failed statement in the command's batch
+	Index int
+
+	// Code is the server error code (e.g. 11000 for a duplicate key)
+	Code int
+
+	// Message is the server's errmsg
+	Message string
+}
+
+// String returns a human-readable string representation of the write error
+func (e WriteError) String() string {
+	return fmt.Sprintf("[%d] code %d: %s", e.Index, e.Code, e.Message)
+}
+
+// IsDuplicateKey reports whether the statement failed on a duplicate key
+func (e WriteError) IsDuplicateKey() bool {
+	return e.Code == DuplicateKeyCode
+}
+
+// WriteErrors returns the per-statement errors of an insert/update/delete response
+// A batch can return ok: 1 while some of its statements failed; IsOK doesn't see those.
+// Returns nil if there are none.
+func (r *Result) WriteErrors() []WriteError {
+	if r.Response == nil {
+		return nil
+	}
+	entries, ok := r.Response["writeErrors"].(bson.A)
+	if !ok {
+		return nil
+	}
+
+	var errs []WriteError
+	for _, entry := range entries {
+		doc, ok := toDocument(entry)
+		if !ok {
+			continue
+		}
+		index, _ := intField("index", doc["index"])
+		code, _ := intField("code", doc["code"])
+		message, _ := doc["errmsg"].(string)
+		errs = append(errs, WriteError{Index: int(index), Code: int(code), Message: message})
+	}
+	return errs
+}
@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestResult_WriteErrors(t *testing.T) {
+	// Decode a real response so writeErrors entries arrive as bson.D, as from RunCommand
+	raw, err := bson.Marshal(bson.D{
+		{Key: "n", Value: int32(1)},
+		{Key: "writeErrors", Value: bson.A{
+			bson.D{{Key: "index", Value: int32(1)}, {Key: "code", Value: int32(11000)}, {Key: "errmsg", Value: "E11000 duplicate key error"}},
+			bson.D{{Key: "index", Value: int32(2)}, {Key: "code", Value: int32(121)}, {Key: "errmsg", Value: "Document failed validation"}},
+		}},
+		{Key: "ok", Value: 1.0},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	var response bson.M
+	if err := bson.Unmarshal(raw, &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	result := &Result{Success: true, Response: response}
+	if !result.IsOK() {
+		t.Error("IsOK() = false for ok: 1 with writeErrors")
+	}
+
+	got := result.WriteErrors()
+	want := []WriteError{
+		{Index: 1, Code: 11000, Message: "E11000 duplicate key error"},
+		{Index: 2, Code: 121, Message: "Document failed validation"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("WriteErrors() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("WriteErrors()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if errs := (&Result{Success: true, Response: bson.M{"n": int32(3), "ok": 1.0}}).WriteErrors(); errs != nil {
+		t.Errorf("WriteErrors() = %v for a response without writeErrors", errs)
+	}
+}
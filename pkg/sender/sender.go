@@ -14,20 +14,22 @@ import (
 type Sender struct {
 	client *mongo.Client
 	ctx    context.Context
+
+	// useHelpers dispatches recognized commands through typed driver helpers (see UseDriverHelpers)
+	useHelpers bool
+
+	// sessions are the logical sessions started by SendCommandInSession, by key
+	sessions map[uint64]*mongo.Session
+
+	// pool counts the client's connection checkouts
+	pool *PoolMonitor
 }
 
 // New creates a new Sender with a connection to MongoDB
 func New(ctx context.Context, uri string, opts ...*options.ClientOptions) (*Sender, error) {
-	// Build combined options (v2 API: Connect doesn't take context)
-	clientOpts := options.Client().ApplyURI(uri)
-	for _, opt := range opts {
-		clientOpts = options.MergeClientOptions(clientOpts, opt)
-	}
-
-	// Connect to MongoDB (v2: Connect doesn't take ctx as first parameter)
-	client, err := mongo.Connect(clientOpts)
+	client, pool, err := connect(uri, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, err
 	}
 
 	// Ping to verify connection
@@ -36,13 +38,24 @@ func New(ctx context.Context, uri string, opts ...*options.ClientOptions) (*Send
 	}
 
 	return &Sender{
-		client: client,
-		ctx:    ctx,
+		client:   client,
+		ctx:      ctx,
+		sessions: make(map[uint64]*mongo.Session),
+		pool:     pool,
 	}, nil
 }
 
-// Close closes the connection to MongoDB
+// PoolStats returns the client's connection checkout counts so far
+func (s *Sender) PoolStats() PoolStats {
+	return s.pool.Stats()
+}
+
+// Close ends any sessions and closes the connection to MongoDB
 func (s *Sender) Close() error {
+	for key, sess := range s.sessions {
+		sess.EndSession(s.ctx)
+		delete(s.sessions, key)
+	}
 	if s.client != nil {
 		return s.client.Disconnect(s.ctx)
 	}
@@ -52,30 +65,25 @@ func (s *Sender) Close() error {
 // SendCommand sends a BSON command to the specified database
 // The command document should already have internal fields cleaned
 func (s *Sender) SendCommand(database string, command bson.M) (*Result, error) {
-	startTime := time.Now()
-
-	// Get database handle
-	db := s.client.Database(database)
-
-	// Execute the command
-	var result bson.M
-	err := db.RunCommand(s.ctx, command).Decode(&result)
-
-	duration := time.Since(startTime)
+	return s.sendCommandContext(s.ctx, database, command)
+}
 
-	if err != nil {
-		return &Result{
-			Success:  false,
-			Error:    err,
-			Duration: duration,
-		}, err
+// SendCommandInSession sends a command in a logical session dedicated to key
+// Commands sent with the same key share one session, so a cursor opened by one can be
+// continued by a later getMore (the server rejects a getMore from another session).
+// The session is started on first use and ended by Close.
+func (s *Sender) SendCommandInSession(key uint64, database string, command bson.M) (*Result, error) {
+	sess, ok := s.sessions[key]
+	if !ok {
+		var err error
+		sess, err = s.client.StartSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start session: %w", err)
+		}
+		s.sessions[key] = sess
 	}
 
-	return &Result{
-		Success:  true,
-		Response: result,
-		Duration: duration,
-	}, nil
+	return s.sendCommandContext(mongo.NewSessionContext(s.ctx, sess), database, command)
 }
 
 // SendCommandWithTimeout sends a command with a specific timeout
@@ -83,11 +91,22 @@ func (s *Sender) SendCommandWithTimeout(database string, command bson.M, timeout
 	ctx, cancel := context.WithTimeout(s.ctx, timeout)
 	defer cancel()
 
+	return s.sendCommandContext(ctx, database, command)
+}
+
+// sendCommandContext runs a command with ctx, through a helper if enabled and possible
+func (s *Sender) sendCommandContext(ctx context.Context, database string, command bson.M) (*Result, error) {
 	startTime := time.Now()
 
-	db := s.client.Database(database)
 	var result bson.M
-	err := db.RunCommand(ctx, command).Decode(&result)
+	var err error
+	handled := false
+	if s.useHelpers {
+		result, handled, err = s.sendViaHelper(ctx, database, command)
+	}
+	if !handled {
+		err = s.client.Database(database).RunCommand(ctx, command).Decode(&result)
+	}
 
 	duration := time.Since(startTime)
 
@@ -0,0 +1,41 @@
+package sender
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Topology is the kind of deployment a target is
+type Topology string
+
+const (
+	// TopologyStandalone is a single mongod without replication
+	TopologyStandalone Topology = "standalone"
+
+	// TopologyReplicaSet is a replica set member
+	TopologyReplicaSet Topology = "replica set"
+
+	// TopologySharded is a mongos router of a sharded cluster
+	TopologySharded Topology = "sharded"
+)
+
+// TopologyFromHello classifies a deployment from its hello (or isMaster) response
+func TopologyFromHello(hello bson.M) Topology {
+	if msg, _ := hello["msg"].(string); msg == "isdbgrid" {
+		return TopologySharded
+	}
+	if _, ok := hello["setName"]; ok {
+		return TopologyReplicaSet
+	}
+	return TopologyStandalone
+}
+
+// DetectTopology runs hello against the target and classifies it
+func (s *Sender) DetectTopology() (Topology, error) {
+	var hello bson.M
+	if err := s.client.Database("admin").RunCommand(s.ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return "", fmt.Errorf("failed to run hello: %w", err)
+	}
+	return TopologyFromHello(hello), nil
+}
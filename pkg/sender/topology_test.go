@@ -0,0 +1,25 @@
+package sender
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestTopologyFromHello(t *testing.T) {
+	tests := []struct {
+		name  string
+		hello bson.M
+		want  Topology
+	}{
+		{"standalone", bson.M{"isWritablePrimary": true, "ok": 1.0}, TopologyStandalone},
+		{"replica set primary", bson.M{"isWritablePrimary": true, "setName": "rs0", "ok": 1.0}, TopologyReplicaSet},
+		{"replica set secondary", bson.M{"secondary": true, "setName": "rs0", "ok": 1.0}, TopologyReplicaSet},
+		{"mongos", bson.M{"isWritablePrimary": true, "msg": "isdbgrid", "ok": 1.0}, TopologySharded},
+	}
+	for _, tt := range tests {
+		if got := TopologyFromHello(tt.hello); got != tt.want {
+			t.Errorf("%s: TopologyFromHello = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package sender
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// upsertCarriedFields are the insert options kept on the update built by UpsertFromInsert
+var upsertCarriedFields = []string{"ordered", "writeConcern", "bypassDocumentValidation", "comment"}
+
+// UpsertFromInsert rewrites the documents at the given indexes of an insert command as
+// an update command that replaces each one, keyed on its _id, with upsert: true
+// The replacement is applied whether or not a document with that _id exists, so a
+// re-sent insert overwrites the existing document instead of failing on a duplicate key.
+// Update statements are in indexes order. Fails if a document has no _id.
+func UpsertFromInsert(cmd *Command, indexes []int) (*Command, error) {
+	if cmd.Name != "insert" {
+		return nil, fmt.Errorf("cannot upsert a %s command (insert only)", cmd.Name)
+	}
+	collection, ok := cmd.Document["insert"].(string)
+	if !ok {
+		return nil, fmt.Errorf("insert must be a string, got %T", cmd.Document["insert"])
+	}
+	documents, ok := cmd.Document["documents"].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("insert has no documents array")
+	}
+
+	updates := make(bson.A, 0, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(documents) {
+			return nil, fmt.Errorf("document index %d out of range (%d documents)", i, len(documents))
+		}
+		doc, ok := toDocument(documents[i])
+		if !ok {
+			return nil, fmt.Errorf("document %d is not a document (%T)", i, documents[i])
+		}
+		id, ok := doc["_id"]
+		if !ok {
+			return nil, fmt.Errorf("document %d has no _id to upsert on", i)
+		}
+		updates = append(updates, bson.M{
+			"q":      bson.M{"_id": id},
+			"u":      documents[i],
+			"upsert": true,
+		})
+	}
+
+	update := bson.M{"update": collection, "updates": updates}
+	for _, field := range upsertCarriedFields {
+		if v, ok := cmd.Document[field]; ok {
+			update[field] = v
+		}
+	}
+
+	return &Command{
+		Database:       cmd.Database,
+		Name:           "update",
+		Document:       update,
+		OriginalPacket: cmd.OriginalPacket,
+	}, nil
+}
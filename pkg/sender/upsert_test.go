@@ -0,0 +1,58 @@
+package sender
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUpsertFromInsert(t *testing.T) {
+	cmd := &Command{
+		Database: "app",
+		Name:     "insert",
+		Document: bson.M{
+			"insert": "users",
+			"documents": bson.A{
+				bson.D{{Key: "_id", Value: int32(1)}},
+				bson.M{"_id": "b", "name": "bob"},
+				bson.D{{Key: "name", Value: "no id"}},
+			},
+			"ordered":      false,
+			"writeConcern": bson.M{"w": "majority"},
+		},
+	}
+
+	upsert, err := UpsertFromInsert(cmd, []int{1, 0})
+	if err != nil {
+		t.Fatalf("UpsertFromInsert failed: %v", err)
+	}
+	if upsert.Name != "update" || upsert.Database != "app" || upsert.Document["update"] != "users" {
+		t.Errorf("got %s on %s.%v, want an update of app.users", upsert.Name, upsert.Database, upsert.Document["update"])
+	}
+	if upsert.Document["ordered"] != false || upsert.Document["writeConcern"] == nil {
+		t.Errorf("insert options not carried over: %v", upsert.Document)
+	}
+
+	updates := upsert.Document["updates"].(bson.A)
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+	first := updates[0].(bson.M)
+	if first["q"].(bson.M)["_id"] != "b" || first["upsert"] != true {
+		t.Errorf("first update = %v, want an upsert of _id b", first)
+	}
+	if updates[1].(bson.M)["q"].(bson.M)["_id"] != int32(1) {
+		t.Errorf("second update = %v, want _id 1", updates[1])
+	}
+
+	if _, err := UpsertFromInsert(cmd, []int{2}); err == nil || !strings.Contains(err.Error(), "no _id") {
+		t.Errorf("UpsertFromInsert error = %v, want a missing _id", err)
+	}
+	if _, err := UpsertFromInsert(cmd, []int{3}); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("UpsertFromInsert error = %v, want index out of range", err)
+	}
+	if _, err := UpsertFromInsert(&Command{Name: "update", Document: bson.M{}}, nil); err == nil {
+		t.Error("UpsertFromInsert accepted an update command")
+	}
+}
@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+)
+
+// maxValidationFailures bounds how many failing packets a ValidationReport lists
+// Every failure is still counted in Failed and FailureReasons.
+const maxValidationFailures = 100
+
+// ValidationFailure is one request packet that could not be extracted as a Command
+type ValidationFailure struct {
+	// SessionID is the packet's recorded session
+	SessionID uint64
+
+	// Offset is the packet's offset in microseconds from the start of the recording
+	Offset uint64
+
+	// Order is the packet's order within the recording
+	Order uint64
+
+	// Err is the extraction error
+	Err error
+}
+
+// ValidationReport tallies how a recording's packets parse into Commands
+type ValidationReport struct {
+	// Packets is the number of packets read
+	Packets int
+
+	// Events is the number of session start/end events (empty message)
+	Events int
+
+	// Responses is the number of response packets (never sent, so not extracted)
+	Responses int
+
+	// Parsed is the number of requests extracted as a Command
+	Parsed int
+
+	// Failed is the number of OP_MSG requests that failed to extract
+	Failed int
+
+	// SkippedByOpCode counts requests with an opcode other than OP_MSG, by opcode
+	SkippedByOpCode map[uint32]int
+
+	// FailureReasons counts failed requests by error message
+	FailureReasons map[string]int
+
+	// Failures lists the first failing requests (at most 100)
+	Failures []ValidationFailure
+}
+
+// Skipped returns the number of requests skipped by opcode
+func (v *ValidationReport) Skipped() int {
+	n := 0
+	for _, count := range v.SkippedByOpCode {
+		n += count
+	}
+	return n
+}
+
+// Valid reports whether every OP_MSG request parsed
+func (v *ValidationReport) Valid() bool {
+	return v.Failed == 0
+}
+
+// ValidateRecording extracts a Command from every request in a recording without
+// connecting to MongoDB, as a preflight check before replaying it in command mode
+// Requests with an opcode other than OP_MSG (legacy opcodes, OP_COMPRESSED) are
+// skipped, as ExtractCommand rejects them. Extraction failures are reported, not
+// returned; the error is only set if the recording itself can't be read.
+func ValidateRecording(r *reader.RecordingReader) (*ValidationReport, error) {
+	return validatePackets(r)
+}
+
+// validatePackets builds a ValidationReport over every packet of src
+func validatePackets(src reader.PacketIterator) (*ValidationReport, error) {
+	report := &ValidationReport{
+		SkippedByOpCode: make(map[uint32]int),
+		FailureReasons:  make(map[string]int),
+	}
+
+	for {
+		packet, err := src.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read packet %d: %w", report.Packets+1, err)
+		}
+		report.Packets++
+
+		switch {
+		case len(packet.Message) == 0:
+			report.Events++
+			continue
+		case !packet.IsRequest():
+			report.Responses++
+			continue
+		}
+
+		if opCode := packet.GetOpCode(); opCode != 2013 {
+			report.SkippedByOpCode[opCode]++
+			continue
+		}
+
+		if _, err := ExtractCommand(packet); err != nil {
+			report.Failed++
+			report.FailureReasons[err.Error()]++
+			if len(report.Failures) < maxValidationFailures {
+				report.Failures = append(report.Failures, ValidationFailure{
+					SessionID: packet.SessionID,
+					Offset:    packet.Offset,
+					Order:     packet.Order,
+					Err:       err,
+				})
+			}
+			continue
+		}
+		report.Parsed++
+	}
+}
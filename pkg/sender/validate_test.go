@@ -0,0 +1,86 @@
+package sender
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnow/traffic-replay/pkg/reader"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestValidateRecording(t *testing.T) {
+	find := buildCommandPacket(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "app"}})
+	noDB := buildCommandPacket(t, bson.D{{Key: "find", Value: "users"}})
+
+	response := buildCommandPacket(t, bson.D{{Key: "ok", Value: 1.0}})
+	binary.LittleEndian.PutUint32(response.Message[8:12], 1) // responseTo
+
+	query := &reader.Packet{Message: buildOpQuery(t, "app.$cmd", bson.D{{Key: "ping", Value: 1}})}
+
+	packets := []*reader.Packet{
+		{}, // session start
+		find,
+		response,
+		noDB,
+		query,
+		find,
+		{}, // session end
+	}
+
+	path := filepath.Join(t.TempDir(), "recording.bin")
+	w, err := reader.NewRecordingWriter(path, reader.WriterOptions{})
+	if err != nil {
+		t.Fatalf("NewRecordingWriter failed: %v", err)
+	}
+	for i, packet := range packets {
+		packet.SessionID = 7
+		packet.Offset = uint64(i * 100)
+		packet.Order = uint64(i)
+		if err := w.Write(packet); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := reader.NewRecordingReader(path)
+	if err != nil {
+		t.Fatalf("NewRecordingReader failed: %v", err)
+	}
+	defer r.Close()
+
+	report, err := ValidateRecording(r)
+	if err != nil {
+		t.Fatalf("ValidateRecording failed: %v", err)
+	}
+
+	if report.Packets != 7 || report.Events != 2 || report.Responses != 1 {
+		t.Errorf("packets/events/responses = %d/%d/%d, want 7/2/1", report.Packets, report.Events, report.Responses)
+	}
+	if report.Parsed != 2 || report.Failed != 1 {
+		t.Errorf("parsed/failed = %d/%d, want 2/1", report.Parsed, report.Failed)
+	}
+	if report.Skipped() != 1 || report.SkippedByOpCode[2004] != 1 {
+		t.Errorf("skipped = %v, want one OP_QUERY", report.SkippedByOpCode)
+	}
+	if report.Valid() {
+		t.Error("Valid() = true, want false with a failed request")
+	}
+
+	if len(report.Failures) != 1 {
+		t.Fatalf("got %d failures, want 1", len(report.Failures))
+	}
+	failure := report.Failures[0]
+	if failure.Order != 3 || failure.Offset != 300 || failure.SessionID != 7 {
+		t.Errorf("failure at order %d offset %d session %d, want the $db-less find", failure.Order, failure.Offset, failure.SessionID)
+	}
+	if !strings.Contains(failure.Err.Error(), "database name") {
+		t.Errorf("failure error = %v, want a missing database", failure.Err)
+	}
+	if report.FailureReasons[failure.Err.Error()] != 1 {
+		t.Errorf("failure reasons = %v, want the failure counted", report.FailureReasons)
+	}
+}